@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// agingSweepInterval is how often the auto-changer loop removes
+	// wallpapers older than MaxWallpaperAgeDays
+	agingSweepInterval = 24 * time.Hour
+	// agingKeepRecent is how many of the latest history entries the sweep
+	// leaves alone, so Previous still has somewhere to go
+	agingKeepRecent = 5
+)
+
+// sweepOldWallpapers moves non-favorite wallpapers downloaded more than
+// MaxWallpaperAgeDays ago to the trash and returns how many were removed.
+// The current wallpaper, the last few history entries and pinned wallpapers
+// are kept.
+func (a *App) sweepOldWallpapers() int {
+	days := a.GetSettings().MaxWallpaperAgeDays
+	if days <= 0 || !a.storageAvailable() {
+		return 0
+	}
+	cutoff := a.clock.Now().AddDate(0, 0, -days)
+
+	pinned := map[string]bool{}
+	for _, id := range a.GetSettings().PinnedMonitors {
+		pinned[id] = true
+	}
+
+	a.mu.Lock()
+	protected := map[string]bool{a.data.CurrentPath: true}
+	for _, path := range a.data.Recent[max(len(a.data.Recent)-agingKeepRecent, 0):] {
+		protected[path] = true
+	}
+
+	var kept, expired []WallpaperInfo
+	for _, wp := range a.data.Wallpapers {
+		inUse := protected[wp.Filepath] || (wp.ProcessedPath != "" && protected[wp.ProcessedPath])
+		if wp.Favorite || inUse || pinned[wp.ID] || !wp.DownloadDate.Before(cutoff) {
+			kept = append(kept, wp)
+		} else {
+			expired = append(expired, wp)
+		}
+	}
+	if len(expired) == 0 {
+		a.mu.Unlock()
+		return 0
+	}
+	a.data.Wallpapers = kept
+	remaining := append([]WallpaperInfo(nil), kept...)
+	a.mu.Unlock()
+
+	for _, wp := range expired {
+		a.trashWallpaperFiles(wp)
+	}
+	a.saveWallpapers()
+	fmt.Printf("Aging removed %d wallpapers older than %d days\n", len(expired), days)
+	a.emit("wallpapersUpdated", remaining)
+	return len(expired)
+}
@@ -5,17 +5,18 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"sort"
-	"syscall"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
-	"unsafe"
 
 	"github.com/getlantern/systray"
 	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
@@ -23,17 +24,385 @@ import (
 
 // App struct
 type App struct {
-	ctx      context.Context
-	settings AppSettings
-	data     AppData
+	ctx       context.Context
+	appCtx    context.Context // cancelled on shutdown
+	cancel    context.CancelFunc
+	data      AppData
+	httpCache map[string]cacheEntry
+	cacheMu   sync.Mutex
+	mu        sync.Mutex // guards data and storageDown
+	sources   sourceTracker
+	client    fetcher
+	runner    commandRunner
+	clock     clock
+
+	// attemptTimeout bounds each download attempt, as downloadTimeout
+	// does unless a test shortens it
+	attemptTimeout time.Duration
+
+	// settings is replaced by the UI, the settings watcher and background
+	// tasks while others read it, so it is read through GetSettings and
+	// changed through setSettings or changeSettings, which hold settingsMu.
+	// settingsSaveMu orders writes of settings.json.
+	settings       AppSettings
+	settingsMu     sync.RWMutex
+	settingsSaveMu sync.Mutex
+
+	// storageDown is set while the wallpaper directory is unreachable
+	storageDown bool
+
+	// waitingForIdle is set while a due change waits for the user to go idle
+	waitingForIdle bool
+
+	// lockScreenWarning emits lockScreenUnsupported at most once per run
+	lockScreenWarning sync.Once
+
+	// recentPos is the Previous/Next cursor into data.Recent (-1 = latest)
+	recentPos int
+	menu      appMenu
+	tray      trayMenu
+	sun       sunSchedule
+	weather   weatherCache
+	dbus      dbusState
+	hotkeys   hotkeyState
+	links     deepLinkState
+	api       apiState
+	unlock    unlockState
+
+	// unlockBusy is held while an unlock-triggered change runs
+	unlockBusy sync.Mutex
+	// changeBusy is held while downloadAndSet runs, so repeated clicks
+	// don't start more downloads
+	changeBusy sync.Mutex
+
+	// wake interrupts the auto-changer's wait so it re-reads the settings
+	wake chan struct{}
+
+	// settingsWatch reloads settings.json when it is edited outside the app
+	settingsWatch settingsWatcher
+
+	// configDir replaces the default settings directory (--config-dir)
+	configDir string
+
+	// monitors re-applies pinned wallpapers after display changes
+	monitors monitorWatch
+
+	// status holds the last change error for status.json
+	status statusState
+
+	// prefetch tracks a running PrefetchWallpapers
+	prefetch prefetchState
+
+	// autoChanger controls the auto-changer loop, and autoChangerRunning is
+	// set while it is alive
+	autoChanger        autoChangerState
+	autoChangerRunning atomic.Bool
+
+	// updates caches CheckForUpdate's answer
+	updates updateState
+
+	// secrets keeps API keys and tokens out of settings.json
+	secrets secretState
+
+	// sftpHosts holds SFTP host keys waiting to be trusted
+	sftpHosts sftpHostState
+
+	// downloads lets CancelDownload stop the downloads in progress
+	downloads downloadState
+
+	lifecycleMu  sync.Mutex
+	shuttingDown bool
+	quitting     bool
+	tasks        sync.WaitGroup // auto-changer and in-flight downloads
 }
 
 // AppSettings defines user-configurable settings
 type AppSettings struct {
 	AutoChangeEnabled   bool     `json:"auto_change_enabled"`
+	CloseToTray         bool     `json:"close_to_tray"`
+	RestoreOnStartup    bool     `json:"restore_on_startup"`
+	StripMetadata       bool     `json:"strip_metadata"`
+	SetLockScreenToo    bool     `json:"set_lock_screen_too"`
 	ChangeIntervalHours int      `json:"change_interval_hours"`
 	DownloadSources     []string `json:"download_sources"`
-	MaxWallpapers       int      `json:"max_wallpapers"`
+	MaxWallpapers       int      `json:"max_wallpapers"` // 0 keeps every wallpaper
+
+	// SourceRotation picks the source downloads start from: "sequential"
+	// (always the first), "round-robin" (the one after the last used) or
+	// "least-recently-used" (the longest without a successful download)
+	SourceRotation string `json:"source_rotation,omitempty"`
+
+	// PinnedMonitors keeps a wallpaper on a display while rotation continues
+	// on the others (monitor ID -> wallpaper ID; Windows and macOS only)
+	PinnedMonitors map[string]string `json:"pinned_monitors,omitempty"`
+
+	// DownloadsDisabled never downloads: every change, automatic or
+	// manual, rotates through the library in LocalRotation order,
+	// "shuffle" (the default) or "sequential"
+	DownloadsDisabled bool   `json:"downloads_disabled"`
+	LocalRotation     string `json:"local_rotation,omitempty"`
+
+	// DownloadSchedule downloads into the library on its own cadence, and
+	// ChangeSchedule limits when automatic changes happen
+	DownloadSchedule DownloadSchedule `json:"download_schedule"`
+	ChangeSchedule   ChangeSchedule   `json:"change_schedule"`
+
+	// DryRun logs the commands that would change the wallpaper instead of
+	// running them, so the app only builds the library, e.g. as a
+	// downloader on a headless machine
+	DryRun bool `json:"dry_run"`
+
+	// MaxWallpaperAgeDays moves non-favorite wallpapers downloaded more than
+	// this many days ago to the trash, checked daily (0 = disabled)
+	MaxWallpaperAgeDays int `json:"max_wallpaper_age_days"`
+
+	// WallpaperDirectory overrides where wallpapers are stored (empty =
+	// ~/Pictures/WallpaperEngine). It may be on a drive that isn't always
+	// mounted; the app pauses rather than forgetting its files.
+	WallpaperDirectory string `json:"wallpaper_directory,omitempty"`
+
+	// FilenameTemplate names new downloads and imports, e.g.
+	// "{date}_{source}_{title}.{ext}". Tokens: {date}, {unix}, {source},
+	// {title}, {id}, {ext} and, for imports, {original}. Empty keeps the
+	// built-in wallpaper_<unix>_<id> names. Existing files are only renamed
+	// by RepairLibrary(true).
+	FilenameTemplate string `json:"filename_template,omitempty"`
+
+	// PreferLuminanceByTime makes automatic changes favour dark wallpapers at
+	// night and light ones during the day
+	PreferLuminanceByTime bool    `json:"prefer_luminance_by_time"`
+	LuminanceThreshold    float64 `json:"luminance_threshold"`
+	DayStartHour          int     `json:"day_start_hour"`
+	NightStartHour        int     `json:"night_start_hour"`
+
+	// FollowColorScheme makes that preference follow the desktop's
+	// dark/light setting (GNOME's color-scheme) instead of the time, where
+	// the desktop reports one
+	FollowColorScheme bool `json:"follow_color_scheme"`
+
+	// DarkModeWallpaperID is the wallpaper GNOME shows in dark mode (empty =
+	// the current wallpaper in both modes)
+	DarkModeWallpaperID string `json:"dark_mode_wallpaper_id,omitempty"`
+
+	// Latitude and Longitude are the location used for weather and, with
+	// FollowSun, for switching between day and night at the local sunrise
+	// and sunset instead of the fixed hours. Where the sun doesn't rise or
+	// set that day, the fixed hours apply.
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	FollowSun bool     `json:"follow_sun"`
+
+	// ChangeOnThemeBoundary makes an automatic change the moment day turns
+	// to night or back, so the luminance preference takes effect at once
+	ChangeOnThemeBoundary bool `json:"change_on_theme_boundary"`
+
+	// WeatherEnabled makes automatic changes prefer wallpapers tagged for
+	// the current weather at Latitude/Longitude (sunny, rain, snow, ...)
+	WeatherEnabled bool `json:"weather_enabled"`
+
+	// SeasonalThemes adds the keywords of the season or holiday at hand
+	// to the search terms of keyword sources, tags what they download
+	// with them, and makes automatic changes prefer wallpapers so tagged
+	SeasonalThemes bool `json:"seasonal_themes"`
+	// SeasonalCalendar replaces the built-in themes when not empty
+	SeasonalCalendar []SeasonalTheme `json:"seasonal_calendar,omitempty"`
+
+	// OrientationFilter restricts downloads and rotation to "landscape" or
+	// "portrait" images (empty = any)
+	OrientationFilter string `json:"orientation_filter"`
+
+	// ContentFilter keeps mature images out: "strict" (the default),
+	// "moderate" or "off". It sets the provider's own filter where there
+	// is one (Wallhaven, Unsplash, Reddit search) and skips feed items
+	// rated adult and Reddit posts marked over 18. Reddit only has the one
+	// flag, so moderate is as strict as strict there.
+	ContentFilter string `json:"content_filter"`
+
+	// AspectRatioTolerance rejects downloads and skips library wallpapers
+	// whose width/height ratio is off from the primary monitor's by more
+	// than this fraction, e.g. 0.2 (0 = no limit)
+	AspectRatioTolerance float64 `json:"aspect_ratio_tolerance"`
+
+	// ShuffleRecencyWeight and ShuffleRatingWeight bias picks from the
+	// library toward wallpapers not shown for a while and rated highly
+	// (0 ignores that factor; both 0 pick uniformly)
+	ShuffleRecencyWeight float64 `json:"shuffle_recency_weight"`
+	ShuffleRatingWeight  float64 `json:"shuffle_rating_weight"`
+
+	// SimilarityThreshold is the maximum perceptual-hash Hamming distance at
+	// which a new wallpaper is rejected as a duplicate (-1 = exact only)
+	SimilarityThreshold int `json:"similarity_threshold"`
+
+	// UseScreenResolution fills {width} and {height} placeholders in source
+	// URLs with the primary screen's resolution
+	UseScreenResolution bool `json:"use_screen_resolution"`
+
+	// MaxDownloadSpeedKBps throttles background downloads (0 = unlimited)
+	MaxDownloadSpeedKBps int `json:"max_download_speed_kbps"`
+
+	// BlurRadius applies a Gaussian blur to a copy of each new wallpaper
+	// before it is set (0 = disabled)
+	BlurRadius int `json:"blur_radius"`
+
+	// MaxFileSizeBytes aborts downloads larger than this (0 = unlimited)
+	MaxFileSizeBytes int64 `json:"max_file_size_bytes"`
+
+	// MinFileSizeBytes rejects smaller files as likely error pages or
+	// placeholders, unless they decode to at least an HD image (0 = no
+	// minimum)
+	MinFileSizeBytes int64 `json:"min_file_size_bytes"`
+
+	// MaxRedirects is how many redirects a download may follow (0 = none)
+	MaxRedirects int `json:"max_redirects"`
+
+	// AllowRedirectDowngrade lets downloads follow redirects from https to
+	// plain http
+	AllowRedirectDowngrade bool `json:"allow_redirect_downgrade"`
+
+	// ParallelSourceFetch downloads from several sources at once and
+	// keeps the first wallpaper to arrive, instead of trying them in turn
+	ParallelSourceFetch bool `json:"parallel_source_fetch"`
+
+	// KeepOriginal keeps an untouched copy of each download or import
+	// that conversion or processing would change, for processing again
+	// later. With StripMetadata on, JPEG originals are kept stripped and
+	// other originals aren't kept.
+	KeepOriginal bool `json:"keep_original"`
+
+	// KeepExtraDownloads adds wallpapers that finish downloading after a
+	// parallel fetch was won to the library, instead of discarding them
+	KeepExtraDownloads bool `json:"keep_extra_downloads"`
+
+	// TrashRetentionDays keeps deleted wallpapers in the trash folder this
+	// long before CleanupCaches purges them (0 = delete immediately)
+	TrashRetentionDays int `json:"trash_retention_days"`
+
+	// VerifyIntegrityOnLoad rehashes the library in the background at
+	// startup and quarantines files that no longer match (see
+	// VerifyIntegrity). Off by default, as it reads every file.
+	VerifyIntegrityOnLoad bool `json:"verify_integrity_on_load"`
+
+	// MaxPreviewSizeMB caps the size of base64 previews returned to the
+	// frontend (0 = unlimited)
+	MaxPreviewSizeMB int `json:"max_preview_size_mb"`
+
+	// EnableHotkeys registers the global Hotkeys for next/previous/skip
+	EnableHotkeys bool         `json:"enable_hotkeys"`
+	Hotkeys       HotkeyConfig `json:"hotkeys"`
+
+	// PauseDuringFullscreen defers scheduled changes while a fullscreen app
+	// (a game, a video) is in front, until it exits
+	PauseDuringFullscreen bool `json:"pause_during_fullscreen"`
+
+	// OnlyChangeWhenIdle holds a due change until there has been no input
+	// for IdleThresholdSeconds, or until two further intervals have passed
+	OnlyChangeWhenIdle   bool `json:"only_change_when_idle"`
+	IdleThresholdSeconds int  `json:"idle_threshold_seconds"`
+
+	// ChangeOnUnlock performs an automatic change when the session is
+	// unlocked, unless the last change was less than
+	// MinMinutesBetweenUnlockChanges ago
+	ChangeOnUnlock                 bool `json:"change_on_unlock"`
+	MinMinutesBetweenUnlockChanges int  `json:"min_minutes_between_unlock_changes"`
+
+	// Collage options: the gap between cells in pixels, its color as
+	// "#rrggbb", and whether GenerateCollage also sets the result
+	CollageGutter         int    `json:"collage_gutter"`
+	CollageBackground     string `json:"collage_background"`
+	SetCollageAsWallpaper bool   `json:"set_collage_as_wallpaper"`
+
+	// OverlayText is drawn in the OverlayPosition corner of a copy of each
+	// wallpaper before it is set; the library file is left untouched. Empty
+	// disables the overlay. OverlayFontSize is in pixels at 1080p.
+	OverlayText     string `json:"overlay_text"`
+	OverlayPosition string `json:"overlay_position"`
+	OverlayFontSize int    `json:"overlay_font_size"`
+	OverlayColor    string `json:"overlay_color"`
+
+	// NotifyOnChange shows a desktop notification after automatic changes
+	NotifyOnChange bool `json:"notify_on_change"`
+
+	// UpdateCheckEnabled allows CheckForUpdate to ask GitHub for the latest
+	// release. Checks only run when requested; nothing is downloaded.
+	UpdateCheckEnabled bool `json:"update_check_enabled"`
+
+	// ControlAPIEnabled serves the control API on ControlAPIAddress; bind it
+	// to 0.0.0.0 to reach it from other devices on the LAN. Requests must
+	// carry ControlAPIToken as a bearer token; one is generated when the API
+	// is first enabled.
+	ControlAPIEnabled bool   `json:"control_api_enabled"`
+	ControlAPIAddress string `json:"control_api_address"`
+	ControlAPIToken   string `json:"control_api_token"`
+
+	// ExtensionOrigin is the browser extension origin allowed to call the
+	// control API cross-origin, e.g. "chrome-extension://<id>"
+	ExtensionOrigin string `json:"extension_origin,omitempty"`
+
+	// UserAgent replaces the default "WallpaperEngine/1.0" on outgoing
+	// requests; some providers, Reddit among them, reject the generic one
+	UserAgent string `json:"user_agent,omitempty"`
+
+	// SourceConfigs holds optional per-source overrides keyed by source URL
+	SourceConfigs map[string]SourceConfig `json:"source_configs,omitempty"`
+
+	// unresolvedSecrets maps the secrets that couldn't be read from the
+	// store to their references, so saving writes the references back
+	// instead of dropping them. It is replaced, never changed in place.
+	unresolvedSecrets map[string]string
+}
+
+// SourceConfig defines per-source behaviour for an entry in DownloadSources
+type SourceConfig struct {
+	// DisableConditional turns off ETag/Last-Modified revalidation for
+	// random-image endpoints that return new content on every request
+	DisableConditional bool `json:"disable_conditional,omitempty"`
+
+	// RateLimit overrides the provider's default request quota
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
+
+	// Headers are added to every request for the source, e.g. an
+	// Authorization header for a private server. Values of headers named
+	// like authorization, cookie, token, API key or secret are masked in
+	// logs and kept in the secret store rather than settings.json.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Type selects how the source is fetched: empty for a URL that returns
+	// an image, "webdav" for a WebDAV or Nextcloud folder, "sftp" for a
+	// folder on an SSH server (sftp://user@host:port/dir) or "rss" for an
+	// RSS or Atom feed, whose images are picked at random, "earth" for
+	// satellite imagery, or "script"
+	Type string `json:"type,omitempty"`
+
+	// Zoom is the Himawari tile grid of an earth:himawari source, 2 (the
+	// default) or 4 tiles per side. ReplacePrevious makes an earth source
+	// overwrite a single earth-latest library entry instead of adding one
+	// per download.
+	Zoom            int  `json:"zoom,omitempty"`
+	ReplacePrevious bool `json:"replace_previous,omitempty"`
+
+	// Command is run for a script source (named like script:photos),
+	// without a shell, and prints an absolute image path or an http(s) URL
+	// on its first line. TimeoutSeconds defaults to 30.
+	Command        string `json:"command,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+
+	// Username and Password authenticate requests to the source, with HTTP
+	// Basic auth or as the SSH login. Password (an app password on
+	// Nextcloud) is kept in the secret store.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// PrivateKeyPath is an SSH key for SFTP sources. KeyPassphrase, kept in
+	// the secret store, decrypts it.
+	PrivateKeyPath string `json:"private_key_path,omitempty"`
+	KeyPassphrase  string `json:"key_passphrase,omitempty"`
+
+	// InsecureSkipVerify accepts self-signed certificates from the source
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+
+	// ContentFilter makes the global ContentFilter stricter for this
+	// source. It can only loosen it when the global filter is off.
+	ContentFilter string `json:"content_filter,omitempty"`
 }
 
 // WallpaperInfo holds metadata about a downloaded wallpaper
@@ -44,28 +413,150 @@ type WallpaperInfo struct {
 	LocalURL     string    `json:"local_url"`
 	DownloadDate time.Time `json:"download_date"`
 	SourceURL    string    `json:"source_url"`
-	FileSize     int64     `json:"file_size"`
+	// Source is the configured download source it came from, where
+	// SourceURL is the image itself
+	Source    string  `json:"source,omitempty"`
+	FileSize  int64   `json:"file_size"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Luminance float64 `json:"luminance"`
+	// LuminanceMeasured tells a measured Luminance of 0, a black image,
+	// from one that was never measured
+	LuminanceMeasured bool `json:"luminance_measured,omitempty"`
+	// MIMEType is the format sniffed from the file's content
+	MIMEType string `json:"mime_type,omitempty"`
+
+	// Hash is the SHA-256 of the file and PerceptualHash its 64-bit dHash
+	Hash           string `json:"hash,omitempty"`
+	PerceptualHash string `json:"perceptual_hash,omitempty"`
+
+	// RemoteETag is the ETag of the WebDAV file it was downloaded from, or
+	// the size and modification time of the SFTP one, so a changed file is
+	// fetched again
+	RemoteETag string `json:"remote_etag,omitempty"`
+
+	// RedirectedFrom is the URL requested when the server redirected to
+	// SourceURL
+	RedirectedFrom string `json:"redirected_from,omitempty"`
+
+	// Title is generated when the wallpaper is added: a feed item's title,
+	// an import's filename, or the source's keyword and date. PageURL is
+	// the link of the feed item an RSS source took the wallpaper from.
+	Title   string `json:"title,omitempty"`
+	PageURL string `json:"page_url,omitempty"`
+
+	// OriginalType is the content type of a source that was converted on
+	// the way in: GIF or TIFF flattened to PNG, AVIF or HEIC to JPEG
+	OriginalType string `json:"original_type,omitempty"`
+
+	// ProcessedPath is the blurred copy that gets applied, if any
+	ProcessedPath string `json:"processed_path,omitempty"`
+
+	// OriginalPath is the file as downloaded or imported, kept with
+	// KeepOriginal when it differs from the stored file, and OriginalHash
+	// its SHA-256
+	OriginalPath string `json:"original_path,omitempty"`
+	OriginalHash string `json:"original_hash,omitempty"`
+
+	// Colors is the dominant palette as "#rrggbb", most common first
+	Colors []string `json:"colors,omitempty"`
+
+	// User-provided label and notes
+	DisplayName string `json:"display_name,omitempty"`
+	Notes       string `json:"notes,omitempty"`
+
+	// Skipped keeps the wallpaper out of automatic selection
+	Skipped bool `json:"skipped,omitempty"`
+
+	// Tags are free-form labels, lowercased and without duplicates
+	Tags []string `json:"tags,omitempty"`
+
+	// Favorite and Rating (1-5 stars, 0 = unrated) are set by the user
+	Favorite bool `json:"favorite,omitempty"`
+	Rating   int  `json:"rating,omitempty"`
+
+	// TimesSet counts how often the wallpaper was applied, LastSetAt when
+	// it last was
+	TimesSet  int       `json:"times_set,omitempty"`
+	LastSetAt time.Time `json:"last_set_at,omitempty"`
+
+	// EXIF metadata, when the image had any
+	CapturedAt   time.Time `json:"captured_at,omitempty"`
+	CameraModel  string    `json:"camera_model,omitempty"`
+	GPSLatitude  *float64  `json:"gps_latitude,omitempty"`
+	GPSLongitude *float64  `json:"gps_longitude,omitempty"`
 }
 
 // AppData holds the application's runtime data
 type AppData struct {
 	Wallpapers []WallpaperInfo `json:"wallpapers"`
+	Scheduler  SchedulerState  `json:"scheduler"`
+
+	// CurrentPath is the file most recently applied as the wallpaper
+	CurrentPath string `json:"current_path,omitempty"`
+
+	// Recent lists applied files, oldest first, for Previous/Next
+	Recent []string `json:"recent,omitempty"`
+
+	// ActiveProfile names the settings profile in settings.json ("" = default)
+	ActiveProfile string `json:"active_profile,omitempty"`
+
+	// History lists change attempts, oldest first, up to
+	// changeHistoryLimit
+	History []ChangeEvent `json:"history,omitempty"`
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
+	a := &App{
+		runner:         execRunner{},
+		clock:          realClock{},
+		attemptTimeout: downloadTimeout,
+		recentPos:      -1,
+		wake:           make(chan struct{}, 1),
+	}
+	client := newHTTPClient()
+	client.CheckRedirect = a.checkRedirect
+	a.client = client
+	return a
 }
 
 // startup is called when the app starts.
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+	a.appCtx, a.cancel = context.WithCancel(context.Background())
 	// Load settings and wallpapers from disk on startup
 	a.loadSettings()
+	a.startSettingsWatcher()
 	a.loadWallpapers()
+	a.loadConditionalCache()
+	a.loadSourceState()
+	a.cleanupPartialDownloads()
+	a.markInstance()
+	a.writeStatus()
+	go a.backfillImageMetadata()
+	go a.CleanupCaches()
+	go a.verifyIntegrityOnLoad()
+	// Secrets in a locked keyring are asked for once the window is up
+	go a.resolvePendingSecrets(pendingSecrets(a.GetSettings(), AppSettings{}))
+
+	// Let desktop scripts control the app over D-Bus (Linux only)
+	a.startDBusService()
+	a.startHotkeys()
+	a.startControlAPI()
+	a.startSessionWatcher()
+	go a.registerURLScheme()
+
+	// Re-apply the last wallpaper in case the OS reset it
+	if a.GetSettings().RestoreOnStartup && a.beginTask() {
+		go func() {
+			defer a.tasks.Done()
+			a.restoreWallpaper()
+		}()
+	}
 
 	// Start the background wallpaper changer
-	go a.startAutoChanger()
+	a.startAutoChanger()
 	a.setupSystemTray()
 }
 
@@ -73,145 +564,539 @@ func (a *App) startup(ctx context.Context) {
 
 // GetWallpapers returns the list of saved wallpapers
 func (a *App) GetWallpapers() []WallpaperInfo {
-	// Update local URLs for webview access
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Point local URLs at the asset server, since webviews may block file://
 	for i := range a.data.Wallpapers {
-		a.data.Wallpapers[i].LocalURL = "file://" + a.data.Wallpapers[i].Filepath
+		a.data.Wallpapers[i].LocalURL = wallpaperURL(a.data.Wallpapers[i].ID)
 	}
-	return a.data.Wallpapers
+	wallpapers := append([]WallpaperInfo(nil), a.data.Wallpapers...)
+
+	// Unlabelled wallpapers show their title, or else their filename
+	for i := range wallpapers {
+		wallpapers[i].DisplayName = displayTitle(wallpapers[i])
+	}
+	return wallpapers
 }
 
-// GetWallpaperAsBase64 returns wallpaper as base64 data URL for preview
-func (a *App) GetWallpaperAsBase64(filepath string) (string, error) {
+// GetWallpaperAsBase64 returns wallpaper as base64 data URL for preview.
+// A non-zero maxDimension downscales the image so its longest side fits and
+// returns it as a JPEG. Results larger than MaxPreviewSizeMB are refused.
+func (a *App) GetWallpaperAsBase64(filepath string, maxDimension int) (string, error) {
 	// Check if file exists
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
+	stat, err := os.Stat(filepath)
+	if os.IsNotExist(err) {
 		return "", fmt.Errorf("file does not exist: %s", filepath)
 	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %v", err)
+	}
 
-	// Read the file
-	data, err := os.ReadFile(filepath)
+	limit := int64(a.GetSettings().MaxPreviewSizeMB) * 1024 * 1024
+	var buf strings.Builder
+
+	if maxDimension > 0 {
+		img, err := decodeImage(filepath)
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString("data:image/jpeg;base64,")
+		enc := base64.NewEncoder(base64.StdEncoding, &buf)
+		if err := encodeThumbnail(enc, img, maxDimension); err != nil {
+			return "", fmt.Errorf("failed to encode preview: %v", err)
+		}
+		enc.Close()
+		if limit > 0 && int64(buf.Len()) > limit {
+			return "", fmt.Errorf("preview is larger than %d MB; use a smaller maxDimension", a.GetSettings().MaxPreviewSizeMB)
+		}
+		return buf.String(), nil
+	}
+
+	// Check the encoded size up front so huge files are never read
+	prefix := "data:" + imageMIMEType(filepath) + ";base64,"
+	size := int64(len(prefix)) + int64(base64.StdEncoding.EncodedLen(int(stat.Size())))
+	if limit > 0 && size > limit {
+		return "", fmt.Errorf("file is too large for a base64 preview (%d MB limit); request a downscaled preview with maxDimension instead", a.GetSettings().MaxPreviewSizeMB)
+	}
+
+	f, err := os.Open(filepath)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %v", err)
 	}
+	defer f.Close()
 
-	// Encode to base64 with proper data URI prefix
-	encoded := base64.StdEncoding.EncodeToString(data)
-	dataURI := "data:image/jpeg;base64," + encoded
+	// Stream straight into the encoder instead of holding the raw bytes too
+	buf.Grow(int(size))
+	buf.WriteString(prefix)
+	enc := base64.NewEncoder(base64.StdEncoding, &buf)
+	if _, err := io.Copy(enc, f); err != nil {
+		return "", fmt.Errorf("failed to read file: %v", err)
+	}
+	enc.Close()
 
-	return dataURI, nil
+	return buf.String(), nil
 }
 
-// GetSettings returns the current application settings
+// imageMIMEType returns the MIME type for an image path, defaulting to JPEG
+func imageMIMEType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	}
+	return "image/jpeg"
+}
+
+// GetSettings returns the current application settings. The maps and
+// slices in the copy are shared, so they must not be changed in place.
 func (a *App) GetSettings() AppSettings {
+	a.settingsMu.RLock()
+	defer a.settingsMu.RUnlock()
 	return a.settings
 }
 
+// setSettings replaces the settings and returns the ones it replaced
+func (a *App) setSettings(s AppSettings) AppSettings {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	old := a.settings
+	a.settings = s
+	return old
+}
+
+// changeSettings applies edit to the settings under the lock, so a change
+// to one field can't undo a concurrent change to another. It returns the
+// settings from before the edit.
+func (a *App) changeSettings(edit func(s *AppSettings)) AppSettings {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	old := a.settings
+	edit(&a.settings)
+	return old
+}
+
 // UpdateSettings saves new settings and restarts the auto-changer
 func (a *App) UpdateSettings(newSettings AppSettings) error {
-	a.settings = newSettings
-	return a.saveSettings()
+	if err := validateSettings(newSettings); err != nil {
+		return err
+	}
+	newSettings.MaxWallpapers = max(newSettings.MaxWallpapers, 0)
+	newSettings.DownloadSources, _ = normalizeSources(newSettings.DownloadSources, newSettings.SourceConfigs)
+	old := a.changeSettings(func(s *AppSettings) {
+		// Pins are changed through PinWallpaperToMonitor and UnpinMonitor,
+		// so a settings form loaded before a pin can't undo it
+		newSettings.PinnedMonitors = s.PinnedMonitors
+		*s = withUnresolvedSecrets(newSettings, s.unresolvedSecrets)
+	})
+	if err := a.saveSettings(); err != nil {
+		return err
+	}
+	a.settingsChanged(old)
+	return nil
 }
 
-// DownloadAndSetWallpaper fetches a new wallpaper, sets it, and saves it
+// settingsChanged restarts the services whose settings differ from old and
+// wakes the auto-changer so it schedules with the new values
+func (a *App) settingsChanged(old AppSettings) {
+	settings := a.GetSettings()
+	if settings.EnableHotkeys != old.EnableHotkeys || settings.Hotkeys != old.Hotkeys {
+		a.restartHotkeys()
+	}
+	if settings.ControlAPIEnabled != old.ControlAPIEnabled ||
+		settings.ControlAPIAddress != old.ControlAPIAddress ||
+		settings.ControlAPIToken != old.ControlAPIToken {
+		a.restartControlAPI()
+	}
+	if settings.ChangeOnUnlock != old.ChangeOnUnlock {
+		a.restartSessionWatcher()
+	}
+	if names := pendingSecrets(settings, old); len(names) > 0 {
+		go a.resolvePendingSecrets(names)
+	}
+	// A shorter age limit applies now rather than at the next daily sweep
+	if settings.MaxWallpaperAgeDays != old.MaxWallpaperAgeDays && a.beginTask() {
+		go func() {
+			defer a.tasks.Done()
+			a.sweepOldWallpapers()
+		}()
+	}
+	if settings.AutoChangeEnabled != old.AutoChangeEnabled {
+		// Start over rather than carry waits from the old schedule
+		a.restartAutoChanger()
+	} else {
+		a.wakeAutoChanger()
+	}
+	a.emitAutoChangeStatus()
+}
+
+// DownloadAndSetWallpaper fetches a new wallpaper, sets it, and saves it.
+// When every source fails the error is a *sourcesFailedError whose message
+// gives each source's reason.
 func (a *App) DownloadAndSetWallpaper() (*WallpaperInfo, error) {
-	for _, url := range a.settings.DownloadSources {
-		info, err := a.downloadFile(url)
+	return a.downloadAndSet(triggerManual)
+}
+
+// downloadAndSet tries each source in turn until a wallpaper is downloaded
+// and applied. Automatic changes honour the luminance preference and the
+// download speed limit; manual ones bypass the limit. With downloads
+// disabled it rotates through the library instead. Only one change
+// downloads at a time; another fails with errChangeInProgress.
+func (a *App) downloadAndSet(trigger string) (*WallpaperInfo, error) {
+	if a.GetSettings().DownloadsDisabled {
+		return a.changeFromLibrary(trigger)
+	}
+	if !a.changeBusy.TryLock() {
+		return nil, errChangeInProgress
+	}
+	defer a.changeBusy.Unlock()
+	if !a.beginTask() {
+		return nil, errShuttingDown
+	}
+	defer a.tasks.Done()
+
+	if !a.storageAvailable() {
+		a.changeError(trigger, errStorageUnavailable)
+		return nil, errStorageUnavailable
+	}
+	automatic := trigger != triggerManual
+
+	failed := &sourcesFailedError{}
+	remaining := a.rotatedSources()
+	for len(remaining) > 0 {
+		var url string
+		var info *WallpaperInfo
+		var err error
+		if a.GetSettings().ParallelSourceFetch {
+			url, info, remaining, err = a.raceSources(remaining, automatic, failed)
+		} else {
+			url, info, remaining, err = a.firstCandidate(remaining, automatic, failed)
+		}
 		if err != nil {
-			fmt.Printf("Failed to download from %s: %v\n", url, err)
+			a.changeError(trigger, err)
+			return nil, err
+		}
+		if info == nil {
+			break
+		}
+
+		if err := a.storeDownload(url, info); err != nil {
+			fmt.Printf("Skipping %s: %v\n", info.Filename, err)
+			failed.add(url, err)
 			continue
 		}
+		a.recordSourceUsed(url)
 
-		err = a.SetWallpaper(info.Filepath)
+		target := *info
+		if automatic {
+			var matched bool
+			if target, matched = a.matchTagPreference(*info); !matched {
+				target = a.matchLuminancePreference(*info)
+			}
+		}
+
+		err = a.setWallpaper(wallpaperPath(target), trigger)
 		if err != nil {
-			fmt.Printf("Failed to set wallpaper %s: %v\n", info.Filepath, err)
+			fmt.Printf("Failed to set wallpaper %s: %v\n", wallpaperPath(target), err)
+			failed.add(url, fmt.Errorf("cannot set wallpaper: %w", err))
 			continue
 		}
 
-		a.addWallpaper(*info)
-		wailsruntime.EventsEmit(a.ctx, "wallpaperChanged", *info)
-		return info, nil
+		a.emitWallpaperChanged(target)
+		return &target, nil
 	}
-	return nil, fmt.Errorf("all download sources failed")
+	a.changeError(trigger, failed)
+	return nil, failed
 }
 
-// SetWallpaper sets the desktop background from a given file path
-// SetWallpaper sets the desktop background from a given file path
-func (a *App) SetWallpaper(filepath string) error {
-	switch runtime.GOOS {
-	case "windows":
-		return setWallpaperWindows(filepath)
-	case "darwin":
-		cmd := exec.Command("osascript", "-e", fmt.Sprintf(`tell application "Finder" to set desktop picture to POSIX file "%s"`, filepath))
-		return cmd.Run()
-	case "linux":
-		// Try multiple Linux desktop environments
-		commands := [][]string{
-			{"gsettings", "set", "org.gnome.desktop.background", "picture-uri", "file://" + filepath},
-			{"feh", "--bg-scale", filepath},
-			{"nitrogen", "--set-scaled", filepath},
+// firstCandidate tries sources one at a time and returns the first
+// candidate with its source and the sources after it. Failures are added
+// to failed; the error is errDownloadCanceled if the user stopped it.
+func (a *App) firstCandidate(sources []string, automatic bool, failed *sourcesFailedError) (string, *WallpaperInfo, []string, error) {
+	for i, url := range sources {
+		info, err := a.fetchCandidate(a.lifetime(), url, automatic)
+		if errors.Is(err, errDownloadCanceled) {
+			return "", nil, nil, err
 		}
-
-		for _, cmdArgs := range commands {
-			cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
-			if cmd.Run() == nil {
-				return nil
-			}
+		if err != nil {
+			failed.add(url, err)
+			continue
 		}
-		return fmt.Errorf("no suitable wallpaper command found")
+		return url, info, sources[i+1:], nil
 	}
-
-	return fmt.Errorf("unsupported operating system")
+	return "", nil, nil, nil
 }
 
-// setWallpaperWindows uses direct Windows API call - no external processes
-func setWallpaperWindows(imagePath string) error {
-	// Load user32.dll and get SystemParametersInfoW function
-	user32 := syscall.NewLazyDLL("user32.dll")
-	systemParametersInfo := user32.NewProc("SystemParametersInfoW")
+// fetchCandidate downloads a wallpaper from source and checks it isn't
+// already in the library, subject to the source's rate limit. The
+// source's health is recorded, except when the download was stopped by
+// ctx or CancelDownload rather than failing.
+func (a *App) fetchCandidate(ctx context.Context, url string, automatic bool) (*WallpaperInfo, error) {
+	if !a.allowRequest(url) {
+		fmt.Printf("Skipping %s: rate limit reached\n", url)
+		return nil, errRateLimited
+	}
 
-	// Convert Go string to Windows UTF-16 string pointer
-	imagePathPtr, err := syscall.UTF16PtrFromString(imagePath)
+	info, err := a.downloadFile(ctx, url, !automatic)
+	if err != nil && (errors.Is(err, errDownloadCanceled) || ctx.Err() != nil) {
+		return nil, err
+	}
+	a.recordSourceResult(url, err)
+	if errors.Is(err, errNotModified) {
+		fmt.Printf("No new content from %s\n", url)
+		return nil, err
+	}
 	if err != nil {
-		return fmt.Errorf("failed to convert path to UTF-16: %v", err)
+		fmt.Printf("Failed to download from %s: %v\n", url, err)
+		return nil, err
 	}
 
-	// Call SystemParametersInfoW
-	// SPI_SETDESKWALLPAPER = 20
-	// SPIF_UPDATEINIFILE | SPIF_SENDCHANGE = 3
-	ret, _, lastErr := systemParametersInfo.Call(
-		uintptr(20),                           // SPI_SETDESKWALLPAPER
-		uintptr(0),                            // uiParam (not used)
-		uintptr(unsafe.Pointer(imagePathPtr)), // pvParam (image path)
-		uintptr(3),                            // fWinIni (update registry and broadcast change)
-	)
+	// Earth imagery is always new, even when it looks the same
+	earth := a.sourceConfig(url).Type == sourceTypeEarth
+	if existing, ok := a.findDuplicate(*info); ok && !earth {
+		fmt.Printf("Skipping %s: duplicate of %s\n", info.Filename, existing.Filename)
+		removeWallpaperFiles(*info)
+		return nil, fmt.Errorf("%w: same as %s", errDuplicate, existing.Filename)
+	}
+	return info, nil
+}
 
-	// Check if the call was successful
-	if ret == 0 {
-		return fmt.Errorf("SystemParametersInfoW failed: %v", lastErr)
+// storeDownload processes a candidate and adds it to the library. On
+// failure its files are removed.
+func (a *App) storeDownload(url string, info *WallpaperInfo) error {
+	if err := a.processWallpaper(info); err != nil {
+		fmt.Printf("Failed to process wallpaper %s: %v\n", info.Filename, err)
 	}
 
+	var err error
+	if a.sourceConfig(url).Type == sourceTypeEarth {
+		err = a.storeEarthWallpaper(url, info)
+	} else {
+		err = a.addWallpaper(*info)
+	}
+	if err != nil {
+		removeWallpaperFiles(*info)
+		return err
+	}
+	a.emitWallpaperDownloaded(*info)
 	return nil
 }
 
-// DeleteWallpaper removes a wallpaper file and its metadata
+// DownloadAndSetFromURL downloads a single image from an http(s) URL, adds
+// it to the library and sets it. An image already in the library is set
+// again rather than stored twice.
+func (a *App) DownloadAndSetFromURL(rawURL string) (*WallpaperInfo, error) {
+	return a.addFromURL(rawURL, nil, true)
+}
+
+// addFromURL downloads rawURL into the library, adds tags to the entry and
+// sets it when set is true
+func (a *App) addFromURL(rawURL string, tags []string, set bool) (*WallpaperInfo, error) {
+	if a.GetSettings().DownloadsDisabled {
+		return nil, errDownloadsDisabled
+	}
+	if err := validateImageURL(rawURL); err != nil {
+		return nil, err
+	}
+	if !a.beginTask() {
+		return nil, errShuttingDown
+	}
+	defer a.tasks.Done()
+
+	if !a.storageAvailable() {
+		return nil, errStorageUnavailable
+	}
+
+	target, err := a.fetchFromURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if tags = normalizeTags(tags); len(tags) > 0 {
+		if err := a.updateWallpaper(target.ID, func(wp *WallpaperInfo) {
+			wp.Tags = normalizeTags(append(wp.Tags, tags...))
+			target.Tags = wp.Tags
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if set {
+		if err := a.setWallpaper(wallpaperPath(target), triggerManual); err != nil {
+			return nil, err
+		}
+		a.emitWallpaperChanged(target)
+	}
+	a.emit("wallpapersUpdated", a.GetWallpapers())
+	return &target, nil
+}
+
+// fetchFromURL downloads rawURL into the library and returns the stored
+// entry, or the existing entry when the image is already saved
+func (a *App) fetchFromURL(rawURL string) (WallpaperInfo, error) {
+	info, err := a.downloadFile(a.lifetime(), rawURL, true)
+	if errors.Is(err, errNotModified) {
+		if existing, ok := a.findBySourceURL(rawURL); ok {
+			return existing, nil
+		}
+	}
+	if err != nil {
+		return WallpaperInfo{}, err
+	}
+
+	if existing, ok := a.findDuplicate(*info); ok {
+		removeWallpaperFiles(*info)
+		return existing, nil
+	}
+
+	if err := a.processWallpaper(info); err != nil {
+		fmt.Printf("Failed to process wallpaper %s: %v\n", info.Filename, err)
+	}
+	if err := a.addWallpaper(*info); err != nil {
+		removeWallpaperFiles(*info)
+		return WallpaperInfo{}, err
+	}
+	a.emitWallpaperDownloaded(*info)
+	return *info, nil
+}
+
+// DeleteWallpaper moves a wallpaper's files to the trash and removes its
+// metadata
 func (a *App) DeleteWallpaper(id string) error {
 	var newWallpapers []WallpaperInfo
-	var deletedFile string
+	var deleted *WallpaperInfo
+
+	a.mu.Lock()
 
 	for _, wp := range a.data.Wallpapers {
 		if wp.ID == id {
-			deletedFile = wp.Filepath
+			deleted = &wp
 		} else {
 			newWallpapers = append(newWallpapers, wp)
 		}
 	}
 
-	if deletedFile != "" {
-		os.Remove(deletedFile)
-		a.data.Wallpapers = newWallpapers
-		a.saveWallpapers()
-		wailsruntime.EventsEmit(a.ctx, "wallpapersUpdated", a.data.Wallpapers)
+	if deleted == nil {
+		a.mu.Unlock()
+		return nil
+	}
+	a.data.Wallpapers = newWallpapers
+	remaining := append([]WallpaperInfo(nil), newWallpapers...)
+	a.mu.Unlock()
+
+	a.trashWallpaperFiles(*deleted)
+	a.saveWallpapers()
+	a.emit("wallpapersUpdated", remaining)
+	a.clearPinsFor(map[string]bool{id: true})
+
+	return nil
+}
+
+// CopyWallpaper copies a wallpaper's file to destPath, which may be a file
+// path or an existing directory, and returns the path written. The original
+// extension is always kept. If the destination exists, uniqueName appends
+// a numbered suffix instead of failing.
+func (a *App) CopyWallpaper(id string, destPath string, uniqueName bool) (string, error) {
+	wp, ok := a.findWallpaper(id)
+	if !ok {
+		return "", fmt.Errorf("wallpaper not found: %s", id)
+	}
+
+	dest := destPath
+	if stat, err := os.Stat(dest); err == nil && stat.IsDir() {
+		dest = filepath.Join(dest, wp.Filename)
+	}
+
+	ext := filepath.Ext(wp.Filepath)
+	if !strings.EqualFold(filepath.Ext(dest), ext) {
+		dest = strings.TrimSuffix(dest, filepath.Ext(dest)) + ext
+	}
+
+	src, err := os.Open(wp.Filepath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open wallpaper: %v", err)
+	}
+	defer src.Close()
+
+	base := strings.TrimSuffix(dest, ext)
+	for i := 1; ; i++ {
+		out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) && uniqueName {
+			dest = fmt.Sprintf("%s (%d)%s", base, i, ext)
+			continue
+		}
+		if os.IsExist(err) {
+			return "", fmt.Errorf("destination already exists: %s", dest)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create destination: %v", err)
+		}
+
+		_, err = io.Copy(out, src)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(dest)
+			return "", fmt.Errorf("failed to copy wallpaper: %v", err)
+		}
+		return dest, nil
+	}
+}
+
+// SetDisplayName sets the label shown for a wallpaper. The file on disk is
+// not renamed; an empty name reverts to showing the filename.
+func (a *App) SetDisplayName(id string, name string) error {
+	name = strings.TrimSpace(name)
+	return a.updateWallpaper(id, func(wp *WallpaperInfo) {
+		wp.DisplayName = name
+	})
+}
+
+// SetNotes stores freeform notes for a wallpaper
+func (a *App) SetNotes(id string, notes string) error {
+	return a.updateWallpaper(id, func(wp *WallpaperInfo) {
+		wp.Notes = notes
+	})
+}
+
+// SetFavorite marks or unmarks a wallpaper as a favorite
+func (a *App) SetFavorite(id string, favorite bool) error {
+	return a.updateWallpaper(id, func(wp *WallpaperInfo) {
+		wp.Favorite = favorite
+	})
+}
+
+// SetRating rates a wallpaper from 1 to 5 stars, or clears it with 0
+func (a *App) SetRating(id string, rating int) error {
+	if rating < 0 || rating > maxRating {
+		return fmt.Errorf("rating must be between 0 and %d", maxRating)
 	}
+	return a.updateWallpaper(id, func(wp *WallpaperInfo) {
+		wp.Rating = rating
+	})
+}
 
+// updateWallpaper applies update to the wallpaper with the given ID under
+// the lock and persists the result
+func (a *App) updateWallpaper(id string, update func(*WallpaperInfo)) error {
+	a.mu.Lock()
+	found := false
+	for i := range a.data.Wallpapers {
+		if a.data.Wallpapers[i].ID == id {
+			update(&a.data.Wallpapers[i])
+			found = true
+			break
+		}
+	}
+	a.mu.Unlock()
+
+	if !found {
+		return fmt.Errorf("wallpaper not found: %s", id)
+	}
+	a.saveWallpapers()
 	return nil
 }
 
@@ -241,66 +1126,61 @@ func (a *App) OpenWallpaperDirectory() error {
 
 // getWallpaperDir gets the directory where wallpapers are stored
 func (a *App) getWallpaperDir() string {
+	// A custom directory is never created, so an unmounted drive isn't
+	// shadowed by an empty folder at its mount point
+	if a.GetSettings().WallpaperDirectory != "" {
+		return a.GetSettings().WallpaperDirectory
+	}
+
 	home, _ := os.UserHomeDir()
 	dir := filepath.Join(home, "Pictures", "WallpaperEngine")
 	os.MkdirAll(dir, os.ModePerm)
 	return dir
 }
 
-// downloadFile downloads a file from a URL to the wallpaper directory
-func (a *App) downloadFile(url string) (*WallpaperInfo, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("User-Agent", "WallpaperEngine/1.0")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// findWallpaper looks up a wallpaper by ID
+func (a *App) findWallpaper(id string) (WallpaperInfo, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	for _, wp := range a.data.Wallpapers {
+		if wp.ID == id {
+			return wp, true
+		}
 	}
+	return WallpaperInfo{}, false
+}
 
-	// Generate unique ID and filename
-	id := generateID()
-	filename := fmt.Sprintf("wallpaper_%d_%s.jpg", time.Now().Unix(), id[:8])
-	filepath := filepath.Join(a.getWallpaperDir(), filename)
+// findBySourceURL looks up the most recent wallpaper downloaded from url,
+// whether it served the image or redirected to it
+func (a *App) findBySourceURL(url string) (WallpaperInfo, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	out, err := os.Create(filepath)
-	if err != nil {
-		return nil, err
+	for i := len(a.data.Wallpapers) - 1; i >= 0; i-- {
+		if a.data.Wallpapers[i].SourceURL == url || a.data.Wallpapers[i].RedirectedFrom == url {
+			return a.data.Wallpapers[i], true
+		}
 	}
-	defer out.Close()
+	return WallpaperInfo{}, false
+}
 
-	size, err := io.Copy(out, resp.Body)
-	if err != nil {
-		return nil, err
-	}
+// findByPageURL returns the newest wallpaper taken from a feed item's page
+func (a *App) findByPageURL(url string) (WallpaperInfo, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	// Validate minimum file size (50KB)
-	if size < 50000 {
-		os.Remove(filepath)
-		return nil, fmt.Errorf("file too small: %d bytes", size)
+	for i := len(a.data.Wallpapers) - 1; i >= 0; i-- {
+		if a.data.Wallpapers[i].PageURL == url {
+			return a.data.Wallpapers[i], true
+		}
 	}
+	return WallpaperInfo{}, false
+}
 
-	return &WallpaperInfo{
-		ID:           id,
-		Filename:     filename,
-		Filepath:     filepath,
-		LocalURL:     "", // Will be set in GetWallpapers
-		DownloadDate: time.Now(),
-		SourceURL:    url,
-		FileSize:     size,
-	}, nil
+// sourceConfig returns the overrides for a source, or the zero value
+func (a *App) sourceConfig(url string) SourceConfig {
+	return a.GetSettings().SourceConfigs[url]
 }
 
 // generateID creates a random ID
@@ -310,83 +1190,225 @@ func generateID() string {
 	return fmt.Sprintf("%x", bytes)
 }
 
-// addWallpaper adds wallpaper metadata and saves the list
-func (a *App) addWallpaper(info WallpaperInfo) {
-	a.data.Wallpapers = append(a.data.Wallpapers, info)
+// findDuplicate returns an existing wallpaper that matches info
+func (a *App) findDuplicate(info WallpaperInfo) (WallpaperInfo, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.findDuplicateLocked(info)
+}
+
+// removeWallpaperFiles deletes a wallpaper's file and any processed copy
+// or kept original
+func removeWallpaperFiles(info WallpaperInfo) {
+	os.Remove(info.Filepath)
+	if info.ProcessedPath != "" {
+		os.Remove(info.ProcessedPath)
+	}
+	removeOriginal(info.OriginalPath)
+}
+
+// addWallpaper adds wallpaper metadata and saves the list. Wallpapers that
+// duplicate an existing one are rejected with errDuplicate.
+func (a *App) addWallpaper(info WallpaperInfo) error {
+	return a.storeWallpaper(info, true)
+}
+
+// storeWallpaper adds wallpaper metadata, checking for duplicates when
+// asked. An entry with the same ID is replaced: its files are removed,
+// while what the user set on it carries over.
+func (a *App) storeWallpaper(info WallpaperInfo, checkDuplicate bool) error {
+	a.mu.Lock()
+	if checkDuplicate {
+		if existing, ok := a.findDuplicateLocked(info); ok {
+			a.mu.Unlock()
+			return fmt.Errorf("%w of %s", errDuplicate, existing.Filename)
+		}
+	}
+	replaced := false
+	for i, wp := range a.data.Wallpapers {
+		if wp.ID != info.ID {
+			continue
+		}
+		if wp.Filepath != info.Filepath {
+			removeWallpaperFiles(wp)
+		}
+		info.DisplayName, info.Notes, info.Tags = wp.DisplayName, wp.Notes, wp.Tags
+		info.Favorite, info.Rating, info.Skipped = wp.Favorite, wp.Rating, wp.Skipped
+		info.TimesSet, info.LastSetAt = wp.TimesSet, wp.LastSetAt
+		a.data.Wallpapers[i] = info
+		replaced = true
+		break
+	}
+	if !replaced {
+		a.data.Wallpapers = append(a.data.Wallpapers, info)
+	}
 
 	// Sort wallpapers by date, newest first
 	sort.Slice(a.data.Wallpapers, func(i, j int) bool {
 		return a.data.Wallpapers[i].DownloadDate.After(a.data.Wallpapers[j].DownloadDate)
 	})
 
-	// Keep only max wallpapers
-	if len(a.data.Wallpapers) > a.settings.MaxWallpapers {
+	// Keep only MaxWallpapers, with 0 meaning no limit, unless the files
+	// can't be reached to delete them. A running prefetch makes room for
+	// everything it downloads.
+	evicted := map[string]bool{}
+	if maxWallpapers := a.GetSettings().MaxWallpapers; maxWallpapers > 0 && !a.storageDown {
+		limit := maxWallpapers + a.prefetchReserve()
 		// Remove oldest wallpapers
-		for i := a.settings.MaxWallpapers; i < len(a.data.Wallpapers); i++ {
-			os.Remove(a.data.Wallpapers[i].Filepath)
+		for i := limit; i < len(a.data.Wallpapers); i++ {
+			wp := a.data.Wallpapers[i]
+			fmt.Printf("Evicting wallpaper over the limit of %d: %s\n", maxWallpapers, wp.Filepath)
+			a.trashWallpaperFiles(wp)
+			evicted[wp.ID] = true
+		}
+		if len(a.data.Wallpapers) > limit {
+			a.data.Wallpapers = a.data.Wallpapers[:limit]
 		}
-		a.data.Wallpapers = a.data.Wallpapers[:a.settings.MaxWallpapers]
 	}
+	a.mu.Unlock()
 
+	a.clearPinsFor(evicted)
 	a.saveWallpapers()
+	return nil
 }
 
 // --- Persistence ---
 
 func (a *App) getConfigPath(filename string) string {
-	configDir, _ := os.UserConfigDir()
-	appDir := filepath.Join(configDir, "WallpaperEngine")
+	appDir := a.configDir
+	if appDir == "" {
+		configDir, _ := os.UserConfigDir()
+		appDir = filepath.Join(configDir, "WallpaperEngine")
+	}
 	os.MkdirAll(appDir, os.ModePerm)
 	return filepath.Join(appDir, filename)
 }
 
+// saveSettings writes settings.json. Secrets go to the secret store and
+// the file only holds references to them.
 func (a *App) saveSettings() error {
-	data, err := json.MarshalIndent(a.settings, "", "  ")
+	a.settingsSaveMu.Lock()
+	defer a.settingsSaveMu.Unlock()
+	s := a.GetSettings()
+	if err := a.storeSecrets(s); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(withSecretRefs(s), "", "  ")
 	if err != nil {
 		return err
 	}
 	return os.WriteFile(a.getConfigPath("settings.json"), data, 0644)
 }
 
+// defaultSettings returns the settings used on first run. Fields missing from
+// an existing settings.json keep these values.
+func defaultSettings() AppSettings {
+	// Default settings with high-quality wallpaper sources
+	return AppSettings{
+		AutoChangeEnabled:   true,
+		CloseToTray:         true,
+		MaxPreviewSizeMB:    25,
+		MaxFileSizeBytes:    defaultMaxFileSize,
+		MinFileSizeBytes:    defaultMinFileSize,
+		MaxRedirects:        defaultMaxRedirects,
+		TrashRetentionDays:  7,
+		Hotkeys:             defaultHotkeys,
+		ControlAPIAddress:   defaultControlAPIAddress,
+		ChangeIntervalHours: 1,
+		MaxWallpapers:       20,
+		SourceRotation:      sourceRotationSequential,
+		DownloadSources: []string{
+			// 4K Sources
+			"https://source.unsplash.com/3840x2160/landscape",
+			"https://source.unsplash.com/3840x2160/nature",
+			"https://source.unsplash.com/3840x2160/mountain",
+			"https://source.unsplash.com/3840x2160/forest",
+			"https://source.unsplash.com/3840x2160/ocean",
+			// 2K Sources
+			"https://source.unsplash.com/2560x1440/architecture",
+			"https://source.unsplash.com/2560x1440/city",
+			"https://source.unsplash.com/2560x1440/space",
+			// Picsum for variety
+			"https://picsum.photos/3840/2160",
+			"https://picsum.photos/2560/1440",
+		},
+		LuminanceThreshold:  0.5,
+		SimilarityThreshold: defaultSimilarityThreshold,
+		DayStartHour:        7,
+		NightStartHour:      19,
+
+		MinMinutesBetweenUnlockChanges: 30,
+		IdleThresholdSeconds:           120,
+		CollageGutter:                  8,
+		CollageBackground:              "#000000",
+		OverlayPosition:                overlayBottomRight,
+		OverlayFontSize:                32,
+		OverlayColor:                   "#ffffff",
+		UpdateCheckEnabled:             true,
+		ShuffleRecencyWeight:           1,
+		ShuffleRatingWeight:            1,
+		FollowSun:                      true,
+		ContentFilter:                  contentFilterStrict,
+	}
+}
+
 func (a *App) loadSettings() {
+	s := defaultSettings()
 	data, err := os.ReadFile(a.getConfigPath("settings.json"))
-	if err == nil {
-		json.Unmarshal(data, &a.settings)
-	} else {
-		// Default settings with high-quality wallpaper sources
-		a.settings = AppSettings{
-			AutoChangeEnabled:   true,
-			ChangeIntervalHours: 1,
-			MaxWallpapers:       20,
-			DownloadSources: []string{
-				// 4K Sources
-				"https://source.unsplash.com/3840x2160/landscape",
-				"https://source.unsplash.com/3840x2160/nature",
-				"https://source.unsplash.com/3840x2160/mountain",
-				"https://source.unsplash.com/3840x2160/forest",
-				"https://source.unsplash.com/3840x2160/ocean",
-				// 2K Sources
-				"https://source.unsplash.com/2560x1440/architecture",
-				"https://source.unsplash.com/2560x1440/city",
-				"https://source.unsplash.com/2560x1440/space",
-				// Picsum for variety
-				"https://picsum.photos/3840/2160",
-				"https://picsum.photos/2560/1440",
-			},
-		}
+	if err != nil {
+		a.setSettings(s)
 		a.saveSettings()
+		return
+	}
+	json.Unmarshal(data, &s)
+	s.MaxWallpapers = max(s.MaxWallpapers, 0)
+	s.MaxDownloadSpeedKBps = max(s.MaxDownloadSpeedKBps, 0)
+
+	// Hand edits can leave typos and repeats in the sources
+	var invalid []string
+	s.DownloadSources, invalid = normalizeSources(s.DownloadSources, s.SourceConfigs)
+	if len(invalid) > 0 {
+		fmt.Printf("Ignoring invalid download sources: %s\n", strings.Join(invalid, ", "))
+	}
+
+	// Versions before the secret store saved secrets in plaintext
+	s, plaintext := a.resolveSecrets(s)
+	a.setSettings(s)
+	if plaintext {
+		fmt.Println("Moving secrets from settings.json to the secret store")
+		if err := a.saveSettings(); err != nil {
+			fmt.Printf("Failed to move secrets: %v\n", err)
+		}
 	}
 }
 
 func (a *App) saveWallpapers() {
+	a.mu.Lock()
 	data, _ := json.MarshalIndent(a.data, "", "  ")
+	a.mu.Unlock()
 	os.WriteFile(a.getConfigPath("wallpapers.json"), data, 0644)
 }
 
 func (a *App) loadWallpapers() {
+	// Files on an unmounted drive are only temporarily missing
+	available := a.storageAvailable()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	data, err := os.ReadFile(a.getConfigPath("wallpapers.json"))
 	if err == nil {
 		json.Unmarshal(data, &a.data)
+		// Libraries saved before LuminanceMeasured only have the value
+		for i := range a.data.Wallpapers {
+			if a.data.Wallpapers[i].Luminance != 0 {
+				a.data.Wallpapers[i].LuminanceMeasured = true
+			}
+		}
+		if !available {
+			return
+		}
+
 		// Clean up missing files
 		var validWallpapers []WallpaperInfo
 		for _, wp := range a.data.Wallpapers {
@@ -400,40 +1422,195 @@ func (a *App) loadWallpapers() {
 
 // --- Background Service ---
 
+// autoChangerState is the running auto-changer loop: cancel stops it and
+// done is closed once it has returned
+type autoChangerState struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startAutoChanger starts the auto-changer loop as a background task,
+// unless it is already running or the app is shutting down
 func (a *App) startAutoChanger() {
-	ticker := time.NewTicker(1 * time.Minute) // Check every minute
+	a.autoChanger.mu.Lock()
+	defer a.autoChanger.mu.Unlock()
+	if a.autoChanger.done != nil || !a.beginTask() {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(a.lifetime())
+	done := make(chan struct{})
+	a.autoChanger.cancel, a.autoChanger.done = cancel, done
 	go func() {
-		lastChange := time.Now()
-		for range ticker.C {
-			if a.settings.AutoChangeEnabled {
-				interval := time.Duration(a.settings.ChangeIntervalHours) * time.Hour
-				if time.Since(lastChange) >= interval {
-					fmt.Printf("Auto-changing wallpaper at %s\n", time.Now().Format("15:04:05"))
-					_, err := a.DownloadAndSetWallpaper()
-					if err != nil {
-						fmt.Printf("Auto-change failed: %v\n", err)
-					}
-					lastChange = time.Now()
-				}
+		defer a.tasks.Done()
+		defer close(done)
+		a.runAutoChanger(ctx)
+	}()
+}
+
+// stopAutoChanger stops the auto-changer loop and waits for it to return.
+// A change in progress is finished first.
+func (a *App) stopAutoChanger() {
+	a.autoChanger.mu.Lock()
+	cancel, done := a.autoChanger.cancel, a.autoChanger.done
+	a.autoChanger.cancel, a.autoChanger.done = nil, nil
+	a.autoChanger.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// restartAutoChanger applies changed scheduling settings from scratch
+func (a *App) restartAutoChanger() {
+	a.stopAutoChanger()
+	a.startAutoChanger()
+}
+
+// runAutoChanger runs the scheduling loop until ctx is cancelled, when the
+// auto-changer is stopped or the app shuts down. Decisions come from
+// nextAction and all time flows through a.clock.
+func (a *App) runAutoChanger(ctx context.Context) {
+	a.autoChangerRunning.Store(true)
+	defer a.autoChangerRunning.Store(false)
+
+	if a.schedulerState().LastChange.IsZero() {
+		// First run: wait a full interval, as before state was persisted
+		a.setLastChange(a.clock.Now())
+	}
+
+	var nextSweep, themeBoundary time.Time
+	for {
+		now := a.clock.Now()
+		a.checkMonitors(now)
+		if !now.Before(nextSweep) {
+			a.sweepOldWallpapers()
+			nextSweep = now.Add(agingSweepInterval)
+		}
+		download, nextDownload := downloadDue(now, a.schedulerState(), a.GetSettings())
+		if download {
+			a.startDownloadBatch(now, "scheduled")
+		}
+		action, next := nextAction(now, a.schedulerState(), a.GetSettings())
+		if nextDownload.Before(next) {
+			next = nextDownload
+		}
+		themeChange := false
+		if a.GetSettings().ChangeOnThemeBoundary && a.GetSettings().PreferLuminanceByTime {
+			// The boundary is kept once passed, so a change deferred for
+			// fullscreen or idle still happens
+			if themeBoundary.IsZero() || now.Before(themeBoundary) {
+				themeBoundary = a.nextThemeBoundary(now)
+			} else if state := a.schedulerState(); state.paused(now) || state.pinned(now) {
+				themeBoundary = a.nextThemeBoundary(now)
+			} else if action == ActionNone {
+				action, themeChange = ActionChange, true
+			}
+			if themeBoundary.Before(next) {
+				next = themeBoundary
 			}
+		} else {
+			themeBoundary = time.Time{}
 		}
-	}()
+		if action == ActionChange && a.deferForFullscreen() {
+			action, next = ActionNone, now.Add(fullscreenPollInterval)
+		}
+		if action == ActionChange && a.deferForIdle(now) {
+			action, next = ActionNone, now.Add(idlePollInterval)
+		}
+
+		if action == ActionChange {
+			if themeChange {
+				fmt.Printf("Changing wallpaper at the day/night boundary at %s\n", now.Format("15:04:05"))
+			} else {
+				fmt.Printf("Auto-changing wallpaper at %s\n", now.Format("15:04:05"))
+			}
+			themeBoundary = time.Time{}
+			if themeChange {
+				a.autoChange(triggerSchedule)
+			} else {
+				a.autoChange(triggerAuto)
+			}
+			continue
+		}
+
+		a.refreshMenu()
+		a.refreshTray()
+		wait := min(next.Sub(now), schedulerPollInterval)
+		select {
+		case <-a.clock.After(max(wait, time.Second)):
+		case <-a.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// wakeAutoChanger makes the auto-changer re-evaluate its schedule now
+func (a *App) wakeAutoChanger() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// autoChange performs one automatic change and records its time, whether
+// or not it succeeded, so a failing source isn't retried immediately.
+// trigger says what prompted it, for the change history.
+func (a *App) autoChange(trigger string) {
+	a.setWaitingForIdle(false)
+	change := a.downloadAndSet
+	if a.GetSettings().DownloadSchedule.IntervalHours > 0 {
+		change = a.changeFromLibrary
+	}
+	info, err := change(trigger)
+	if err != nil {
+		fmt.Printf("Auto-change failed: %v\n", err)
+		a.reportChangeFailure(trigger, err, a.GetSettings().DownloadsDisabled || a.GetSettings().DownloadSchedule.IntervalHours > 0)
+	} else {
+		a.notifyChange(*info)
+	}
+	a.setLastChange(a.clock.Now())
 }
 
 // beforeClose is called when the user tries to close the window
 func (a *App) beforeClose(ctx context.Context) (prevent bool) {
+	a.lifecycleMu.Lock()
+	quitting := a.quitting
+	a.lifecycleMu.Unlock()
+
+	if quitting || !a.GetSettings().CloseToTray {
+		return false
+	}
+
 	// Hide to system tray instead of closing
 	wailsruntime.Hide(ctx)
 	return true // Prevent actual closing
 }
 
-// ShowWindow shows the main window (can be called from tray menu)
+// emit sends an event to the frontend. It is a no-op before startup.
+func (a *App) emit(name string, data ...interface{}) {
+	if a.ctx == nil {
+		return
+	}
+	wailsruntime.EventsEmit(a.ctx, name, data...)
+}
+
+// ShowWindow shows the main window
 func (a *App) ShowWindow() {
 	wailsruntime.Show(a.ctx)
 }
 
 // QuitApp quits the application completely
 func (a *App) QuitApp() {
+	// Quit also goes through beforeClose, which must not hide the window
+	a.lifecycleMu.Lock()
+	a.quitting = true
+	a.lifecycleMu.Unlock()
+
 	wailsruntime.Quit(a.ctx)
 }
 
@@ -450,18 +1627,42 @@ func (a *App) onReady() {
 
 	// Menu items
 	mShow := systray.AddMenuItem("Show Wallset", "Show the main window")
+	systray.AddSeparator()
+	mNext := systray.AddMenuItem("Next Wallpaper", "Go forward in history or download a new wallpaper")
+	mSkip := systray.AddMenuItem("Skip", "Never pick the current wallpaper again and set a new one")
 	mDownload := systray.AddMenuItem("Download New", "Download and set new wallpaper")
+	mPause := systray.AddMenuItemCheckbox("Pause Auto-Change", "Pause or resume automatic changes", false)
+	mFolder := systray.AddMenuItem("Open Folder", "Open the wallpaper folder")
+	systray.AddSeparator()
+	mStatus := systray.AddMenuItem("Auto-change: —", "")
+	mStatus.Disable()
 	systray.AddSeparator()
 	mQuit := systray.AddMenuItem("Quit", "Quit Wallset")
 
+	a.tray.mu.Lock()
+	a.tray.pause = mPause
+	a.tray.status = mStatus
+	a.tray.mu.Unlock()
+	a.refreshTray()
+
 	// Handle menu clicks
 	go func() {
 		for {
 			select {
 			case <-mShow.ClickedCh:
 				a.ShowWindow()
+			case <-mNext.ClickedCh:
+				go a.menuAction("next", a.NextWallpaper)
+			case <-mSkip.ClickedCh:
+				go a.menuAction("skip", a.SkipCurrent)
 			case <-mDownload.ClickedCh:
 				go a.DownloadAndSetWallpaper()
+			case <-mPause.ClickedCh:
+				go a.SetAutoChangePaused(!mPause.Checked())
+			case <-mFolder.ClickedCh:
+				if err := a.OpenWallpaperDirectory(); err != nil {
+					fmt.Printf("Failed to open wallpaper folder: %v\n", err)
+				}
 			case <-mQuit.ClickedCh:
 				systray.Quit()
 				a.QuitApp()
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestApp returns an App with its config and wallpapers in temporary
+// directories and DryRun on, so nothing touches the real desktop
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	a := NewApp()
+	a.configDir = t.TempDir()
+	a.clock = newFakeClock(time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC))
+	s := defaultSettings()
+	s.WallpaperDirectory = t.TempDir()
+	s.DryRun = true
+	a.setSettings(s)
+	return a
+}
+
+// fakeClock is a clock that only moves when told to. After fires at once,
+// moving the clock forward by the wait, so retries and backoffs don't
+// slow tests down.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.Advance(d)
+	return ch
+}
+
+// Advance moves the clock forward by d and returns the new time
+func (c *fakeClock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// wallpaperRoute is where the asset server serves library images by ID;
+	// LocalURL points here. A size query parameter returns a thumbnail.
+	wallpaperRoute = "/wallpapers/"
+	// maxAssetThumbnailSize bounds the size parameter, so a request can't
+	// make the app resize images to arbitrary dimensions
+	maxAssetThumbnailSize = 4096
+)
+
+// wallpaperURL returns the asset server URL of a library wallpaper
+func wallpaperURL(id string) string {
+	return wallpaperRoute + id
+}
+
+// assetHandler serves what the embedded frontend assets don't contain:
+// library images, so the webview needn't load file:// URLs (which many
+// block) or base64 strings
+func (a *App) assetHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(wallpaperRoute, a.serveWallpaper)
+	return mux
+}
+
+// serveWallpaper answers GET /wallpapers/<id>[?size=N] with the image file,
+// or a cached thumbnail whose longest side is at most N pixels
+func (a *App) serveWallpaper(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, wallpaperRoute)
+	wp, ok := a.findWallpaper(id)
+	if id == "" || !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	path, root := wp.Filepath, a.getWallpaperDir()
+	if param := r.URL.Query().Get("size"); param != "" {
+		size, err := strconv.Atoi(param)
+		if err != nil || size <= 0 || size > maxAssetThumbnailSize {
+			http.Error(w, "invalid size", http.StatusBadRequest)
+			return
+		}
+		if path, err = a.thumbnail(wp, size); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		root = a.getThumbnailDir()
+	}
+
+	// Library entries come from wallpapers.json; never serve a file that
+	// isn't where the app keeps its images
+	if !withinDir(root, path) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFile(w, r, path)
+}
+
+// withinDir reports whether path is inside dir once both are cleaned and
+// symlinks resolved
+func withinDir(dir, path string) bool {
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, resolved)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
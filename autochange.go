@@ -0,0 +1,404 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// recentLimit caps how many applied wallpapers Previous can step back through
+const recentLimit = 50
+
+// Auto-changer states reported in AutoChangeStatus.State
+const (
+	autoChangeOff            = "off"
+	autoChangePaused         = "paused"
+	autoChangePinned         = "pinned"
+	autoChangeScheduled      = "scheduled"
+	autoChangeWaitingForIdle = "waiting_for_idle"
+)
+
+// AutoChangeStatus describes the auto-changer for the UI and menus
+type AutoChangeStatus struct {
+	Enabled bool `json:"enabled"`
+	Paused  bool `json:"paused"`
+	// State is "off", "paused", "pinned", "scheduled" or "waiting_for_idle"
+	State string `json:"state"`
+	// PausedUntil is set while a timed pause is in effect
+	PausedUntil time.Time `json:"paused_until"`
+	// PinnedUntil is set while the current wallpaper is pinned
+	PinnedUntil time.Time `json:"pinned_until"`
+	LastChange  time.Time `json:"last_change"`
+	// NextChange is zero when no change is scheduled
+	NextChange  time.Time `json:"next_change"`
+	CurrentPath string    `json:"current_path"`
+	// CurrentTitle is the display name of the current wallpaper, if known
+	CurrentTitle string `json:"current_title"`
+	// SeasonalTheme is the theme whose keywords are in use, if any
+	SeasonalTheme    string   `json:"seasonal_theme,omitempty"`
+	SeasonalKeywords []string `json:"seasonal_keywords,omitempty"`
+}
+
+// GetAutoChangeStatus returns the current auto-changer status
+func (a *App) GetAutoChangeStatus() AutoChangeStatus {
+	now := a.clock.Now()
+	state := a.schedulerState()
+
+	status := AutoChangeStatus{
+		Enabled:    a.GetSettings().AutoChangeEnabled,
+		Paused:     state.paused(now),
+		LastChange: state.LastChange,
+	}
+	if now.Before(state.PausedUntil) {
+		status.PausedUntil = state.PausedUntil
+	}
+	if state.pinned(now) {
+		status.PinnedUntil = state.PinnedUntil
+	}
+	if theme, ok := a.activeSeasonalTheme(); ok {
+		status.SeasonalTheme = theme.Name
+		status.SeasonalKeywords = normalizeTags(theme.Keywords)
+	}
+	if action, next := nextAction(now, state, a.GetSettings()); action == ActionChange {
+		status.NextChange = now
+	} else if status.Enabled && !status.Paused && a.GetSettings().ChangeIntervalHours > 0 {
+		status.NextChange = next
+	}
+
+	a.mu.Lock()
+	switch {
+	case status.Paused:
+		status.State = autoChangePaused
+	case !status.PinnedUntil.IsZero():
+		status.State = autoChangePinned
+	case status.NextChange.IsZero():
+		status.State = autoChangeOff
+	case a.waitingForIdle:
+		status.State = autoChangeWaitingForIdle
+	default:
+		status.State = autoChangeScheduled
+	}
+	status.CurrentPath = a.data.CurrentPath
+	if wp, ok := a.findByPathLocked(status.CurrentPath); ok {
+		status.CurrentTitle = displayTitle(wp)
+	}
+	a.mu.Unlock()
+	return status
+}
+
+// displayTitle names a wallpaper for people: its display name, else its
+// title from the source, else its filename
+func displayTitle(wp WallpaperInfo) string {
+	switch {
+	case wp.DisplayName != "":
+		return wp.DisplayName
+	case wp.Title != "":
+		return wp.Title
+	}
+	return wp.Filename
+}
+
+// SetAutoChangePaused pauses or resumes automatic changes. The pause is
+// persisted, so it survives restarts.
+func (a *App) SetAutoChangePaused(paused bool) AutoChangeStatus {
+	a.mu.Lock()
+	a.data.Scheduler.Paused = paused
+	a.data.Scheduler.PausedUntil = time.Time{}
+	a.mu.Unlock()
+	a.saveWallpapers()
+
+	return a.emitAutoChangeStatus()
+}
+
+// pauseAutoChangeFor suspends automatic changes for d, after which they
+// resume on their own
+func (a *App) pauseAutoChangeFor(d time.Duration) AutoChangeStatus {
+	a.mu.Lock()
+	a.data.Scheduler.PausedUntil = a.clock.Now().Add(d)
+	a.mu.Unlock()
+	a.saveWallpapers()
+
+	return a.emitAutoChangeStatus()
+}
+
+// PinCurrentForToday keeps the current wallpaper until local midnight:
+// automatic changes are held back for the rest of the day and resume on
+// their own tomorrow. Manual changes still work.
+func (a *App) PinCurrentForToday() (AutoChangeStatus, error) {
+	now := a.clock.Now()
+	return a.PinUntil(time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location()))
+}
+
+// PinUntil holds back automatic changes until the given time, like
+// PinCurrentForToday. Pinning again replaces the previous pin.
+func (a *App) PinUntil(until time.Time) (AutoChangeStatus, error) {
+	if !until.After(a.clock.Now()) {
+		return AutoChangeStatus{}, fmt.Errorf("pin must end in the future")
+	}
+	a.mu.Lock()
+	if a.data.CurrentPath == "" {
+		a.mu.Unlock()
+		return AutoChangeStatus{}, fmt.Errorf("no wallpaper to pin")
+	}
+	a.data.Scheduler.PinnedUntil = until
+	a.mu.Unlock()
+	a.saveWallpapers()
+
+	return a.emitAutoChangeStatus(), nil
+}
+
+// Unpin ends a pin early, letting automatic changes resume
+func (a *App) Unpin() AutoChangeStatus {
+	a.mu.Lock()
+	a.data.Scheduler.PinnedUntil = time.Time{}
+	a.mu.Unlock()
+	a.saveWallpapers()
+
+	return a.emitAutoChangeStatus()
+}
+
+// NextWallpaper moves forward after PreviousWallpaper, or otherwise
+// downloads and applies a new wallpaper. The auto-change timer restarts.
+func (a *App) NextWallpaper() (*WallpaperInfo, error) {
+	if path, ok := a.stepRecent(1); ok {
+		return a.applyRecent(path)
+	}
+
+	info, err := a.downloadAndSet(triggerManual)
+	if err != nil {
+		return nil, err
+	}
+	a.setLastChange(a.clock.Now())
+	return info, nil
+}
+
+// PreviousWallpaper re-applies the wallpaper shown before the current one.
+// The auto-change timer restarts.
+func (a *App) PreviousWallpaper() (*WallpaperInfo, error) {
+	path, ok := a.stepRecent(-1)
+	if !ok {
+		return nil, fmt.Errorf("no previous wallpaper")
+	}
+	return a.applyRecent(path)
+}
+
+// SkipCurrent moves on from the current wallpaper and keeps it out of the
+// way: it is marked skipped, so time-of-day matching won't pick it, and
+// dropped from the Previous/Next history. A new wallpaper is then applied.
+func (a *App) SkipCurrent() (*WallpaperInfo, error) {
+	a.mu.Lock()
+	current := a.data.CurrentPath
+	for i := range a.data.Wallpapers {
+		wp := &a.data.Wallpapers[i]
+		if current != "" && (wp.Filepath == current || wp.ProcessedPath == current) {
+			wp.Skipped = true
+		}
+	}
+	var recent []string
+	for _, path := range a.data.Recent {
+		if path != current {
+			recent = append(recent, path)
+		}
+	}
+	a.data.Recent = recent
+	a.recentPos = -1
+	a.mu.Unlock()
+	a.saveWallpapers()
+
+	info, err := a.downloadAndSet(triggerManual)
+	if err != nil {
+		return nil, err
+	}
+	a.setLastChange(a.clock.Now())
+	return info, nil
+}
+
+// Values of LocalRotation
+const (
+	localRotationShuffle    = "shuffle"
+	localRotationSequential = "sequential"
+)
+
+// errLibraryEmpty is returned when downloads are disabled and the library
+// has nothing to rotate to
+var errLibraryEmpty = errors.New("no other wallpapers in the library to rotate to, and downloads are disabled; import some or turn downloads back on")
+
+// errDownloadsDisabled is returned by anything that would download while
+// DownloadsDisabled is set
+var errDownloadsDisabled = errors.New("downloads are disabled")
+
+// changeFromLibrary applies a wallpaper from the library instead of
+// downloading one, for when DownloadSchedule fills the library or
+// downloads are disabled. In shuffle order the pick favors wallpapers not
+// shown for a while and follows the weather, seasonal and time-of-day
+// preferences; in sequential order it is the next one in the library.
+// Running low on unshown wallpapers starts an extra batch, and an empty
+// library falls back to a download, unless downloads are disabled.
+func (a *App) changeFromLibrary(trigger string) (*WallpaperInfo, error) {
+	if !a.storageAvailable() {
+		a.changeError(trigger, errStorageUnavailable)
+		return nil, errStorageUnavailable
+	}
+
+	fits := a.shapeFilter()
+	a.mu.Lock()
+	var pool []WallpaperInfo
+	unshown, next := 0, 0
+	for _, wp := range a.data.Wallpapers {
+		if wp.TimesSet == 0 {
+			unshown++
+		}
+		if wp.Skipped || !fits(wp.Width, wp.Height) {
+			continue
+		}
+		if wallpaperPath(wp) == a.data.CurrentPath {
+			// Sequential order continues after the current wallpaper
+			next = len(pool)
+			continue
+		}
+		pool = append(pool, wp)
+	}
+	a.mu.Unlock()
+
+	if a.GetSettings().DownloadsDisabled {
+		if len(pool) == 0 {
+			a.changeError(trigger, errLibraryEmpty)
+			return nil, errLibraryEmpty
+		}
+	} else {
+		if unshown < a.GetSettings().DownloadSchedule.MinUnshown {
+			a.startDownloadBatch(a.clock.Now(), fmt.Sprintf("only %d unshown", unshown))
+		}
+		if len(pool) == 0 {
+			fmt.Println("Library is empty, downloading instead")
+			return a.downloadAndSet(trigger)
+		}
+	}
+
+	if a.GetSettings().LocalRotation == localRotationSequential {
+		target := pool[next%len(pool)]
+		return a.setFromLibrary(target, trigger)
+	}
+	target := a.pickFromLibrary(pool)
+	if trigger != triggerManual {
+		var matched bool
+		if target, matched = a.matchTagPreference(target); !matched {
+			target = a.matchLuminancePreference(target)
+		}
+	}
+	return a.setFromLibrary(target, trigger)
+}
+
+// setFromLibrary applies a wallpaper picked by changeFromLibrary
+func (a *App) setFromLibrary(target WallpaperInfo, trigger string) (*WallpaperInfo, error) {
+	if err := a.setWallpaper(wallpaperPath(target), trigger); err != nil {
+		a.recordChangeError(err)
+		return nil, err
+	}
+	a.emitWallpaperChanged(target)
+	return &target, nil
+}
+
+// applyRecent applies a wallpaper reached by Next/Previous navigation
+func (a *App) applyRecent(path string) (*WallpaperInfo, error) {
+	err := a.applyWallpaper(path)
+	a.recordChange(triggerManual, path, err)
+	if err != nil {
+		return nil, err
+	}
+	a.recordShown(path)
+	a.setCurrentPath(path)
+	a.setLastChange(a.clock.Now())
+
+	a.mu.Lock()
+	wp, ok := a.findByPathLocked(path)
+	a.mu.Unlock()
+	if !ok {
+		wp = WallpaperInfo{Filename: path, Filepath: path}
+	}
+	a.emitWallpaperChanged(wp)
+	return &wp, nil
+}
+
+// recordRecent appends a newly applied path to the navigation list. Any
+// entries ahead of the cursor (after going back) are dropped, as in a
+// browser history.
+func (a *App) recordRecent(path string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.recentPos >= 0 && a.recentPos < len(a.data.Recent)-1 {
+		a.data.Recent = a.data.Recent[:a.recentPos+1]
+	}
+	if n := len(a.data.Recent); n == 0 || a.data.Recent[n-1] != path {
+		a.data.Recent = append(a.data.Recent, path)
+	}
+	if len(a.data.Recent) > recentLimit {
+		a.data.Recent = a.data.Recent[len(a.data.Recent)-recentLimit:]
+	}
+	a.recentPos = -1
+}
+
+// stepRecent moves the navigation cursor by delta, skipping files that no
+// longer exist, and returns the path it lands on
+func (a *App) stepRecent(delta int) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pos := a.recentPos
+	if pos < 0 {
+		pos = len(a.data.Recent) - 1
+	}
+	for i := pos + delta; i >= 0 && i < len(a.data.Recent); i += delta {
+		if _, err := os.Stat(a.data.Recent[i]); err == nil {
+			a.recentPos = i
+			return a.data.Recent[i], true
+		}
+	}
+	return "", false
+}
+
+// findByPathLocked finds the wallpaper whose original or processed file is
+// path. The caller must hold a.mu.
+func (a *App) findByPathLocked(path string) (WallpaperInfo, bool) {
+	if path == "" {
+		return WallpaperInfo{}, false
+	}
+	for _, wp := range a.data.Wallpapers {
+		if wp.Filepath == path || wp.ProcessedPath == path {
+			return wp, true
+		}
+	}
+	return WallpaperInfo{}, false
+}
+
+// emitAutoChangeStatus publishes autoChangeStatusChanged and refreshes the
+// native and tray menus, returning the status sent
+func (a *App) emitAutoChangeStatus() AutoChangeStatus {
+	status := a.GetAutoChangeStatus()
+	a.emit("autoChangeStatusChanged", status)
+	a.refreshMenu()
+	a.refreshTray()
+	a.writeStatus()
+	return status
+}
+
+// emitWallpaperDownloaded publishes wallpaperDownloaded for a download
+// just added to the library, before it is applied, so the gallery can show
+// it even if applying is slow or fails
+func (a *App) emitWallpaperDownloaded(info WallpaperInfo) {
+	info.LocalURL = wallpaperURL(info.ID)
+	a.emit("wallpaperDownloaded", info)
+}
+
+// emitWallpaperChanged publishes wallpaperChanged, refreshes the native and
+// tray menus and the status file, and signals D-Bus listeners
+func (a *App) emitWallpaperChanged(info WallpaperInfo) {
+	a.emit("wallpaperChanged", info)
+	a.refreshMenu()
+	a.refreshTray()
+	a.recordChangeSuccess()
+	a.writeStatus()
+	a.notifyDBusChanged(info)
+}
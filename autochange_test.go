@@ -0,0 +1,58 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestAutoChangerStartStop starts and stops the auto-changer many times,
+// directly and through restartAutoChanger, and checks that every loop has
+// exited afterwards and no goroutines were left behind
+func TestAutoChangerStartStop(t *testing.T) {
+	a := newTestApp(t)
+	a.clock = realClock{}
+	a.runner = &recordingRunner{}
+	a.changeSettings(func(s *AppSettings) {
+		s.AutoChangeEnabled = true
+		s.ChangeIntervalHours = 24
+	})
+	a.setLastChange(time.Now())
+
+	before := runtime.NumGoroutine()
+	for i := range 50 {
+		a.startAutoChanger()
+		// Starting twice must not start a second loop
+		a.startAutoChanger()
+		if i%2 == 0 {
+			a.restartAutoChanger()
+		}
+		a.stopAutoChanger()
+		if a.autoChangerRunning.Load() {
+			t.Fatalf("auto-changer still running after stop %d", i)
+		}
+	}
+	// Stopping a stopped auto-changer is harmless
+	a.stopAutoChanger()
+
+	done := make(chan struct{})
+	go func() {
+		a.tasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("auto-changer tasks never finished")
+	}
+
+	// Goroutines that are exiting may take a moment to be gone
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		buf := make([]byte, 1<<16)
+		t.Errorf("%d goroutines before, %d after:\n%s", before, after, buf[:runtime.Stack(buf, true)])
+	}
+}
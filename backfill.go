@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RepairReport summarises what RepairLibrary changed
+type RepairReport struct {
+	Checked int `json:"checked"`
+	Updated int `json:"updated"`
+	Removed int `json:"removed"`
+	Renamed int `json:"renamed"`
+}
+
+// backfillBatch is how many wallpapers backfill measures between saves,
+// so an interrupted run keeps most of its work
+const backfillBatch = 20
+
+// BackfillProgress is the payload of the "backfillProgress" event
+type BackfillProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// backfillImageMetadata measures dimensions, luminance and format and
+// computes hashes for wallpapers saved before those fields were recorded.
+// It runs in the background at startup.
+func (a *App) backfillImageMetadata() {
+	a.backfill(func(wp WallpaperInfo) bool {
+		return wp.Width == 0 || !wp.LuminanceMeasured || wp.PerceptualHash == "" || wp.Hash == "" || wp.MIMEType == ""
+	})
+}
+
+// RepairLibrary rescans the library: entries whose files are gone are
+// dropped, and missing metadata (palette, EXIF, measurements, titles) is
+// filled in.
+// With renameFiles, files are also renamed to match FilenameTemplate.
+func (a *App) RepairLibrary(renameFiles bool) (RepairReport, error) {
+	if !a.storageAvailable() {
+		return RepairReport{}, errStorageUnavailable
+	}
+
+	var report RepairReport
+	a.mu.Lock()
+	var kept []WallpaperInfo
+	for _, wp := range a.data.Wallpapers {
+		if _, err := os.Stat(wp.Filepath); err != nil {
+			report.Removed++
+			continue
+		}
+		kept = append(kept, wp)
+	}
+	a.data.Wallpapers = kept
+	report.Checked = len(kept)
+	a.mu.Unlock()
+
+	report.Updated = a.backfill(func(wp WallpaperInfo) bool {
+		return wp.Width == 0 || !wp.LuminanceMeasured || wp.PerceptualHash == "" || wp.Hash == "" ||
+			wp.MIMEType == "" || wp.Title == "" || len(wp.Colors) == 0
+	})
+	if renameFiles && a.GetSettings().FilenameTemplate != "" {
+		report.Renamed = a.renameLibraryFiles()
+	}
+	if report.Updated == 0 && (report.Removed > 0 || report.Renamed > 0) {
+		a.saveWallpapers()
+	}
+
+	a.emit("wallpapersUpdated", a.GetWallpapers())
+	return report, nil
+}
+
+// backfill fills in metadata for the wallpapers selected by pending and
+// returns how many were updated. Files are read without holding the lock,
+// and the library is saved after every backfillBatch wallpapers, with a
+// "backfillProgress" event, so a restart picks up where it stopped.
+// Shutting down stops it between wallpapers.
+func (a *App) backfill(pending func(WallpaperInfo) bool) int {
+	a.mu.Lock()
+	var todo []WallpaperInfo
+	for _, wp := range a.data.Wallpapers {
+		if pending(wp) {
+			todo = append(todo, wp)
+		}
+	}
+	a.mu.Unlock()
+
+	if len(todo) == 0 {
+		return 0
+	}
+
+	done := 0
+	for done < len(todo) && a.lifetime().Err() == nil {
+		batch := todo[done:min(done+backfillBatch, len(todo))]
+		updated := make(map[string]WallpaperInfo, len(batch))
+		for _, wp := range batch {
+			if a.lifetime().Err() != nil {
+				break
+			}
+			wp = measureWallpaper(wp)
+			if wp.Title == "" {
+				wp.Title = a.wallpaperTitle(wp)
+			}
+			updated[wp.ID] = wp
+		}
+		a.applyBackfill(updated)
+		done += len(updated)
+		a.emit("backfillProgress", BackfillProgress{Done: done, Total: len(todo)})
+	}
+	return done
+}
+
+// measureWallpaper fills in the metadata a wallpaper is missing from its
+// file
+func measureWallpaper(wp WallpaperInfo) WallpaperInfo {
+	if wp.Hash == "" {
+		if hash, err := fileHash(wp.Filepath); err == nil {
+			wp.Hash = hash
+		}
+	}
+	if wp.MIMEType == "" {
+		if contentType, err := sniffImageType(wp.Filepath); err == nil {
+			wp.MIMEType = contentType
+		}
+	}
+	if wp.Width == 0 || !wp.LuminanceMeasured || wp.PerceptualHash == "" || len(wp.Colors) == 0 {
+		analysis, err := analyzeImage(wp.Filepath)
+		if err != nil {
+			fmt.Printf("Failed to analyze %s: %v\n", wp.Filename, err)
+		} else {
+			wp.Width = analysis.Width
+			wp.Height = analysis.Height
+			wp.Luminance = analysis.Luminance
+			wp.LuminanceMeasured = true
+			wp.PerceptualHash = analysis.PerceptualHash
+			wp.Colors = analysis.Colors
+		}
+	}
+	if wp.CapturedAt.IsZero() && wp.CameraModel == "" {
+		if exif, err := readExif(wp.Filepath); err == nil {
+			exif.apply(&wp, true)
+		}
+	}
+	return wp
+}
+
+// applyBackfill copies measured metadata into the library and saves it.
+// Only the measured fields are copied, so edits made meanwhile survive,
+// and GPS positions only when StripMetadata is off.
+func (a *App) applyBackfill(updated map[string]WallpaperInfo) {
+	withLocation := !a.GetSettings().StripMetadata
+	a.mu.Lock()
+	for i := range a.data.Wallpapers {
+		if wp, ok := updated[a.data.Wallpapers[i].ID]; ok {
+			a.data.Wallpapers[i].Width = wp.Width
+			a.data.Wallpapers[i].Height = wp.Height
+			a.data.Wallpapers[i].MIMEType = wp.MIMEType
+			if a.data.Wallpapers[i].Title == "" {
+				a.data.Wallpapers[i].Title = wp.Title
+			}
+			a.data.Wallpapers[i].Hash = wp.Hash
+			a.data.Wallpapers[i].Luminance = wp.Luminance
+			a.data.Wallpapers[i].LuminanceMeasured = wp.LuminanceMeasured
+			a.data.Wallpapers[i].PerceptualHash = wp.PerceptualHash
+			a.data.Wallpapers[i].Colors = wp.Colors
+			a.data.Wallpapers[i].CapturedAt = wp.CapturedAt
+			a.data.Wallpapers[i].CameraModel = wp.CameraModel
+			if withLocation {
+				a.data.Wallpapers[i].GPSLatitude = wp.GPSLatitude
+				a.data.Wallpapers[i].GPSLongitude = wp.GPSLongitude
+			}
+		}
+	}
+	a.mu.Unlock()
+
+	a.saveWallpapers()
+}
+
+// renameLibraryFiles renames every wallpaper file, and its processed copy,
+// to the name FilenameTemplate gives it, and returns how many changed. The
+// lock is held throughout, so no one sees a path that is about to change;
+// the current path and Previous/Next history are updated to match.
+// Thumbnails are keyed by ID and need no change.
+func (a *App) renameLibraryFiles() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	moved := make(map[string]string)
+	renamed := 0
+	for i := range a.data.Wallpapers {
+		wp := &a.data.Wallpapers[i]
+		dir := filepath.Dir(wp.Filepath)
+		base := strings.TrimSuffix(filepath.Base(wp.SourceURL), filepath.Ext(wp.SourceURL))
+		title := wp.DisplayName
+		if title == "" {
+			title = base
+		}
+		name := expandFilenameTemplate(a.GetSettings().FilenameTemplate, filenameTokens{
+			Date:     wp.DownloadDate,
+			Source:   sourceToken(wp.SourceURL),
+			Title:    title,
+			ID:       wp.ID[:min(8, len(wp.ID))],
+			Ext:      filepath.Ext(wp.Filepath),
+			Original: base,
+		})
+		if matchesFilename(wp.Filename, name) {
+			continue
+		}
+
+		newPath, err := claimFilename(dir, name)
+		if err == nil {
+			if err = os.Rename(wp.Filepath, newPath); err != nil {
+				os.Remove(newPath)
+			}
+		}
+		if err != nil {
+			fmt.Printf("Failed to rename %s: %v\n", wp.Filename, err)
+			continue
+		}
+		newProcessed := ""
+		if wp.ProcessedPath != "" {
+			newProcessed = processedPath(newPath, "blur")
+			if err := os.Rename(wp.ProcessedPath, newProcessed); err != nil {
+				// Keep the pair consistent: undo the original's rename
+				os.Rename(newPath, wp.Filepath)
+				fmt.Printf("Failed to rename %s: %v\n", filepath.Base(wp.ProcessedPath), err)
+				continue
+			}
+			moved[wp.ProcessedPath] = newProcessed
+		}
+
+		moved[wp.Filepath] = newPath
+		wp.Filepath = newPath
+		wp.Filename = filepath.Base(newPath)
+		wp.ProcessedPath = newProcessed
+		renamed++
+	}
+
+	if to, ok := moved[a.data.CurrentPath]; ok {
+		a.data.CurrentPath = to
+	}
+	for i, p := range a.data.Recent {
+		if to, ok := moved[p]; ok {
+			a.data.Recent[i] = to
+		}
+	}
+	return renamed
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trashDirName is the folder inside the wallpaper directory that deleted
+// files are moved to until they are purged
+const trashDirName = ".trash"
+
+// CleanupReport summarises what CleanupCaches removed
+type CleanupReport struct {
+	ThumbnailsRemoved int   `json:"thumbnails_removed"`
+	TrashRemoved      int   `json:"trash_removed"`
+	OriginalsRemoved  int   `json:"originals_removed"`
+	BytesReclaimed    int64 `json:"bytes_reclaimed"`
+}
+
+// getThumbnailDir returns the cache directory for generated thumbnails
+func (a *App) getThumbnailDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	dir := filepath.Join(cacheDir, "WallpaperEngine", "thumbnails")
+	os.MkdirAll(dir, os.ModePerm)
+	return dir
+}
+
+// thumbnailPath returns where the thumbnail of a wallpaper at a given size
+// is cached. Names start with the wallpaper ID so orphans can be found.
+func (a *App) thumbnailPath(id string, size int) string {
+	return filepath.Join(a.getThumbnailDir(), fmt.Sprintf("%s_%d.jpg", id, size))
+}
+
+// thumbnail returns the path of a cached JPEG thumbnail for wp whose
+// longest side is at most size pixels, generating it if needed
+func (a *App) thumbnail(wp WallpaperInfo, size int) (string, error) {
+	path := a.thumbnailPath(wp.ID, size)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	img, err := decodeImage(wp.Filepath)
+	if err != nil {
+		return "", err
+	}
+
+	tmp := path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	err = encodeThumbnail(out, img, size)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write thumbnail: %v", err)
+	}
+	return path, nil
+}
+
+// GetThumbnail returns a cached thumbnail of a wallpaper as a data URI
+func (a *App) GetThumbnail(id string, size int) (string, error) {
+	if size <= 0 {
+		return "", fmt.Errorf("invalid thumbnail size: %d", size)
+	}
+	wp, ok := a.findWallpaper(id)
+	if !ok {
+		return "", fmt.Errorf("wallpaper not found: %s", id)
+	}
+
+	path, err := a.thumbnail(wp, size)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read thumbnail: %v", err)
+	}
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// trashWallpaperFiles moves a wallpaper's files to the trash, where they
+// are kept for TrashRetentionDays. With retention disabled they are
+// deleted straight away.
+func (a *App) trashWallpaperFiles(info WallpaperInfo) {
+	if a.GetSettings().TrashRetentionDays <= 0 {
+		removeWallpaperFiles(info)
+		return
+	}
+
+	dir := filepath.Join(a.getWallpaperDir(), trashDirName)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		removeWallpaperFiles(info)
+		return
+	}
+
+	// The prefix records when the file was trashed, since a rename keeps
+	// the original modification time
+	prefix := strconv.FormatInt(time.Now().Unix(), 10) + "_"
+	for _, path := range []string{info.Filepath, info.ProcessedPath, info.OriginalPath} {
+		if path == "" {
+			continue
+		}
+		if err := os.Rename(path, filepath.Join(dir, prefix+filepath.Base(path))); err != nil {
+			os.Remove(path)
+		}
+	}
+}
+
+// CleanupCaches removes thumbnails and kept originals of wallpapers no
+// longer in the library and purges trashed files older than
+// TrashRetentionDays
+func (a *App) CleanupCaches() (CleanupReport, error) {
+	var report CleanupReport
+
+	a.mu.Lock()
+	known := make(map[string]bool, len(a.data.Wallpapers))
+	for _, wp := range a.data.Wallpapers {
+		known[wp.ID] = true
+	}
+	a.mu.Unlock()
+
+	thumbs, _ := filepath.Glob(filepath.Join(a.getThumbnailDir(), "*"))
+	for _, path := range thumbs {
+		id, _, _ := strings.Cut(filepath.Base(path), "_")
+		if known[id] {
+			continue
+		}
+		if size, ok := removeCounted(path); ok {
+			report.ThumbnailsRemoved++
+			report.BytesReclaimed += size
+		}
+	}
+
+	// The trash lives with the wallpapers, so it can't be purged while the
+	// drive is away
+	if !a.storageAvailable() {
+		return report, errStorageUnavailable
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -a.GetSettings().TrashRetentionDays)
+	trashed, _ := filepath.Glob(filepath.Join(a.getWallpaperDir(), trashDirName, "*"))
+	for _, path := range trashed {
+		stamp, _, _ := strings.Cut(filepath.Base(path), "_")
+		secs, err := strconv.ParseInt(stamp, 10, 64)
+		if err == nil && time.Unix(secs, 0).After(cutoff) {
+			continue
+		}
+		if size, ok := removeCounted(path); ok {
+			report.TrashRemoved++
+			report.BytesReclaimed += size
+		}
+	}
+	a.cleanupOriginals(&report)
+
+	if report.ThumbnailsRemoved > 0 || report.TrashRemoved > 0 || report.OriginalsRemoved > 0 {
+		fmt.Printf("Cleanup removed %d thumbnails, %d trashed files and %d orphaned originals (%d bytes)\n",
+			report.ThumbnailsRemoved, report.TrashRemoved, report.OriginalsRemoved, report.BytesReclaimed)
+	}
+	return report, nil
+}
+
+// removeCounted deletes a file and returns the size it occupied
+func removeCounted(path string) (int64, bool) {
+	stat, err := os.Stat(path)
+	if err != nil || stat.IsDir() {
+		return 0, false
+	}
+	if err := os.Remove(path); err != nil {
+		return 0, false
+	}
+	return stat.Size(), true
+}
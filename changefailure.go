@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// changeFailedInterval is how long an identical auto-change failure goes
+// unreported after its "wallpaperChangeFailed" event
+const changeFailedInterval = time.Hour
+
+// Failure classes of a SourceFailure
+const (
+	failureHTTP        = "http"
+	failureTimeout     = "timeout"
+	failureNetwork     = "network"
+	failureRateLimited = "rate_limited"
+	// failureNothingNew is a source with only images already in the library
+	failureNothingNew = "nothing_new"
+	failureOther      = "other"
+)
+
+// SourceFailure is why one source gave no wallpaper. StatusCode is set
+// for the http class.
+type SourceFailure struct {
+	Source     string `json:"source"`
+	Class      string `json:"class"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error"`
+}
+
+// ChangeFailure is the payload of the "wallpaperChangeFailed" event and
+// what GetLastError returns. Sources lists each source's failure when
+// downloading was tried; LibraryFallback is set when the change came from
+// the library instead. Remedy suggests what the user can do about it.
+type ChangeFailure struct {
+	Time            time.Time       `json:"time"`
+	Trigger         string          `json:"trigger"`
+	Error           string          `json:"error"`
+	Sources         []SourceFailure `json:"sources,omitempty"`
+	LibraryFallback bool            `json:"library_fallback"`
+	Remedy          string          `json:"remedy"`
+}
+
+// GetLastError returns the auto-changer's most recent failure, or nil if
+// it has succeeded since
+func (a *App) GetLastError() *ChangeFailure {
+	a.status.mu.Lock()
+	defer a.status.mu.Unlock()
+	if a.status.lastFailure == nil {
+		return nil
+	}
+	failure := *a.status.lastFailure
+	return &failure
+}
+
+// reportChangeFailure keeps a failed auto-change for GetLastError and
+// emits "wallpaperChangeFailed". The same failure again within
+// changeFailedInterval is kept but not emitted. Canceled downloads,
+// shutdown and a change already in progress aren't failures.
+func (a *App) reportChangeFailure(trigger string, err error, libraryFallback bool) {
+	if errors.Is(err, errDownloadCanceled) || errors.Is(err, errShuttingDown) || errors.Is(err, errChangeInProgress) {
+		return
+	}
+	failure := ChangeFailure{
+		Time:            a.clock.Now(),
+		Trigger:         trigger,
+		Error:           err.Error(),
+		LibraryFallback: libraryFallback,
+	}
+	var failed *sourcesFailedError
+	if errors.As(err, &failed) {
+		for _, f := range failed.failures {
+			failure.Sources = append(failure.Sources, classifyFailure(f.source, f.err))
+		}
+	}
+	failure.Remedy = failureRemedy(err, failure.Sources)
+	signature := failure.signature()
+
+	a.status.mu.Lock()
+	a.status.lastFailure = &failure
+	repeated := signature == a.status.failureSignature && failure.Time.Sub(a.status.failureEmittedAt) < changeFailedInterval
+	if !repeated {
+		a.status.failureSignature = signature
+		a.status.failureEmittedAt = failure.Time
+	}
+	a.status.mu.Unlock()
+
+	if !repeated {
+		a.emit("wallpaperChangeFailed", failure)
+	}
+}
+
+// signature identifies a failure for rate limiting: its sources and their
+// classes, or the error when no source was tried. Details that vary
+// between attempts, such as filenames, are left out.
+func (f ChangeFailure) signature() string {
+	if len(f.Sources) == 0 {
+		return f.Error
+	}
+	parts := make([]string, len(f.Sources))
+	for i, s := range f.Sources {
+		parts[i] = fmt.Sprintf("%s %s %d", s.Source, s.Class, s.StatusCode)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// classifyFailure sorts a source's error into a failure class
+func classifyFailure(source string, err error) SourceFailure {
+	failure := SourceFailure{Source: source, Class: failureOther, Error: err.Error()}
+	var status httpStatusError
+	var netErr net.Error
+	var urlErr *url.Error
+	switch {
+	case errors.As(err, &status):
+		failure.Class = failureHTTP
+		failure.StatusCode = status.code
+	case errors.Is(err, errRateLimited):
+		failure.Class = failureRateLimited
+	case errors.Is(err, errNotModified) || errors.Is(err, errDuplicate):
+		failure.Class = failureNothingNew
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		failure.Class = failureTimeout
+	case errors.As(err, &netErr) || errors.As(err, &urlErr):
+		failure.Class = failureNetwork
+	}
+	return failure
+}
+
+// failureRemedy suggests a fix for a failed change, judging by what every
+// source had in common
+func failureRemedy(err error, sources []SourceFailure) string {
+	switch {
+	case errors.Is(err, errStorageUnavailable):
+		return "the wallpaper folder is unavailable — reconnect its drive or choose another folder"
+	case errors.Is(err, errLibraryEmpty):
+		return "there is nothing in the library to rotate to — import wallpapers or turn downloads back on"
+	case errors.Is(err, errFileNotFound):
+		return "the wallpaper file is missing — remove it from the library"
+	case errors.Is(err, errNotAnImage):
+		return "the wallpaper file is damaged — remove it from the library"
+	case len(sources) == 0:
+		var failed *sourcesFailedError
+		if errors.As(err, &failed) {
+			return "no download sources are configured — add one in settings"
+		}
+		return "see the change history for details"
+	}
+
+	all := func(match func(SourceFailure) bool) bool {
+		return !slices.ContainsFunc(sources, func(s SourceFailure) bool { return !match(s) })
+	}
+	code := sources[0].StatusCode
+	switch {
+	case all(func(s SourceFailure) bool { return s.Class == failureHTTP && s.StatusCode == code }):
+		switch {
+		case code == 404 || code == 410:
+			return fmt.Sprintf("all sources returned %d — check your source URLs", code)
+		case code == 401 || code == 403:
+			return fmt.Sprintf("all sources refused access (HTTP %d) — check their credentials or API keys", code)
+		case code == 429:
+			return "all sources are throttling requests — change wallpapers less often"
+		case code >= 500:
+			return fmt.Sprintf("all sources are failing (HTTP %d) — try again later", code)
+		}
+		return fmt.Sprintf("all sources returned HTTP %d — check your source URLs", code)
+	case all(func(s SourceFailure) bool { return s.Class == failureNetwork || s.Class == failureTimeout }):
+		return "no source could be reached — check your internet connection or proxy"
+	case all(func(s SourceFailure) bool { return s.Class == failureRateLimited }):
+		return "every source is at its rate limit — change wallpapers less often"
+	case all(func(s SourceFailure) bool { return s.Class == failureNothingNew }):
+		return "the sources have nothing new — add more sources or increase the change interval"
+	}
+	return "several sources failed — see each source's error"
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// changeNowFlag downloads and applies a new wallpaper
+	changeNowFlag = "--change-now"
+	// nextFlag moves forward in the history, or downloads a new wallpaper
+	nextFlag = "--next"
+	// instanceFile holds the process ID of the running GUI instance
+	instanceFile = "instance.pid"
+)
+
+// cliOptions are the command-line flags that run one action without the GUI
+type cliOptions struct {
+	changeNow bool
+	next      bool
+	list      bool
+	status    bool
+	version   bool
+	set       string
+	configDir string
+}
+
+// headless reports whether an action was requested, in which case the app
+// does its work and exits instead of opening a window
+func (o cliOptions) headless() bool {
+	return o.changeNow || o.next || o.list || o.status || o.version || o.set != ""
+}
+
+// readOnly reports whether the requested action only reads state, so it
+// can run next to a GUI instance instead of being handed to it
+func (o cliOptions) readOnly() bool {
+	return o.list || o.status || o.version
+}
+
+// newFlagSet returns the parser for the command-line flags. Go's flag
+// package accepts both -next and --next.
+func newFlagSet(opts *cliOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("wallset", flag.ContinueOnError)
+	fs.BoolVar(&opts.changeNow, strings.TrimPrefix(changeNowFlag, "--"), false, "download and apply a new wallpaper, then exit")
+	fs.BoolVar(&opts.next, strings.TrimPrefix(nextFlag, "--"), false, "apply the next wallpaper, then exit")
+	fs.BoolVar(&opts.list, "list", false, "print the wallpaper library, then exit")
+	fs.BoolVar(&opts.version, "version", false, "print the app version, then exit")
+	fs.BoolVar(&opts.status, "status", false, "print the status document (as in status.json), then exit")
+	fs.StringVar(&opts.set, strings.TrimPrefix(setFlag, "--"), "", "add the image at `path` to the library and apply it, then exit")
+	fs.StringVar(&opts.configDir, "config-dir", "", "read settings and library data from `dir`")
+	return fs
+}
+
+// parseCLI parses the command line. Arguments after the flags, such as
+// wallset:// links, are left for the GUI, and the process serial number
+// older macOS launchers add is skipped. A request for help returns
+// flag.ErrHelp; any other flag it doesn't understand is an error.
+func parseCLI(args []string) (cliOptions, error) {
+	var opts cliOptions
+	fs := newFlagSet(&opts)
+	fs.SetOutput(io.Discard)
+	args = slices.DeleteFunc(slices.Clone(args), func(arg string) bool {
+		return strings.HasPrefix(arg, "-psn_")
+	})
+	if err := fs.Parse(args); err != nil {
+		return cliOptions{}, err
+	}
+	if opts.configDir != "" {
+		dir, err := filepath.Abs(opts.configDir)
+		if err != nil {
+			return cliOptions{}, fmt.Errorf("invalid -config-dir: %v", err)
+		}
+		opts.configDir = dir
+	}
+	return opts, nil
+}
+
+// printUsage describes the command-line flags
+func printUsage(w io.Writer) {
+	var opts cliOptions
+	fs := newFlagSet(&opts)
+	fs.SetOutput(w)
+	fmt.Fprintln(w, "Usage: wallset [flags]")
+	fmt.Fprintln(w, "Without an action flag the app opens its window.")
+	fs.PrintDefaults()
+}
+
+// runCLI performs the action in opts without starting Wails, so it works
+// from cron jobs and on machines without a display. It loads and saves the
+// same files as the GUI and returns the process exit code.
+func runCLI(opts cliOptions) int {
+	if opts.version {
+		fmt.Println(version)
+		return 0
+	}
+	if opts.status {
+		return printStatus(opts.configDir)
+	}
+
+	a := NewApp()
+	a.configDir = opts.configDir
+	a.appCtx, a.cancel = context.WithCancel(context.Background())
+	a.loadSettings()
+	a.loadWallpapers()
+
+	// Listing may run alongside the GUI, so it must not save anything
+	if opts.list {
+		a.printLibrary(os.Stdout)
+		return 0
+	}
+	a.loadConditionalCache()
+	a.loadSourceState()
+	defer a.shutdown(context.Background())
+
+	var info *WallpaperInfo
+	var err error
+	switch {
+	case opts.set != "":
+		info, err = a.importAndApply(opts.set)
+	case opts.next:
+		info, err = a.NextWallpaper()
+	case opts.changeNow:
+		info, err = a.DownloadAndSetWallpaper()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wallset: %v\n", err)
+		return 1
+	}
+	fmt.Println(wallpaperPath(*info))
+	return 0
+}
+
+// printLibrary writes one line per wallpaper: an asterisk for the current
+// one, then its ID, dimensions and path
+func (a *App) printLibrary(w io.Writer) {
+	a.mu.Lock()
+	current := a.data.CurrentPath
+	a.mu.Unlock()
+	for _, wp := range a.GetWallpapers() {
+		mark := " "
+		if wallpaperPath(wp) == current || wp.Filepath == current {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s %s\t%dx%d\t%s\n", mark, wp.ID, wp.Width, wp.Height, wp.Filepath)
+	}
+}
+
+// markInstance records this process as the running GUI instance, so the
+// command line hands actions to it instead of working behind its back
+func (a *App) markInstance() {
+	os.WriteFile(a.getConfigPath(instanceFile), []byte(strconv.Itoa(os.Getpid())), 0644)
+}
+
+// clearInstance removes the file written by markInstance
+func (a *App) clearInstance() {
+	path := a.getConfigPath(instanceFile)
+	if data, err := os.ReadFile(path); err == nil && strings.TrimSpace(string(data)) == strconv.Itoa(os.Getpid()) {
+		os.Remove(path)
+	}
+}
+
+// instanceRunning reports whether a GUI instance using configDir is alive.
+// Actions are then passed to it through the single-instance lock, since it
+// would overwrite changes saved by another process.
+func instanceRunning(configDir string) bool {
+	a := &App{configDir: configDir}
+	data, err := os.ReadFile(a.getConfigPath(instanceFile))
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 || pid == os.Getpid() {
+		return false
+	}
+	return processAlive(pid)
+}
+
+// processAlive reports whether a process with the given ID exists. On
+// Windows FindProcess already fails for processes that have exited.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer p.Release()
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"testing"
+)
+
+func TestParseCLI(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    cliOptions
+		wantErr error
+	}{
+		{args: nil},
+		{args: []string{"--next"}, want: cliOptions{next: true}},
+		{args: []string{"wallset://set?url=x"}},
+		{args: []string{"-psn_0_12345"}},
+		{args: []string{"--help"}, wantErr: flag.ErrHelp},
+		{args: []string{"-h"}, wantErr: flag.ErrHelp},
+	}
+	for _, tt := range tests {
+		got, err := parseCLI(tt.args)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("parseCLI(%q) = %+v, %v; want %+v, %v", tt.args, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	for _, args := range [][]string{{"--no-such-flag"}, {"--set"}} {
+		if _, err := parseCLI(args); err == nil || errors.Is(err, flag.ErrHelp) {
+			t.Errorf("parseCLI(%q) error = %v, want a parse error", args, err)
+		}
+	}
+}
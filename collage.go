@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Layouts accepted by GenerateCollage
+const (
+	layoutGrid2x2 = "grid2x2"
+	layoutGrid3x3 = "grid3x3"
+	layoutStrip   = "strip"
+)
+
+// Strip collages hold between minStripCells and maxStripCells images
+const (
+	minStripCells = 4
+	maxStripCells = 9
+)
+
+// collageTag is added to every generated collage
+const collageTag = "collage"
+
+// collageGrid returns the columns and rows of a layout for n requested
+// images. Strips are one row, sized to n within the allowed range.
+func collageGrid(layout string, n int) (int, int, error) {
+	switch layout {
+	case layoutGrid2x2:
+		return 2, 2, nil
+	case layoutGrid3x3:
+		return 3, 3, nil
+	case layoutStrip:
+		return min(max(n, minStripCells), maxStripCells), 1, nil
+	}
+	return 0, 0, fmt.Errorf("unknown collage layout %q (use %s, %s or %s)", layout, layoutGrid2x2, layoutGrid3x3, layoutStrip)
+}
+
+// collageCell returns the rectangle of cell i in a cols x rows grid on a
+// width x height canvas, leaving gutter pixels between and around cells
+func collageCell(i, cols, rows, width, height, gutter int) image.Rectangle {
+	col, row := i%cols, i/cols
+	cellW := (width - gutter*(cols+1)) / cols
+	cellH := (height - gutter*(rows+1)) / rows
+	x := gutter + col*(cellW+gutter)
+	y := gutter + row*(cellH+gutter)
+	return image.Rect(x, y, x+cellW, y+cellH)
+}
+
+// collageSources resolves ids to wallpapers and tops them up to cells,
+// picking randomly from favorites first and then the rest of the library
+func (a *App) collageSources(ids []string, cells int) ([]WallpaperInfo, error) {
+	if len(ids) > cells {
+		return nil, fmt.Errorf("layout has %d cells but %d wallpapers were given", cells, len(ids))
+	}
+
+	chosen := make(map[string]bool)
+	var sources []WallpaperInfo
+	for _, id := range ids {
+		wp, ok := a.findWallpaper(id)
+		if !ok {
+			return nil, fmt.Errorf("wallpaper not found: %s", id)
+		}
+		sources = append(sources, wp)
+		chosen[id] = true
+	}
+
+	var favorites, others []WallpaperInfo
+	for _, wp := range a.GetWallpapers() {
+		switch {
+		case chosen[wp.ID] || hasTag(wp, collageTag):
+		case wp.Favorite:
+			favorites = append(favorites, wp)
+		default:
+			others = append(others, wp)
+		}
+	}
+	for _, pool := range [][]WallpaperInfo{favorites, others} {
+		rand.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+		for _, wp := range pool {
+			if len(sources) == cells {
+				break
+			}
+			sources = append(sources, wp)
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no wallpapers available for a collage")
+	}
+	return sources, nil
+}
+
+// hasTag reports whether a wallpaper carries tag
+func hasTag(wp WallpaperInfo, tag string) bool {
+	for _, t := range wp.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateCollage tiles library images into one wallpaper at the screen's
+// resolution and adds it to the library tagged "collage". Layouts are
+// grid2x2, grid3x3 and strip (one row of 4-9). Missing cells are filled
+// from favorites, then the rest of the library. Images are decoded and
+// scaled one at a time to keep memory use down.
+func (a *App) GenerateCollage(ids []string, layout string) (*WallpaperInfo, error) {
+	cols, rows, err := collageGrid(layout, len(ids))
+	if err != nil {
+		return nil, err
+	}
+	background, err := parseCollageBackground(a.GetSettings().CollageBackground)
+	if err != nil {
+		return nil, err
+	}
+	if !a.storageAvailable() {
+		return nil, errStorageUnavailable
+	}
+
+	sources, err := a.collageSources(ids, cols*rows)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height, ok := a.primaryScreenSize()
+	if !ok {
+		width, height = defaultTemplateWidth, defaultTemplateHeight
+	}
+	gutter := a.GetSettings().CollageGutter
+	if cell := collageCell(0, cols, rows, width, height, gutter); cell.Dx() < 1 || cell.Dy() < 1 {
+		return nil, fmt.Errorf("gutter of %dpx leaves no room for the images", gutter)
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	for i, wp := range sources {
+		img, err := decodeImage(wp.Filepath)
+		if err != nil {
+			fmt.Printf("Skipping %s in collage: %v\n", wp.Filename, err)
+			continue
+		}
+		cell := collageCell(i, cols, rows, width, height, gutter)
+		draw.Draw(canvas, cell, scaleToCover(img, cell.Dx(), cell.Dy()), image.Point{}, draw.Src)
+	}
+
+	id := generateID()
+	path, err := claimFilename(a.getWallpaperDir(), generatedFilename("collage", id, ".jpg"))
+	if err != nil {
+		return nil, err
+	}
+	if err := saveJPEG(canvas, path); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	canvas = nil
+
+	info, err := a.inspectWallpaper(path)
+	if err != nil {
+		removeWallpaperFiles(WallpaperInfo{Filepath: path})
+		return nil, err
+	}
+	info.ID = id
+	info.Tags = []string{collageTag}
+	info.Title = a.wallpaperTitle(*info)
+	if err := a.addWallpaper(*info); err != nil {
+		removeWallpaperFiles(*info)
+		return nil, err
+	}
+	a.emit("wallpapersUpdated", a.GetWallpapers())
+
+	if a.GetSettings().SetCollageAsWallpaper {
+		if err := a.setWallpaper(info.Filepath, triggerManual); err != nil {
+			return info, err
+		}
+		a.emitWallpaperChanged(*info)
+	}
+	return info, nil
+}
+
+// parseCollageBackground parses the "#rrggbb" background, defaulting to black
+func parseCollageBackground(hex string) (color.RGBA, error) {
+	if hex == "" {
+		return color.RGBA{A: 255}, nil
+	}
+	r, g, b, ok := parseHexColor(hex)
+	if !ok {
+		return color.RGBA{}, fmt.Errorf("collage background must be #rrggbb")
+	}
+	return color.RGBA{R: uint8(r * 255), G: uint8(g * 255), B: uint8(b * 255), A: 255}, nil
+}
+
+// Contact sheet cells are square, with a thumbnail centred in each
+const (
+	contactSheetCell   = 320
+	contactSheetGutter = 8
+)
+
+// CreateContactSheet arranges thumbnails of the given wallpapers (all of
+// them when ids is empty) into a grid with cols columns and writes it to
+// outPath as a JPEG, or a PNG when the path ends in .png
+func (a *App) CreateContactSheet(ids []string, cols int, outPath string) error {
+	if cols <= 0 {
+		return fmt.Errorf("columns must be positive")
+	}
+	ext := strings.ToLower(filepath.Ext(outPath))
+	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+		return fmt.Errorf("contact sheet must be a .jpg or .png file")
+	}
+	background, err := parseCollageBackground(a.GetSettings().CollageBackground)
+	if err != nil {
+		return err
+	}
+
+	var wallpapers []WallpaperInfo
+	if len(ids) == 0 {
+		wallpapers = a.GetWallpapers()
+	}
+	for _, id := range ids {
+		wp, ok := a.findWallpaper(id)
+		if !ok {
+			return fmt.Errorf("wallpaper not found: %s", id)
+		}
+		wallpapers = append(wallpapers, wp)
+	}
+	if len(wallpapers) == 0 {
+		return fmt.Errorf("no wallpapers to include")
+	}
+
+	cols = min(cols, len(wallpapers))
+	rows := (len(wallpapers) + cols - 1) / cols
+	pitch := contactSheetCell + contactSheetGutter
+	sheet := image.NewRGBA(image.Rect(0, 0, cols*pitch+contactSheetGutter, rows*pitch+contactSheetGutter))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	for i, wp := range wallpapers {
+		path, err := a.thumbnail(wp, contactSheetCell)
+		if err != nil {
+			fmt.Printf("Skipping %s in contact sheet: %v\n", wp.Filename, err)
+			continue
+		}
+		thumb, err := decodeImage(path)
+		if err != nil {
+			continue
+		}
+
+		// Centre the thumbnail in its cell
+		b := thumb.Bounds()
+		x := contactSheetGutter + (i%cols)*pitch + (contactSheetCell-b.Dx())/2
+		y := contactSheetGutter + (i/cols)*pitch + (contactSheetCell-b.Dy())/2
+		draw.Draw(sheet, image.Rect(x, y, x+b.Dx(), y+b.Dy()), thumb, b.Min, draw.Src)
+	}
+
+	if ext == ".png" {
+		return savePNG(sheet, outPath)
+	}
+	return saveJPEG(sheet, outPath)
+}
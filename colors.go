@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+)
+
+// paletteSize is the number of dominant colors kept per wallpaper
+const paletteSize = 5
+
+// paletteSampleSize is the longest side of the copy the palette is built from
+const paletteSampleSize = 64
+
+// hueTolerance is how far, in degrees, a palette hue may be from the
+// requested one to match a color filter
+const hueTolerance = 30.0
+
+// namedHues maps the color names accepted by FilterColor to a hue in degrees
+var namedHues = map[string]float64{
+	"red":    0,
+	"orange": 30,
+	"yellow": 55,
+	"green":  120,
+	"cyan":   180,
+	"blue":   225,
+	"purple": 275,
+	"pink":   320,
+}
+
+// namedGrays maps neutral color names to a lightness in [0, 1]
+var namedGrays = map[string]float64{
+	"black": 0.1,
+	"gray":  0.5,
+	"grey":  0.5,
+	"white": 0.9,
+}
+
+// dominantColors returns up to paletteSize hex colors, most common first. The
+// image is downscaled and each pixel binned into a 3-bit-per-channel
+// histogram; each returned color is the mean of the pixels in its bin.
+func dominantColors(img image.Image) []string {
+	small := toRGBA(resizeToFit(img, paletteSampleSize))
+
+	type bin struct {
+		count   int
+		r, g, b int
+	}
+	var bins [512]bin
+	for y := 0; y < small.Rect.Dy(); y++ {
+		row := small.Pix[y*small.Stride:]
+		for x := 0; x < small.Rect.Dx(); x++ {
+			r, g, b := int(row[x*4]), int(row[x*4+1]), int(row[x*4+2])
+			bn := &bins[(r>>5)<<6|(g>>5)<<3|b>>5]
+			bn.count++
+			bn.r += r
+			bn.g += g
+			bn.b += b
+		}
+	}
+
+	order := make([]int, 0, len(bins))
+	for i := range bins {
+		if bins[i].count > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return bins[order[i]].count > bins[order[j]].count
+	})
+
+	var colors []string
+	for _, i := range order[:min(len(order), paletteSize)] {
+		bn := bins[i]
+		colors = append(colors, fmt.Sprintf("#%02x%02x%02x", bn.r/bn.count, bn.g/bn.count, bn.b/bn.count))
+	}
+	return colors
+}
+
+// parseHexColor parses "#rrggbb" into channels in [0, 1]
+func parseHexColor(s string) (r, g, b float64, ok bool) {
+	var ri, gi, bi int
+	if len(s) != 7 || s[0] != '#' {
+		return 0, 0, 0, false
+	}
+	if _, err := fmt.Sscanf(s, "#%02x%02x%02x", &ri, &gi, &bi); err != nil {
+		return 0, 0, 0, false
+	}
+	return float64(ri) / 255, float64(gi) / 255, float64(bi) / 255, true
+}
+
+// toHSL converts RGB in [0, 1] to hue in degrees, saturation and lightness
+func toHSL(r, g, b float64) (h, s, l float64) {
+	hi := math.Max(r, math.Max(g, b))
+	lo := math.Min(r, math.Min(g, b))
+	l = (hi + lo) / 2
+	if hi == lo {
+		return 0, 0, l
+	}
+
+	d := hi - lo
+	if l > 0.5 {
+		s = d / (2 - hi - lo)
+	} else {
+		s = d / (hi + lo)
+	}
+	switch hi {
+	case r:
+		h = math.Mod((g-b)/d+6, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	return h * 60, s, l
+}
+
+// isGray reports whether a color has too little saturation to have a
+// meaningful hue
+func isGray(s, l float64) bool {
+	return s < 0.15 || l < 0.08 || l > 0.95
+}
+
+// matchesColor reports whether any palette color is close to filter, which
+// is a color name or a "#rrggbb" value. Chromatic filters match by hue;
+// neutral ones match grays of similar lightness.
+func matchesColor(palette []string, filter string) bool {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+
+	wantHue, chromatic := namedHues[filter]
+	wantLight, neutral := namedGrays[filter]
+	if !chromatic && !neutral {
+		r, g, b, ok := parseHexColor(filter)
+		if !ok {
+			return false
+		}
+		h, s, l := toHSL(r, g, b)
+		wantHue, wantLight = h, l
+		chromatic, neutral = !isGray(s, l), isGray(s, l)
+	}
+
+	for _, c := range palette {
+		r, g, b, ok := parseHexColor(c)
+		if !ok {
+			continue
+		}
+		h, s, l := toHSL(r, g, b)
+		if isGray(s, l) {
+			if neutral && math.Abs(l-wantLight) <= 0.2 {
+				return true
+			}
+			continue
+		}
+		if chromatic && hueDistance(h, wantHue) <= hueTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// hueDistance is the angular distance between two hues in degrees
+func hueDistance(a, b float64) float64 {
+	d := math.Abs(a - b)
+	return math.Min(d, 360-d)
+}
+
+// validateColorFilter checks a FilterColor value
+func validateColorFilter(filter string) error {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	if filter == "" {
+		return nil
+	}
+	if _, ok := namedHues[filter]; ok {
+		return nil
+	}
+	if _, ok := namedGrays[filter]; ok {
+		return nil
+	}
+	if _, _, _, ok := parseHexColor(filter); ok {
+		return nil
+	}
+	return fmt.Errorf("invalid color filter: %s", filter)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Values of ContentFilter, from strictest to loosest
+const (
+	contentFilterStrict   = "strict"
+	contentFilterModerate = "moderate"
+	contentFilterOff      = "off"
+)
+
+// contentFilterLevel orders the filter values, strictest highest. Empty
+// counts as strict, so a missing value never loosens anything.
+func contentFilterLevel(filter string) int {
+	switch filter {
+	case contentFilterOff:
+		return 0
+	case contentFilterModerate:
+		return 1
+	}
+	return 2
+}
+
+// validateContentFilter checks a global or per-source filter value
+func validateContentFilter(filter string) error {
+	switch filter {
+	case "", contentFilterStrict, contentFilterModerate, contentFilterOff:
+		return nil
+	}
+	return fmt.Errorf("invalid content filter %q: use %q, %q or %q", filter, contentFilterStrict, contentFilterModerate, contentFilterOff)
+}
+
+// contentFilter returns the filter in effect for a source: the stricter of
+// the global setting and the source's own. A source can tighten the global
+// filter but only loosen it when the global one is off.
+func (a *App) contentFilter(source string) string {
+	global := a.GetSettings().ContentFilter
+	if global == "" {
+		global = contentFilterStrict
+	}
+	local := a.sourceConfig(source).ContentFilter
+	if local != "" && contentFilterLevel(local) > contentFilterLevel(global) {
+		return local
+	}
+	return global
+}
+
+// isRedditHost reports whether host is reddit.com or one of its
+// subdomains
+func isRedditHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "reddit.com" || strings.HasSuffix(host, ".reddit.com")
+}
+
+// filterSourceURL adds the provider's own content filter to a request
+// URL: Wallhaven's purity, Unsplash's content_filter and Reddit's
+// include_over_18. Other URLs, and any URL with the filter off, are left
+// as they are. Only Reddit's search honours include_over_18; its feeds
+// are filtered on each post's over_18 flag instead, see redditListing.
+// Reddit has no flag short of over 18, so moderate filters it as strict
+// does.
+func filterSourceURL(raw, filter string) string {
+	if filter == contentFilterOff {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	query := u.Query()
+	switch {
+	case host == "wallhaven.cc":
+		// Purity flags are SFW, sketchy, NSFW
+		if filter == contentFilterStrict {
+			query.Set("purity", "100")
+		} else {
+			query.Set("purity", "110")
+		}
+	case host == "api.unsplash.com":
+		// Unsplash's default, low, is already moderate
+		if filter != contentFilterStrict {
+			return raw
+		}
+		query.Set("content_filter", "high")
+	case isRedditHost(host):
+		query.Set("include_over_18", "off")
+	default:
+		return raw
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestContentFilter checks that a source can make the global filter
+// stricter, but only loosen it when the global filter is off
+func TestContentFilter(t *testing.T) {
+	tests := []struct {
+		global, local, want string
+	}{
+		{"", "", contentFilterStrict},
+		{contentFilterStrict, "", contentFilterStrict},
+		{contentFilterStrict, contentFilterModerate, contentFilterStrict},
+		{contentFilterStrict, contentFilterOff, contentFilterStrict},
+		{contentFilterModerate, "", contentFilterModerate},
+		{contentFilterModerate, contentFilterStrict, contentFilterStrict},
+		{contentFilterModerate, contentFilterOff, contentFilterModerate},
+		{contentFilterOff, "", contentFilterOff},
+		{contentFilterOff, contentFilterModerate, contentFilterModerate},
+		{contentFilterOff, contentFilterStrict, contentFilterStrict},
+	}
+	const source = "https://wallhaven.cc/api/v1/search"
+	for _, tt := range tests {
+		a := newTestApp(t)
+		a.changeSettings(func(s *AppSettings) {
+			s.ContentFilter = tt.global
+			s.SourceConfigs = map[string]SourceConfig{source: {ContentFilter: tt.local}}
+		})
+		if got := a.contentFilter(source); got != tt.want {
+			t.Errorf("global %q, source %q: got %q, want %q", tt.global, tt.local, got, tt.want)
+		}
+	}
+}
+
+func TestFilterSourceURL(t *testing.T) {
+	tests := []struct {
+		raw, filter, want string
+	}{
+		{"https://wallhaven.cc/api/v1/search?q=sea", contentFilterStrict, "https://wallhaven.cc/api/v1/search?purity=100&q=sea"},
+		{"https://wallhaven.cc/api/v1/search?q=sea", contentFilterModerate, "https://wallhaven.cc/api/v1/search?purity=110&q=sea"},
+		{"https://wallhaven.cc/api/v1/search?purity=111", contentFilterOff, "https://wallhaven.cc/api/v1/search?purity=111"},
+		{"https://api.unsplash.com/photos/random", contentFilterStrict, "https://api.unsplash.com/photos/random?content_filter=high"},
+		{"https://api.unsplash.com/photos/random", contentFilterModerate, "https://api.unsplash.com/photos/random"},
+		{"https://www.reddit.com/search.json?q=sea", contentFilterModerate, "https://www.reddit.com/search.json?include_over_18=off&q=sea"},
+		{"https://example.com/image.jpg", contentFilterStrict, "https://example.com/image.jpg"},
+	}
+	for _, tt := range tests {
+		if got := filterSourceURL(tt.raw, tt.filter); got != tt.want {
+			t.Errorf("filterSourceURL(%q, %q) = %q, want %q", tt.raw, tt.filter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultControlAPIAddress only accepts connections from this machine
+	defaultControlAPIAddress = "127.0.0.1:47823"
+	// maxEnqueueBody bounds the size of a POST /enqueue or /set request body
+	maxEnqueueBody = 8 << 10
+	// controlAPIShutdownTimeout bounds how long in-flight requests may
+	// delay stopping the server
+	controlAPIShutdownTimeout = 2 * time.Second
+)
+
+// apiState holds the running control API server
+type apiState struct {
+	mu  sync.Mutex
+	srv *http.Server
+}
+
+// EnqueueRequest is the body of POST /enqueue
+type EnqueueRequest struct {
+	URL            string   `json:"url"`
+	SetImmediately bool     `json:"setImmediately"`
+	Tags           []string `json:"tags"`
+}
+
+// SetRequest is the body of POST /set
+type SetRequest struct {
+	ID string `json:"id"`
+}
+
+// startControlAPI starts the control API server when it is enabled. A
+// token is generated and saved the first time, so the API is never open.
+func (a *App) startControlAPI() {
+	if !a.GetSettings().ControlAPIEnabled {
+		return
+	}
+	// A token that is in the store but couldn't be read must not be
+	// replaced, or clients holding it would be locked out
+	created := false
+	old := a.changeSettings(func(s *AppSettings) {
+		if _, locked := s.unresolvedSecrets[controlAPITokenSecret]; s.ControlAPIToken == "" && !locked {
+			s.ControlAPIToken = generateID() + generateID()
+			created = true
+		}
+	})
+	if created {
+		a.saveSettings()
+	} else if old.ControlAPIToken == "" {
+		fmt.Println("Control API not started: its token couldn't be read from the secret store")
+		return
+	}
+
+	ln, err := net.Listen("tcp", a.GetSettings().ControlAPIAddress)
+	if err != nil {
+		fmt.Printf("Failed to start control API: %v\n", err)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/enqueue", a.controlHandler(http.MethodPost, a.handleEnqueue))
+	mux.HandleFunc("/change", a.controlHandler(http.MethodPost, a.handleChange))
+	mux.HandleFunc("/next", a.controlHandler(http.MethodPost, a.handleNext))
+	mux.HandleFunc("/set", a.controlHandler(http.MethodPost, a.handleSet))
+	mux.HandleFunc("/wallpapers", a.controlHandler(http.MethodGet, a.handleWallpapers))
+	mux.HandleFunc("/health", a.controlHandler(http.MethodGet, a.handleHealth))
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	a.api.mu.Lock()
+	a.api.srv = srv
+	a.api.mu.Unlock()
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Control API stopped: %v\n", err)
+		}
+	}()
+}
+
+// stopControlAPI shuts the server down, letting in-flight requests finish
+func (a *App) stopControlAPI() {
+	a.api.mu.Lock()
+	srv := a.api.srv
+	a.api.srv = nil
+	a.api.mu.Unlock()
+
+	if srv == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), controlAPIShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+	}
+}
+
+// restartControlAPI applies changed control API settings
+func (a *App) restartControlAPI() {
+	a.stopControlAPI()
+	a.startControlAPI()
+}
+
+// controlHandler wraps an endpoint with the checks every request gets: CORS
+// restricted to ExtensionOrigin, the allowed method and the bearer token
+func (a *App) controlHandler(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if a.GetSettings().ExtensionOrigin == "" || origin != a.GetSettings().ExtensionOrigin {
+				writeAPIError(w, http.StatusForbidden, "origin not allowed")
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Vary", "Origin")
+		}
+
+		// Preflight requests carry no credentials; the real request is
+		// still checked below
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", method)
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		if !a.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAPIError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorized reports whether r carries the configured bearer token
+func (a *App) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	want := a.GetSettings().ControlAPIToken
+	return ok && want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// handleEnqueue adds an image URL pushed by the browser extension to the
+// library, optionally setting it, and answers with the stored entry
+func (a *App) handleEnqueue(w http.ResponseWriter, r *http.Request) {
+	var req EnqueueRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if err := validateImageURL(req.URL); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	info, err := a.addFromURL(req.URL, req.Tags, req.SetImmediately)
+	if err != nil {
+		writeAPIError(w, changeErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, info)
+}
+
+// handleChange downloads and applies a new wallpaper, as the Change Now
+// button does, and answers with it
+func (a *App) handleChange(w http.ResponseWriter, r *http.Request) {
+	info, err := a.DownloadAndSetWallpaper()
+	if err != nil {
+		writeAPIError(w, changeErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleNext applies the next wallpaper and answers with it
+func (a *App) handleNext(w http.ResponseWriter, r *http.Request) {
+	info, err := a.NextWallpaper()
+	if err != nil {
+		writeAPIError(w, changeErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleSet applies the library wallpaper with the given ID
+func (a *App) handleSet(w http.ResponseWriter, r *http.Request) {
+	var req SetRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.ID == "" {
+		writeAPIError(w, http.StatusBadRequest, "missing id")
+		return
+	}
+
+	info, err := a.SetWallpaperByID(req.ID)
+	switch {
+	case errors.Is(err, errWallpaperNotFound):
+		writeAPIError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, errFileNotFound):
+		writeAPIError(w, http.StatusGone, err.Error())
+	case err != nil:
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, info)
+	}
+}
+
+// handleWallpapers lists the library
+func (a *App) handleWallpapers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.GetWallpapers())
+}
+
+// handleHealth answers with the version and health report
+func (a *App) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.GetHealth())
+}
+
+// decodeJSONBody reads a size-limited JSON request body into v. On failure
+// the error response has been written and false is returned.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeAPIError(w, http.StatusUnsupportedMediaType, "content type must be application/json")
+		return false
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxEnqueueBody)
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeAPIError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return false
+		}
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON body")
+		return false
+	}
+	return true
+}
+
+// changeErrorStatus maps a failed download or change to a status code:
+// 503 when the app can't act right now, 502 when the sources failed
+func changeErrorStatus(err error) int {
+	if errors.Is(err, errStorageUnavailable) || errors.Is(err, errShuttingDown) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
+
+// writeJSON sends v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError sends {"error": message}
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
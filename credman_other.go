@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// newCredentialStore is only available on Windows
+func newCredentialStore() (secretStore, error) {
+	return nil, fmt.Errorf("Credential Manager is only available on Windows")
+}
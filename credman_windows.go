@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = 1168
+)
+
+var (
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+// credentialW mirrors the Win32 CREDENTIALW structure
+type credentialW struct {
+	flags              uint32
+	credType           uint32
+	targetName         *uint16
+	comment            *uint16
+	lastWritten        syscall.Filetime
+	credentialBlobSize uint32
+	credentialBlob     *byte
+	persist            uint32
+	attributeCount     uint32
+	attributes         uintptr
+	targetAlias        *uint16
+	userName           *uint16
+}
+
+// credentialStore keeps secrets as generic credentials in the Windows
+// Credential Manager, protected by the user's logon
+type credentialStore struct{}
+
+func newCredentialStore() (secretStore, error) {
+	if err := procCredReadW.Find(); err != nil {
+		return nil, err
+	}
+	return credentialStore{}, nil
+}
+
+func (credentialStore) Backend() string { return "credential-manager" }
+
+// credentialTarget is the Credential Manager target name of a secret
+func credentialTarget(name string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(secretService + "/" + name)
+}
+
+func (credentialStore) Get(name string) (string, error) {
+	target, err := credentialTarget(name)
+	if err != nil {
+		return "", err
+	}
+	var cred *credentialW
+	ret, _, lastErr := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ret == 0 {
+		if errno, ok := lastErr.(syscall.Errno); ok && errno == errorNotFound {
+			return "", errSecretNotFound
+		}
+		return "", fmt.Errorf("CredReadW failed: %v", lastErr)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	if cred.credentialBlobSize == 0 {
+		return "", nil
+	}
+	return string(unsafe.Slice(cred.credentialBlob, cred.credentialBlobSize)), nil
+}
+
+func (credentialStore) Set(name, value string) error {
+	target, err := credentialTarget(name)
+	if err != nil {
+		return err
+	}
+	blob := []byte(value)
+	cred := credentialW{
+		credType:           credTypeGeneric,
+		targetName:         target,
+		credentialBlobSize: uint32(len(blob)),
+		persist:            credPersistLocalMachine,
+	}
+	if len(blob) > 0 {
+		cred.credentialBlob = &blob[0]
+	}
+	ret, _, lastErr := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if ret == 0 {
+		return fmt.Errorf("CredWriteW failed: %v", lastErr)
+	}
+	return nil
+}
+
+func (credentialStore) Delete(name string) error {
+	target, err := credentialTarget(name)
+	if err != nil {
+		return err
+	}
+	ret, _, lastErr := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if ret == 0 {
+		if errno, ok := lastErr.(syscall.Errno); ok && errno == errorNotFound {
+			return nil
+		}
+		return fmt.Errorf("CredDeleteW failed: %v", lastErr)
+	}
+	return nil
+}
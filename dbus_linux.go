@@ -0,0 +1,197 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+const (
+	dbusName  = "io.github.wallset"
+	dbusPath  = dbus.ObjectPath("/io/github/wallset")
+	dbusIface = "io.github.wallset.Wallset"
+)
+
+// dbusIntrospection describes the exported object to D-Bus tools
+const dbusIntrospection = introspect.IntrospectDeclarationString + `
+<node>
+	<interface name="` + dbusIface + `">
+		<method name="Next"/>
+		<method name="Previous"/>
+		<method name="SkipCurrent"/>
+		<method name="SetFromFile">
+			<arg name="path" direction="in" type="s"/>
+		</method>
+		<method name="GetCurrent">
+			<arg name="id" direction="out" type="s"/>
+			<arg name="path" direction="out" type="s"/>
+			<arg name="title" direction="out" type="s"/>
+		</method>
+		<method name="Pause">
+			<arg name="seconds" direction="in" type="u"/>
+		</method>
+		<signal name="WallpaperChanged">
+			<arg name="id" type="s"/>
+			<arg name="path" type="s"/>
+			<arg name="title" type="s"/>
+		</signal>
+	</interface>` + introspect.IntrospectDataString + `</node>`
+
+// dbusState holds the session bus connection while the service is exported
+type dbusState struct {
+	mu   sync.Mutex
+	conn *dbus.Conn
+}
+
+// dbusService is the object exported on the session bus. Its methods are
+// called by godbus and must return *dbus.Error last.
+type dbusService struct {
+	app *App
+}
+
+func (s dbusService) Next() *dbus.Error {
+	if _, err := s.app.NextWallpaper(); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (s dbusService) Previous() *dbus.Error {
+	if _, err := s.app.PreviousWallpaper(); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (s dbusService) SkipCurrent() *dbus.Error {
+	if _, err := s.app.SkipCurrent(); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (s dbusService) SetFromFile(path string) *dbus.Error {
+	abs, err := filepath.Abs(path)
+	if err == nil {
+		_, err = os.Stat(abs)
+	}
+	if err == nil {
+		err = s.app.setWallpaper(abs, triggerManual)
+	}
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	a := s.app
+	a.mu.Lock()
+	wp, ok := a.findByPathLocked(abs)
+	a.mu.Unlock()
+	if !ok {
+		wp = WallpaperInfo{Filename: filepath.Base(abs), Filepath: abs}
+	}
+	a.emitWallpaperChanged(wp)
+	return nil
+}
+
+func (s dbusService) GetCurrent() (string, string, string, *dbus.Error) {
+	a := s.app
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	path := a.data.CurrentPath
+	wp, _ := a.findByPathLocked(path)
+	title := wp.DisplayName
+	if title == "" {
+		title = wp.Filename
+	}
+	return wp.ID, path, title, nil
+}
+
+// Pause suspends auto-change for the given number of seconds, or until
+// resumed from the app when seconds is 0
+func (s dbusService) Pause(seconds uint32) *dbus.Error {
+	if seconds == 0 {
+		s.app.SetAutoChangePaused(true)
+	} else {
+		s.app.pauseAutoChangeFor(time.Duration(seconds) * time.Second)
+	}
+	return nil
+}
+
+// startDBusService exports the control object on the session bus. It is
+// skipped quietly when there is no session bus (headless or SSH sessions)
+// or another instance already owns the name.
+func (a *App) startDBusService() {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		fmt.Printf("D-Bus unavailable, skipping service: %v\n", err)
+		return
+	}
+
+	reply, err := conn.RequestName(dbusName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		fmt.Printf("D-Bus name %s is taken, skipping service\n", dbusName)
+		conn.Close()
+		return
+	}
+
+	conn.Export(dbusService{app: a}, dbusPath, dbusIface)
+	conn.Export(introspect.Introspectable(dbusIntrospection), dbusPath, "org.freedesktop.DBus.Introspectable")
+
+	a.dbus.mu.Lock()
+	a.dbus.conn = conn
+	a.dbus.mu.Unlock()
+}
+
+// stopDBusService releases the bus name and closes the connection
+func (a *App) stopDBusService() {
+	a.dbus.mu.Lock()
+	defer a.dbus.mu.Unlock()
+
+	if a.dbus.conn != nil {
+		a.dbus.conn.Close()
+		a.dbus.conn = nil
+	}
+}
+
+// notifyDBusChanged emits the WallpaperChanged signal
+func (a *App) notifyDBusChanged(info WallpaperInfo) {
+	a.dbus.mu.Lock()
+	defer a.dbus.mu.Unlock()
+
+	if a.dbus.conn == nil {
+		return
+	}
+	title := info.DisplayName
+	if title == "" {
+		title = info.Filename
+	}
+	a.dbus.conn.Emit(dbusPath, dbusIface+".WallpaperChanged", info.ID, info.Filepath, title)
+}
+
+// callRunningInstance invokes a method on an instance that already owns the
+// bus name, so command-line invocations can control it. It reports false
+// when no instance is reachable.
+func callRunningInstance(method string, args ...interface{}) (bool, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return false, nil
+	}
+	defer conn.Close()
+
+	call := conn.Object(dbusName, dbusPath).Call(dbusIface+"."+method, 0, args...)
+	if call.Err != nil {
+		if dbusErr, ok := call.Err.(dbus.Error); ok && dbusErr.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+			return false, nil
+		}
+		return true, call.Err
+	}
+	return true, nil
+}
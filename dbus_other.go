@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+// dbusState is empty where there is no session bus
+type dbusState struct{}
+
+func (a *App) startDBusService()                    {}
+func (a *App) stopDBusService()                     {}
+func (a *App) notifyDBusChanged(info WallpaperInfo) {}
+
+// callRunningInstance always reports that no instance is reachable
+func callRunningInstance(method string, args ...interface{}) (bool, error) {
+	return false, nil
+}
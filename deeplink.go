@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/options"
+)
+
+const (
+	// deepLinkScheme is the custom URL scheme, as in wallset://set?url=...
+	deepLinkScheme = "wallset"
+	// maxDeepLinkLength bounds how much of a link is parsed at all
+	maxDeepLinkLength = 4096
+	// linuxDesktopFile is the handler entry written for xdg-open
+	linuxDesktopFile = "wallset-url-handler.desktop"
+)
+
+// DeepLinkRequest is emitted as "deepLinkRequested" so the frontend can ask
+// before anything is downloaded
+type DeepLinkRequest struct {
+	URL  string `json:"url"`
+	Host string `json:"host"`
+}
+
+// deepLinkState queues links received before the frontend is listening
+type deepLinkState struct {
+	mu      sync.Mutex
+	ready   bool
+	pending []DeepLinkRequest
+}
+
+// parseDeepLink validates a wallset:// link and returns the image URL it
+// carries. Only wallset://set?url=<http(s) URL> is accepted.
+func parseDeepLink(raw string) (string, error) {
+	if len(raw) > maxDeepLinkLength {
+		return "", fmt.Errorf("link is too long")
+	}
+	link, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("malformed link: %v", err)
+	}
+	if !strings.EqualFold(link.Scheme, deepLinkScheme) {
+		return "", fmt.Errorf("not a %s:// link", deepLinkScheme)
+	}
+
+	// wallset://set parses with "set" as the host; wallset:set as opaque
+	action := link.Host
+	if action == "" {
+		action = strings.Trim(link.Opaque+link.Path, "/")
+	}
+	if !strings.EqualFold(action, "set") {
+		return "", fmt.Errorf("unknown link action %q", action)
+	}
+
+	target := link.Query().Get("url")
+	if err := validateImageURL(target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// validateImageURL accepts absolute http(s) URLs with a host and without
+// embedded credentials
+func validateImageURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("no image URL given")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid image URL: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("only http and https image URLs are allowed")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("image URL has no host")
+	}
+	if u.User != nil {
+		return fmt.Errorf("image URL must not contain credentials")
+	}
+	return nil
+}
+
+// handleDeepLink validates a link and asks the frontend to confirm it.
+// Rejected links only emit "deepLinkRejected"; nothing is fetched.
+func (a *App) handleDeepLink(raw string) {
+	target, err := parseDeepLink(raw)
+	if err != nil {
+		fmt.Printf("Rejected deep link: %v\n", err)
+		a.emit("deepLinkRejected", err.Error())
+		return
+	}
+	u, _ := url.Parse(target)
+	req := DeepLinkRequest{URL: target, Host: u.Hostname()}
+
+	a.links.mu.Lock()
+	if !a.links.ready {
+		a.links.pending = append(a.links.pending, req)
+		a.links.mu.Unlock()
+		return
+	}
+	a.links.mu.Unlock()
+
+	a.ShowWindow()
+	a.emit("deepLinkRequested", req)
+}
+
+// handleLaunchArgs acts on the arguments of a launch: wallset:// links go
+// to handleDeepLink, "--set <file>" imports and sets an image, and
+// --change-now and --next change the wallpaper. Relative paths are resolved
+// against workDir.
+func (a *App) handleLaunchArgs(args []string, workDir string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.HasPrefix(strings.ToLower(arg), deepLinkScheme+":"):
+			a.handleDeepLink(arg)
+		case arg == setFlag && i+1 < len(args):
+			i++
+			path := args[i]
+			if !filepath.IsAbs(path) && workDir != "" {
+				path = filepath.Join(workDir, path)
+			}
+			go a.importAndSet(path)
+		case arg == changeNowFlag:
+			go a.DownloadAndSetWallpaper()
+		case arg == nextFlag:
+			go a.NextWallpaper()
+		}
+	}
+}
+
+// domReady runs once the frontend has loaded. Links queued until now, and
+// one passed on the command line at first launch, are delivered.
+func (a *App) domReady(ctx context.Context) {
+	a.links.mu.Lock()
+	a.links.ready = true
+	pending := a.links.pending
+	a.links.pending = nil
+	a.links.mu.Unlock()
+
+	for _, req := range pending {
+		a.ShowWindow()
+		a.emit("deepLinkRequested", req)
+	}
+	wd, _ := os.Getwd()
+	a.handleLaunchArgs(os.Args[1:], wd)
+}
+
+// onSecondInstance receives the arguments of a second launch, which is how
+// Windows and Linux hand over a clicked link or Explorer's "--set", and
+// brings the window forward
+func (a *App) onSecondInstance(data options.SecondInstanceData) {
+	a.ShowWindow()
+	a.handleLaunchArgs(data.Args, data.WorkingDirectory)
+}
+
+// registerURLScheme makes this executable the wallset:// handler for the
+// current user. Installers register it too; this covers portable builds.
+// macOS declares the scheme in Info.plist, so nothing is done there.
+func (a *App) registerURLScheme() {
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		err = a.registerURLSchemeWindows(exe)
+	case "linux":
+		err = a.registerURLSchemeLinux(exe)
+	}
+	if err != nil {
+		fmt.Printf("Failed to register %s:// handler: %v\n", deepLinkScheme, err)
+	}
+}
+
+// registerURLSchemeWindows writes the handler under HKCU\Software\Classes,
+// skipping the writes when it already points at exe
+func (a *App) registerURLSchemeWindows(exe string) error {
+	key := `HKCU\Software\Classes\` + deepLinkScheme
+	command := fmt.Sprintf(`"%s" "%%1"`, exe)
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	if out, err := a.runner.Output(ctx, "reg", "query", key+`\shell\open\command`, "/ve"); err == nil &&
+		strings.Contains(string(out), command) {
+		return nil
+	}
+
+	for _, args := range [][]string{
+		{"add", key, "/ve", "/d", "URL:Wallset link", "/f"},
+		{"add", key, "/v", "URL Protocol", "/d", "", "/f"},
+		{"add", key + `\shell\open\command`, "/ve", "/d", command, "/f"},
+	} {
+		if err := a.runner.Run(ctx, "reg", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerURLSchemeLinux writes a hidden .desktop entry declaring the
+// x-scheme-handler MIME type and makes it the default handler
+func (a *App) registerURLSchemeLinux(exe string) error {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	dir := filepath.Join(dataHome, "applications")
+	path := filepath.Join(dir, linuxDesktopFile)
+
+	entry := fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=Wallset
+Exec="%s" %%u
+NoDisplay=true
+MimeType=x-scheme-handler/%s;
+`, exe, deepLinkScheme)
+	if existing, err := os.ReadFile(path); err == nil && string(existing) == entry {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(entry), 0644); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return a.runner.Run(ctx, "xdg-mime", "default", linuxDesktopFile, "x-scheme-handler/"+deepLinkScheme)
+}
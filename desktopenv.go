@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Linux desktops with a dedicated wallpaper setter
+const (
+	desktopGNOME    = "gnome"
+	desktopKDE      = "kde"
+	desktopXFCE     = "xfce"
+	desktopCinnamon = "cinnamon"
+	desktopMATE     = "mate"
+	desktopSway     = "sway"
+	// desktopUnknown means detection was inconclusive; the generic
+	// setters are tried in turn
+	desktopUnknown = "unknown"
+)
+
+// desktopAliases maps lowercase XDG_CURRENT_DESKTOP/DESKTOP_SESSION names
+// to the desktop whose setter applies. Budgie, Unity and Pantheon use the
+// GNOME background schema.
+var desktopAliases = map[string]string{
+	"gnome":         desktopGNOME,
+	"gnome-xorg":    desktopGNOME,
+	"ubuntu":        desktopGNOME,
+	"unity":         desktopGNOME,
+	"pop":           desktopGNOME,
+	"budgie":        desktopGNOME,
+	"pantheon":      desktopGNOME,
+	"kde":           desktopKDE,
+	"plasma":        desktopKDE,
+	"plasmawayland": desktopKDE,
+	"xfce":          desktopXFCE,
+	"xfce4":         desktopXFCE,
+	"cinnamon":      desktopCinnamon,
+	"x-cinnamon":    desktopCinnamon,
+	"mate":          desktopMATE,
+	"sway":          desktopSway,
+}
+
+// DesktopEnvironment describes what GetDesktopEnvironment detected
+type DesktopEnvironment struct {
+	OS string `json:"os"`
+	// Desktop is the Linux desktop whose setter is used, or "unknown"
+	// when the generic setters are tried; empty on other systems
+	Desktop           string `json:"desktop"`
+	Wayland           bool   `json:"wayland"`
+	XDGCurrentDesktop string `json:"xdg_current_desktop"`
+	DesktopSession    string `json:"desktop_session"`
+	// Commands are what SetWallpaper would try, in order
+	Commands []PlannedCommand `json:"commands"`
+}
+
+// GetDesktopEnvironment reports the detected desktop environment and the
+// wallpaper commands chosen for it, for diagnosing a wallpaper that
+// doesn't change
+func (a *App) GetDesktopEnvironment() DesktopEnvironment {
+	env := DesktopEnvironment{
+		OS:       runtime.GOOS,
+		Commands: wallpaperPlan(runtime.GOOS, "<path>"),
+	}
+	if runtime.GOOS == "linux" {
+		env.Desktop = detectDesktop(os.Getenv)
+		env.Wayland = os.Getenv("WAYLAND_DISPLAY") != ""
+		env.XDGCurrentDesktop = os.Getenv("XDG_CURRENT_DESKTOP")
+		env.DesktopSession = os.Getenv("DESKTOP_SESSION")
+	}
+	return env
+}
+
+// detectDesktop identifies the Linux desktop from XDG_CURRENT_DESKTOP (a
+// colon-separated list, most specific first), then DESKTOP_SESSION, then
+// sway's own socket variable
+func detectDesktop(getenv func(string) string) string {
+	names := strings.Split(getenv("XDG_CURRENT_DESKTOP"), ":")
+	names = append(names, getenv("DESKTOP_SESSION"))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		// Session names may carry a path, e.g. /usr/share/xsessions/plasma
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		if desktop, ok := desktopAliases[name]; ok {
+			return desktop
+		}
+	}
+	if getenv("SWAYSOCK") != "" {
+		return desktopSway
+	}
+	return desktopUnknown
+}
+
+// linuxWallpaperPlan returns the setter for a detected desktop, or the
+// generic candidates when it is unknown
+func linuxWallpaperPlan(desktop, path string) []PlannedCommand {
+	uri := "file://" + path
+	switch desktop {
+	case desktopGNOME:
+		return []PlannedCommand{
+			{Name: "gsettings", Args: []string{"set", "org.gnome.desktop.background", "picture-uri", uri}},
+		}
+	case desktopKDE:
+		return []PlannedCommand{
+			{Name: "plasma-apply-wallpaperimage", Args: []string{path}},
+		}
+	case desktopXFCE:
+		// Every monitor and workspace has its own last-image property
+		script := `props=$(xfconf-query -c xfce4-desktop -l | grep '/last-image$') || exit 1
+for p in $props; do xfconf-query -c xfce4-desktop -p "$p" -s "$1" || exit 1; done`
+		return []PlannedCommand{
+			{Name: "sh", Args: []string{"-c", script, "sh", path}},
+		}
+	case desktopCinnamon:
+		return []PlannedCommand{
+			{Name: "gsettings", Args: []string{"set", "org.cinnamon.desktop.background", "picture-uri", uri}},
+		}
+	case desktopMATE:
+		return []PlannedCommand{
+			{Name: "gsettings", Args: []string{"set", "org.mate.background", "picture-filename", path}},
+		}
+	case desktopSway:
+		return []PlannedCommand{
+			{Name: "swaymsg", Args: []string{"output", "*", "bg", path, "fill"}},
+		}
+	}
+	return []PlannedCommand{
+		{Name: "gsettings", Args: []string{"set", "org.gnome.desktop.background", "picture-uri", uri}},
+		{Name: "feh", Args: []string{"--bg-scale", path}},
+		{Name: "nitrogen", Args: []string{"--set-scaled", path}},
+	}
+}
@@ -0,0 +1,681 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// downloadTimeout bounds a whole request, or when the body is
+	// throttled, the wait for response headers and then each stall in the
+	// body
+	downloadTimeout = 30 * time.Second
+	// downloadAttempts is how many times a transient failure is retried
+	downloadAttempts = 3
+	// partialMaxAge is how long an abandoned .part file is kept
+	partialMaxAge = 24 * time.Hour
+	// defaultMaxFileSize is the default MaxFileSizeBytes (50 MB)
+	defaultMaxFileSize = 50 * 1024 * 1024
+	// defaultMinFileSize is the default MinFileSizeBytes
+	defaultMinFileSize = 50000
+	// smallFileMinWidth and smallFileMinHeight are the HD size a file
+	// under MinFileSizeBytes must decode to, in either orientation, to be
+	// kept: well-compressed photos can be that small, placeholders can't
+	smallFileMinWidth  = 1280
+	smallFileMinHeight = 720
+	// defaultMaxRedirects is the default MaxRedirects, as in net/http
+	defaultMaxRedirects = 10
+	// maxRedirectsLimit bounds MaxRedirects
+	maxRedirectsLimit = 30
+)
+
+// fetcher performs HTTP requests. *http.Client satisfies it; tests can
+// substitute a fake or a client pointed at an httptest.Server.
+type fetcher interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// newHTTPClient returns the client used for downloads. It has no overall
+// timeout because throttled bodies may legitimately take minutes; requests
+// are bounded per attempt instead.
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = downloadTimeout
+	return &http.Client{Transport: transport}
+}
+
+// errRedirectRefused is returned for a redirect past MaxRedirects or, unless
+// AllowRedirectDowngrade is set, from https to http
+var errRedirectRefused = errors.New("redirect refused")
+
+// checkRedirect is the CheckRedirect of the app's clients. via holds the
+// requests made so far, so its length is the redirects followed once req
+// is sent.
+func (a *App) checkRedirect(req *http.Request, via []*http.Request) error {
+	chain := make([]string, 0, len(via)+1)
+	for _, r := range via {
+		chain = append(chain, r.URL.String())
+	}
+	chain = append(chain, req.URL.String())
+
+	if len(via) > a.GetSettings().MaxRedirects {
+		return fmt.Errorf("%w: more than %d redirects: %s", errRedirectRefused, a.GetSettings().MaxRedirects, strings.Join(chain, " -> "))
+	}
+	if via[len(via)-1].URL.Scheme == "https" && req.URL.Scheme == "http" && !a.GetSettings().AllowRedirectDowngrade {
+		return fmt.Errorf("%w: https to http: %s", errRedirectRefused, strings.Join(chain, " -> "))
+	}
+	return nil
+}
+
+// redirectChain lists the URLs a response was redirected through, from
+// the one requested to the one that answered
+func redirectChain(resp *http.Response) []string {
+	var chain []string
+	for req := resp.Request; req != nil; {
+		chain = append([]string{req.URL.String()}, chain...)
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	return chain
+}
+
+// retryableError marks a failure worth retrying, such as a dropped connection
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string { return e.err.Error() }
+func (e retryableError) Unwrap() error { return e.err }
+
+// httpStatusError is a response with a status that gives no image
+type httpStatusError struct {
+	code int
+}
+
+func (e httpStatusError) Error() string { return fmt.Sprintf("HTTP %d", e.code) }
+
+// errDownloadCanceled is returned by downloads stopped with CancelDownload
+var errDownloadCanceled = errors.New("download canceled")
+
+// sourceFailure is why one source gave no wallpaper
+type sourceFailure struct {
+	source string
+	err    error
+}
+
+// sourcesFailedError is returned when no source gave a wallpaper. Its
+// message lists each source with its reason, e.g. "source X: HTTP 503",
+// and errors.Is/As see through to the individual errors.
+type sourcesFailedError struct {
+	failures []sourceFailure
+}
+
+func (e *sourcesFailedError) Error() string {
+	if len(e.failures) == 0 {
+		return "all download sources failed: no sources configured"
+	}
+	reasons := make([]string, len(e.failures))
+	for i, f := range e.failures {
+		reasons[i] = f.source + ": " + f.err.Error()
+	}
+	return "all download sources failed: " + strings.Join(reasons, "; ")
+}
+
+func (e *sourcesFailedError) Unwrap() []error {
+	errs := make([]error, len(e.failures))
+	for i, f := range e.failures {
+		errs[i] = f.err
+	}
+	return errs
+}
+
+// add records a source's failure
+func (e *sourcesFailedError) add(source string, err error) {
+	e.failures = append(e.failures, sourceFailure{source: source, err: err})
+}
+
+// partialDownload tracks a .part file across retries of the same URL
+type partialDownload struct {
+	path         string
+	etag         string
+	acceptRanges bool
+	header       http.Header // headers of the most recent response
+	url          string      // where the most recent response came from, after redirects
+}
+
+// downloadState lets CancelDownload stop the downloads in progress, each
+// registered with its cancel function
+type downloadState struct {
+	mu      sync.Mutex
+	next    int
+	cancels map[int]context.CancelCauseFunc
+}
+
+// startDownload registers a download and returns its context, which
+// CancelDownload and shutdown cancel, and the function to call when it
+// has finished
+func (a *App) startDownload(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	a.downloads.mu.Lock()
+	defer a.downloads.mu.Unlock()
+	if a.downloads.cancels == nil {
+		a.downloads.cancels = make(map[int]context.CancelCauseFunc)
+	}
+	id := a.downloads.next
+	a.downloads.next++
+	a.downloads.cancels[id] = cancel
+	return ctx, func() {
+		a.downloads.mu.Lock()
+		delete(a.downloads.cancels, id)
+		a.downloads.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// CancelDownload stops the downloads in progress and emits
+// "downloadCanceled". Their partial files are removed as they unwind, and
+// a change that was downloading fails with errDownloadCanceled instead of
+// moving on to the next source. It reports whether anything was running.
+func (a *App) CancelDownload() bool {
+	a.downloads.mu.Lock()
+	cancels := a.downloads.cancels
+	a.downloads.cancels = nil
+	a.downloads.mu.Unlock()
+
+	for _, cancel := range cancels {
+		cancel(errDownloadCanceled)
+	}
+	if len(cancels) == 0 {
+		return false
+	}
+	fmt.Printf("Canceled %d download(s)\n", len(cancels))
+	a.emit("downloadCanceled")
+	return true
+}
+
+// downloadFile downloads a file from a source to the wallpaper directory.
+// Unless bypassLimit is set, the body is throttled to MaxDownloadSpeedKBps.
+// Resolution placeholders in the source are expanded before the request,
+// and WebDAV, SFTP, feed, earth and script sources pick a file first;
+// per-source state stays keyed by the source as configured. A download
+// stopped by CancelDownload fails with errDownloadCanceled; cancelling ctx
+// stops it too.
+func (a *App) downloadFile(ctx context.Context, source string, bypassLimit bool) (*WallpaperInfo, error) {
+	ctx, done := a.startDownload(ctx)
+	defer done()
+	info, err := a.fetchWallpaper(ctx, source, bypassLimit)
+	if err != nil && errors.Is(context.Cause(ctx), errDownloadCanceled) {
+		return nil, errDownloadCanceled
+	}
+	return info, err
+}
+
+// fetchWallpaper does the work of downloadFile under ctx
+func (a *App) fetchWallpaper(ctx context.Context, source string, bypassLimit bool) (_ *WallpaperInfo, err error) {
+	speedLimit := a.GetSettings().MaxDownloadSpeedKBps
+	if bypassLimit {
+		speedLimit = 0
+	}
+
+	// Generate unique ID and filename
+	id := generateID()
+	dir := a.getWallpaperDir()
+	path, err := claimFilename(dir, generatedFilename("wallpaper", id, ".jpg"))
+	if err != nil {
+		return nil, err
+	}
+	// Whatever is at path by the time of a failure, the claimed name or a
+	// partly processed image, is ours to remove
+	defer func() {
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	url := a.expandSourceURL(source)
+	var remoteETag string
+	var item feedItem
+	var header http.Header
+	var redirectedFrom string
+	var seasonal []string
+	fetched := false
+	switch a.sourceConfig(source).Type {
+	case sourceTypeSFTP:
+		file, err := a.fetchSFTP(ctx, source, path, speedLimit, a.GetSettings().MaxFileSizeBytes)
+		if err != nil {
+			return nil, err
+		}
+		url, remoteETag, fetched = file.URL, file.ETag, true
+	case sourceTypeEarth:
+		config := a.sourceConfig(source)
+		if earthProvider(source) == earthHimawari {
+			tiles, err := a.fetchHimawari(ctx, source, path, config.Zoom)
+			if err != nil {
+				return nil, err
+			}
+			url, fetched = tiles, true
+		} else {
+			image, err := a.latestEPICImage(ctx, source)
+			if err != nil {
+				return nil, err
+			}
+			url = image
+		}
+	case sourceTypeScript:
+		target, err := a.runSourceScript(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		url = target
+		if filepath.IsAbs(target) {
+			if err := copyScriptFile(target, path, a.GetSettings().MaxFileSizeBytes); err != nil {
+				return nil, err
+			}
+			fetched = true
+		}
+	case sourceTypeWebDAV:
+		file, err := a.pickWebDAVFile(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		url, remoteETag = file.URL, file.ETag
+	case sourceTypeRSS:
+		image, picked, err := a.pickFeedImage(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		url, item = image, picked
+	case "":
+		url = filterSourceURL(url, a.contentFilter(source))
+		if keywords := a.seasonalKeywords(); len(keywords) > 0 {
+			if themed := seasonalSourceURL(url, keywords); themed != url {
+				url, seasonal = themed, keywords
+			}
+		}
+	}
+	if !fetched {
+		var final string
+		var err error
+		header, final, err = a.fetchToFile(ctx, a.sourceClient(source), source, url, path, speedLimit, a.GetSettings().MaxFileSizeBytes)
+		if err != nil {
+			return nil, err
+		}
+		if final != url {
+			redirectedFrom, url = url, final
+		}
+	}
+
+	// Name the file after FilenameTemplate, or the server's filename
+	if name := a.downloadFilename(url, header.Get("Content-Disposition"), id); name != "" {
+		if named, err := claimFilename(dir, name); err == nil {
+			if os.Rename(path, named) == nil {
+				path = named
+			} else {
+				os.Remove(named)
+			}
+		}
+	}
+
+	// Keep the untouched download before anything rewrites it
+	original := a.keepOriginal(path, id)
+	discard := func() {
+		os.Remove(path)
+		removeOriginal(original)
+	}
+
+	// Some links serve GIFs or AVIF; convert them to something settable
+	still, originalType, err := a.convertForDesktop(path)
+	if err != nil {
+		discard()
+		return nil, err
+	}
+	path = still
+
+	exif, err := a.prepareImage(path)
+	if err != nil {
+		discard()
+		return nil, err
+	}
+
+	info, err := a.inspectWallpaper(path)
+	if err != nil {
+		discard()
+		return nil, err
+	}
+	// Earth imagery is square by nature and meant to be shown letterboxed
+	if a.sourceConfig(source).Type != sourceTypeEarth {
+		if err := a.checkAspectRatio(info.Width, info.Height); err != nil {
+			discard()
+			return nil, err
+		}
+	}
+	attachOriginal(info, original)
+	info.ID = id
+	info.Source = source
+	info.SourceURL = url
+	info.RedirectedFrom = redirectedFrom
+	info.RemoteETag = remoteETag
+	info.Title = item.Title
+	info.PageURL = item.Link
+	info.OriginalType = originalType
+	info.Tags = seasonal
+	exif.apply(info, !a.GetSettings().StripMetadata)
+	info.Title = a.wallpaperTitle(*info)
+
+	a.storeValidators(source, header)
+	return info, nil
+}
+
+// checkAspectRatio rejects a download whose shape doesn't suit the primary
+// monitor within AspectRatioTolerance
+func (a *App) checkAspectRatio(width, height int) error {
+	tolerance := a.GetSettings().AspectRatioTolerance
+	if tolerance <= 0 {
+		return nil
+	}
+	screenWidth, screenHeight, ok := a.primaryScreenSize()
+	if !ok || matchesAspectRatio(width, height, screenWidth, screenHeight, tolerance) {
+		return nil
+	}
+	return fmt.Errorf("wrong aspect ratio: %dx%d does not suit a %dx%d screen", width, height, screenWidth, screenHeight)
+}
+
+// fetchToFile streams url into dest via a .part file and returns the final
+// response headers and the URL they came from after any redirects.
+// Transient failures are retried with backoff, resuming with a Range
+// request when the server allows it.
+func (a *App) fetchToFile(ctx context.Context, client fetcher, source, url, dest string, speedLimit int, maxSize int64) (http.Header, string, error) {
+	part := &partialDownload{path: dest + ".part"}
+
+	var err error
+	for attempt := 0; attempt < downloadAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(1<<attempt) * time.Second
+			fmt.Printf("Retrying %s in %s: %v\n", url, backoff, err)
+			select {
+			case <-a.clock.After(backoff):
+			case <-ctx.Done():
+				os.Remove(part.path)
+				return nil, "", context.Cause(ctx)
+			}
+		}
+
+		err = a.fetchAttempt(ctx, client, source, url, part, speedLimit, maxSize, attempt == 0)
+		var retryable retryableError
+		if err == nil || !errors.As(err, &retryable) || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		os.Remove(part.path)
+		return nil, "", err
+	}
+
+	if err := os.Rename(part.path, dest); err != nil {
+		os.Remove(part.path)
+		return nil, "", err
+	}
+	return part.header, part.url, nil
+}
+
+// inspectWallpaper validates a stored image file and describes it: size,
+// hashes, dimensions and luminance. Images failing validation are reported
+// as errors and left for the caller to remove.
+func (a *App) inspectWallpaper(path string) (*WallpaperInfo, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	size := stat.Size()
+
+	hash, err := fileHash(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Error pages and login forms served in place of an image
+	contentType, _ := sniffImageType(path)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("not an image: %s", contentType)
+	}
+	analysis, err := analyzeImage(path)
+	if err != nil {
+		// JPEG and PNG always decode unless the file is damaged, e.g. cut
+		// off mid-transfer; other formats may just lack a decoder
+		if contentType == "image/jpeg" || contentType == "image/png" {
+			return nil, fmt.Errorf("corrupt image: %v", err)
+		}
+		fmt.Printf("Failed to analyze %s: %v\n", filepath.Base(path), err)
+	}
+	if size < a.GetSettings().MinFileSizeBytes && (err != nil || !isHDSize(analysis.Width, analysis.Height)) {
+		return nil, tooSmallError(size)
+	}
+
+	if !matchesOrientation(analysis.Width, analysis.Height, a.GetSettings().OrientationFilter) {
+		return nil, fmt.Errorf("wrong orientation: %dx%d is not %s", analysis.Width, analysis.Height, a.GetSettings().OrientationFilter)
+	}
+
+	return &WallpaperInfo{
+		Filename:          filepath.Base(path),
+		Filepath:          path,
+		LocalURL:          "", // Will be set in GetWallpapers
+		DownloadDate:      time.Now(),
+		FileSize:          size,
+		Width:             analysis.Width,
+		Height:            analysis.Height,
+		Luminance:         analysis.Luminance,
+		LuminanceMeasured: true,
+		MIMEType:          contentType,
+		Hash:              hash,
+		PerceptualHash:    analysis.PerceptualHash,
+		Colors:            analysis.Colors,
+	}, nil
+}
+
+// fetchAttempt performs one request for url, writing the body into the
+// .part file. When a previous attempt left partial data and the server
+// advertised byte ranges with a strong ETag, only the remainder is requested.
+// Bodies that would make the file larger than maxSize (0 = no limit) fail.
+func (a *App) fetchAttempt(ctx context.Context, client fetcher, source, url string, part *partialDownload, speedLimit int, maxSize int64, first bool) error {
+	// The timeout covers the whole attempt, unless the body is throttled, in
+	// which case it restarts with every read so only a stalled body fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := time.AfterFunc(a.attemptTimeout, cancel)
+	defer timer.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	a.setSourceHeaders(req, source)
+	if first {
+		a.setConditionalHeaders(req, source)
+	}
+
+	var offset int64
+	if stat, err := os.Stat(part.path); err == nil && stat.Size() > 0 && part.acceptRanges && part.etag != "" {
+		offset = stat.Size()
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// If-Range makes the server send the full body if the resource changed
+		req.Header.Set("If-Range", part.etag)
+	}
+
+	resp, err := client.Do(req)
+	if errors.Is(err, errRedirectRefused) {
+		return err
+	}
+	if err != nil {
+		return retryableError{err}
+	}
+	defer resp.Body.Close()
+	part.url = url
+	if resp.Request != nil {
+		part.url = resp.Request.URL.String()
+		if chain := redirectChain(resp); len(chain) > 1 {
+			fmt.Printf("Redirected: %s\n", strings.Join(chain, " -> "))
+		}
+	}
+	a.recordResponse(source, resp)
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		a.refreshValidators(source, resp.Header)
+		return errNotModified
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			os.Remove(part.path)
+			return retryableError{fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))}
+		}
+	case resp.StatusCode == http.StatusOK:
+		// A full body: either the first attempt, or the server ignored the
+		// Range request, so start the file over instead of appending
+		offset = 0
+		part.etag = resp.Header.Get("ETag")
+		part.acceptRanges = resp.Header.Get("Accept-Ranges") == "bytes" && !strings.HasPrefix(part.etag, "W/")
+		// Ranges count encoded bytes, but the file holds decoded ones
+		if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+			part.acceptRanges = false
+		}
+	case resp.StatusCode >= 500:
+		return retryableError{httpStatusError{resp.StatusCode}}
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		if headers := a.sourceConfig(source).Headers; len(headers) > 0 {
+			fmt.Printf("%s refused the request (HTTP %d) with headers %s\n", url, resp.StatusCode, formatHeaders(headers))
+		}
+		return httpStatusError{resp.StatusCode}
+	default:
+		return httpStatusError{resp.StatusCode}
+	}
+	part.header = resp.Header
+
+	// Content-Length counts encoded bytes, so it only describes the file
+	// when the body isn't compressed
+	expected := resp.ContentLength
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		expected = -1
+	}
+
+	// Reject early when the server announces a body that is too large,
+	// without downloading it. Small bodies are left for inspectWallpaper,
+	// which can tell a small photo from a placeholder.
+	if maxSize > 0 && expected > 0 && offset+expected > maxSize {
+		return tooLargeError(maxSize)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	out, err := os.OpenFile(part.path, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var body io.Reader = resp.Body
+	if speedLimit > 0 {
+		idle := idleReader{resp.Body, func() { timer.Reset(a.attemptTimeout) }}
+		timer.Reset(a.attemptTimeout)
+		body = newThrottledReader(idle, speedLimit)
+	}
+	body, err = decodeContent(body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return err
+	}
+
+	if maxSize > 0 {
+		// Read one byte past the limit to tell "exactly at" from "over"
+		body = io.LimitReader(body, maxSize-offset+1)
+	}
+
+	written, err := io.Copy(out, body)
+	if err != nil {
+		return retryableError{err}
+	}
+	if maxSize > 0 && offset+written > maxSize {
+		return tooLargeError(maxSize)
+	}
+	// A connection dropped mid-body can end the copy without an error; the
+	// partial file is kept so the retry can resume it
+	if expected > 0 && written != expected {
+		return retryableError{fmt.Errorf("truncated download: got %d of %d bytes", written, expected)}
+	}
+	return nil
+}
+
+// tooLargeError reports a download exceeding MaxFileSizeBytes
+func tooLargeError(maxSize int64) error {
+	return fmt.Errorf("file exceeds the maximum size of %d bytes", maxSize)
+}
+
+// tooSmallError reports a file below MinFileSizeBytes that isn't an HD
+// image
+func tooSmallError(size int64) error {
+	return fmt.Errorf("file too small: %d bytes", size)
+}
+
+// isHDSize reports whether an image is at least smallFileMinWidth by
+// smallFileMinHeight, in either orientation
+func isHDSize(width, height int) bool {
+	return max(width, height) >= smallFileMinWidth && min(width, height) >= smallFileMinHeight
+}
+
+// decodeContent wraps body in a decompressing reader for the given
+// Content-Encoding. The transport only decodes gzip it asked for itself,
+// so mirrors that compress unprompted are handled here.
+func decodeContent(body io.Reader, encoding string) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip response: %v", err)
+		}
+		return zr, nil
+	case "deflate":
+		// "deflate" should be zlib-wrapped, but some servers send raw
+		// DEFLATE data; a zlib stream's first byte is always 0x?8
+		br := bufio.NewReader(body)
+		head, err := br.Peek(1)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deflate response: %v", err)
+		}
+		if head[0]&0x0F == 8 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("invalid deflate response: %v", err)
+			}
+			return zr, nil
+		}
+		return flate.NewReader(br), nil
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+}
+
+// cleanupPartialDownloads removes .part files abandoned more than a day ago
+func (a *App) cleanupPartialDownloads() {
+	matches, _ := filepath.Glob(filepath.Join(a.getWallpaperDir(), "*.part"))
+	for _, path := range matches {
+		if stat, err := os.Stat(path); err == nil && time.Since(stat.ModTime()) > partialMaxAge {
+			os.Remove(path)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testJPEG encodes a width by height image of noise, which compresses
+// poorly enough that an HD image passes MinFileSizeBytes
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rng.Intn(256))
+	}
+	img.Set(0, 0, color.White)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serveBytes answers every request with body as contentType
+func serveBytes(contentType string, body []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+	}
+}
+
+// wallpaperFiles lists the files left in the wallpaper directory
+func wallpaperFiles(t *testing.T, a *App) []string {
+	t.Helper()
+	entries, err := os.ReadDir(a.getWallpaperDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, e.Name())
+		}
+	}
+	return files
+}
+
+// download fetches server's /wallpaper.jpg with a, without the speed limit
+func download(t *testing.T, a *App, server *httptest.Server) (*WallpaperInfo, error) {
+	t.Helper()
+	return a.downloadFile(context.Background(), server.URL+"/wallpaper.jpg", true)
+}
+
+func TestDownloadSuccess(t *testing.T) {
+	a := newTestApp(t)
+	body := testJPEG(t, 1280, 720)
+	server := httptest.NewServer(serveBytes("image/jpeg", body))
+	defer server.Close()
+
+	info, err := download(t, a, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Width != 1280 || info.Height != 720 || info.MIMEType != "image/jpeg" {
+		t.Errorf("got %dx%d %s, want 1280x720 image/jpeg", info.Width, info.Height, info.MIMEType)
+	}
+	data, err := os.ReadFile(info.Filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, body) {
+		t.Errorf("stored file differs from the served body")
+	}
+	if files := wallpaperFiles(t, a); len(files) != 1 {
+		t.Errorf("wallpaper directory holds %v, want only the download", files)
+	}
+}
+
+func TestDownloadNotFound(t *testing.T) {
+	a := newTestApp(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := download(t, a, server)
+	var status httpStatusError
+	if !errors.As(err, &status) || status.code != http.StatusNotFound {
+		t.Fatalf("err = %v, want HTTP 404", err)
+	}
+	if files := wallpaperFiles(t, a); len(files) > 0 {
+		t.Errorf("failed download left %v", files)
+	}
+}
+
+func TestDownloadTimeout(t *testing.T) {
+	a := newTestApp(t)
+	a.attemptTimeout = 50 * time.Millisecond
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		// Slower than the attempt timeout
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	if _, err := download(t, a, server); err == nil {
+		t.Fatal("download of a stalled response succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("download took %s to time out", elapsed)
+	}
+	if got := attempts.Load(); got != downloadAttempts {
+		t.Errorf("made %d attempts, want %d", got, downloadAttempts)
+	}
+	if files := wallpaperFiles(t, a); len(files) > 0 {
+		t.Errorf("timed out download left %v", files)
+	}
+}
+
+// TestDownloadThrottledTimeout checks that a throttled body may take
+// longer than the attempt timeout as long as it keeps coming, and that one
+// that stalls still times out
+func TestDownloadThrottledTimeout(t *testing.T) {
+	body := testJPEG(t, 1280, 720)
+	t.Run("steady", func(t *testing.T) {
+		a := newTestApp(t)
+		a.attemptTimeout = 100 * time.Millisecond
+		// About 400ms for the whole body
+		a.changeSettings(func(s *AppSettings) { s.MaxDownloadSpeedKBps = 2000 })
+		server := httptest.NewServer(serveBytes("image/jpeg", body))
+		defer server.Close()
+
+		start := time.Now()
+		if _, err := a.downloadFile(context.Background(), server.URL+"/wallpaper.jpg", false); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < a.attemptTimeout {
+			t.Errorf("download took %s, so it wasn't throttled", elapsed)
+		}
+	})
+
+	t.Run("stalled", func(t *testing.T) {
+		a := newTestApp(t)
+		a.attemptTimeout = 50 * time.Millisecond
+		a.changeSettings(func(s *AppSettings) { s.MaxDownloadSpeedKBps = 2000 })
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body[:len(body)/4])
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}))
+		defer server.Close()
+
+		start := time.Now()
+		if _, err := a.downloadFile(context.Background(), server.URL+"/wallpaper.jpg", false); err == nil {
+			t.Fatal("download of a stalled body succeeded")
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("download took %s to time out", elapsed)
+		}
+		if files := wallpaperFiles(t, a); len(files) > 0 {
+			t.Errorf("timed out download left %v", files)
+		}
+	})
+}
+
+func TestDownloadWrongContentType(t *testing.T) {
+	a := newTestApp(t)
+	// Large enough to pass MinFileSizeBytes on size alone
+	page := "<!DOCTYPE html><html><body>" + strings.Repeat("<p>Please log in</p>", 5000) + "</body></html>"
+	server := httptest.NewServer(serveBytes("text/html", []byte(page)))
+	defer server.Close()
+
+	_, err := download(t, a, server)
+	if err == nil || !strings.Contains(err.Error(), "not an image") {
+		t.Fatalf("err = %v, want not an image", err)
+	}
+	if files := wallpaperFiles(t, a); len(files) > 0 {
+		t.Errorf("rejected download left %v", files)
+	}
+}
+
+func TestDownloadTooSmall(t *testing.T) {
+	a := newTestApp(t)
+	server := httptest.NewServer(serveBytes("image/jpeg", testJPEG(t, 64, 64)))
+	defer server.Close()
+
+	_, err := download(t, a, server)
+	if err == nil || !strings.Contains(err.Error(), "too small") {
+		t.Fatalf("err = %v, want file too small", err)
+	}
+	if files := wallpaperFiles(t, a); len(files) > 0 {
+		t.Errorf("rejected download left %v", files)
+	}
+}
+
+func TestDownloadTooLarge(t *testing.T) {
+	body := testJPEG(t, 1280, 720)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		// Refused from Content-Length without reading the body
+		{"announced", serveBytes("image/jpeg", body)},
+		// Cut off while reading a body of unknown length
+		{"chunked", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			w.Write(body)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(t)
+			a.changeSettings(func(s *AppSettings) { s.MaxFileSizeBytes = int64(len(body) / 2) })
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			_, err := download(t, a, server)
+			if err == nil || !strings.Contains(err.Error(), "exceeds the maximum size") {
+				t.Fatalf("err = %v, want maximum size exceeded", err)
+			}
+			if files := wallpaperFiles(t, a); len(files) > 0 {
+				t.Errorf("rejected download left %v", files)
+			}
+		})
+	}
+}
+
+// resetMidBody sends the headers and half of body, then resets the
+// connection
+func resetMidBody(t *testing.T, body []byte, w http.ResponseWriter) {
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+	buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: image/jpeg\r\n")
+	buf.WriteString("Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n")
+	buf.Write(body[:len(body)/2])
+	buf.Flush()
+	// With no linger, closing sends a RST instead of a FIN
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+}
+
+func TestDownloadConnectionReset(t *testing.T) {
+	a := newTestApp(t)
+	body := testJPEG(t, 1280, 720)
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		resetMidBody(t, body, w)
+	}))
+	defer server.Close()
+
+	if _, err := download(t, a, server); err == nil {
+		t.Fatal("download of a reset connection succeeded")
+	}
+	// Without an ETag nothing can be resumed, so each attempt starts over
+	if got := attempts.Load(); got != downloadAttempts {
+		t.Errorf("made %d attempts, want %d", got, downloadAttempts)
+	}
+	if files := wallpaperFiles(t, a); len(files) > 0 {
+		t.Errorf("failed download left %v", files)
+	}
+}
+
+// TestDownloadResumesTruncatedBody closes the connection halfway through
+// a body with a strong ETag and checks that the retry asks for the rest
+// with Range and If-Range and appends it to the partial file
+func TestDownloadResumesTruncatedBody(t *testing.T) {
+	a := newTestApp(t)
+	body := testJPEG(t, 1280, 720)
+	const etag = `"v1"`
+	var (
+		attempts atomic.Int32
+		mu       sync.Mutex
+		ranges   []string
+		ifRanges []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		ifRanges = append(ifRanges, r.Header.Get("If-Range"))
+		mu.Unlock()
+
+		if attempts.Add(1) > 1 {
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Header().Set("ETag", etag)
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: image/jpeg\r\n")
+		buf.WriteString("ETag: " + etag + "\r\nAccept-Ranges: bytes\r\n")
+		buf.WriteString("Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n")
+		buf.Write(body[:len(body)/2])
+		buf.Flush()
+	}))
+	defer server.Close()
+
+	info, err := download(t, a, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("made %d attempts, want 2", got)
+	}
+	mu.Lock()
+	wantRange := "bytes=" + strconv.Itoa(len(body)/2) + "-"
+	if len(ranges) < 2 || ranges[0] != "" || ranges[1] != wantRange || ifRanges[1] != etag {
+		t.Errorf("requests sent Range %q and If-Range %q, want the retry to send %q and %q", ranges, ifRanges, wantRange, etag)
+	}
+	mu.Unlock()
+
+	data, err := os.ReadFile(info.Filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, body) {
+		t.Errorf("resumed file differs from the served body")
+	}
+	if files := wallpaperFiles(t, a); len(files) != 1 {
+		t.Errorf("wallpaper directory holds %v, want only the download", files)
+	}
+}
+
+// TestDownloadCompressed serves the image with each Content-Encoding, both
+// when the transport asked for gzip and when the server compresses
+// unprompted, and checks the stored file is the decoded image
+func TestDownloadCompressed(t *testing.T) {
+	body := testJPEG(t, 1280, 720)
+	compress := func(encoding string) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		case "raw deflate":
+			w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+		}
+		w.Write(body)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name               string
+		encoding           string
+		disableCompression bool
+	}{
+		{"gzip requested by the transport", "gzip", false},
+		{"gzip unprompted", "gzip", true},
+		{"deflate unprompted", "deflate", true},
+		{"raw deflate unprompted", "raw deflate", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := compress(tt.encoding)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/jpeg")
+				w.Header().Set("Content-Encoding", strings.TrimPrefix(tt.encoding, "raw "))
+				w.Header().Set("Content-Length", strconv.Itoa(len(compressed)))
+				w.Write(compressed)
+			}))
+			defer server.Close()
+
+			a := newTestApp(t)
+			a.client = &http.Client{Transport: &http.Transport{DisableCompression: tt.disableCompression}}
+			info, err := download(t, a, server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(info.Filepath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, body) {
+				t.Errorf("stored %d bytes, want the %d-byte decoded image", len(data), len(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"net/url"
+	"time"
+)
+
+// sourceTypeEarth is SourceConfig.Type for live imagery of the Earth. The
+// source names the provider: earth:epic or earth:himawari.
+const sourceTypeEarth = "earth"
+
+// Earth imagery providers
+const (
+	earthEPIC     = "epic"
+	earthHimawari = "himawari"
+)
+
+const (
+	// earthLatestID is the library entry ReplacePrevious sources overwrite
+	earthLatestID = "earth-latest"
+
+	epicAPIURL     = "https://epic.gsfc.nasa.gov/api/natural"
+	epicArchiveURL = "https://epic.gsfc.nasa.gov/archive/natural"
+	himawariURL    = "https://himawari8-dl.nict.go.jp/himawari8/img/D531106"
+
+	// himawariTileSize is the edge of one Himawari tile in pixels
+	himawariTileSize = 550
+	// defaultHimawariZoom is the tiles per side when Zoom is unset
+	defaultHimawariZoom = 2
+)
+
+// earthProvider returns the provider an earth source names
+func earthProvider(source string) string {
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme != sourceTypeEarth {
+		return ""
+	}
+	switch u.Opaque {
+	case earthEPIC, earthHimawari:
+		return u.Opaque
+	}
+	return ""
+}
+
+// validHimawariZoom reports whether zoom is a tile grid the source supports
+func validHimawariZoom(zoom int) bool {
+	return zoom == 0 || zoom == 2 || zoom == 4
+}
+
+// latestEPICImage returns the archive URL of NASA EPIC's newest natural
+// color image
+func (a *App) latestEPICImage(ctx context.Context, source string) (string, error) {
+	body, err := a.fetchPage(ctx, source, epicAPIURL)
+	if err != nil {
+		return "", fmt.Errorf("EPIC API unavailable: %v", err)
+	}
+	var images []struct {
+		Image string `json:"image"`
+		Date  string `json:"date"`
+	}
+	if err := json.Unmarshal(body, &images); err != nil {
+		return "", fmt.Errorf("invalid EPIC response: %v", err)
+	}
+
+	var latest string
+	var taken time.Time
+	for _, img := range images {
+		t, err := time.Parse("2006-01-02 15:04:05", img.Date)
+		if err == nil && img.Image != "" && t.After(taken) {
+			latest, taken = img.Image, t
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("EPIC has no recent images")
+	}
+	return fmt.Sprintf("%s/%s/png/%s.png", epicArchiveURL, taken.Format("2006/01/02"), latest), nil
+}
+
+// fetchHimawari stitches the newest full-disk Himawari image from a
+// zoom×zoom grid of tiles and saves it to dest as a JPEG. It returns the
+// URL of the tile set.
+func (a *App) fetchHimawari(ctx context.Context, source, dest string, zoom int) (string, error) {
+	if zoom == 0 {
+		zoom = defaultHimawariZoom
+	}
+	body, err := a.fetchPage(ctx, source, himawariURL+"/latest.json")
+	if err != nil {
+		return "", fmt.Errorf("Himawari unavailable: %v", err)
+	}
+	var latest struct {
+		Date string `json:"date"`
+	}
+	if err := json.Unmarshal(body, &latest); err != nil {
+		return "", fmt.Errorf("invalid Himawari response: %v", err)
+	}
+	taken, err := time.Parse("2006-01-02 15:04:05", latest.Date)
+	if err != nil {
+		return "", fmt.Errorf("invalid Himawari date %q", latest.Date)
+	}
+
+	base := fmt.Sprintf("%s/%dd/%d/%s", himawariURL, zoom, himawariTileSize, taken.Format("2006/01/02/150405"))
+	canvas := image.NewRGBA(image.Rect(0, 0, zoom*himawariTileSize, zoom*himawariTileSize))
+	for x := 0; x < zoom; x++ {
+		for y := 0; y < zoom; y++ {
+			data, err := a.fetchPage(ctx, source, fmt.Sprintf("%s_%d_%d.png", base, x, y))
+			if err != nil {
+				return "", fmt.Errorf("Himawari tile %d,%d: %v", x, y, err)
+			}
+			tile, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				return "", fmt.Errorf("Himawari tile %d,%d: %v", x, y, err)
+			}
+			at := image.Pt(x*himawariTileSize, y*himawariTileSize)
+			draw.Draw(canvas, tile.Bounds().Sub(tile.Bounds().Min).Add(at), tile, tile.Bounds().Min, draw.Src)
+		}
+	}
+	if err := saveJPEG(canvas, dest); err != nil {
+		return "", err
+	}
+	return base, nil
+}
+
+// storeEarthWallpaper adds a download from an earth source. The imagery
+// changes all the time, so it is never rejected as a duplicate; with
+// ReplacePrevious it takes over the single earth-latest entry.
+func (a *App) storeEarthWallpaper(source string, info *WallpaperInfo) error {
+	if a.sourceConfig(source).ReplacePrevious {
+		info.ID = earthLatestID
+	}
+	return a.storeWallpaper(*info, false)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// JPEG markers used when walking segments
+const (
+	markerSOI   = 0xD8
+	markerSOS   = 0xDA
+	markerAPP1  = 0xE1
+	markerAPP13 = 0xED
+)
+
+// exifTimeLayout is the fixed format of EXIF date/time strings
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// EXIF tags read from the image
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+)
+
+// TIFF field types
+const (
+	typeASCII    = 2
+	typeLong     = 4
+	typeRational = 5
+)
+
+var errNotJPEG = errors.New("not a JPEG file")
+
+// exifData is the subset of EXIF metadata the library keeps
+type exifData struct {
+	CapturedAt   time.Time
+	CameraModel  string
+	GPSLatitude  *float64
+	GPSLongitude *float64
+}
+
+// apply copies the metadata onto a wallpaper. The GPS position is left
+// out unless withLocation is set, so a location stripped from the file
+// doesn't end up in the library instead.
+func (e exifData) apply(info *WallpaperInfo, withLocation bool) {
+	info.CapturedAt = e.CapturedAt
+	info.CameraModel = e.CameraModel
+	if withLocation {
+		info.GPSLatitude = e.GPSLatitude
+		info.GPSLongitude = e.GPSLongitude
+	}
+}
+
+// jpegSegment is one marker segment before the image data
+type jpegSegment struct {
+	marker byte
+	data   []byte // payload without the length field
+}
+
+// readJPEGSegments reads the marker segments of a JPEG up to and including
+// the start of scan. The reader is left at the entropy-coded data, so
+// callers can copy the rest of the file untouched.
+func readJPEGSegments(r *bufio.Reader) ([]jpegSegment, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi[0] != 0xFF || soi[1] != markerSOI {
+		return nil, errNotJPEG
+	}
+
+	var segments []jpegSegment
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG marker")
+		}
+
+		// Markers may be preceded by any number of 0xFF fill bytes
+		marker := byte(0xFF)
+		for marker == 0xFF {
+			if marker, err = r.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return nil, err
+		}
+		n := int(binary.BigEndian.Uint16(length[:])) - 2
+		if n < 0 {
+			return nil, fmt.Errorf("invalid JPEG segment length")
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, jpegSegment{marker: marker, data: data})
+		if marker == markerSOS {
+			return segments, nil
+		}
+	}
+}
+
+// readExif extracts metadata from a JPEG file. Files without EXIF return
+// the zero value and no error.
+func readExif(path string) (exifData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return exifData{}, err
+	}
+	defer f.Close()
+
+	segments, err := readJPEGSegments(bufio.NewReader(f))
+	if err != nil {
+		return exifData{}, err
+	}
+	for _, seg := range segments {
+		if seg.marker == markerAPP1 && bytes.HasPrefix(seg.data, []byte("Exif\x00\x00")) {
+			return parseExif(seg.data[6:])
+		}
+	}
+	return exifData{}, nil
+}
+
+// parseExif reads the fields we keep from a TIFF-structured EXIF block
+func parseExif(tiff []byte) (exifData, error) {
+	if len(tiff) < 8 {
+		return exifData{}, fmt.Errorf("EXIF block too short")
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return exifData{}, fmt.Errorf("invalid EXIF byte order")
+	}
+
+	ifd0, err := readIFD(tiff, order, order.Uint32(tiff[4:]))
+	if err != nil {
+		return exifData{}, err
+	}
+
+	var data exifData
+	dateTime := ifd0.ascii(tiff, order, tagDateTime)
+	if off, ok := ifd0.long(order, tagExifIFD); ok {
+		if sub, err := readIFD(tiff, order, off); err == nil {
+			if original := sub.ascii(tiff, order, tagDateTimeOriginal); original != "" {
+				dateTime = original
+			}
+		}
+	}
+	if t, err := time.ParseInLocation(exifTimeLayout, dateTime, time.Local); err == nil {
+		data.CapturedAt = t
+	}
+
+	// Model usually includes the make already, but not always
+	maker, model := ifd0.ascii(tiff, order, tagMake), ifd0.ascii(tiff, order, tagModel)
+	if maker != "" && !strings.HasPrefix(strings.ToLower(model), strings.ToLower(maker)) {
+		model = strings.TrimSpace(maker + " " + model)
+	}
+	data.CameraModel = model
+
+	if off, ok := ifd0.long(order, tagGPSIFD); ok {
+		if gps, err := readIFD(tiff, order, off); err == nil {
+			data.GPSLatitude = gps.coordinate(tiff, order, tagGPSLatitude, tagGPSLatitudeRef, "S")
+			data.GPSLongitude = gps.coordinate(tiff, order, tagGPSLongitude, tagGPSLongitudeRef, "W")
+		}
+	}
+	return data, nil
+}
+
+// ifdEntry is a raw 12-byte TIFF directory entry
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte // the 4-byte value/offset field
+}
+
+type ifd []ifdEntry
+
+// readIFD parses the directory at offset
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) (ifd, error) {
+	if int64(offset)+2 > int64(len(tiff)) {
+		return nil, fmt.Errorf("EXIF directory out of range")
+	}
+	n := int(order.Uint16(tiff[offset:]))
+	start := int(offset) + 2
+	if start+n*12 > len(tiff) {
+		return nil, fmt.Errorf("EXIF directory out of range")
+	}
+
+	entries := make(ifd, n)
+	for i := range entries {
+		e := tiff[start+i*12:]
+		entries[i] = ifdEntry{
+			tag:   order.Uint16(e),
+			typ:   order.Uint16(e[2:]),
+			count: order.Uint32(e[4:]),
+			value: e[8:12],
+		}
+	}
+	return entries, nil
+}
+
+func (d ifd) find(tag uint16) (ifdEntry, bool) {
+	for _, e := range d {
+		if e.tag == tag {
+			return e, true
+		}
+	}
+	return ifdEntry{}, false
+}
+
+// long returns a LONG (type 4) value
+func (d ifd) long(order binary.ByteOrder, tag uint16) (uint32, bool) {
+	e, ok := d.find(tag)
+	if !ok || e.typ != typeLong {
+		return 0, false
+	}
+	return order.Uint32(e.value), true
+}
+
+// ascii returns an ASCII (type 2) value without its NUL terminator
+func (d ifd) ascii(tiff []byte, order binary.ByteOrder, tag uint16) string {
+	e, ok := d.find(tag)
+	if !ok || e.typ != typeASCII {
+		return ""
+	}
+	raw := e.value[:min(int(e.count), 4)]
+	if e.count > 4 {
+		off := int64(order.Uint32(e.value))
+		if off+int64(e.count) > int64(len(tiff)) {
+			return ""
+		}
+		raw = tiff[off : off+int64(e.count)]
+	}
+	return strings.TrimRight(string(raw), "\x00 ")
+}
+
+// coordinate returns a GPS degrees/minutes/seconds value in decimal
+// degrees, negated when the reference matches negative ("S" or "W")
+func (d ifd) coordinate(tiff []byte, order binary.ByteOrder, tag, refTag uint16, negative string) *float64 {
+	e, ok := d.find(tag)
+	if !ok || e.typ != typeRational || e.count != 3 {
+		return nil
+	}
+	off := int64(order.Uint32(e.value))
+	if off+24 > int64(len(tiff)) {
+		return nil
+	}
+
+	var parts [3]float64
+	for i := range parts {
+		num := order.Uint32(tiff[off+int64(i)*8:])
+		den := order.Uint32(tiff[off+int64(i)*8+4:])
+		if den == 0 {
+			return nil
+		}
+		parts[i] = float64(num) / float64(den)
+	}
+
+	value := parts[0] + parts[1]/60 + parts[2]/3600
+	if d.ascii(tiff, order, refTag) == negative {
+		value = -value
+	}
+	return &value
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testdata/gps.jpg is a 16x16 JPEG whose big-endian EXIF block holds
+// "TestCam" "X100", 2024:05:17 08:30:00 and the Sydney Opera House at
+// 33°51'35.9"S 151°12'40.2"E, followed by an IPTC (APP13) block
+func TestReadExifGPS(t *testing.T) {
+	data, err := readExif("testdata/gps.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.GPSLatitude == nil || data.GPSLongitude == nil {
+		t.Fatalf("no coordinates decoded: %+v", data)
+	}
+	const wantLat, wantLon = -(33 + 51.0/60 + 35.9/3600), 151 + 12.0/60 + 40.2/3600
+	if math.Abs(*data.GPSLatitude-wantLat) > 1e-6 || math.Abs(*data.GPSLongitude-wantLon) > 1e-6 {
+		t.Errorf("coordinates = %f, %f; want %f, %f", *data.GPSLatitude, *data.GPSLongitude, wantLat, wantLon)
+	}
+	if data.CameraModel != "TestCam X100" {
+		t.Errorf("camera = %q, want TestCam X100", data.CameraModel)
+	}
+	if want := time.Date(2024, 5, 17, 8, 30, 0, 0, time.Local); !data.CapturedAt.Equal(want) {
+		t.Errorf("captured at %s, want %s", data.CapturedAt, want)
+	}
+
+	var info WallpaperInfo
+	data.apply(&info, true)
+	if info.GPSLatitude == nil || *info.GPSLatitude != *data.GPSLatitude {
+		t.Errorf("apply didn't copy the latitude")
+	}
+}
+
+// TestImportKeepsStrippedLocationOutOfLibrary imports testdata/gps.jpg
+// with and without StripMetadata and checks whether its GPS position
+// reaches the library and wallpapers.json. The capture time and camera
+// are recorded either way.
+func TestImportKeepsStrippedLocationOutOfLibrary(t *testing.T) {
+	for _, strip := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strip=%v", strip), func(t *testing.T) {
+			a := newTestApp(t)
+			a.changeSettings(func(s *AppSettings) {
+				s.StripMetadata = strip
+				s.MinFileSizeBytes = 0
+			})
+
+			info, err := a.ImportWallpaper("testdata/gps.jpg")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hasGPS := info.GPSLatitude != nil || info.GPSLongitude != nil; hasGPS == strip {
+				t.Errorf("GPS position recorded: %v, want %v", hasGPS, !strip)
+			}
+			if info.CapturedAt.IsZero() || info.CameraModel != "TestCam X100" {
+				t.Errorf("captured at %s with %q, want the fixture's time and camera", info.CapturedAt, info.CameraModel)
+			}
+
+			saved, err := os.ReadFile(a.getConfigPath("wallpapers.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hasGPS := strings.Contains(string(saved), "gps_latitude"); hasGPS == strip {
+				t.Errorf("wallpapers.json holds a GPS position: %v, want %v", hasGPS, !strip)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRating is the highest star rating
+const maxRating = 5
+
+// galleryThumbnailSize is the longest side of thumbnails in the HTML export
+const galleryThumbnailSize = 320
+
+// csvHeader names the columns written by ExportMetadataCSV
+var csvHeader = []string{
+	"id", "filename", "source_url", "download_date", "size_bytes",
+	"width", "height", "tags", "rating", "favorite",
+}
+
+// csvRow renders one wallpaper as a row matching csvHeader. Tags are joined
+// with semicolons so the cell stays a single field.
+func csvRow(wp WallpaperInfo) []string {
+	return []string{
+		wp.ID,
+		wp.Filename,
+		wp.SourceURL,
+		wp.DownloadDate.Format(time.RFC3339),
+		strconv.FormatInt(wp.FileSize, 10),
+		strconv.Itoa(wp.Width),
+		strconv.Itoa(wp.Height),
+		strings.Join(wp.Tags, ";"),
+		strconv.Itoa(wp.Rating),
+		strconv.FormatBool(wp.Favorite),
+	}
+}
+
+// ExportMetadataCSV writes one row per wallpaper to path, with a header row.
+// Fields are quoted as needed by encoding/csv.
+func (a *App) ExportMetadataCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot write %s: %v", path, err)
+	}
+
+	w := csv.NewWriter(f)
+	w.Write(csvHeader)
+	for _, wp := range a.GetWallpapers() {
+		w.Write(csvRow(wp))
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+// galleryItem is one card of the HTML export
+type galleryItem struct {
+	Thumbnail template.URL
+	Name      string
+	Source    string
+	Date      string
+	Size      string
+	Camera    string
+	Tags      string
+}
+
+// galleryTemplate renders a self-contained contact sheet: styles are inline
+// and thumbnails are data URIs, so the page works offline
+var galleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Wallset gallery</title>
+<style>
+body { margin: 24px; font-family: system-ui, sans-serif; background: #1b2636; color: #e8edf3; }
+h1 { font-weight: 500; }
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(320px, 1fr)); gap: 16px; }
+figure { margin: 0; background: #243247; border-radius: 8px; overflow: hidden; }
+img { display: block; width: 100%; height: 180px; object-fit: cover; background: #111; }
+figcaption { padding: 8px 12px; font-size: 13px; line-height: 1.5; overflow-wrap: anywhere; }
+.name { font-weight: 600; }
+.meta { color: #9fb0c4; }
+</style>
+</head>
+<body>
+<h1>Wallset gallery</h1>
+<p class="meta">{{len .Items}} wallpapers, exported {{.Exported}}</p>
+<div class="grid">
+{{- range .Items}}
+<figure>
+{{- if .Thumbnail}}<img src="{{.Thumbnail}}" alt="{{.Name}}">{{else}}<img alt="{{.Name}}">{{end}}
+<figcaption>
+<div class="name">{{.Name}}</div>
+{{- if .Source}}<div class="meta">Source: {{.Source}}</div>{{end}}
+<div class="meta">{{.Date}}{{if .Size}} · {{.Size}}{{end}}</div>
+{{- if .Camera}}<div class="meta">{{.Camera}}</div>{{end}}
+{{- if .Tags}}<div class="meta">Tags: {{.Tags}}</div>{{end}}
+</figcaption>
+</figure>
+{{- end}}
+</div>
+</body>
+</html>
+`))
+
+// galleryCard describes wp for the HTML export, embedding its cached
+// thumbnail. A thumbnail that can't be made leaves the image blank.
+func (a *App) galleryCard(wp WallpaperInfo) galleryItem {
+	item := galleryItem{
+		Name:   wp.DisplayName,
+		Source: wp.SourceURL,
+		Date:   wp.DownloadDate.Format("2 Jan 2006"),
+		Camera: wp.CameraModel,
+		Tags:   strings.Join(wp.Tags, ", "),
+	}
+	if wp.Width > 0 && wp.Height > 0 {
+		item.Size = fmt.Sprintf("%d×%d", wp.Width, wp.Height)
+	}
+
+	if path, err := a.thumbnail(wp, galleryThumbnailSize); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			// Trusted: built here from a JPEG we encoded
+			item.Thumbnail = template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data))
+		}
+	}
+	return item
+}
+
+// ExportGalleryHTML writes a single self-contained HTML contact sheet of
+// the library to path, with embedded thumbnails and source captions
+func (a *App) ExportGalleryHTML(path string) error {
+	var items []galleryItem
+	for _, wp := range a.GetWallpapers() {
+		items = append(items, a.galleryCard(wp))
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot write %s: %v", path, err)
+	}
+	err = galleryTemplate.Execute(f, struct {
+		Items    []galleryItem
+		Exported string
+	}{items, time.Now().Format("2 Jan 2006 15:04")})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// ExportSettings writes the settings as JSON to path, e.g. to copy them to
+// another machine. Secrets are written as secret store references, as in
+// settings.json; with redactSecrets they are masked entirely so the file
+// can be shared.
+func (a *App) ExportSettings(path string, redactSecrets bool) error {
+	settings := withSecretRefs(a.GetSettings())
+	if redactSecrets {
+		settings = redactSettings(settings)
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("cannot write %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxFilenameLength bounds sanitized filenames, in runes, before the ID
+// suffix and extension are added
+const maxFilenameLength = 80
+
+// dispositionFilename returns a safe filename from a Content-Disposition
+// header value, with shortID appended to avoid collisions, or "" if the
+// header has no usable filename. The extension is kept when it is an
+// image type we can decode, otherwise fallbackExt is used.
+func dispositionFilename(header, shortID, fallbackExt string) string {
+	base, ext := dispositionName(header, fallbackExt)
+	if base == "" {
+		return ""
+	}
+	return base + "_" + shortID + ext
+}
+
+// dispositionName returns the sanitized base name and the extension of the
+// filename in a Content-Disposition header, or "" when there is none. The
+// extension falls back to fallbackExt unless it is one we can decode.
+func dispositionName(header, fallbackExt string) (string, string) {
+	if header == "" {
+		return "", fallbackExt
+	}
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return "", fallbackExt
+	}
+
+	// ParseMediaType decodes RFC 5987 filename* values into "filename"
+	name := params["filename"]
+
+	// Servers may send either separator; only the last element counts
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+
+	ext := strings.ToLower(filepath.Ext(name))
+	if !importExtensions[ext] {
+		ext = fallbackExt
+	}
+	if ext == ".jpeg" {
+		ext = ".jpg"
+	}
+	return sanitizeFilename(strings.TrimSuffix(name, filepath.Ext(name))), ext
+}
+
+// sanitizeFilename removes characters that are unsafe in filenames on any
+// supported OS and limits the length
+func sanitizeFilename(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsControl(r):
+			continue
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	// Leading dots would hide the file; trailing dots and spaces are
+	// stripped by Windows
+	clean := strings.Trim(b.String(), ". ")
+	if runes := []rune(clean); len(runes) > maxFilenameLength {
+		clean = strings.TrimRight(string(runes[:maxFilenameLength]), ". ")
+	}
+	return clean
+}
+
+// filenameTokens are the values a FilenameTemplate can refer to
+type filenameTokens struct {
+	Date     time.Time
+	Source   string // host a download came from; "imported" for imports
+	Title    string
+	ID       string
+	Ext      string // with the leading dot
+	Original string // an import's original basename, without extension
+}
+
+// filenameTokenNames lists the tokens FilenameTemplate accepts
+var filenameTokenNames = []string{"{date}", "{unix}", "{source}", "{title}", "{id}", "{ext}", "{original}"}
+
+// validateFilenameTemplate rejects templates with unknown tokens or path
+// separators. The empty template keeps the built-in names.
+func validateFilenameTemplate(template string) error {
+	if strings.ContainsAny(template, `/\`) {
+		return fmt.Errorf("filename template must not contain path separators")
+	}
+	rest := template
+	for _, token := range filenameTokenNames {
+		rest = strings.ReplaceAll(rest, token, "")
+	}
+	if i := strings.Index(rest, "{"); i >= 0 {
+		return fmt.Errorf("unknown token in filename template near %q (use %s)", rest[i:], strings.Join(filenameTokenNames, ", "))
+	}
+	return nil
+}
+
+// expandFilenameTemplate fills in a FilenameTemplate. Every value is
+// sanitized, and the extension is always kept at the end, added if the
+// template leaves out {ext}.
+func expandFilenameTemplate(template string, t filenameTokens) string {
+	orDefault := func(v, fallback string) string {
+		if v = sanitizeFilename(v); v != "" {
+			return v
+		}
+		return fallback
+	}
+	title := orDefault(t.Title, "untitled")
+	ext := strings.TrimPrefix(t.Ext, ".")
+
+	base := strings.TrimSuffix(strings.TrimSuffix(template, "{ext}"), ".")
+	base = strings.NewReplacer(
+		"{date}", t.Date.Format("2006-01-02"),
+		"{unix}", strconv.FormatInt(t.Date.Unix(), 10),
+		"{source}", orDefault(t.Source, "unknown"),
+		"{title}", title,
+		"{id}", t.ID,
+		"{ext}", ext,
+		"{original}", orDefault(t.Original, title),
+	).Replace(base)
+
+	base = sanitizeFilename(base)
+	if base == "" {
+		base = orDefault(t.ID, "wallpaper")
+	}
+	return base + "." + ext
+}
+
+// uniqueFilename returns name, or name with a counter before the extension
+// ("_2", "_3", ...) if a file by that name already exists in dir
+func uniqueFilename(dir, name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(filepath.Join(dir, candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+}
+
+// claimFilename picks a free name in dir for name, as uniqueFilename
+// does, and creates it empty so that a concurrent download or import
+// can't pick the same one. The caller writes or renames its file over the
+// placeholder, or removes it on failure.
+func claimFilename(dir, name string) (string, error) {
+	for {
+		path := filepath.Join(dir, uniqueFilename(dir, name))
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return path, f.Close()
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		// Taken since uniqueFilename looked; it will skip it now
+	}
+}
+
+// generatedFilename returns the built-in name for a new wallpaper: prefix,
+// the Unix time and the start of id, e.g. wallpaper_1700000000_1a2b3c4d.jpg.
+// Names can still repeat within a second, so they go through
+// claimFilename.
+func generatedFilename(prefix, id, ext string) string {
+	return fmt.Sprintf("%s_%d_%s%s", prefix, time.Now().Unix(), id[:min(8, len(id))], ext)
+}
+
+// convertedPath returns where a conversion of path to ext is saved: path
+// itself when it already has that extension, otherwise a claimed name
+// beside it, so a conversion never replaces an unrelated file
+func convertedPath(path, ext string) (string, error) {
+	if strings.EqualFold(filepath.Ext(path), ext) {
+		return path, nil
+	}
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return claimFilename(filepath.Dir(path), base+ext)
+}
+
+// matchesFilename reports whether current is name, possibly with a counter
+// added by uniqueFilename
+func matchesFilename(current, name string) bool {
+	if current == name {
+		return true
+	}
+	ext := filepath.Ext(name)
+	if !strings.HasSuffix(current, ext) {
+		return false
+	}
+	counter, ok := strings.CutPrefix(strings.TrimSuffix(current, ext), strings.TrimSuffix(name, ext)+"_")
+	if !ok {
+		return false
+	}
+	_, err := strconv.Atoi(counter)
+	return err == nil
+}
+
+// sourceToken returns the {source} value for a wallpaper's SourceURL: the
+// host of a download, or "imported" for a local file
+func sourceToken(source string) string {
+	if u, err := url.Parse(source); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return u.Hostname()
+	}
+	return "imported"
+}
+
+// downloadFilename returns the name to store a download from rawURL under,
+// or "" to keep the generated one. Without a template, the server's
+// Content-Disposition name is used when it sends one.
+func (a *App) downloadFilename(rawURL, disposition, id string) string {
+	if a.GetSettings().FilenameTemplate == "" {
+		return dispositionFilename(disposition, id[:8], ".jpg")
+	}
+
+	title, ext := dispositionName(disposition, ".jpg")
+	if title == "" {
+		if u, err := url.Parse(rawURL); err == nil {
+			title = strings.TrimSuffix(path.Base(u.Path), path.Ext(u.Path))
+		}
+	}
+	return expandFilenameTemplate(a.GetSettings().FilenameTemplate, filenameTokens{
+		Date:   time.Now(),
+		Source: sourceToken(rawURL),
+		Title:  title,
+		ID:     id[:8],
+		Ext:    ext,
+	})
+}
+
+// importFilename returns the name to store an import of original under
+func (a *App) importFilename(original, id, ext string) string {
+	if a.GetSettings().FilenameTemplate == "" {
+		return generatedFilename("imported", id, ext)
+	}
+	base := strings.TrimSuffix(filepath.Base(original), filepath.Ext(original))
+	return expandFilenameTemplate(a.GetSettings().FilenameTemplate, filenameTokens{
+		Date:     time.Now(),
+		Source:   sourceToken(original),
+		Title:    base,
+		ID:       id[:8],
+		Ext:      ext,
+		Original: base,
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestClaimFilenameConcurrent has many goroutines claim the same name in
+// a directory that already holds it and its first counters, and checks
+// that no two get the same path and no existing file is reused
+func TestClaimFilenameConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	existing := []string{"sunset.jpg", "sunset_2.jpg", "sunset_3.jpg", "sunset_5.jpg"}
+	for _, name := range existing {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const claims = 50
+	paths := make([]string, claims)
+	var wg sync.WaitGroup
+	for i := range claims {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path, err := claimFilename(dir, "sunset.jpg")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			paths[i] = path
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, name := range existing {
+		seen[filepath.Join(dir, name)] = true
+	}
+	for _, path := range paths {
+		if seen[path] {
+			t.Errorf("%s was handed out twice or was already taken", filepath.Base(path))
+		}
+		seen[path] = true
+		if filepath.Dir(path) != dir {
+			t.Errorf("%s is outside %s", path, dir)
+		}
+	}
+	for _, name := range existing {
+		if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != name {
+			t.Errorf("%s was overwritten", name)
+		}
+	}
+}
@@ -2,24 +2,134 @@
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
 
+export function CancelDownload():Promise<boolean>;
+
+export function CancelPrefetch():Promise<void>;
+
+export function CheckForUpdate():Promise<main.UpdateInfo>;
+
+export function CleanupCaches():Promise<main.CleanupReport>;
+
+export function CopyWallpaper(arg1:string,arg2:string,arg3:boolean):Promise<string>;
+
+export function CreateContactSheet(arg1:Array<string>,arg2:number,arg3:string):Promise<void>;
+
+export function CreateProfile(arg1:string):Promise<void>;
+
+export function DeleteProfile(arg1:string):Promise<void>;
+
 export function DeleteWallpaper(arg1:string):Promise<void>;
 
+export function DownloadAndSetFromURL(arg1:string):Promise<main.WallpaperInfo>;
+
 export function DownloadAndSetWallpaper():Promise<main.WallpaperInfo>;
 
+export function ExplainSetWallpaper(arg1:string):Promise<Array<main.PlannedCommand>>;
+
+export function ExportGalleryHTML(arg1:string):Promise<void>;
+
+export function ExportMetadataCSV(arg1:string):Promise<void>;
+
+export function ExportSettings(arg1:string,arg2:boolean):Promise<void>;
+
+export function FindSimilar(arg1:string):Promise<Array<main.WallpaperInfo>>;
+
+export function GenerateCollage(arg1:Array<string>,arg2:string):Promise<main.WallpaperInfo>;
+
+export function GetAutoChangeStatus():Promise<main.AutoChangeStatus>;
+
+export function GetChangeHistory(arg1:number,arg2:number):Promise<Array<main.ChangeEvent>>;
+
+export function GetColorScheme():Promise<string>;
+
+export function GetDailyChanges(arg1:number):Promise<Array<main.DailyChanges>>;
+
+export function GetDesktopEnvironment():Promise<main.DesktopEnvironment>;
+
+export function GetHealth():Promise<main.HealthReport>;
+
+export function GetLastError():Promise<main.ChangeFailure>;
+
+export function GetMonitors():Promise<Array<main.MonitorInfo>>;
+
+export function GetSecretBackend():Promise<string>;
+
 export function GetSettings():Promise<main.AppSettings>;
 
-export function GetWallpaperAsBase64(arg1:string):Promise<string>;
+export function GetSourceStatus():Promise<Array<main.SourceStatus>>;
+
+export function GetSunTimes():Promise<main.SunTimes>;
+
+export function GetThumbnail(arg1:string,arg2:number):Promise<string>;
+
+export function GetVersion():Promise<string>;
+
+export function GetWallpaperAsBase64(arg1:string,arg2:number):Promise<string>;
 
 export function GetWallpaperDirectory():Promise<string>;
 
 export function GetWallpapers():Promise<Array<main.WallpaperInfo>>;
 
+export function GetWallpapersByOrientation(arg1:string):Promise<Array<main.WallpaperInfo>>;
+
+export function ImportWallpaper(arg1:string):Promise<main.WallpaperInfo>;
+
+export function InstallShellIntegration():Promise<void>;
+
+export function ListProfiles():Promise<Array<main.ProfileInfo>>;
+
+export function ListWallpapers(arg1:main.ListOptions):Promise<Array<main.WallpaperInfo>>;
+
+export function NextWallpaper():Promise<main.WallpaperInfo>;
+
 export function OpenWallpaperDirectory():Promise<void>;
 
+export function PinCurrentForToday():Promise<main.AutoChangeStatus>;
+
+export function PinUntil(arg1:any):Promise<main.AutoChangeStatus>;
+
+export function PinWallpaperToMonitor(arg1:string,arg2:string):Promise<void>;
+
+export function PrefetchWallpapers(arg1:number):Promise<main.PrefetchResult>;
+
+export function PreviousWallpaper():Promise<main.WallpaperInfo>;
+
 export function QuitApp():Promise<void>;
 
-export function SetWallpaper(arg1:string):Promise<void>;
+export function RemoveShellIntegration():Promise<void>;
+
+export function RepairLibrary(arg1:boolean):Promise<main.RepairReport>;
+
+export function SetAutoChangePaused(arg1:boolean):Promise<main.AutoChangeStatus>;
+
+export function SetDarkModeWallpaper(arg1:string):Promise<void>;
+
+export function SetDisplayName(arg1:string,arg2:string):Promise<void>;
+
+export function SetFavorite(arg1:string,arg2:boolean):Promise<void>;
+
+export function SetLockScreenWallpaper(arg1:string):Promise<void>;
+
+export function SetNotes(arg1:string,arg2:string):Promise<void>;
+
+export function SetOverlayText(arg1:string):Promise<void>;
+
+export function SetRating(arg1:string,arg2:number):Promise<void>;
+
+export function SetWallpaperByID(arg1:string):Promise<main.WallpaperInfo>;
 
 export function ShowWindow():Promise<void>;
 
+export function SkipCurrent():Promise<main.WallpaperInfo>;
+
+export function SwitchProfile(arg1:string):Promise<main.AppSettings>;
+
+export function TrustSFTPHostKey(arg1:string,arg2:string):Promise<void>;
+
+export function Unpin():Promise<main.AutoChangeStatus>;
+
+export function UnpinMonitor(arg1:string):Promise<void>;
+
 export function UpdateSettings(arg1:main.AppSettings):Promise<void>;
+
+export function VerifyIntegrity():Promise<main.IntegrityReport>;
@@ -2,20 +2,132 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function CancelDownload() {
+  return window['go']['main']['App']['CancelDownload']();
+}
+
+export function CancelPrefetch() {
+  return window['go']['main']['App']['CancelPrefetch']();
+}
+
+export function CheckForUpdate() {
+  return window['go']['main']['App']['CheckForUpdate']();
+}
+
+export function CleanupCaches() {
+  return window['go']['main']['App']['CleanupCaches']();
+}
+
+export function CopyWallpaper(arg1, arg2, arg3) {
+  return window['go']['main']['App']['CopyWallpaper'](arg1, arg2, arg3);
+}
+
+export function CreateContactSheet(arg1, arg2, arg3) {
+  return window['go']['main']['App']['CreateContactSheet'](arg1, arg2, arg3);
+}
+
+export function CreateProfile(arg1) {
+  return window['go']['main']['App']['CreateProfile'](arg1);
+}
+
+export function DeleteProfile(arg1) {
+  return window['go']['main']['App']['DeleteProfile'](arg1);
+}
+
 export function DeleteWallpaper(arg1) {
   return window['go']['main']['App']['DeleteWallpaper'](arg1);
 }
 
+export function DownloadAndSetFromURL(arg1) {
+  return window['go']['main']['App']['DownloadAndSetFromURL'](arg1);
+}
+
 export function DownloadAndSetWallpaper() {
   return window['go']['main']['App']['DownloadAndSetWallpaper']();
 }
 
+export function ExplainSetWallpaper(arg1) {
+  return window['go']['main']['App']['ExplainSetWallpaper'](arg1);
+}
+
+export function ExportGalleryHTML(arg1) {
+  return window['go']['main']['App']['ExportGalleryHTML'](arg1);
+}
+
+export function ExportMetadataCSV(arg1) {
+  return window['go']['main']['App']['ExportMetadataCSV'](arg1);
+}
+
+export function ExportSettings(arg1, arg2) {
+  return window['go']['main']['App']['ExportSettings'](arg1, arg2);
+}
+
+export function FindSimilar(arg1) {
+  return window['go']['main']['App']['FindSimilar'](arg1);
+}
+
+export function GenerateCollage(arg1, arg2) {
+  return window['go']['main']['App']['GenerateCollage'](arg1, arg2);
+}
+
+export function GetAutoChangeStatus() {
+  return window['go']['main']['App']['GetAutoChangeStatus']();
+}
+
+export function GetChangeHistory(arg1, arg2) {
+  return window['go']['main']['App']['GetChangeHistory'](arg1, arg2);
+}
+
+export function GetColorScheme() {
+  return window['go']['main']['App']['GetColorScheme']();
+}
+
+export function GetDailyChanges(arg1) {
+  return window['go']['main']['App']['GetDailyChanges'](arg1);
+}
+
+export function GetDesktopEnvironment() {
+  return window['go']['main']['App']['GetDesktopEnvironment']();
+}
+
+export function GetHealth() {
+  return window['go']['main']['App']['GetHealth']();
+}
+
+export function GetLastError() {
+  return window['go']['main']['App']['GetLastError']();
+}
+
+export function GetMonitors() {
+  return window['go']['main']['App']['GetMonitors']();
+}
+
+export function GetSecretBackend() {
+  return window['go']['main']['App']['GetSecretBackend']();
+}
+
 export function GetSettings() {
   return window['go']['main']['App']['GetSettings']();
 }
 
-export function GetWallpaperAsBase64(arg1) {
-  return window['go']['main']['App']['GetWallpaperAsBase64'](arg1);
+export function GetSourceStatus() {
+  return window['go']['main']['App']['GetSourceStatus']();
+}
+
+export function GetSunTimes() {
+  return window['go']['main']['App']['GetSunTimes']();
+}
+
+export function GetThumbnail(arg1, arg2) {
+  return window['go']['main']['App']['GetThumbnail'](arg1, arg2);
+}
+
+export function GetVersion() {
+  return window['go']['main']['App']['GetVersion']();
+}
+
+export function GetWallpaperAsBase64(arg1, arg2) {
+  return window['go']['main']['App']['GetWallpaperAsBase64'](arg1, arg2);
 }
 
 export function GetWallpaperDirectory() {
@@ -26,22 +138,130 @@ export function GetWallpapers() {
   return window['go']['main']['App']['GetWallpapers']();
 }
 
+export function GetWallpapersByOrientation(arg1) {
+  return window['go']['main']['App']['GetWallpapersByOrientation'](arg1);
+}
+
+export function ImportWallpaper(arg1) {
+  return window['go']['main']['App']['ImportWallpaper'](arg1);
+}
+
+export function InstallShellIntegration() {
+  return window['go']['main']['App']['InstallShellIntegration']();
+}
+
+export function ListProfiles() {
+  return window['go']['main']['App']['ListProfiles']();
+}
+
+export function ListWallpapers(arg1) {
+  return window['go']['main']['App']['ListWallpapers'](arg1);
+}
+
+export function NextWallpaper() {
+  return window['go']['main']['App']['NextWallpaper']();
+}
+
 export function OpenWallpaperDirectory() {
   return window['go']['main']['App']['OpenWallpaperDirectory']();
 }
 
+export function PinCurrentForToday() {
+  return window['go']['main']['App']['PinCurrentForToday']();
+}
+
+export function PinUntil(arg1) {
+  return window['go']['main']['App']['PinUntil'](arg1);
+}
+
+export function PinWallpaperToMonitor(arg1, arg2) {
+  return window['go']['main']['App']['PinWallpaperToMonitor'](arg1, arg2);
+}
+
+export function PrefetchWallpapers(arg1) {
+  return window['go']['main']['App']['PrefetchWallpapers'](arg1);
+}
+
+export function PreviousWallpaper() {
+  return window['go']['main']['App']['PreviousWallpaper']();
+}
+
 export function QuitApp() {
   return window['go']['main']['App']['QuitApp']();
 }
 
-export function SetWallpaper(arg1) {
-  return window['go']['main']['App']['SetWallpaper'](arg1);
+export function RemoveShellIntegration() {
+  return window['go']['main']['App']['RemoveShellIntegration']();
+}
+
+export function RepairLibrary(arg1) {
+  return window['go']['main']['App']['RepairLibrary'](arg1);
+}
+
+export function SetAutoChangePaused(arg1) {
+  return window['go']['main']['App']['SetAutoChangePaused'](arg1);
+}
+
+export function SetDarkModeWallpaper(arg1) {
+  return window['go']['main']['App']['SetDarkModeWallpaper'](arg1);
+}
+
+export function SetDisplayName(arg1, arg2) {
+  return window['go']['main']['App']['SetDisplayName'](arg1, arg2);
+}
+
+export function SetFavorite(arg1, arg2) {
+  return window['go']['main']['App']['SetFavorite'](arg1, arg2);
+}
+
+export function SetLockScreenWallpaper(arg1) {
+  return window['go']['main']['App']['SetLockScreenWallpaper'](arg1);
+}
+
+export function SetNotes(arg1, arg2) {
+  return window['go']['main']['App']['SetNotes'](arg1, arg2);
+}
+
+export function SetOverlayText(arg1) {
+  return window['go']['main']['App']['SetOverlayText'](arg1);
+}
+
+export function SetRating(arg1, arg2) {
+  return window['go']['main']['App']['SetRating'](arg1, arg2);
+}
+
+export function SetWallpaperByID(arg1) {
+  return window['go']['main']['App']['SetWallpaperByID'](arg1);
 }
 
 export function ShowWindow() {
   return window['go']['main']['App']['ShowWindow']();
 }
 
+export function SkipCurrent() {
+  return window['go']['main']['App']['SkipCurrent']();
+}
+
+export function SwitchProfile(arg1) {
+  return window['go']['main']['App']['SwitchProfile'](arg1);
+}
+
+export function TrustSFTPHostKey(arg1, arg2) {
+  return window['go']['main']['App']['TrustSFTPHostKey'](arg1, arg2);
+}
+
+export function Unpin() {
+  return window['go']['main']['App']['Unpin']();
+}
+
+export function UnpinMonitor(arg1) {
+  return window['go']['main']['App']['UnpinMonitor'](arg1);
+}
+
 export function UpdateSettings(arg1) {
   return window['go']['main']['App']['UpdateSettings'](arg1);
 }
+
+export function VerifyIntegrity() {
+  return window['go']['main']['App']['VerifyIntegrity']();
+}
@@ -2,9 +2,77 @@ export namespace main {
 	
 	export class AppSettings {
 	    auto_change_enabled: boolean;
+	    close_to_tray: boolean;
+	    restore_on_startup: boolean;
+	    strip_metadata: boolean;
+	    set_lock_screen_too: boolean;
 	    change_interval_hours: number;
 	    download_sources: string[];
 	    max_wallpapers: number;
+	    source_rotation?: string;
+	    pinned_monitors?: {[key: string]: string};
+	    downloads_disabled: boolean;
+	    local_rotation?: string;
+	    download_schedule: DownloadSchedule;
+	    change_schedule: ChangeSchedule;
+	    dry_run: boolean;
+	    max_wallpaper_age_days: number;
+	    wallpaper_directory?: string;
+	    filename_template?: string;
+	    prefer_luminance_by_time: boolean;
+	    luminance_threshold: number;
+	    day_start_hour: number;
+	    night_start_hour: number;
+	    follow_color_scheme: boolean;
+	    dark_mode_wallpaper_id?: string;
+	    latitude?: number;
+	    longitude?: number;
+	    follow_sun: boolean;
+	    change_on_theme_boundary: boolean;
+	    weather_enabled: boolean;
+	    seasonal_themes: boolean;
+	    seasonal_calendar?: SeasonalTheme[];
+	    orientation_filter: string;
+	    content_filter: string;
+	    aspect_ratio_tolerance: number;
+	    shuffle_recency_weight: number;
+	    shuffle_rating_weight: number;
+	    similarity_threshold: number;
+	    use_screen_resolution: boolean;
+	    max_download_speed_kbps: number;
+	    blur_radius: number;
+	    max_file_size_bytes: number;
+	    min_file_size_bytes: number;
+	    max_redirects: number;
+	    allow_redirect_downgrade: boolean;
+	    parallel_source_fetch: boolean;
+	    keep_original: boolean;
+	    keep_extra_downloads: boolean;
+	    trash_retention_days: number;
+	    verify_integrity_on_load: boolean;
+	    max_preview_size_mb: number;
+	    enable_hotkeys: boolean;
+	    hotkeys: HotkeyConfig;
+	    pause_during_fullscreen: boolean;
+	    only_change_when_idle: boolean;
+	    idle_threshold_seconds: number;
+	    change_on_unlock: boolean;
+	    min_minutes_between_unlock_changes: number;
+	    collage_gutter: number;
+	    collage_background: string;
+	    set_collage_as_wallpaper: boolean;
+	    overlay_text: string;
+	    overlay_position: string;
+	    overlay_font_size: number;
+	    overlay_color: string;
+	    notify_on_change: boolean;
+	    update_check_enabled: boolean;
+	    control_api_enabled: boolean;
+	    control_api_address: string;
+	    control_api_token: string;
+	    extension_origin?: string;
+	    user_agent?: string;
+	    source_configs?: {[key: string]: SourceConfig};
 	
 	    static createFrom(source: any = {}) {
 	        return new AppSettings(source);
@@ -13,10 +81,856 @@ export namespace main {
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.auto_change_enabled = source["auto_change_enabled"];
+	        this.close_to_tray = source["close_to_tray"];
+	        this.restore_on_startup = source["restore_on_startup"];
+	        this.strip_metadata = source["strip_metadata"];
+	        this.set_lock_screen_too = source["set_lock_screen_too"];
 	        this.change_interval_hours = source["change_interval_hours"];
 	        this.download_sources = source["download_sources"];
 	        this.max_wallpapers = source["max_wallpapers"];
+	        this.source_rotation = source["source_rotation"];
+	        this.pinned_monitors = source["pinned_monitors"];
+	        this.downloads_disabled = source["downloads_disabled"];
+	        this.local_rotation = source["local_rotation"];
+	        this.download_schedule = this.convertValues(source["download_schedule"], DownloadSchedule);
+	        this.change_schedule = this.convertValues(source["change_schedule"], ChangeSchedule);
+	        this.dry_run = source["dry_run"];
+	        this.max_wallpaper_age_days = source["max_wallpaper_age_days"];
+	        this.wallpaper_directory = source["wallpaper_directory"];
+	        this.filename_template = source["filename_template"];
+	        this.prefer_luminance_by_time = source["prefer_luminance_by_time"];
+	        this.luminance_threshold = source["luminance_threshold"];
+	        this.day_start_hour = source["day_start_hour"];
+	        this.night_start_hour = source["night_start_hour"];
+	        this.follow_color_scheme = source["follow_color_scheme"];
+	        this.dark_mode_wallpaper_id = source["dark_mode_wallpaper_id"];
+	        this.latitude = source["latitude"];
+	        this.longitude = source["longitude"];
+	        this.follow_sun = source["follow_sun"];
+	        this.change_on_theme_boundary = source["change_on_theme_boundary"];
+	        this.weather_enabled = source["weather_enabled"];
+	        this.seasonal_themes = source["seasonal_themes"];
+	        this.seasonal_calendar = this.convertValues(source["seasonal_calendar"], SeasonalTheme);
+	        this.orientation_filter = source["orientation_filter"];
+	        this.content_filter = source["content_filter"];
+	        this.aspect_ratio_tolerance = source["aspect_ratio_tolerance"];
+	        this.shuffle_recency_weight = source["shuffle_recency_weight"];
+	        this.shuffle_rating_weight = source["shuffle_rating_weight"];
+	        this.similarity_threshold = source["similarity_threshold"];
+	        this.use_screen_resolution = source["use_screen_resolution"];
+	        this.max_download_speed_kbps = source["max_download_speed_kbps"];
+	        this.blur_radius = source["blur_radius"];
+	        this.max_file_size_bytes = source["max_file_size_bytes"];
+	        this.min_file_size_bytes = source["min_file_size_bytes"];
+	        this.max_redirects = source["max_redirects"];
+	        this.allow_redirect_downgrade = source["allow_redirect_downgrade"];
+	        this.parallel_source_fetch = source["parallel_source_fetch"];
+	        this.keep_original = source["keep_original"];
+	        this.keep_extra_downloads = source["keep_extra_downloads"];
+	        this.trash_retention_days = source["trash_retention_days"];
+	        this.verify_integrity_on_load = source["verify_integrity_on_load"];
+	        this.max_preview_size_mb = source["max_preview_size_mb"];
+	        this.enable_hotkeys = source["enable_hotkeys"];
+	        this.hotkeys = this.convertValues(source["hotkeys"], HotkeyConfig);
+	        this.pause_during_fullscreen = source["pause_during_fullscreen"];
+	        this.only_change_when_idle = source["only_change_when_idle"];
+	        this.idle_threshold_seconds = source["idle_threshold_seconds"];
+	        this.change_on_unlock = source["change_on_unlock"];
+	        this.min_minutes_between_unlock_changes = source["min_minutes_between_unlock_changes"];
+	        this.collage_gutter = source["collage_gutter"];
+	        this.collage_background = source["collage_background"];
+	        this.set_collage_as_wallpaper = source["set_collage_as_wallpaper"];
+	        this.overlay_text = source["overlay_text"];
+	        this.overlay_position = source["overlay_position"];
+	        this.overlay_font_size = source["overlay_font_size"];
+	        this.overlay_color = source["overlay_color"];
+	        this.notify_on_change = source["notify_on_change"];
+	        this.update_check_enabled = source["update_check_enabled"];
+	        this.control_api_enabled = source["control_api_enabled"];
+	        this.control_api_address = source["control_api_address"];
+	        this.control_api_token = source["control_api_token"];
+	        this.extension_origin = source["extension_origin"];
+	        this.user_agent = source["user_agent"];
+	        this.source_configs = this.convertValues(source["source_configs"], SourceConfig, true);
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AutoChangeStatus {
+	    enabled: boolean;
+	    paused: boolean;
+	    state: string;
+	    // Go type: time
+	    paused_until: any;
+	    // Go type: time
+	    pinned_until: any;
+	    // Go type: time
+	    last_change: any;
+	    // Go type: time
+	    next_change: any;
+	    current_path: string;
+	    current_title: string;
+	    seasonal_theme?: string;
+	    seasonal_keywords?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new AutoChangeStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.paused = source["paused"];
+	        this.state = source["state"];
+	        this.paused_until = this.convertValues(source["paused_until"], null);
+	        this.pinned_until = this.convertValues(source["pinned_until"], null);
+	        this.last_change = this.convertValues(source["last_change"], null);
+	        this.next_change = this.convertValues(source["next_change"], null);
+	        this.current_path = source["current_path"];
+	        this.current_title = source["current_title"];
+	        this.seasonal_theme = source["seasonal_theme"];
+	        this.seasonal_keywords = source["seasonal_keywords"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ChangeEvent {
+	    // Go type: time
+	    time: any;
+	    trigger: string;
+	    success: boolean;
+	    error?: string;
+	    wallpaper_id?: string;
+	    path?: string;
+	    title?: string;
+	    source_url?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChangeEvent(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = this.convertValues(source["time"], null);
+	        this.trigger = source["trigger"];
+	        this.success = source["success"];
+	        this.error = source["error"];
+	        this.wallpaper_id = source["wallpaper_id"];
+	        this.path = source["path"];
+	        this.title = source["title"];
+	        this.source_url = source["source_url"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ChangeFailure {
+	    // Go type: time
+	    time: any;
+	    trigger: string;
+	    error: string;
+	    sources?: SourceFailure[];
+	    library_fallback: boolean;
+	    remedy: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChangeFailure(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = this.convertValues(source["time"], null);
+	        this.trigger = source["trigger"];
+	        this.error = source["error"];
+	        this.sources = this.convertValues(source["sources"], SourceFailure);
+	        this.library_fallback = source["library_fallback"];
+	        this.remedy = source["remedy"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ChangeSchedule {
+	    start_hour: number;
+	    end_hour: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChangeSchedule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.start_hour = source["start_hour"];
+	        this.end_hour = source["end_hour"];
+	    }
+	}
+	export class CleanupReport {
+	    thumbnails_removed: number;
+	    trash_removed: number;
+	    originals_removed: number;
+	    bytes_reclaimed: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CleanupReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.thumbnails_removed = source["thumbnails_removed"];
+	        this.trash_removed = source["trash_removed"];
+	        this.originals_removed = source["originals_removed"];
+	        this.bytes_reclaimed = source["bytes_reclaimed"];
+	    }
+	}
+	export class DailyChanges {
+	    date: string;
+	    successes: number;
+	    failures: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DailyChanges(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.date = source["date"];
+	        this.successes = source["successes"];
+	        this.failures = source["failures"];
+	    }
+	}
+	export class DesktopEnvironment {
+	    os: string;
+	    desktop: string;
+	    wayland: boolean;
+	    xdg_current_desktop: string;
+	    desktop_session: string;
+	    commands: PlannedCommand[];
+	
+	    static createFrom(source: any = {}) {
+	        return new DesktopEnvironment(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.os = source["os"];
+	        this.desktop = source["desktop"];
+	        this.wayland = source["wayland"];
+	        this.xdg_current_desktop = source["xdg_current_desktop"];
+	        this.desktop_session = source["desktop_session"];
+	        this.commands = this.convertValues(source["commands"], PlannedCommand);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class DiskUsage {
+	    wallpaper_directory: string;
+	    storage_available: boolean;
+	    wallpaper_count: number;
+	    library_bytes: number;
+	    trash_bytes: number;
+	    thumbnail_bytes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DiskUsage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.wallpaper_directory = source["wallpaper_directory"];
+	        this.storage_available = source["storage_available"];
+	        this.wallpaper_count = source["wallpaper_count"];
+	        this.library_bytes = source["library_bytes"];
+	        this.trash_bytes = source["trash_bytes"];
+	        this.thumbnail_bytes = source["thumbnail_bytes"];
+	    }
+	}
+	export class DownloadSchedule {
+	    interval_hours: number;
+	    start_hour: number;
+	    end_hour: number;
+	    batch_size: number;
+	    min_unshown: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DownloadSchedule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.interval_hours = source["interval_hours"];
+	        this.start_hour = source["start_hour"];
+	        this.end_hour = source["end_hour"];
+	        this.batch_size = source["batch_size"];
+	        this.min_unshown = source["min_unshown"];
+	    }
+	}
+	export class HealthReport {
+	    version: string;
+	    os: string;
+	    arch: string;
+	    auto_changer_running: boolean;
+	    auto_change_state: string;
+	    // Go type: time
+	    pinned_until?: any;
+	    // Go type: time
+	    last_successful_change: any;
+	    last_error?: string;
+	    sources: SourceHealth;
+	    disk: DiskUsage;
+	
+	    static createFrom(source: any = {}) {
+	        return new HealthReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.version = source["version"];
+	        this.os = source["os"];
+	        this.arch = source["arch"];
+	        this.auto_changer_running = source["auto_changer_running"];
+	        this.auto_change_state = source["auto_change_state"];
+	        this.pinned_until = this.convertValues(source["pinned_until"], null);
+	        this.last_successful_change = this.convertValues(source["last_successful_change"], null);
+	        this.last_error = source["last_error"];
+	        this.sources = this.convertValues(source["sources"], SourceHealth);
+	        this.disk = this.convertValues(source["disk"], DiskUsage);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class HotkeyConfig {
+	    next: string;
+	    previous: string;
+	    skip: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new HotkeyConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.next = source["next"];
+	        this.previous = source["previous"];
+	        this.skip = source["skip"];
+	    }
+	}
+	export class IntegrityFailure {
+	    id: string;
+	    filename: string;
+	    path: string;
+	    reason: string;
+	    quarantined_to: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new IntegrityFailure(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.filename = source["filename"];
+	        this.path = source["path"];
+	        this.reason = source["reason"];
+	        this.quarantined_to = source["quarantined_to"];
+	    }
+	}
+	export class IntegrityReport {
+	    checked: number;
+	    skipped: number;
+	    failed: IntegrityFailure[];
+	
+	    static createFrom(source: any = {}) {
+	        return new IntegrityReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.checked = source["checked"];
+	        this.skipped = source["skipped"];
+	        this.failed = this.convertValues(source["failed"], IntegrityFailure);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ListOptions {
+	    query: string;
+	    sort_by: string;
+	    ascending: boolean;
+	    filter_color: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ListOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.query = source["query"];
+	        this.sort_by = source["sort_by"];
+	        this.ascending = source["ascending"];
+	        this.filter_color = source["filter_color"];
+	    }
+	}
+	export class MonitorInfo {
+	    id: string;
+	    index: number;
+	    pinned_wallpaper_id?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MonitorInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.index = source["index"];
+	        this.pinned_wallpaper_id = source["pinned_wallpaper_id"];
+	    }
+	}
+	export class PlannedCommand {
+	    name: string;
+	    args: string[];
+	    native?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new PlannedCommand(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.args = source["args"];
+	        this.native = source["native"];
+	    }
+	}
+	export class PrefetchResult {
+	    requested: number;
+	    succeeded: number;
+	    duplicates: number;
+	    failed: number;
+	    cancelled: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new PrefetchResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.requested = source["requested"];
+	        this.succeeded = source["succeeded"];
+	        this.duplicates = source["duplicates"];
+	        this.failed = source["failed"];
+	        this.cancelled = source["cancelled"];
+	    }
+	}
+	export class ProfileInfo {
+	    name: string;
+	    active: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProfileInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.active = source["active"];
+	    }
+	}
+	export class RateLimit {
+	    requests: number;
+	    period_seconds: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RateLimit(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.requests = source["requests"];
+	        this.period_seconds = source["period_seconds"];
+	    }
+	}
+	export class RepairReport {
+	    checked: number;
+	    updated: number;
+	    removed: number;
+	    renamed: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RepairReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.checked = source["checked"];
+	        this.updated = source["updated"];
+	        this.removed = source["removed"];
+	        this.renamed = source["renamed"];
+	    }
+	}
+	export class SeasonalTheme {
+	    name: string;
+	    start_month_day: string;
+	    end_month_day: string;
+	    keywords: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new SeasonalTheme(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.start_month_day = source["start_month_day"];
+	        this.end_month_day = source["end_month_day"];
+	        this.keywords = source["keywords"];
+	    }
+	}
+	export class SourceConfig {
+	    disable_conditional?: boolean;
+	    rate_limit?: RateLimit;
+	    headers?: {[key: string]: string};
+	    type?: string;
+	    zoom?: number;
+	    replace_previous?: boolean;
+	    command?: string;
+	    timeout_seconds?: number;
+	    username?: string;
+	    password?: string;
+	    private_key_path?: string;
+	    key_passphrase?: string;
+	    insecure_skip_verify?: boolean;
+	    content_filter?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SourceConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.disable_conditional = source["disable_conditional"];
+	        this.rate_limit = this.convertValues(source["rate_limit"], RateLimit);
+	        this.headers = source["headers"];
+	        this.type = source["type"];
+	        this.zoom = source["zoom"];
+	        this.replace_previous = source["replace_previous"];
+	        this.command = source["command"];
+	        this.timeout_seconds = source["timeout_seconds"];
+	        this.username = source["username"];
+	        this.password = source["password"];
+	        this.private_key_path = source["private_key_path"];
+	        this.key_passphrase = source["key_passphrase"];
+	        this.insecure_skip_verify = source["insecure_skip_verify"];
+	        this.content_filter = source["content_filter"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SourceFailure {
+	    source: string;
+	    class: string;
+	    status_code?: number;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SourceFailure(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.source = source["source"];
+	        this.class = source["class"];
+	        this.status_code = source["status_code"];
+	        this.error = source["error"];
+	    }
+	}
+	export class SourceHealth {
+	    total: number;
+	    healthy: number;
+	    failing: number;
+	    cooling_down: number;
+	    details: SourceStatus[];
+	
+	    static createFrom(source: any = {}) {
+	        return new SourceHealth(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.total = source["total"];
+	        this.healthy = source["healthy"];
+	        this.failing = source["failing"];
+	        this.cooling_down = source["cooling_down"];
+	        this.details = this.convertValues(source["details"], SourceStatus);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SourceStatus {
+	    url: string;
+	    // Go type: time
+	    last_attempt: any;
+	    // Go type: time
+	    last_success: any;
+	    last_error?: string;
+	    // Go type: time
+	    cooldown_until: any;
+	    tokens_remaining: number;
+	    quota_remaining: number;
+	    quota_reset?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SourceStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.url = source["url"];
+	        this.last_attempt = this.convertValues(source["last_attempt"], null);
+	        this.last_success = this.convertValues(source["last_success"], null);
+	        this.last_error = source["last_error"];
+	        this.cooldown_until = this.convertValues(source["cooldown_until"], null);
+	        this.tokens_remaining = source["tokens_remaining"];
+	        this.quota_remaining = source["quota_remaining"];
+	        this.quota_reset = source["quota_reset"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SunTimes {
+	    // Go type: time
+	    sunrise: any;
+	    // Go type: time
+	    sunset: any;
+	    polar?: string;
+	    following: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new SunTimes(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sunrise = this.convertValues(source["sunrise"], null);
+	        this.sunset = this.convertValues(source["sunset"], null);
+	        this.polar = source["polar"];
+	        this.following = source["following"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class UpdateInfo {
+	    current_version: string;
+	    latest_version: string;
+	    update_available: boolean;
+	    release_url: string;
+	    // Go type: time
+	    checked_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new UpdateInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.current_version = source["current_version"];
+	        this.latest_version = source["latest_version"];
+	        this.update_available = source["update_available"];
+	        this.release_url = source["release_url"];
+	        this.checked_at = this.convertValues(source["checked_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class WallpaperInfo {
 	    id: string;
@@ -26,7 +940,37 @@ export namespace main {
 	    // Go type: time
 	    download_date: any;
 	    source_url: string;
+	    source?: string;
 	    file_size: number;
+	    width: number;
+	    height: number;
+	    luminance: number;
+	    mime_type?: string;
+	    hash?: string;
+	    perceptual_hash?: string;
+	    remote_etag?: string;
+	    redirected_from?: string;
+	    title?: string;
+	    page_url?: string;
+	    original_type?: string;
+	    processed_path?: string;
+	    original_path?: string;
+	    original_hash?: string;
+	    colors?: string[];
+	    display_name?: string;
+	    notes?: string;
+	    skipped?: boolean;
+	    tags?: string[];
+	    favorite?: boolean;
+	    rating?: number;
+	    times_set?: number;
+	    // Go type: time
+	    last_set_at?: any;
+	    // Go type: time
+	    captured_at?: any;
+	    camera_model?: string;
+	    gps_latitude?: number;
+	    gps_longitude?: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new WallpaperInfo(source);
@@ -40,7 +984,35 @@ export namespace main {
 	        this.local_url = source["local_url"];
 	        this.download_date = this.convertValues(source["download_date"], null);
 	        this.source_url = source["source_url"];
+	        this.source = source["source"];
 	        this.file_size = source["file_size"];
+	        this.width = source["width"];
+	        this.height = source["height"];
+	        this.luminance = source["luminance"];
+	        this.mime_type = source["mime_type"];
+	        this.hash = source["hash"];
+	        this.perceptual_hash = source["perceptual_hash"];
+	        this.remote_etag = source["remote_etag"];
+	        this.redirected_from = source["redirected_from"];
+	        this.title = source["title"];
+	        this.page_url = source["page_url"];
+	        this.original_type = source["original_type"];
+	        this.processed_path = source["processed_path"];
+	        this.original_path = source["original_path"];
+	        this.original_hash = source["original_hash"];
+	        this.colors = source["colors"];
+	        this.display_name = source["display_name"];
+	        this.notes = source["notes"];
+	        this.skipped = source["skipped"];
+	        this.tags = source["tags"];
+	        this.favorite = source["favorite"];
+	        this.rating = source["rating"];
+	        this.times_set = source["times_set"];
+	        this.last_set_at = this.convertValues(source["last_set_at"], null);
+	        this.captured_at = this.convertValues(source["captured_at"], null);
+	        this.camera_model = source["camera_model"];
+	        this.gps_latitude = source["gps_latitude"];
+	        this.gps_longitude = source["gps_longitude"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
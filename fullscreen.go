@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// fullscreenPollInterval is how often a deferred change rechecks whether
+// the fullscreen app has exited
+const fullscreenPollInterval = 30 * time.Second
+
+// fullscreenActive reports whether the focused window covers its whole
+// monitor. Detection failures count as not fullscreen, so changes are never
+// blocked by a missing tool.
+func (a *App) fullscreenActive() bool {
+	var active bool
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		active, err = foregroundFullscreenWindows()
+	case "linux":
+		active, err = a.foregroundFullscreenX11()
+	}
+	if err != nil {
+		return false
+	}
+	return active
+}
+
+// foregroundFullscreenX11 asks the window manager, through xprop, whether
+// the active window has _NET_WM_STATE_FULLSCREEN
+func (a *App) foregroundFullscreenX11() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	out, err := a.runner.Output(ctx, "xprop", "-root", "_NET_ACTIVE_WINDOW")
+	if err != nil {
+		return false, err
+	}
+	// _NET_ACTIVE_WINDOW(WINDOW): window id # 0x3a00007
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return false, fmt.Errorf("unexpected xprop output: %q", out)
+	}
+	id := strings.TrimSuffix(fields[len(fields)-1], ",")
+	if !strings.HasPrefix(id, "0x") || id == "0x0" {
+		return false, nil
+	}
+
+	out, err = a.runner.Output(ctx, "xprop", "-id", id, "_NET_WM_STATE")
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(string(out), "_NET_WM_STATE_FULLSCREEN"), nil
+}
+
+// deferForFullscreen reports whether a due change should wait because
+// PauseDuringFullscreen is on and a fullscreen app is in front
+func (a *App) deferForFullscreen() bool {
+	if !a.GetSettings().PauseDuringFullscreen || !a.fullscreenActive() {
+		return false
+	}
+	fmt.Println("Deferring auto-change while a fullscreen app is active")
+	return true
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// foregroundFullscreenWindows is only available on Windows
+func foregroundFullscreenWindows() (bool, error) {
+	return false, fmt.Errorf("foreground window checks are only available on Windows")
+}
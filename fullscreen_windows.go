@@ -0,0 +1,70 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const monitorDefaultToNearest = 2
+
+var (
+	procGetForegroundWindow = user32.NewProc("GetForegroundWindow")
+	procGetShellWindow      = user32.NewProc("GetShellWindow")
+	procGetDesktopWindow    = user32.NewProc("GetDesktopWindow")
+	procGetWindowRect       = user32.NewProc("GetWindowRect")
+	procGetClassNameW       = user32.NewProc("GetClassNameW")
+	procMonitorFromWindow   = user32.NewProc("MonitorFromWindow")
+	procGetMonitorInfoW     = user32.NewProc("GetMonitorInfoW")
+)
+
+// winRect mirrors the Win32 RECT structure
+type winRect struct {
+	left, top, right, bottom int32
+}
+
+// monitorInfo mirrors the Win32 MONITORINFO structure
+type monitorInfo struct {
+	size    uint32
+	monitor winRect
+	work    winRect
+	flags   uint32
+}
+
+// foregroundFullscreenWindows reports whether the foreground window covers
+// its whole monitor. The desktop and shell windows, which always do, are
+// excluded.
+func foregroundFullscreenWindows() (bool, error) {
+	hwnd, _, _ := procGetForegroundWindow.Call()
+	if hwnd == 0 {
+		return false, nil
+	}
+	shell, _, _ := procGetShellWindow.Call()
+	desktop, _, _ := procGetDesktopWindow.Call()
+	if hwnd == shell || hwnd == desktop {
+		return false, nil
+	}
+	switch windowClass(hwnd) {
+	case "Progman", "WorkerW", "Shell_TrayWnd":
+		return false, nil
+	}
+
+	var rect winRect
+	if ret, _, err := procGetWindowRect.Call(hwnd, uintptr(unsafe.Pointer(&rect))); ret == 0 {
+		return false, err
+	}
+	monitor, _, _ := procMonitorFromWindow.Call(hwnd, monitorDefaultToNearest)
+	info := monitorInfo{size: uint32(unsafe.Sizeof(monitorInfo{}))}
+	if ret, _, err := procGetMonitorInfoW.Call(monitor, uintptr(unsafe.Pointer(&info))); ret == 0 {
+		return false, err
+	}
+
+	m := info.monitor
+	return rect.left <= m.left && rect.top <= m.top && rect.right >= m.right && rect.bottom >= m.bottom, nil
+}
+
+// windowClass returns the window class name of hwnd
+func windowClass(hwnd uintptr) string {
+	buf := make([]uint16, 256)
+	n, _, _ := procGetClassNameW.Call(hwnd, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	return syscall.UTF16ToString(buf[:n])
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Values of GetColorScheme
+const (
+	colorSchemeDark  = "dark"
+	colorSchemeLight = "light"
+)
+
+// gnomeDarkPlan returns the command setting GNOME's dark-style background,
+// which GNOME 42 and later show instead of picture-uri in dark mode
+func gnomeDarkPlan(path string) PlannedCommand {
+	return PlannedCommand{Name: "gsettings", Args: []string{"set", "org.gnome.desktop.background", "picture-uri-dark", "file://" + path}}
+}
+
+// setDarkVariant sets GNOME's dark-style background after path has been
+// applied: the DarkModeWallpaperID wallpaper when one is chosen, otherwise
+// path itself, so the change shows in both modes. Other desktops have a
+// single background and are left alone. Older GNOME versions without the
+// key only log the failure.
+func (a *App) setDarkVariant(path string) {
+	if runtime.GOOS != "linux" || detectDesktop(os.Getenv) != desktopGNOME {
+		return
+	}
+	if id := a.GetSettings().DarkModeWallpaperID; id != "" {
+		if wp, ok := a.findWallpaper(id); ok {
+			path = wallpaperPath(wp)
+		}
+	}
+	if err := a.runPlannedCommand(gnomeDarkPlan(path)); err != nil {
+		fmt.Printf("Could not set the dark-style background: %v\n", err)
+	}
+}
+
+// SetDarkModeWallpaper chooses the library wallpaper GNOME shows in dark
+// mode and applies it. An empty ID makes dark mode follow the current
+// wallpaper again.
+func (a *App) SetDarkModeWallpaper(id string) error {
+	path := a.GetAutoChangeStatus().CurrentPath
+	if id != "" {
+		wp, ok := a.findWallpaper(id)
+		if !ok {
+			return fmt.Errorf("%w: %s", errWallpaperNotFound, id)
+		}
+		path = wallpaperPath(wp)
+	}
+	if runtime.GOOS != "linux" || detectDesktop(os.Getenv) != desktopGNOME {
+		return fmt.Errorf("separate dark-mode wallpapers need GNOME")
+	}
+
+	a.changeSettings(func(s *AppSettings) { s.DarkModeWallpaperID = id })
+	if err := a.saveSettings(); err != nil {
+		return err
+	}
+	if path == "" {
+		return nil
+	}
+	return a.runPlannedCommand(gnomeDarkPlan(path))
+}
+
+// GetColorScheme returns the desktop's preferred color scheme, "dark" or
+// "light", or an empty string where it isn't known. Only GNOME's
+// org.gnome.desktop.interface color-scheme is read.
+func (a *App) GetColorScheme() string {
+	if runtime.GOOS != "linux" || detectDesktop(os.Getenv) != desktopGNOME {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	out, err := a.runner.Output(ctx, "gsettings", "get", "org.gnome.desktop.interface", "color-scheme")
+	if err != nil {
+		return ""
+	}
+	return parseColorScheme(string(out))
+}
+
+// parseColorScheme reads gsettings output such as 'prefer-dark'. GNOME's
+// "default" scheme is light.
+func parseColorScheme(out string) string {
+	switch strings.Trim(strings.TrimSpace(out), "'") {
+	case "prefer-dark":
+		return colorSchemeDark
+	case "prefer-light", "default":
+		return colorSchemeLight
+	}
+	return ""
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// defaultUserAgent is sent when the UserAgent setting is empty
+const defaultUserAgent = "WallpaperEngine/1.0"
+
+// maskedValue replaces secret header values in logs and redacted exports
+const maskedValue = "********"
+
+// sensitiveHeaderWords mark header names whose values are secrets
+var sensitiveHeaderWords = []string{"authorization", "cookie", "token", "api-key", "apikey", "secret"}
+
+// userAgent returns the User-Agent for outgoing requests
+func (a *App) userAgent() string {
+	if ua := strings.TrimSpace(a.GetSettings().UserAgent); ua != "" {
+		return ua
+	}
+	return defaultUserAgent
+}
+
+// setSourceHeaders adds the User-Agent, the source's credentials and its
+// configured headers to req. Source headers win, so a source can have its
+// own User-Agent.
+func (a *App) setSourceHeaders(req *http.Request, source string) {
+	config := a.sourceConfig(source)
+	req.Header.Set("User-Agent", a.userAgent())
+	if config.Username != "" {
+		req.SetBasicAuth(config.Username, config.Password)
+	}
+	for name, value := range config.Headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// sensitiveHeader reports whether a header's value must not be shown
+func sensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitiveHeaderWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskHeaders returns a copy of headers with secret values masked
+func maskHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if sensitiveHeader(name) {
+			value = maskedValue
+		}
+		masked[name] = value
+	}
+	return masked
+}
+
+// formatHeaders renders headers for a log line, masking secrets
+func formatHeaders(headers map[string]string) string {
+	var parts []string
+	for name, value := range maskHeaders(headers) {
+		parts = append(parts, name+": "+value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// validateSourceHeaders rejects header names that aren't HTTP tokens and
+// values containing line breaks. Errors name the header but never the value.
+func validateSourceHeaders(source string, headers map[string]string) error {
+	for name, value := range headers {
+		if !validHeaderName(name) {
+			return fmt.Errorf("invalid header name %q for %s", name, source)
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return fmt.Errorf("header %s for %s contains a line break", name, source)
+		}
+	}
+	return nil
+}
+
+// validHeaderName reports whether name is an RFC 9110 token
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// redactSettings returns a copy of s with source header secrets and the
+// control API token masked, for sharing an export
+func redactSettings(s AppSettings) AppSettings {
+	return mapSecrets(s, func(name, value string) string {
+		if value == "" {
+			return ""
+		}
+		return maskedValue
+	})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// version is the app version, set at build time with
+//
+//	wails build -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+// HealthReport summarises the app's state for diagnostics and bug reports
+type HealthReport struct {
+	Version string `json:"version"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	// AutoChangerRunning is whether the background loop is alive;
+	// AutoChangeState says what it is doing, as in AutoChangeStatus.State
+	AutoChangerRunning bool   `json:"auto_changer_running"`
+	AutoChangeState    string `json:"auto_change_state"`
+	// PinnedUntil is set while the current wallpaper is pinned
+	PinnedUntil          *time.Time   `json:"pinned_until,omitempty"`
+	LastSuccessfulChange time.Time    `json:"last_successful_change"`
+	LastError            string       `json:"last_error,omitempty"`
+	Sources              SourceHealth `json:"sources"`
+	Disk                 DiskUsage    `json:"disk"`
+}
+
+// SourceHealth counts download sources by their last result
+type SourceHealth struct {
+	Total int `json:"total"`
+	// Healthy sources have not failed since their last success
+	Healthy     int            `json:"healthy"`
+	Failing     int            `json:"failing"`
+	CoolingDown int            `json:"cooling_down"`
+	Details     []SourceStatus `json:"details"`
+}
+
+// DiskUsage reports what the library occupies on disk
+type DiskUsage struct {
+	WallpaperDirectory string `json:"wallpaper_directory"`
+	StorageAvailable   bool   `json:"storage_available"`
+	WallpaperCount     int    `json:"wallpaper_count"`
+	LibraryBytes       int64  `json:"library_bytes"`
+	TrashBytes         int64  `json:"trash_bytes"`
+	ThumbnailBytes     int64  `json:"thumbnail_bytes"`
+}
+
+// GetVersion returns the version the app was built as
+func (a *App) GetVersion() string {
+	return version
+}
+
+// GetHealth reports the app version, auto-changer state, source health
+// and disk usage
+func (a *App) GetHealth() HealthReport {
+	status := a.GetAutoChangeStatus()
+	report := HealthReport{
+		Version:            version,
+		OS:                 runtime.GOOS,
+		Arch:               runtime.GOARCH,
+		AutoChangerRunning: a.autoChangerRunning.Load(),
+		AutoChangeState:    status.State,
+		PinnedUntil:        optionalTime(status.PinnedUntil),
+	}
+
+	a.status.mu.Lock()
+	report.LastSuccessfulChange = a.status.lastSuccess
+	report.LastError = a.status.lastError
+	a.status.mu.Unlock()
+
+	now := time.Now()
+	report.Sources.Details = a.GetSourceStatus()
+	for _, s := range report.Sources.Details {
+		report.Sources.Total++
+		if s.LastError != "" {
+			report.Sources.Failing++
+		} else {
+			report.Sources.Healthy++
+		}
+		if s.CooldownUntil.After(now) {
+			report.Sources.CoolingDown++
+		}
+	}
+
+	dir := a.getWallpaperDir()
+	report.Disk = DiskUsage{
+		WallpaperDirectory: dir,
+		StorageAvailable:   a.storageAvailable(),
+		WallpaperCount:     len(a.GetWallpapers()),
+		ThumbnailBytes:     dirSize(a.getThumbnailDir()),
+	}
+	if report.Disk.StorageAvailable {
+		trash := dirSize(filepath.Join(dir, trashDirName))
+		report.Disk.TrashBytes = trash
+		report.Disk.LibraryBytes = dirSize(dir) - trash
+	}
+	return report
+}
+
+// dirSize returns the total size of the regular files under dir
+func dirSize(dir string) int64 {
+	var total int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// errNoHEIFDecoder is returned for AVIF and HEIC files when none of the
+// converters in heifConvertPlan is installed
+var errNoHEIFDecoder = errors.New("no AVIF/HEIC decoder available: install libheif (heif-convert) or ImageMagick (magick)")
+
+// heifBrands are the ISO-BMFF brands of HEIF still images with HEVC
+// coding; AVIF files carry "avif" or "avis" instead
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "heim": true, "heis": true,
+	"hevc": true, "hevx": true, "mif1": true, "msf1": true,
+}
+
+// sniffHEIF recognises AVIF and HEIC from the ftyp box at the start of the
+// file. Generic "mif1" files count as AVIF when they list the avif brand.
+func sniffHEIF(head []byte) string {
+	if len(head) < 16 || string(head[4:8]) != "ftyp" {
+		return ""
+	}
+	size := int(head[0])<<24 | int(head[1])<<16 | int(head[2])<<8 | int(head[3])
+	size = min(max(size, 16), len(head))
+
+	// Major brand at 8, minor version at 12, then compatible brands
+	brands := []string{string(head[8:12])}
+	for i := 16; i+4 <= size; i += 4 {
+		brands = append(brands, string(head[i:i+4]))
+	}
+	heif := false
+	for _, brand := range brands {
+		if brand == "avif" || brand == "avis" {
+			return contentTypeAVIF
+		}
+		heif = heif || heifBrands[brand]
+	}
+	if heif {
+		return contentTypeHEIC
+	}
+	return ""
+}
+
+// heifConvertPlan returns the commands that can turn an AVIF or HEIC file
+// into a JPEG on goos, in order of preference. Each is tried only if its
+// binary is installed.
+func heifConvertPlan(goos, src, dst string) []PlannedCommand {
+	plan := []PlannedCommand{
+		{Name: "heif-convert", Args: []string{"-q", "90", src, dst}},
+		{Name: "magick", Args: []string{src, "-quality", "90", dst}},
+	}
+	if goos == "darwin" {
+		sips := PlannedCommand{Name: "sips", Args: []string{"-s", "format", "jpeg", src, "--out", dst}}
+		plan = append([]PlannedCommand{sips}, plan...)
+	}
+	return plan
+}
+
+// transcodeToJPEG replaces an AVIF or HEIC file at path with a JPEG made
+// by an external converter and returns the JPEG's path. When no converter
+// is installed, errNoHEIFDecoder is returned.
+func (a *App) transcodeToJPEG(path string) (string, error) {
+	// Converters pick the format from the extension, so the temporary
+	// file must end in .jpg too
+	tmp := strings.TrimSuffix(path, filepath.Ext(path)) + "_converted.jpg"
+
+	found := false
+	var lastErr error
+	for _, cmd := range heifConvertPlan(runtime.GOOS, path, tmp) {
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		err := a.runner.Run(ctx, cmd.Name, cmd.Args...)
+		cancel()
+		if errors.Is(err, exec.ErrNotFound) {
+			continue
+		}
+		found = true
+		if err == nil && isJPEGFile(tmp) {
+			lastErr = nil
+			break
+		}
+		if err == nil {
+			err = fmt.Errorf("no JPEG was written")
+		}
+		lastErr = fmt.Errorf("%s: %v", cmd.Name, err)
+		os.Remove(tmp)
+	}
+	if !found {
+		return "", errNoHEIFDecoder
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("failed to convert %s: %v", filepath.Base(path), lastErr)
+	}
+
+	out, err := convertedPath(path, ".jpg")
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, out); err != nil {
+		os.Remove(tmp)
+		if out != path {
+			os.Remove(out)
+		}
+		return "", err
+	}
+	if out != path {
+		os.Remove(path)
+	}
+	return out, nil
+}
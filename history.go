@@ -0,0 +1,121 @@
+package main
+
+import (
+	"time"
+)
+
+// changeHistoryLimit caps the change history; older events are dropped
+const changeHistoryLimit = 500
+
+// Triggers recorded in ChangeEvent.Trigger
+const (
+	triggerManual = "manual"
+	// triggerAuto is the auto-change interval, triggerSchedule a change at
+	// the day/night boundary
+	triggerAuto     = "auto"
+	triggerSchedule = "schedule"
+	triggerUnlock   = "unlock"
+	// triggerRestore re-applies the last wallpaper at startup
+	triggerRestore = "restore"
+)
+
+// ChangeEvent is one attempt to apply a wallpaper. The wallpaper's title
+// and source are copied in, so the event still reads well once the
+// wallpaper is deleted. Failures before a wallpaper was picked, such as
+// every source failing, have no wallpaper.
+type ChangeEvent struct {
+	Time        time.Time `json:"time"`
+	Trigger     string    `json:"trigger"`
+	Success     bool      `json:"success"`
+	Error       string    `json:"error,omitempty"`
+	WallpaperID string    `json:"wallpaper_id,omitempty"`
+	Path        string    `json:"path,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	SourceURL   string    `json:"source_url,omitempty"`
+}
+
+// DailyChanges counts the change attempts of one local day
+type DailyChanges struct {
+	// Date is YYYY-MM-DD
+	Date      string `json:"date"`
+	Successes int    `json:"successes"`
+	Failures  int    `json:"failures"`
+}
+
+// recordChange appends an attempt to apply path, or to pick a wallpaper
+// when path is empty, to the change history. Failures are saved at once;
+// successes are saved with the current path that follows them.
+func (a *App) recordChange(trigger, path string, err error) {
+	event := ChangeEvent{
+		Time:    a.clock.Now(),
+		Trigger: trigger,
+		Success: err == nil,
+		Path:    path,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	a.mu.Lock()
+	if wp, ok := a.findByPathLocked(path); ok {
+		event.WallpaperID = wp.ID
+		event.Title = displayTitle(wp)
+		event.SourceURL = wp.SourceURL
+	}
+	a.data.History = append(a.data.History, event)
+	if excess := len(a.data.History) - changeHistoryLimit; excess > 0 {
+		a.data.History = append([]ChangeEvent(nil), a.data.History[excess:]...)
+	}
+	a.mu.Unlock()
+
+	if err != nil {
+		a.saveWallpapers()
+	}
+}
+
+// changeError records a change that failed before any wallpaper was
+// applied, for status.json and the change history
+func (a *App) changeError(trigger string, err error) {
+	a.recordChangeError(err)
+	a.recordChange(trigger, "", err)
+}
+
+// GetChangeHistory returns up to limit change events from the last
+// sinceDays days, newest first. A limit or sinceDays of 0 or less means
+// no limit.
+func (a *App) GetChangeHistory(limit int, sinceDays int) []ChangeEvent {
+	var cutoff time.Time
+	if sinceDays > 0 {
+		cutoff = a.clock.Now().AddDate(0, 0, -sinceDays)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	events := []ChangeEvent{}
+	for i := len(a.data.History) - 1; i >= 0; i-- {
+		event := a.data.History[i]
+		if event.Time.Before(cutoff) || (limit > 0 && len(events) == limit) {
+			break
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// GetDailyChanges summarises the change history of the last days days,
+// newest day first. Days without attempts are left out.
+func (a *App) GetDailyChanges(days int) []DailyChanges {
+	summary := []DailyChanges{}
+	for _, event := range a.GetChangeHistory(0, days) {
+		date := event.Time.Local().Format("2006-01-02")
+		if len(summary) == 0 || summary[len(summary)-1].Date != date {
+			summary = append(summary, DailyChanges{Date: date})
+		}
+		if event.Success {
+			summary[len(summary)-1].Successes++
+		} else {
+			summary[len(summary)-1].Failures++
+		}
+	}
+	return summary
+}
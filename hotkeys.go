@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HotkeyConfig holds the global key combos, e.g. "Ctrl+Alt+Right". An
+// empty combo leaves that action unbound.
+type HotkeyConfig struct {
+	Next     string `json:"next"`
+	Previous string `json:"previous"`
+	Skip     string `json:"skip"`
+}
+
+// defaultHotkeys are used when hotkeys are first enabled
+var defaultHotkeys = HotkeyConfig{
+	Next:     "Ctrl+Alt+Right",
+	Previous: "Ctrl+Alt+Left",
+	Skip:     "Ctrl+Alt+Down",
+}
+
+// Hotkey modifiers
+const (
+	modCtrl = 1 << iota
+	modAlt
+	modShift
+	modSuper
+)
+
+// hotkey is a parsed key combo bound to an action
+type hotkey struct {
+	action    string // "next", "previous" or "skip"
+	combo     string
+	modifiers int
+	key       string // normalised key name, e.g. "Right", "N", "F5"
+}
+
+// namedKeys are the non-alphanumeric keys accepted in combos
+var namedKeys = map[string]string{
+	"left": "Left", "right": "Right", "up": "Up", "down": "Down",
+	"space": "Space", "home": "Home", "end": "End",
+	"pageup": "PageUp", "pagedown": "PageDown",
+}
+
+// parseHotkey parses a combo like "Ctrl+Shift+N". At least one modifier
+// is required so plain typing is never captured.
+func parseHotkey(action, combo string) (hotkey, error) {
+	hk := hotkey{action: action, combo: combo}
+	parts := strings.Split(combo, "+")
+	for _, part := range parts[:len(parts)-1] {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "ctrl", "control":
+			hk.modifiers |= modCtrl
+		case "alt", "option":
+			hk.modifiers |= modAlt
+		case "shift":
+			hk.modifiers |= modShift
+		case "super", "win", "cmd", "meta":
+			hk.modifiers |= modSuper
+		default:
+			return hk, fmt.Errorf("unknown modifier %q in hotkey %q", part, combo)
+		}
+	}
+	if hk.modifiers == 0 {
+		return hk, fmt.Errorf("hotkey %q needs at least one modifier", combo)
+	}
+
+	key := strings.TrimSpace(parts[len(parts)-1])
+	lower := strings.ToLower(key)
+	switch {
+	case len(key) == 1 && (key[0] >= '0' && key[0] <= '9' || lower[0] >= 'a' && lower[0] <= 'z'):
+		hk.key = strings.ToUpper(key)
+	case namedKeys[lower] != "":
+		hk.key = namedKeys[lower]
+	case len(lower) >= 2 && lower[0] == 'f' && isFunctionKey(lower[1:]):
+		hk.key = strings.ToUpper(key)
+	default:
+		return hk, fmt.Errorf("unknown key %q in hotkey %q", key, combo)
+	}
+	return hk, nil
+}
+
+// isFunctionKey reports whether n is a function key number from 1 to 24
+func isFunctionKey(n string) bool {
+	var num int
+	if _, err := fmt.Sscanf(n, "%d", &num); err != nil || fmt.Sprint(num) != n {
+		return false
+	}
+	return num >= 1 && num <= 24
+}
+
+// hotkeyBindings parses the configured combos, skipping unbound actions
+func hotkeyBindings(cfg HotkeyConfig) ([]hotkey, error) {
+	var bindings []hotkey
+	for _, b := range []struct{ action, combo string }{
+		{"next", cfg.Next},
+		{"previous", cfg.Previous},
+		{"skip", cfg.Skip},
+	} {
+		if strings.TrimSpace(b.combo) == "" {
+			continue
+		}
+		hk, err := parseHotkey(b.action, b.combo)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, hk)
+	}
+	return bindings, nil
+}
+
+// validateHotkeys checks every configured combo
+func validateHotkeys(cfg HotkeyConfig) error {
+	_, err := hotkeyBindings(cfg)
+	return err
+}
+
+// runHotkey performs the action bound to a pressed hotkey. The actions
+// emit their own events, so an open window reflects the change.
+func (a *App) runHotkey(action string) {
+	var err error
+	switch action {
+	case "next":
+		_, err = a.NextWallpaper()
+	case "previous":
+		_, err = a.PreviousWallpaper()
+	case "skip":
+		_, err = a.SkipCurrent()
+	}
+	if err != nil {
+		fmt.Printf("Hotkey %s failed: %v\n", action, err)
+	}
+}
+
+// startHotkeys registers the configured hotkeys when they are enabled
+func (a *App) startHotkeys() {
+	if !a.GetSettings().EnableHotkeys {
+		return
+	}
+	bindings, err := hotkeyBindings(a.GetSettings().Hotkeys)
+	if err != nil {
+		fmt.Printf("Invalid hotkeys: %v\n", err)
+		return
+	}
+	if err := a.registerHotkeys(bindings); err != nil {
+		fmt.Printf("Failed to register hotkeys: %v\n", err)
+	}
+}
+
+// restartHotkeys re-registers hotkeys after the settings changed
+func (a *App) restartHotkeys() {
+	a.unregisterHotkeys()
+	a.startHotkeys()
+}
@@ -0,0 +1,159 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// GNOME custom keybindings live in a relocatable schema; each binding is
+// a path listed in the media-keys custom-keybindings key
+const (
+	mediaKeysSchema     = "org.gnome.settings-daemon.plugins.media-keys"
+	customBindingPath   = "/org/gnome/settings-daemon/plugins/media-keys/custom-keybindings/wallset-"
+	customBindingSchema = mediaKeysSchema + ".custom-keybinding"
+)
+
+// hotkeyState records the keybinding paths added to GNOME
+type hotkeyState struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+var gvariantString = regexp.MustCompile(`'((?:[^'\\]|\\.)*)'`)
+
+// gnomeAccelerator converts a hotkey to GNOME's "<Control><Alt>Right" form
+func gnomeAccelerator(hk hotkey) string {
+	var b strings.Builder
+	for _, m := range []struct {
+		flag int
+		name string
+	}{{modCtrl, "<Control>"}, {modAlt, "<Alt>"}, {modShift, "<Shift>"}, {modSuper, "<Super>"}} {
+		if hk.modifiers&m.flag != 0 {
+			b.WriteString(m.name)
+		}
+	}
+	key := hk.key
+	switch key {
+	case "PageUp":
+		key = "Page_Up"
+	case "PageDown":
+		key = "Page_Down"
+	case "Space":
+		key = "space"
+	default:
+		if len(key) == 1 {
+			key = strings.ToLower(key)
+		}
+	}
+	b.WriteString(key)
+	return b.String()
+}
+
+// registerHotkeys adds GNOME custom keybindings that call the app's D-Bus
+// service. Other desktops have no common API for global shortcuts, so
+// there the error explains that.
+func (a *App) registerHotkeys(bindings []hotkey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	existing, err := a.customKeybindings(ctx)
+	if err != nil {
+		return fmt.Errorf("global hotkeys need GNOME custom keybindings: %v", err)
+	}
+
+	var added []string
+	for _, hk := range bindings {
+		path := customBindingPath + hk.action + "/"
+		method := map[string]string{"next": "Next", "previous": "Previous", "skip": "SkipCurrent"}[hk.action]
+		command := fmt.Sprintf("gdbus call --session --dest %s --object-path %s --method %s.%s", dbusName, dbusPath, dbusIface, method)
+
+		schema := customBindingSchema + ":" + path
+		for _, kv := range [][2]string{
+			{"name", "Wallset: " + hk.action},
+			{"command", command},
+			{"binding", gnomeAccelerator(hk)},
+		} {
+			if err := a.runner.Run(ctx, "gsettings", "set", schema, kv[0], kv[1]); err != nil {
+				return fmt.Errorf("failed to set keybinding %s: %v", hk.combo, err)
+			}
+		}
+		added = append(added, path)
+	}
+
+	if err := a.setCustomKeybindings(ctx, mergePaths(existing, added, nil)); err != nil {
+		return err
+	}
+
+	a.hotkeys.mu.Lock()
+	a.hotkeys.paths = added
+	a.hotkeys.mu.Unlock()
+	return nil
+}
+
+// unregisterHotkeys removes the keybindings added by registerHotkeys
+func (a *App) unregisterHotkeys() {
+	a.hotkeys.mu.Lock()
+	paths := a.hotkeys.paths
+	a.hotkeys.paths = nil
+	a.hotkeys.mu.Unlock()
+
+	if len(paths) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	existing, err := a.customKeybindings(ctx)
+	if err != nil {
+		return
+	}
+	a.setCustomKeybindings(ctx, mergePaths(existing, nil, paths))
+	for _, path := range paths {
+		a.runner.Run(ctx, "gsettings", "reset-recursively", customBindingSchema+":"+path)
+	}
+}
+
+// customKeybindings reads the list of custom keybinding paths
+func (a *App) customKeybindings(ctx context.Context) ([]string, error) {
+	out, err := a.runner.Output(ctx, "gsettings", "get", mediaKeysSchema, "custom-keybindings")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, m := range gvariantString.FindAllStringSubmatch(string(out), -1) {
+		paths = append(paths, m[1])
+	}
+	return paths, nil
+}
+
+// setCustomKeybindings writes the list of custom keybinding paths
+func (a *App) setCustomKeybindings(ctx context.Context, paths []string) error {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = "'" + p + "'"
+	}
+	return a.runner.Run(ctx, "gsettings", "set", mediaKeysSchema, "custom-keybindings", "["+strings.Join(quoted, ", ")+"]")
+}
+
+// mergePaths returns existing plus add, minus remove, without duplicates
+func mergePaths(existing, add, remove []string) []string {
+	drop := make(map[string]bool)
+	for _, p := range remove {
+		drop[p] = true
+	}
+	seen := make(map[string]bool)
+	var result []string
+	for _, p := range append(append([]string(nil), existing...), add...) {
+		if !drop[p] && !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	return result
+}
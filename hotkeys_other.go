@@ -0,0 +1,16 @@
+//go:build !windows && !linux
+
+package main
+
+import "fmt"
+
+// hotkeyState is empty where global hotkeys are not supported
+type hotkeyState struct{}
+
+// registerHotkeys is not implemented on this platform: system-wide hotkeys
+// on macOS need the Carbon event API, which requires cgo
+func (a *App) registerHotkeys(bindings []hotkey) error {
+	return fmt.Errorf("global hotkeys are not supported on this platform")
+}
+
+func (a *App) unregisterHotkeys() {}
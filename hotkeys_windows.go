@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	wmHotkey    = 0x0312
+	wmQuit      = 0x0012
+	modNoRepeat = 0x4000
+)
+
+var (
+	user32               = syscall.NewLazyDLL("user32.dll")
+	kernel32             = syscall.NewLazyDLL("kernel32.dll")
+	procRegisterHotKey   = user32.NewProc("RegisterHotKey")
+	procUnregisterHotKey = user32.NewProc("UnregisterHotKey")
+	procGetMessageW      = user32.NewProc("GetMessageW")
+	procPostThreadMsg    = user32.NewProc("PostThreadMessageW")
+	procGetThreadID      = kernel32.NewProc("GetCurrentThreadId")
+)
+
+// winMsg mirrors the Win32 MSG structure
+type winMsg struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	ptX     int32
+	ptY     int32
+}
+
+// hotkeyState tracks the thread that owns the registered hotkeys. Hotkeys
+// belong to the thread that registered them, so registration and the
+// message loop share one locked OS thread.
+type hotkeyState struct {
+	mu       sync.Mutex
+	threadID uintptr
+	done     chan struct{}
+}
+
+// virtualKey maps a normalised key name to its Windows virtual-key code
+func virtualKey(key string) (uintptr, bool) {
+	switch key {
+	case "Left":
+		return 0x25, true
+	case "Up":
+		return 0x26, true
+	case "Right":
+		return 0x27, true
+	case "Down":
+		return 0x28, true
+	case "Space":
+		return 0x20, true
+	case "PageUp":
+		return 0x21, true
+	case "PageDown":
+		return 0x22, true
+	case "End":
+		return 0x23, true
+	case "Home":
+		return 0x24, true
+	}
+	if len(key) == 1 {
+		return uintptr(key[0]), true // '0'-'9' and 'A'-'Z' match their VK codes
+	}
+	if n, err := strconv.Atoi(key[1:]); err == nil && key[0] == 'F' {
+		return uintptr(0x70 + n - 1), true
+	}
+	return 0, false
+}
+
+// winModifiers converts hotkey modifiers to RegisterHotKey flags
+func winModifiers(mods int) uintptr {
+	var flags uintptr = modNoRepeat
+	if mods&modAlt != 0 {
+		flags |= 0x1
+	}
+	if mods&modCtrl != 0 {
+		flags |= 0x2
+	}
+	if mods&modShift != 0 {
+		flags |= 0x4
+	}
+	if mods&modSuper != 0 {
+		flags |= 0x8
+	}
+	return flags
+}
+
+// registerHotkeys registers the bindings on a dedicated thread and runs its
+// message loop until unregisterHotkeys. Combos taken by another app are
+// reported but don't prevent the others from working.
+func (a *App) registerHotkeys(bindings []hotkey) error {
+	started := make(chan error, 1)
+	done := make(chan struct{})
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer close(done)
+
+		tid, _, _ := procGetThreadID.Call()
+		var failed []string
+		for i, hk := range bindings {
+			vk, _ := virtualKey(hk.key)
+			if ret, _, _ := procRegisterHotKey.Call(0, uintptr(i+1), winModifiers(hk.modifiers), vk); ret == 0 {
+				failed = append(failed, hk.combo)
+			}
+		}
+
+		a.hotkeys.mu.Lock()
+		a.hotkeys.threadID = tid
+		a.hotkeys.done = done
+		a.hotkeys.mu.Unlock()
+
+		if len(failed) > 0 {
+			started <- fmt.Errorf("already in use: %v", failed)
+		} else {
+			started <- nil
+		}
+
+		var msg winMsg
+		for {
+			ret, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+			if int32(ret) <= 0 {
+				break
+			}
+			if msg.message == wmHotkey && msg.wParam >= 1 && int(msg.wParam) <= len(bindings) {
+				go a.runHotkey(bindings[msg.wParam-1].action)
+			}
+		}
+
+		for i := range bindings {
+			procUnregisterHotKey.Call(0, uintptr(i+1))
+		}
+	}()
+
+	return <-started
+}
+
+// unregisterHotkeys stops the hotkey thread, which unregisters everything
+func (a *App) unregisterHotkeys() {
+	a.hotkeys.mu.Lock()
+	tid, done := a.hotkeys.threadID, a.hotkeys.done
+	a.hotkeys.threadID, a.hotkeys.done = 0, nil
+	a.hotkeys.mu.Unlock()
+
+	if done == nil {
+		return
+	}
+	procPostThreadMsg.Call(tid, wmQuit, 0, 0)
+	<-done
+}
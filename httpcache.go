@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+)
+
+// conditionalCacheMaxAge is how long validators are kept before being pruned
+const conditionalCacheMaxAge = 30 * 24 * time.Hour
+
+// errNotModified is returned when a source answers 304 to a conditional request
+var errNotModified = errors.New("not modified")
+
+// cacheEntry holds the validators returned with the last image from a source
+type cacheEntry struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	StoredAt     time.Time `json:"stored_at"`
+}
+
+// conditionalDisabled reports whether a source skips revalidation. Typed
+// sources (WebDAV, feeds, scripts) fetch a different file each time, so
+// one file's validators mustn't be sent for the next.
+func (a *App) conditionalDisabled(source string) bool {
+	config := a.sourceConfig(source)
+	return config.DisableConditional || config.Type != ""
+}
+
+// setConditionalHeaders adds If-None-Match/If-Modified-Since for a cached source
+func (a *App) setConditionalHeaders(req *http.Request, url string) {
+	if a.conditionalDisabled(url) {
+		return
+	}
+
+	a.cacheMu.Lock()
+	entry, ok := a.httpCache[url]
+	a.cacheMu.Unlock()
+	if !ok {
+		return
+	}
+
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// storeValidators remembers the ETag and Last-Modified values of a response
+func (a *App) storeValidators(url string, header http.Header) {
+	if header == nil || a.conditionalDisabled(url) {
+		return
+	}
+
+	etag := header.Get("ETag")
+	lastModified := header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return
+	}
+
+	a.cacheMu.Lock()
+	if a.httpCache == nil {
+		a.httpCache = make(map[string]cacheEntry)
+	}
+	a.httpCache[url] = cacheEntry{
+		ETag:         etag,
+		LastModified: lastModified,
+		StoredAt:     time.Now(),
+	}
+	a.cacheMu.Unlock()
+
+	a.saveConditionalCache()
+}
+
+// refreshValidators handles a 304: the cached entry is still current, so
+// its age is reset (keeping it from being pruned while the source keeps
+// answering 304) and any validators the server resent replace the old ones
+func (a *App) refreshValidators(url string, header http.Header) {
+	a.cacheMu.Lock()
+	entry, ok := a.httpCache[url]
+	if ok {
+		if etag := header.Get("ETag"); etag != "" {
+			entry.ETag = etag
+		}
+		if lastModified := header.Get("Last-Modified"); lastModified != "" {
+			entry.LastModified = lastModified
+		}
+		entry.StoredAt = time.Now()
+		a.httpCache[url] = entry
+	}
+	a.cacheMu.Unlock()
+
+	if ok {
+		a.saveConditionalCache()
+	}
+}
+
+// loadConditionalCache reads the validator cache and drops stale entries
+func (a *App) loadConditionalCache() {
+	cache := make(map[string]cacheEntry)
+	data, err := os.ReadFile(a.getConfigPath("http_cache.json"))
+	if err == nil {
+		json.Unmarshal(data, &cache)
+	}
+
+	for url, entry := range cache {
+		if time.Since(entry.StoredAt) > conditionalCacheMaxAge {
+			delete(cache, url)
+		}
+	}
+
+	a.cacheMu.Lock()
+	a.httpCache = cache
+	a.cacheMu.Unlock()
+}
+
+func (a *App) saveConditionalCache() {
+	a.cacheMu.Lock()
+	data, err := json.MarshalIndent(a.httpCache, "", "  ")
+	a.cacheMu.Unlock()
+	if err != nil {
+		return
+	}
+	os.WriteFile(a.getConfigPath("http_cache.json"), data, 0644)
+}
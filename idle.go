@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// idlePollInterval is how often a change waiting for idle rechecks
+const idlePollInterval = time.Minute
+
+// idleDeadlineIntervals is how many intervals past its due time a change
+// may wait for idle before it happens anyway
+const idleDeadlineIntervals = 2
+
+// idleDuration returns how long since the user last touched the keyboard
+// or mouse
+func (a *App) idleDuration() (time.Duration, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return idleWindows()
+	case "darwin":
+		return a.idleDarwin()
+	case "linux":
+		if d, err := a.idleX11(); err == nil {
+			return d, nil
+		}
+		return idleLogind()
+	}
+	return 0, fmt.Errorf("idle detection is not supported on %s", runtime.GOOS)
+}
+
+// idleDarwin reads HIDIdleTime (nanoseconds) from the IOHIDSystem registry
+// entry, which is what CGEventSourceSecondsSinceLastEventType reports
+// without needing cgo
+func (a *App) idleDarwin() (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	out, err := a.runner.Output(ctx, "ioreg", "-c", "IOHIDSystem", "-d", "4")
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if _, value, ok := strings.Cut(line, `"HIDIdleTime" = `); ok {
+			ns, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(ns), nil
+		}
+	}
+	return 0, fmt.Errorf("HIDIdleTime not found")
+}
+
+// idleX11 asks the X11 screensaver extension through xprintidle, which
+// prints milliseconds
+func (a *App) idleX11() (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	out, err := a.runner.Output(ctx, "xprintidle")
+	if err != nil {
+		return 0, err
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// deferForIdle reports whether a due change should wait because
+// OnlyChangeWhenIdle is on and the user is active. Past the deadline, or
+// when idle time can't be read, the change goes ahead.
+func (a *App) deferForIdle(now time.Time) bool {
+	wait := a.shouldWaitForIdle(now)
+	a.setWaitingForIdle(wait)
+	return wait
+}
+
+func (a *App) shouldWaitForIdle(now time.Time) bool {
+	if !a.GetSettings().OnlyChangeWhenIdle {
+		return false
+	}
+	interval := time.Duration(a.GetSettings().ChangeIntervalHours) * time.Hour
+	due := a.schedulerState().LastChange.Add(interval)
+	if !now.Before(due.Add(idleDeadlineIntervals * interval)) {
+		return false
+	}
+
+	idle, err := a.idleDuration()
+	if err != nil {
+		return false
+	}
+	return idle < time.Duration(a.GetSettings().IdleThresholdSeconds)*time.Second
+}
+
+// setWaitingForIdle records whether a due change is waiting for idle,
+// publishing the status when that changes
+func (a *App) setWaitingForIdle(waiting bool) {
+	a.mu.Lock()
+	changed := a.waitingForIdle != waiting
+	a.waitingForIdle = waiting
+	a.mu.Unlock()
+
+	if changed {
+		a.emitAutoChangeStatus()
+	}
+}
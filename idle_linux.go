@@ -0,0 +1,50 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// idleWindows is only available on Windows
+func idleWindows() (time.Duration, error) {
+	return 0, fmt.Errorf("GetLastInputInfo is only available on Windows")
+}
+
+// idleLogind reads the login session's IdleHint and IdleSinceHint. It is
+// coarser than xprintidle, as desktops only set the hint after their own
+// idle delay, but it works on Wayland.
+func idleLogind() (time.Duration, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	path, err := sessionPath(conn)
+	if err != nil {
+		return 0, err
+	}
+	session := conn.Object(login1Name, path)
+
+	var idle dbus.Variant
+	if err := session.Call("org.freedesktop.DBus.Properties.Get", 0, login1Session, "IdleHint").Store(&idle); err != nil {
+		return 0, err
+	}
+	if hint, _ := idle.Value().(bool); !hint {
+		return 0, nil
+	}
+
+	var since dbus.Variant
+	if err := session.Call("org.freedesktop.DBus.Properties.Get", 0, login1Session, "IdleSinceHint").Store(&since); err != nil {
+		return 0, err
+	}
+	usec, ok := since.Value().(uint64)
+	if !ok || usec == 0 {
+		return 0, fmt.Errorf("IdleSinceHint unavailable")
+	}
+	return time.Since(time.UnixMicro(int64(usec))), nil
+}
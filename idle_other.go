@@ -0,0 +1,18 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// idleWindows is only available on Windows
+func idleWindows() (time.Duration, error) {
+	return 0, fmt.Errorf("GetLastInputInfo is only available on Windows")
+}
+
+// idleLogind is only available on Linux
+func idleLogind() (time.Duration, error) {
+	return 0, fmt.Errorf("logind is only available on Linux")
+}
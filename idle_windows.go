@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+)
+
+var (
+	procGetLastInputInfo = user32.NewProc("GetLastInputInfo")
+	procGetTickCount     = kernel32.NewProc("GetTickCount")
+)
+
+// lastInputInfo mirrors the Win32 LASTINPUTINFO structure
+type lastInputInfo struct {
+	size uint32
+	time uint32
+}
+
+// idleWindows returns the time since the last input via GetLastInputInfo
+func idleWindows() (time.Duration, error) {
+	info := lastInputInfo{size: uint32(unsafe.Sizeof(lastInputInfo{}))}
+	if ret, _, err := procGetLastInputInfo.Call(uintptr(unsafe.Pointer(&info))); ret == 0 {
+		return 0, fmt.Errorf("GetLastInputInfo: %v", err)
+	}
+	now, _, _ := procGetTickCount.Call()
+	// Tick counts wrap every 49.7 days; unsigned subtraction handles it
+	return time.Duration(uint32(now)-info.time) * time.Millisecond, nil
+}
+
+// idleLogind is only available on Linux
+func idleLogind() (time.Duration, error) {
+	return 0, fmt.Errorf("logind is only available on Linux")
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// decodeImage opens and decodes an image file
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	return img, nil
+}
+
+// averageLuminance returns the mean relative luminance of an image in [0, 1].
+// At most ~256x256 pixels are sampled so 4K images stay cheap.
+func averageLuminance(img image.Image) float64 {
+	bounds := img.Bounds()
+	stepX := max(bounds.Dx()/256, 1)
+	stepY := max(bounds.Dy()/256, 1)
+
+	var total float64
+	var count int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			// Rec. 709 luma coefficients on 16-bit channels
+			total += (0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)) / 0xffff
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+// imageAnalysis holds the properties measured from a decoded image
+type imageAnalysis struct {
+	Width          int
+	Height         int
+	Luminance      float64
+	PerceptualHash string
+	Colors         []string
+}
+
+// analyzeImage decodes an image file once and measures its properties
+func analyzeImage(path string) (imageAnalysis, error) {
+	img, err := decodeImage(path)
+	if err != nil {
+		return imageAnalysis{}, err
+	}
+	return imageAnalysis{
+		Width:          img.Bounds().Dx(),
+		Height:         img.Bounds().Dy(),
+		Luminance:      averageLuminance(img),
+		PerceptualHash: formatHash(differenceHash(img)),
+		Colors:         dominantColors(img),
+	}, nil
+}
+
+// fileHash returns the hex SHA-256 of a file's contents
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// toRGBA converts an image to *image.RGBA, copying only when necessary
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// saveJPEG encodes an image as a JPEG file
+func saveJPEG(img image.Image, path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := jpeg.Encode(out, img, &jpeg.Options{Quality: 90}); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to encode image: %v", err)
+	}
+	return nil
+}
+
+// savePNG encodes an image as a PNG file
+func savePNG(img image.Image, path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := png.Encode(out, img); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to encode image: %v", err)
+	}
+	return nil
+}
+
+// gaussianBlur approximates a Gaussian blur with the given sigma using three
+// box blur passes, which keeps the cost linear in the number of pixels
+// regardless of the radius.
+func gaussianBlur(img image.Image, sigma int) *image.RGBA {
+	src := toRGBA(img)
+	dst := image.NewRGBA(src.Rect)
+	copy(dst.Pix, src.Pix)
+	if sigma <= 0 {
+		return dst
+	}
+
+	tmp := make([]uint8, len(dst.Pix))
+	for _, box := range boxSizesForGauss(float64(sigma), 3) {
+		r := (box - 1) / 2
+		boxBlurHorizontal(dst.Pix, tmp, dst.Rect.Dx(), dst.Rect.Dy(), dst.Stride, r)
+		boxBlurVertical(tmp, dst.Pix, dst.Rect.Dx(), dst.Rect.Dy(), dst.Stride, r)
+	}
+	return dst
+}
+
+// boxSizesForGauss returns n box widths whose successive application
+// approximates a Gaussian of the given sigma
+func boxSizesForGauss(sigma float64, n int) []int {
+	ideal := math.Sqrt(12*sigma*sigma/float64(n) + 1)
+	lower := int(math.Floor(ideal))
+	if lower%2 == 0 {
+		lower--
+	}
+	upper := lower + 2
+
+	m := int(math.Round((12*sigma*sigma - float64(n*lower*lower) - float64(4*n*lower) - float64(3*n)) / float64(-4*lower-4)))
+
+	sizes := make([]int, n)
+	for i := range sizes {
+		if i < m {
+			sizes[i] = lower
+		} else {
+			sizes[i] = upper
+		}
+	}
+	return sizes
+}
+
+// boxBlurHorizontal blurs each row of src into dst with a sliding window.
+// Pixels beyond the image edge repeat the edge pixel.
+func boxBlurHorizontal(src, dst []uint8, width, height, stride, r int) {
+	if r <= 0 {
+		copy(dst, src)
+		return
+	}
+	window := 2*r + 1
+
+	for y := 0; y < height; y++ {
+		row := src[y*stride : y*stride+width*4]
+		out := dst[y*stride : y*stride+width*4]
+		var sum [4]int
+
+		for i := -r - 1; i < r; i++ {
+			p := clampInt(i, 0, width-1) * 4
+			for c := 0; c < 4; c++ {
+				sum[c] += int(row[p+c])
+			}
+		}
+
+		for x := 0; x < width; x++ {
+			add := min(x+r, width-1) * 4
+			remove := max(x-r-1, 0) * 4
+			for c := 0; c < 4; c++ {
+				sum[c] += int(row[add+c]) - int(row[remove+c])
+				out[x*4+c] = uint8((sum[c] + window/2) / window)
+			}
+		}
+	}
+}
+
+// boxBlurVertical blurs each column of src into dst with a sliding window.
+// Pixels beyond the image edge repeat the edge pixel.
+func boxBlurVertical(src, dst []uint8, width, height, stride, r int) {
+	if r <= 0 {
+		copy(dst, src)
+		return
+	}
+	window := 2*r + 1
+	sums := make([]int, width*4)
+
+	for i := -r - 1; i < r; i++ {
+		row := src[clampInt(i, 0, height-1)*stride:]
+		for j := range sums {
+			sums[j] += int(row[j])
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		add := src[min(y+r, height-1)*stride:]
+		remove := src[max(y-r-1, 0)*stride:]
+		out := dst[y*stride:]
+		for j := range sums {
+			sums[j] += int(add[j]) - int(remove[j])
+			out[j] = uint8((sums[j] + window/2) / window)
+		}
+	}
+}
+
+// clampInt limits v to the range [lo, hi]
+func clampInt(v, lo, hi int) int {
+	return min(max(v, lo), hi)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// importExtensions are the file types ImportWallpaper accepts
+var importExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".tif":  true,
+	".tiff": true,
+	".avif": true,
+	".heic": true,
+	".heif": true,
+}
+
+// ImportWallpaper copies a local image into the library. The file goes
+// through the same duplicate check and processing as downloads, and its
+// metadata is stripped on the way in when StripMetadata is enabled.
+func (a *App) ImportWallpaper(path string) (*WallpaperInfo, error) {
+	info, existing, err := a.importFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%w of %s", errDuplicate, existing.Filename)
+	}
+	return info, nil
+}
+
+// importAndSet imports path, or finds the library entry it duplicates, and
+// sets it as the wallpaper. It backs Explorer's "Set as wallpaper" verb.
+func (a *App) importAndSet(path string) {
+	if !a.beginTask() {
+		return
+	}
+	defer a.tasks.Done()
+
+	if _, err := a.importAndApply(path); err != nil {
+		fmt.Printf("Failed to set %s: %v\n", path, err)
+		a.emit("importFailed", err.Error())
+	}
+}
+
+// importAndApply does the work of importAndSet and returns the wallpaper
+// applied
+func (a *App) importAndApply(path string) (*WallpaperInfo, error) {
+	info, existing, err := a.importFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		info = existing
+	} else {
+		a.emit("wallpapersUpdated", a.GetWallpapers())
+	}
+
+	if err := a.setWallpaper(wallpaperPath(*info), triggerManual); err != nil {
+		return nil, err
+	}
+	a.emitWallpaperChanged(*info)
+	return info, nil
+}
+
+// importFile does the work of ImportWallpaper. When the image duplicates a
+// library entry nothing is stored and that entry is returned as existing.
+func (a *App) importFile(path string) (info, existing *WallpaperInfo, err error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if !importExtensions[ext] {
+		return nil, nil, fmt.Errorf("unsupported image type: %s", ext)
+	}
+	if path, err = checkImageFile(path, true); err != nil {
+		return nil, nil, err
+	}
+	if ext == ".jpeg" {
+		ext = ".jpg"
+	}
+	if !a.storageAvailable() {
+		return nil, nil, errStorageUnavailable
+	}
+
+	id := generateID()
+	dir := a.getWallpaperDir()
+	dest, err := claimFilename(dir, a.importFilename(path, id, ext))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to import %s: %v", filepath.Base(path), err)
+	}
+
+	// Read EXIF from the original, as the copy may be stripped
+	exif, _ := readExif(path)
+
+	// Write to a .part file so an interrupted import is cleaned up like a
+	// download, and so metadata never reaches the final path
+	part := dest + ".part"
+	if a.GetSettings().StripMetadata && isJPEGFile(path) {
+		err = stripMetadataTo(path, part)
+	} else {
+		err = copyFile(path, part)
+	}
+	if err == nil {
+		err = os.Rename(part, dest)
+	}
+	if err != nil {
+		os.Remove(part)
+		os.Remove(dest)
+		return nil, nil, fmt.Errorf("failed to import %s: %v", filepath.Base(path), err)
+	}
+	original := a.keepOriginal(path, id)
+
+	// GIFs and TIFFs are kept as a still of their first frame, and AVIF
+	// and HEIC photos as JPEG
+	still, originalType, err := a.convertForDesktop(dest)
+	if err != nil {
+		os.Remove(dest)
+		removeOriginal(original)
+		return nil, nil, fmt.Errorf("failed to import %s: %w", filepath.Base(path), err)
+	}
+	dest = still
+
+	info, err = a.inspectWallpaper(dest)
+	if err != nil {
+		os.Remove(dest)
+		removeOriginal(original)
+		return nil, nil, err
+	}
+	attachOriginal(info, original)
+	info.ID = id
+	info.SourceURL = path
+	info.OriginalType = originalType
+	exif.apply(info, !a.GetSettings().StripMetadata)
+	info.Title = a.wallpaperTitle(*info)
+
+	if dup, ok := a.findDuplicate(*info); ok {
+		removeWallpaperFiles(*info)
+		return nil, &dup, nil
+	}
+
+	if err := a.processWallpaper(info); err != nil {
+		fmt.Printf("Failed to process wallpaper %s: %v\n", info.Filename, err)
+	}
+
+	if err := a.addWallpaper(*info); err != nil {
+		removeWallpaperFiles(*info)
+		return nil, nil, err
+	}
+	return info, nil, nil
+}
+
+// copyFile copies src to a new file at dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
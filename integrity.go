@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// quarantineDirName is the folder inside the wallpaper directory that
+// files failing verification are moved to. Unlike the trash it is never
+// purged, so the user can inspect or restore them.
+const quarantineDirName = ".quarantine"
+
+// IntegrityFailure is a wallpaper whose file no longer matches the hash
+// recorded when it was added
+type IntegrityFailure struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Path     string `json:"path"`
+	// Reason is "hash mismatch" or why the file couldn't be read
+	Reason string `json:"reason"`
+	// QuarantinedTo is where the file was moved, or "" if moving failed
+	QuarantinedTo string `json:"quarantined_to"`
+}
+
+// IntegrityReport summarises a VerifyIntegrity pass. Wallpapers without a
+// stored hash can't be verified and are counted as skipped.
+type IntegrityReport struct {
+	Checked int                `json:"checked"`
+	Skipped int                `json:"skipped"`
+	Failed  []IntegrityFailure `json:"failed"`
+}
+
+// verifyIntegrityOnLoad runs VerifyIntegrity at startup when
+// VerifyIntegrityOnLoad is on, announcing failures with the
+// "integrityCheckFailed" event
+func (a *App) verifyIntegrityOnLoad() {
+	if !a.GetSettings().VerifyIntegrityOnLoad {
+		return
+	}
+	report, err := a.VerifyIntegrity()
+	if err != nil {
+		fmt.Printf("Integrity check skipped: %v\n", err)
+		return
+	}
+	if len(report.Failed) > 0 {
+		a.emit("integrityCheckFailed", report.Failed)
+	}
+}
+
+// VerifyIntegrity rehashes every wallpaper file and compares it with the
+// hash stored when it was added. Files that differ or can't be read are
+// moved to the quarantine folder, with their processed copy and kept
+// original, and dropped from the library so they are never set. Reading
+// the whole library is slow, so this only runs on request or with
+// VerifyIntegrityOnLoad.
+func (a *App) VerifyIntegrity() (IntegrityReport, error) {
+	if !a.storageAvailable() {
+		return IntegrityReport{}, errStorageUnavailable
+	}
+
+	var report IntegrityReport
+	a.mu.Lock()
+	var todo []WallpaperInfo
+	for _, wp := range a.data.Wallpapers {
+		if wp.Hash == "" {
+			report.Skipped++
+			continue
+		}
+		todo = append(todo, wp)
+	}
+	a.mu.Unlock()
+
+	// Files are hashed without holding the lock
+	var failed []IntegrityFailure
+	var bad []WallpaperInfo
+	for _, wp := range todo {
+		if a.lifetime().Err() != nil {
+			break
+		}
+		report.Checked++
+		hash, err := fileHash(wp.Filepath)
+		reason := "hash mismatch"
+		if err != nil {
+			reason = err.Error()
+		} else if hash == wp.Hash {
+			continue
+		}
+		failed = append(failed, IntegrityFailure{ID: wp.ID, Filename: wp.Filename, Path: wp.Filepath, Reason: reason})
+		bad = append(bad, wp)
+	}
+	if len(bad) == 0 {
+		return report, nil
+	}
+
+	// An entry replaced while its file was being read, as earth-latest
+	// is, no longer refers to the file that failed
+	a.mu.Lock()
+	removed := map[string]bool{}
+	var kept []WallpaperInfo
+	for _, wp := range a.data.Wallpapers {
+		stale := false
+		for _, b := range bad {
+			if wp.ID == b.ID && wp.Filepath == b.Filepath && wp.Hash == b.Hash {
+				stale = true
+			}
+		}
+		if stale {
+			removed[wp.ID] = true
+			continue
+		}
+		kept = append(kept, wp)
+	}
+	a.data.Wallpapers = kept
+	a.mu.Unlock()
+
+	for i, wp := range bad {
+		if !removed[wp.ID] {
+			continue
+		}
+		failed[i].QuarantinedTo = a.quarantineWallpaperFiles(wp)
+		if failed[i].QuarantinedTo != "" {
+			fmt.Printf("Integrity check failed for %s (%s), moved to %s\n", wp.Filepath, failed[i].Reason, failed[i].QuarantinedTo)
+		} else {
+			fmt.Printf("Integrity check failed for %s (%s), removed from the library\n", wp.Filepath, failed[i].Reason)
+		}
+		report.Failed = append(report.Failed, failed[i])
+	}
+
+	a.saveWallpapers()
+	a.clearPinsFor(removed)
+	a.emit("wallpapersUpdated", a.GetWallpapers())
+	return report, nil
+}
+
+// quarantineWallpaperFiles moves a wallpaper's files to the quarantine
+// folder and returns where the main file went, or "" if it couldn't be
+// moved. Files that can't be moved are left where they are.
+func (a *App) quarantineWallpaperFiles(info WallpaperInfo) string {
+	dir := filepath.Join(a.getWallpaperDir(), quarantineDirName)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		fmt.Printf("Cannot create quarantine folder: %v\n", err)
+		return ""
+	}
+
+	quarantined := ""
+	for _, path := range []string{info.Filepath, info.ProcessedPath, info.OriginalPath} {
+		if path == "" {
+			continue
+		}
+		dest, err := claimFilename(dir, filepath.Base(path))
+		if err == nil {
+			if err = os.Rename(path, dest); err != nil {
+				os.Remove(dest)
+			}
+		}
+		if err != nil {
+			if !os.IsNotExist(err) {
+				fmt.Printf("Cannot quarantine %s: %v\n", path, err)
+			}
+			continue
+		}
+		if path == info.Filepath {
+			quarantined = dest
+		}
+	}
+	return quarantined
+}
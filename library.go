@@ -0,0 +1,117 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sort keys accepted by ListOptions.SortBy
+const (
+	sortByDownloaded = "downloaded"
+	sortByCaptured   = "captured"
+	sortByTitle      = "title"
+)
+
+// Limits applied by normalizeTags
+const (
+	maxTags      = 20
+	maxTagLength = 40
+)
+
+// ListOptions filters and orders the result of ListWallpapers
+type ListOptions struct {
+	// Query matches display names, titles, filenames, notes and tags,
+	// case-insensitively
+	Query string `json:"query"`
+
+	// SortBy is "downloaded" (default), "captured" or "title"; entries
+	// without a capture date fall back to their download date
+	SortBy string `json:"sort_by"`
+
+	// Ascending sorts oldest first instead of newest first, and titles
+	// A to Z instead of Z to A
+	Ascending bool `json:"ascending"`
+
+	// FilterColor keeps wallpapers with a palette color near this one, given
+	// as a name ("blue", "gray", ...) or "#rrggbb"
+	FilterColor string `json:"filter_color"`
+}
+
+// ListWallpapers returns the wallpapers matching opts, in the requested order
+func (a *App) ListWallpapers(opts ListOptions) ([]WallpaperInfo, error) {
+	if err := validateColorFilter(opts.FilterColor); err != nil {
+		return nil, err
+	}
+	query := strings.ToLower(strings.TrimSpace(opts.Query))
+
+	var result []WallpaperInfo
+	for _, wp := range a.GetWallpapers() {
+		if query != "" && !matchesQuery(wp, query) {
+			continue
+		}
+		if opts.FilterColor != "" && !matchesColor(wp.Colors, opts.FilterColor) {
+			continue
+		}
+		result = append(result, wp)
+	}
+
+	sortKey := func(wp WallpaperInfo) time.Time {
+		if opts.SortBy == sortByCaptured && !wp.CapturedAt.IsZero() {
+			return wp.CapturedAt
+		}
+		return wp.DownloadDate
+	}
+	if opts.SortBy == sortByTitle {
+		sort.SliceStable(result, func(i, j int) bool {
+			ti, tj := strings.ToLower(displayTitle(result[i])), strings.ToLower(displayTitle(result[j]))
+			if opts.Ascending {
+				return ti < tj
+			}
+			return ti > tj
+		})
+		return result, nil
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if opts.Ascending {
+			return sortKey(result[i]).Before(sortKey(result[j]))
+		}
+		return sortKey(result[i]).After(sortKey(result[j]))
+	})
+	return result, nil
+}
+
+// matchesQuery reports whether a lower-cased query appears in any of the
+// wallpaper's searchable text
+func matchesQuery(wp WallpaperInfo, query string) bool {
+	for _, field := range []string{wp.DisplayName, wp.Title, wp.Filename, wp.Notes} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	for _, tag := range wp.Tags {
+		if strings.Contains(tag, query) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTags trims and lowercases tags, dropping empty, overlong and
+// repeated ones and keeping at most maxTags
+func normalizeTags(tags []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > maxTagLength || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+		if len(result) == maxTags {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// addTestWallpapers puts n older wallpapers in a's library, each with a
+// file of its own, and returns their paths
+func addTestWallpapers(t *testing.T, a *App, n int) []string {
+	t.Helper()
+	dir := a.getWallpaperDir()
+	var paths []string
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i := range n {
+		id := fmt.Sprintf("old%02d", i)
+		path := filepath.Join(dir, "wallpaper_"+id+".jpg")
+		if err := os.WriteFile(path, []byte(id), 0644); err != nil {
+			t.Fatal(err)
+		}
+		a.data.Wallpapers = append(a.data.Wallpapers, WallpaperInfo{
+			ID:           id,
+			Filename:     filepath.Base(path),
+			Filepath:     path,
+			Hash:         id,
+			DownloadDate: time.Now().Add(-time.Duration(i+1) * time.Hour),
+		})
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// TestUnsetMaxWallpapersKeepsLibrary loads settings that leave
+// max_wallpapers out or set it to 0 or below, downloads a wallpaper, and
+// checks that nothing already in the library was deleted
+func TestUnsetMaxWallpapersKeepsLibrary(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/settings_without_max_wallpapers.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		value    any // nil leaves the fixture without max_wallpapers
+		want     int
+		existing int
+	}{
+		{name: "missing", want: 20, existing: 5},
+		{name: "zero", value: 0, want: 0, existing: 25},
+		{name: "negative", value: -3, want: 0, existing: 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(t)
+			dir := a.GetSettings().WallpaperDirectory
+
+			var raw map[string]any
+			if err := json.Unmarshal(fixture, &raw); err != nil {
+				t.Fatal(err)
+			}
+			if tt.value != nil {
+				raw["max_wallpapers"] = tt.value
+			}
+			data, _ := json.Marshal(raw)
+			if err := os.WriteFile(a.getConfigPath("settings.json"), data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			a.loadSettings()
+			if got := a.GetSettings().MaxWallpapers; got != tt.want {
+				t.Fatalf("MaxWallpapers = %d after loading, want %d", got, tt.want)
+			}
+
+			server := httptest.NewServer(serveBytes("image/jpeg", testJPEG(t, 1280, 720)))
+			defer server.Close()
+			a.changeSettings(func(s *AppSettings) {
+				s.WallpaperDirectory = dir
+				s.DryRun = true
+				s.DownloadSources = []string{server.URL + "/wallpaper.jpg"}
+			})
+			existing := addTestWallpapers(t, a, tt.existing)
+
+			if _, err := a.DownloadAndSetWallpaper(); err != nil {
+				t.Fatal(err)
+			}
+			for _, path := range existing {
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("%s was deleted: %v", filepath.Base(path), err)
+				}
+			}
+			if got := len(a.GetWallpapers()); got != tt.existing+1 {
+				t.Errorf("library holds %d wallpapers, want %d", got, tt.existing+1)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// shutdownTimeout bounds how long shutdown waits for background work
+const shutdownTimeout = 5 * time.Second
+
+// errShuttingDown is returned by work refused once shutdown has started
+var errShuttingDown = errors.New("application is shutting down")
+
+// beginTask registers a background task with the shutdown WaitGroup. It
+// returns false once shutdown has started, in which case the task must not
+// run. Callers that get true must call a.tasks.Done when finished.
+func (a *App) beginTask() bool {
+	a.lifecycleMu.Lock()
+	defer a.lifecycleMu.Unlock()
+
+	if a.shuttingDown {
+		return false
+	}
+	a.tasks.Add(1)
+	return true
+}
+
+// lifetime returns a context that is cancelled when the app shuts down
+func (a *App) lifetime() context.Context {
+	if a.appCtx == nil {
+		return context.Background()
+	}
+	return a.appCtx
+}
+
+// shutdown is called when the app is exiting. It stops the auto-changer,
+// cancels in-flight downloads (their .part files are removed as they
+// unwind), waits briefly for background work and flushes state to disk.
+func (a *App) shutdown(ctx context.Context) {
+	a.lifecycleMu.Lock()
+	a.shuttingDown = true
+	a.lifecycleMu.Unlock()
+
+	if a.cancel != nil {
+		a.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		a.tasks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		fmt.Println("Timed out waiting for background tasks to stop")
+	}
+
+	a.stopSettingsWatcher()
+	a.unregisterHotkeys()
+	a.stopControlAPI()
+	a.stopSessionWatcher()
+	a.stopDBusService()
+
+	a.saveWallpapers()
+	a.saveSettings()
+	a.saveConditionalCache()
+	a.saveSourceState()
+	a.writeStatus()
+	a.clearInstance()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// personalizationCSPKey is the machine-wide lock screen policy key. Writing
+// it needs admin rights, so the per-user WinRT API is tried next.
+const personalizationCSPKey = `HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP`
+
+// errLockScreenUnsupported is returned where no lock screen command exists.
+// Only Windows and GNOME-based Linux desktops can set it; macOS derives the
+// lock screen from the desktop picture.
+var errLockScreenUnsupported = errors.New("setting the lock screen is not supported on this platform")
+
+// winRTLockScreenScript sets the lock screen through the WinRT LockScreen
+// API, awaiting the async calls from PowerShell. %s is the quoted path.
+const winRTLockScreenScript = `$ErrorActionPreference = 'Stop'
+Add-Type -AssemblyName System.Runtime.WindowsRuntime
+[Windows.Storage.StorageFile,Windows.Storage,ContentType=WindowsRuntime] | Out-Null
+[Windows.System.UserProfile.LockScreen,Windows.System.UserProfile,ContentType=WindowsRuntime] | Out-Null
+$methods = [System.WindowsRuntimeSystemExtensions].GetMethods() | Where-Object { $_.Name -eq 'AsTask' -and $_.GetParameters().Count -eq 1 }
+$asOp = ($methods | Where-Object { $_.GetParameters()[0].ParameterType.Name -eq 'IAsyncOperation` + "`" + `1' })[0]
+$asAction = ($methods | Where-Object { $_.GetParameters()[0].ParameterType.Name -eq 'IAsyncAction' })[0]
+$file = $asOp.MakeGenericMethod([Windows.Storage.StorageFile]).Invoke($null, @([Windows.Storage.StorageFile]::GetFileFromPathAsync(%s)))
+$file.Wait(-1) | Out-Null
+$task = $asAction.Invoke($null, @([Windows.System.UserProfile.LockScreen]::SetImageFileAsync($file.Result)))
+$task.Wait(-1) | Out-Null`
+
+// psQuote quotes a string as a PowerShell single-quoted literal
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// lockScreenPlan returns the ordered candidate commands for setting path as
+// the lock screen image on goos, or nil where that isn't supported
+func lockScreenPlan(goos, path string) []PlannedCommand {
+	switch goos {
+	case "windows":
+		registry := fmt.Sprintf(`$ErrorActionPreference = 'Stop'
+New-Item -Path '%[1]s' -Force | Out-Null
+Set-ItemProperty -Path '%[1]s' -Name LockScreenImagePath -Value %[2]s
+Set-ItemProperty -Path '%[1]s' -Name LockScreenImageUrl -Value %[2]s
+Set-ItemProperty -Path '%[1]s' -Name LockScreenImageStatus -Value 1 -Type DWord`, personalizationCSPKey, psQuote(path))
+		return []PlannedCommand{
+			{Name: "powershell", Args: []string{"-NoProfile", "-NonInteractive", "-Command", registry}},
+			{Name: "powershell", Args: []string{"-NoProfile", "-NonInteractive", "-Command", fmt.Sprintf(winRTLockScreenScript, psQuote(path))}},
+		}
+	case "linux":
+		return []PlannedCommand{
+			{Name: "gsettings", Args: []string{"set", "org.gnome.desktop.screensaver", "picture-uri", "file://" + path}},
+		}
+	}
+	return nil
+}
+
+// SetLockScreenWallpaper sets only the lock screen image, leaving the
+// desktop alone. On Windows the policy registry key is tried first and the
+// WinRT LockScreen API second. Platforms without a lock screen command
+// return errLockScreenUnsupported.
+func (a *App) SetLockScreenWallpaper(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	return a.applyLockScreen(path)
+}
+
+// applyLockScreen runs the lock screen plan for path; the first command
+// that succeeds wins
+func (a *App) applyLockScreen(path string) error {
+	plan := lockScreenPlan(runtime.GOOS, path)
+	if len(plan) == 0 {
+		return fmt.Errorf("%w (%s)", errLockScreenUnsupported, runtime.GOOS)
+	}
+
+	var err error
+	for _, cmd := range plan {
+		if err = a.runPlannedCommand(cmd); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// setLockScreen applies path to the lock screen when SetLockScreenToo is
+// enabled, so manual and automatic changes keep both in sync. Failure
+// never affects the desktop change; the first one emits
+// "lockScreenUnsupported" so the frontend can tell the user once.
+func (a *App) setLockScreen(path string) {
+	if !a.GetSettings().SetLockScreenToo {
+		return
+	}
+
+	err := a.applyLockScreen(path)
+	if err == nil {
+		return
+	}
+	a.lockScreenWarning.Do(func() {
+		fmt.Printf("Could not set the lock screen: %v\n", err)
+		a.emit("lockScreenUnsupported", err.Error())
+	})
+}
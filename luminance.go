@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// isDarkLuminance reports whether a luminance value counts as a dark image
+func isDarkLuminance(luminance, threshold float64) bool {
+	return luminance < threshold
+}
+
+// filterByLuminance returns the wallpapers that are dark (or light) relative
+// to the threshold. Wallpapers without a measured luminance are skipped.
+func filterByLuminance(wallpapers []WallpaperInfo, dark bool, threshold float64) []WallpaperInfo {
+	var matches []WallpaperInfo
+	for _, wp := range wallpapers {
+		if !wp.LuminanceMeasured {
+			continue
+		}
+		if isDarkLuminance(wp.Luminance, threshold) == dark {
+			matches = append(matches, wp)
+		}
+	}
+	return matches
+}
+
+// prefersDark reports whether dark wallpapers are preferred at the given
+// time: when following the desktop's color scheme, whether it is dark;
+// otherwise between sunset and sunrise when following the sun, or outside
+// the configured day hours
+func (a *App) prefersDark(now time.Time) bool {
+	if a.GetSettings().FollowColorScheme {
+		if scheme := a.GetColorScheme(); scheme != "" {
+			return scheme == colorSchemeDark
+		}
+	}
+	if lat, lon, ok := a.sunLocation(); ok {
+		if night, ok := a.sun.isNightAt(now, lat, lon); ok {
+			return night
+		}
+	}
+
+	hour := now.Hour()
+	dayStart := a.GetSettings().DayStartHour
+	nightStart := a.GetSettings().NightStartHour
+
+	if dayStart < nightStart {
+		return hour < dayStart || hour >= nightStart
+	}
+	// Day window wraps around midnight
+	return hour < dayStart && hour >= nightStart
+}
+
+// matchLuminancePreference returns the wallpaper to apply for an automatic
+// change: the downloaded one if it suits the time of day, otherwise a random
+// library wallpaper that does. Falls back to the download when nothing matches.
+func (a *App) matchLuminancePreference(downloaded WallpaperInfo) WallpaperInfo {
+	if !a.GetSettings().PreferLuminanceByTime || !downloaded.LuminanceMeasured {
+		return downloaded
+	}
+
+	dark := a.prefersDark(time.Now())
+	threshold := a.GetSettings().LuminanceThreshold
+	if isDarkLuminance(downloaded.Luminance, threshold) == dark {
+		return downloaded
+	}
+
+	fits := a.shapeFilter()
+	a.mu.Lock()
+	var pool []WallpaperInfo
+	for _, wp := range a.data.Wallpapers {
+		if !wp.Skipped && fits(wp.Width, wp.Height) {
+			pool = append(pool, wp)
+		}
+	}
+	a.mu.Unlock()
+	candidates := filterByLuminance(pool, dark, threshold)
+	if len(candidates) == 0 {
+		return downloaded
+	}
+
+	choice := a.pickFromLibrary(candidates)
+	fmt.Printf("Using %s instead of %s to match time of day\n", choice.Filename, downloaded.Filename)
+	return choice
+}
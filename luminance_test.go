@@ -0,0 +1,90 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFilterByLuminance(t *testing.T) {
+	pool := []WallpaperInfo{
+		{ID: "black", Luminance: 0, LuminanceMeasured: true},
+		{ID: "unmeasured"},
+		{ID: "light", Luminance: 0.8, LuminanceMeasured: true},
+	}
+	ids := func(wallpapers []WallpaperInfo) []string {
+		var ids []string
+		for _, wp := range wallpapers {
+			ids = append(ids, wp.ID)
+		}
+		return ids
+	}
+	if got := ids(filterByLuminance(pool, true, 0.5)); !slices.Equal(got, []string{"black"}) {
+		t.Errorf("dark wallpapers %v, want [black]", got)
+	}
+	if got := ids(filterByLuminance(pool, false, 0.5)); !slices.Equal(got, []string{"light"}) {
+		t.Errorf("light wallpapers %v, want [light]", got)
+	}
+}
+
+// TestBlackWallpaperIsNotRemeasured adds an all-black wallpaper, whose
+// luminance is 0, and checks that repairing the library doesn't measure it
+// again
+func TestBlackWallpaperIsNotRemeasured(t *testing.T) {
+	a := newTestApp(t)
+	path := filepath.Join(a.getWallpaperDir(), "black.jpg")
+	if err := saveJPEG(image.NewGray(image.Rect(0, 0, 1280, 720)), path); err != nil {
+		t.Fatal(err)
+	}
+	info, err := a.inspectWallpaper(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Luminance != 0 || !info.LuminanceMeasured {
+		t.Fatalf("luminance %f, measured %v; want 0, measured", info.Luminance, info.LuminanceMeasured)
+	}
+	info.ID = "black"
+	info.Title = "Black"
+	if err := a.addWallpaper(*info); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := a.RepairLibrary(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Updated != 0 {
+		t.Errorf("repair updated %d wallpapers, want none", report.Updated)
+	}
+}
+
+// TestLoadWallpapersMarksMeasuredLuminance loads a library saved before
+// LuminanceMeasured and checks that only entries with a luminance count as
+// measured
+func TestLoadWallpapersMarksMeasuredLuminance(t *testing.T) {
+	a := newTestApp(t)
+	dir := a.getWallpaperDir()
+	for _, name := range []string{"measured.jpg", "unmeasured.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	library := `{"wallpapers": [
+		{"id": "measured", "filepath": "` + filepath.ToSlash(filepath.Join(dir, "measured.jpg")) + `", "luminance": 0.4},
+		{"id": "unmeasured", "filepath": "` + filepath.ToSlash(filepath.Join(dir, "unmeasured.jpg")) + `", "luminance": 0}
+	]}`
+	if err := os.WriteFile(a.getConfigPath("wallpapers.json"), []byte(library), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a.loadWallpapers()
+	got := map[string]bool{}
+	for _, wp := range a.GetWallpapers() {
+		got[wp.ID] = wp.LuminanceMeasured
+	}
+	if len(got) != 2 || !got["measured"] || got["unmeasured"] {
+		t.Errorf("measured flags %v, want measured only", got)
+	}
+}
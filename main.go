@@ -2,30 +2,70 @@ package main
 
 import (
 	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
+	"github.com/wailsapp/wails/v2/pkg/options/mac"
 )
 
 //go:embed all:frontend/dist
 var assets embed.FS
 
 func main() {
+	opts, err := parseCLI(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		printUsage(os.Stdout)
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+	// Actions given on the command line run without a window, unless the
+	// GUI is already running; it then receives them through the
+	// single-instance lock below. Listing and status only read, so they
+	// always run here.
+	if opts.headless() {
+		if opts.readOnly() || !instanceRunning(opts.configDir) {
+			os.Exit(runCLI(opts))
+		}
+		fmt.Println("Passing the request to the running instance")
+	}
+
 	// Create an instance of the app structure
 	app := NewApp()
+	app.configDir = opts.configDir
 
 	// Create application with options
-	err := wails.Run(&options.App{
+	err = wails.Run(&options.App{
 		Title:  "Wallset",
 		Width:  450,
 		Height: 400,
 		AssetServer: &assetserver.Options{
-			Assets: assets,
+			Assets:  assets,
+			Handler: app.assetHandler(),
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
+		Menu:             app.applicationMenu(),
 		OnStartup:        app.startup,
+		OnDomReady:       app.domReady,
 		OnBeforeClose:    app.beforeClose, // ← ADD THIS
+		OnShutdown:       app.shutdown,
+		// A second launch, e.g. from a clicked wallset:// link, is handed to
+		// the running instance instead of opening another window
+		SingleInstanceLock: &options.SingleInstanceLock{
+			UniqueId:               "io.github.wallset",
+			OnSecondInstanceLaunch: app.onSecondInstance,
+		},
+		Mac: &mac.Options{
+			OnUrlOpen: app.handleDeepLink,
+		},
 		Bind: []interface{}{
 			app,
 		},
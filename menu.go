@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/getlantern/systray"
+	"github.com/wailsapp/wails/v2/pkg/menu"
+	"github.com/wailsapp/wails/v2/pkg/menu/keys"
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// appMenu holds the native menu items whose state changes at runtime
+type appMenu struct {
+	mu      sync.Mutex
+	menu    *menu.Menu
+	pause   *menu.MenuItem
+	next    *menu.MenuItem
+	current *menu.MenuItem
+}
+
+// trayMenu holds the tray items whose state changes at runtime. They are
+// nil until the tray is ready.
+type trayMenu struct {
+	mu     sync.Mutex
+	pause  *systray.MenuItem
+	status *systray.MenuItem
+}
+
+// applicationMenu builds the native application menu. It is only used on
+// macOS, where the menu bar is the natural place for quick actions; other
+// platforms have the tray.
+func (a *App) applicationMenu() *menu.Menu {
+	if runtime.GOOS != "darwin" {
+		return nil
+	}
+
+	m := menu.NewMenu()
+	m.Append(menu.AppMenu())
+	m.Append(menu.EditMenu())
+
+	wp := m.AddSubmenu("Wallpaper")
+	wp.AddText("Next Wallpaper", keys.Combo("n", keys.CmdOrCtrlKey, keys.ShiftKey), func(*menu.CallbackData) {
+		go a.menuAction("next", a.NextWallpaper)
+	})
+	wp.AddText("Previous Wallpaper", nil, func(*menu.CallbackData) {
+		go a.menuAction("previous", a.PreviousWallpaper)
+	})
+	a.menu.pause = wp.AddCheckbox("Pause Auto-Change", false, nil, func(data *menu.CallbackData) {
+		go a.SetAutoChangePaused(data.MenuItem.Checked)
+	})
+	wp.AddSeparator()
+	a.menu.next = wp.AddText("Next change: —", nil, nil).Disable()
+	a.menu.current = wp.AddText("Current: —", nil, nil).Disable()
+	wp.AddSeparator()
+	wp.AddText("Open Gallery", keys.CmdOrCtrl("g"), func(*menu.CallbackData) {
+		a.ShowWindow()
+		a.emit("showGallery")
+	})
+
+	m.Append(menu.WindowMenu())
+	a.menu.menu = m
+	return m
+}
+
+// menuAction runs a navigation action from the menu and logs failures
+func (a *App) menuAction(name string, action func() (*WallpaperInfo, error)) {
+	if _, err := action(); err != nil {
+		fmt.Printf("Menu %s failed: %v\n", name, err)
+	}
+}
+
+// refreshMenu updates the pause checkmark, countdown and current title
+func (a *App) refreshMenu() {
+	a.menu.mu.Lock()
+	defer a.menu.mu.Unlock()
+	if a.menu.menu == nil || a.ctx == nil {
+		return
+	}
+
+	status := a.GetAutoChangeStatus()
+	a.menu.pause.SetChecked(status.Paused)
+
+	switch {
+	case status.Paused:
+		a.menu.next.SetLabel("Next change: paused")
+	case status.State == autoChangePinned:
+		a.menu.next.SetLabel("Next change: pinned until " + formatPin(status.PinnedUntil, a.clock.Now()))
+	case status.State == autoChangeWaitingForIdle:
+		a.menu.next.SetLabel("Next change: waiting for idle")
+	case status.NextChange.IsZero():
+		a.menu.next.SetLabel("Next change: off")
+	default:
+		a.menu.next.SetLabel("Next change in " + formatCountdown(status.NextChange.Sub(a.clock.Now())))
+	}
+
+	title := status.CurrentTitle
+	if title == "" {
+		title = "—"
+	}
+	a.menu.current.SetLabel("Current: " + title)
+
+	wailsruntime.MenuUpdateApplicationMenu(a.ctx)
+}
+
+// refreshTray updates the tray's pause checkmark, status line and tooltip
+func (a *App) refreshTray() {
+	a.tray.mu.Lock()
+	defer a.tray.mu.Unlock()
+	if a.tray.pause == nil {
+		return
+	}
+
+	status := a.GetAutoChangeStatus()
+	if status.Paused {
+		a.tray.pause.Check()
+	} else {
+		a.tray.pause.Uncheck()
+	}
+
+	if status.Enabled {
+		a.tray.pause.Enable()
+	} else {
+		a.tray.pause.Disable()
+	}
+
+	var line string
+	switch {
+	case status.Paused:
+		line = "Auto-change: paused"
+	case status.State == autoChangePinned:
+		line = "Auto-change: pinned until " + formatPin(status.PinnedUntil, a.clock.Now())
+	case status.State == autoChangeWaitingForIdle:
+		line = "Auto-change: waiting for idle"
+	case status.NextChange.IsZero():
+		line = "Auto-change: off"
+	default:
+		line = "Auto-change: next in " + formatCountdown(status.NextChange.Sub(a.clock.Now()))
+	}
+	a.tray.status.SetTitle(line)
+
+	tooltip := "Wallset - " + line
+	if status.CurrentTitle != "" {
+		tooltip += "\n" + status.CurrentTitle
+	}
+	systray.SetTooltip(tooltip)
+}
+
+// formatPin renders the end of a pin as "tomorrow", a time today such as
+// "18:30", or a date
+func formatPin(until, now time.Time) string {
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	switch {
+	case until.Equal(tomorrow):
+		return "tomorrow"
+	case until.Before(tomorrow):
+		return until.Format("15:04")
+	}
+	return until.Format("Jan 2 15:04")
+}
+
+// formatCountdown renders a duration as "2h 05m" or "42m"
+func formatCountdown(d time.Duration) string {
+	d = max(d, 0).Round(time.Minute)
+	if d >= time.Hour {
+		return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
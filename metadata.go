@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// stripJPEGMetadata copies a JPEG from r to w without its EXIF/XMP (APP1)
+// and IPTC (APP13) segments. The image data itself is copied untouched.
+func stripJPEGMetadata(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	segments, err := readJPEGSegments(br)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.Write([]byte{0xFF, markerSOI})
+	for _, seg := range segments {
+		if seg.marker == markerAPP1 || seg.marker == markerAPP13 {
+			continue
+		}
+		var length [2]byte
+		binary.BigEndian.PutUint16(length[:], uint16(len(seg.data)+2))
+		bw.Write([]byte{0xFF, seg.marker})
+		bw.Write(length[:])
+		bw.Write(seg.data)
+	}
+	if _, err := io.Copy(bw, br); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// isJPEGFile reports whether the file starts with the JPEG SOI marker
+func isJPEGFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	var head [2]byte
+	_, err = io.ReadFull(f, head[:])
+	return err == nil && bytes.Equal(head[:], []byte{0xFF, markerSOI})
+}
+
+// stripMetadataFile removes metadata from a JPEG in place. Non-JPEG files
+// are left alone.
+func stripMetadataFile(path string) error {
+	if !isJPEGFile(path) {
+		return nil
+	}
+
+	tmp := path + ".strip"
+	if err := stripMetadataTo(path, tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// stripMetadataTo writes a metadata-free copy of the JPEG src to dst. If
+// the segments can't be parsed the image is re-encoded instead, which also
+// drops all metadata.
+func stripMetadataTo(src, dst string) error {
+	err := stripToFile(src, dst)
+	if err == nil {
+		return nil
+	}
+	os.Remove(dst)
+
+	img, decodeErr := decodeImage(src)
+	if decodeErr != nil {
+		return fmt.Errorf("failed to strip metadata: %v", err)
+	}
+	return saveJPEG(img, dst)
+}
+
+// stripToFile writes a metadata-free copy of src to dst
+func stripToFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := stripJPEGMetadata(in, out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// prepareImage runs on a new file before it is inspected. It returns the
+// file's EXIF metadata and strips it from the file when enabled. A missing
+// or unreadable EXIF block just yields the zero value.
+func (a *App) prepareImage(path string) (exifData, error) {
+	exif, _ := readExif(path)
+
+	if a.GetSettings().StripMetadata {
+		if err := stripMetadataFile(path); err != nil {
+			return exif, err
+		}
+	}
+	return exif, nil
+}
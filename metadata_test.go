@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// checkStripped asserts that the JPEG at path has no APP1 or APP13
+// segment, that no GPS position can be read from it, and that it still
+// decodes
+func checkStripped(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	segments, err := readJPEGSegments(bufio.NewReader(f))
+	if err != nil {
+		t.Fatalf("%s: %v", filepath.Base(path), err)
+	}
+	for _, seg := range segments {
+		if seg.marker == markerAPP1 || seg.marker == markerAPP13 {
+			t.Errorf("%s still has an APP%d segment", filepath.Base(path), seg.marker-0xE0)
+		}
+	}
+
+	exif, err := readExif(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exif.GPSLatitude != nil || exif.GPSLongitude != nil {
+		t.Errorf("%s still has a GPS position", filepath.Base(path))
+	}
+	if img, err := decodeImage(path); err != nil {
+		t.Errorf("%s no longer decodes: %v", filepath.Base(path), err)
+	} else if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 16 {
+		t.Errorf("%s decodes to %dx%d, want 16x16", filepath.Base(path), b.Dx(), b.Dy())
+	}
+}
+
+func TestStripMetadataTo(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "stripped.jpg")
+	if err := stripMetadataTo("testdata/gps.jpg", dst); err != nil {
+		t.Fatal(err)
+	}
+	checkStripped(t, dst)
+}
+
+// TestImportStripsMetadata imports testdata/gps.jpg with StripMetadata on
+// and checks the stored file
+func TestImportStripsMetadata(t *testing.T) {
+	a := newTestApp(t)
+	a.changeSettings(func(s *AppSettings) {
+		s.StripMetadata = true
+		s.MinFileSizeBytes = 0
+	})
+
+	info, err := a.ImportWallpaper("testdata/gps.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStripped(t, info.Filepath)
+}
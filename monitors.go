@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resumeGap is how long the auto-changer loop must have been stalled before
+// it assumes the machine slept, after which pinned images are re-applied
+const resumeGap = 5 * time.Minute
+
+// errPerMonitorUnsupported is returned where wallpapers can only be set for
+// the whole desktop
+var errPerMonitorUnsupported = fmt.Errorf("per-monitor wallpapers are only supported on Windows and macOS")
+
+// MonitorInfo describes a display a wallpaper can be pinned to
+type MonitorInfo struct {
+	ID string `json:"id"`
+	// Index is the 1-based position in the order the OS reports displays
+	Index             int    `json:"index"`
+	PinnedWallpaperID string `json:"pinned_wallpaper_id,omitempty"`
+}
+
+// PinnedWallpaperRemoved is the payload of pinnedWallpaperRemoved, emitted
+// when deleting a wallpaper clears the pin that showed it
+type PinnedWallpaperRemoved struct {
+	MonitorID   string `json:"monitor_id"`
+	WallpaperID string `json:"wallpaper_id"`
+}
+
+// monitorWatch notices display changes and resumes from sleep, after which
+// pinned images are re-applied
+type monitorWatch struct {
+	mu        sync.Mutex
+	seen      string
+	lastCheck time.Time
+}
+
+// GetMonitors lists the connected displays and what is pinned to them
+func (a *App) GetMonitors() ([]MonitorInfo, error) {
+	ids, err := a.listMonitors()
+	if err != nil {
+		return nil, err
+	}
+	pins := a.GetSettings().PinnedMonitors
+	monitors := make([]MonitorInfo, len(ids))
+	for i, id := range ids {
+		monitors[i] = MonitorInfo{ID: id, Index: i + 1, PinnedWallpaperID: pins[id]}
+	}
+	return monitors, nil
+}
+
+// listMonitors returns the IDs of the connected displays: device paths on
+// Windows, desktop numbers on macOS
+func (a *App) listMonitors() ([]string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return listMonitorsWindows()
+	case "darwin":
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		defer cancel()
+		out, err := a.runner.Output(ctx, "osascript", "-e", `tell application "System Events" to count desktops`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list displays: %v", err)
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(string(out)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list displays: %v", err)
+		}
+		ids := make([]string, count)
+		for i := range ids {
+			ids[i] = strconv.Itoa(i + 1)
+		}
+		return ids, nil
+	}
+	return nil, errPerMonitorUnsupported
+}
+
+// monitorWallpaperPlan returns the command that sets path on one display,
+// for platforms that do it with an external command
+func monitorWallpaperPlan(goos, monitorID, path string) []PlannedCommand {
+	if goos != "darwin" {
+		return nil
+	}
+	script := fmt.Sprintf(`tell application "System Events" to set picture of desktop %s to POSIX file "%s"`, monitorID, path)
+	return []PlannedCommand{{Name: "osascript", Args: []string{"-e", script}}}
+}
+
+// setMonitorWallpaper sets path as the wallpaper of a single display
+func (a *App) setMonitorWallpaper(monitorID, path string) error {
+	if a.GetSettings().DryRun {
+		fmt.Printf("Dry run, not setting monitor %s to %s\n", monitorID, path)
+		return nil
+	}
+	if runtime.GOOS == "windows" {
+		return setMonitorWallpaperWindows(monitorID, path)
+	}
+	plan := monitorWallpaperPlan(runtime.GOOS, monitorID, path)
+	if len(plan) == 0 {
+		return errPerMonitorUnsupported
+	}
+	return a.runPlannedCommand(plan[0])
+}
+
+// PinWallpaperToMonitor keeps a library wallpaper on one display while
+// rotation continues on the others
+func (a *App) PinWallpaperToMonitor(wallpaperID, monitorID string) error {
+	wp, ok := a.findWallpaper(wallpaperID)
+	if !ok {
+		return fmt.Errorf("%w: %s", errWallpaperNotFound, wallpaperID)
+	}
+	ids, err := a.listMonitors()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(ids, monitorID) {
+		return fmt.Errorf("monitor not found: %s", monitorID)
+	}
+
+	if err := a.setPins(func(pins map[string]string) { pins[monitorID] = wallpaperID }); err != nil {
+		return err
+	}
+	return a.setMonitorWallpaper(monitorID, wallpaperPath(wp))
+}
+
+// UnpinMonitor returns a display to rotation, showing the current wallpaper
+func (a *App) UnpinMonitor(monitorID string) {
+	if _, ok := a.GetSettings().PinnedMonitors[monitorID]; !ok {
+		return
+	}
+	if err := a.setPins(func(pins map[string]string) { delete(pins, monitorID) }); err != nil {
+		fmt.Printf("Failed to save monitor pins: %v\n", err)
+	}
+
+	a.mu.Lock()
+	current := a.data.CurrentPath
+	a.mu.Unlock()
+	if current == "" {
+		return
+	}
+	if err := a.setMonitorWallpaper(monitorID, current); err != nil {
+		fmt.Printf("Failed to restore monitor %s: %v\n", monitorID, err)
+	}
+}
+
+// setPins applies edit to a copy of PinnedMonitors and saves the settings.
+// The map is replaced rather than changed in place, since other goroutines
+// may be reading the current one.
+func (a *App) setPins(edit func(pins map[string]string)) error {
+	a.changeSettings(func(s *AppSettings) {
+		pins := make(map[string]string, len(s.PinnedMonitors))
+		for monitor, id := range s.PinnedMonitors {
+			pins[monitor] = id
+		}
+		edit(pins)
+		if len(pins) == 0 {
+			pins = nil
+		}
+		s.PinnedMonitors = pins
+	})
+	return a.saveSettings()
+}
+
+// clearPinsFor removes the pins showing any of the deleted wallpapers and
+// warns the frontend about each one
+func (a *App) clearPinsFor(deleted map[string]bool) {
+	var cleared []PinnedWallpaperRemoved
+	for monitor, id := range a.GetSettings().PinnedMonitors {
+		if deleted[id] {
+			cleared = append(cleared, PinnedWallpaperRemoved{MonitorID: monitor, WallpaperID: id})
+		}
+	}
+	if len(cleared) == 0 {
+		return
+	}
+	err := a.setPins(func(pins map[string]string) {
+		for _, c := range cleared {
+			delete(pins, c.MonitorID)
+		}
+	})
+	if err != nil {
+		fmt.Printf("Failed to save monitor pins: %v\n", err)
+	}
+	for _, c := range cleared {
+		fmt.Printf("Unpinned monitor %s: wallpaper %s was deleted\n", c.MonitorID, c.WallpaperID)
+		a.emit("pinnedWallpaperRemoved", c)
+	}
+}
+
+// pinnedPaths returns the image file of each pin, leaving out pins whose
+// wallpaper is no longer in the library
+func (a *App) pinnedPaths() map[string]string {
+	pins := a.GetSettings().PinnedMonitors
+	if len(pins) == 0 {
+		return nil
+	}
+	paths := make(map[string]string, len(pins))
+	for monitor, id := range pins {
+		if wp, ok := a.findWallpaper(id); ok {
+			paths[monitor] = wallpaperPath(wp)
+		}
+	}
+	return paths
+}
+
+// applyAcrossMonitors sets path on every display that isn't pinned, and
+// each pinned display's own image. It returns false when nothing is pinned
+// or displays can't be addressed one by one; the caller then sets path on
+// the whole desktop.
+func (a *App) applyAcrossMonitors(path string) (bool, error) {
+	pinned := a.pinnedPaths()
+	if len(pinned) == 0 {
+		return false, nil
+	}
+	ids, err := a.listMonitors()
+	if err != nil {
+		fmt.Printf("Ignoring pinned monitors: %v\n", err)
+		return false, nil
+	}
+
+	for _, id := range ids {
+		target := path
+		if p, ok := pinned[id]; ok {
+			target = p
+		}
+		if err := a.setMonitorWallpaper(id, target); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// reapplyPins sets each pinned display's image again, e.g. after the OS
+// reset wallpapers on a display change or resume
+func (a *App) reapplyPins() {
+	ids, err := a.listMonitors()
+	if err != nil {
+		return
+	}
+	pinned := a.pinnedPaths()
+	for _, id := range ids {
+		if path, ok := pinned[id]; ok {
+			if err := a.setMonitorWallpaper(id, path); err != nil {
+				fmt.Printf("Failed to re-apply pinned wallpaper on %s: %v\n", id, err)
+			}
+		}
+	}
+}
+
+// checkMonitors runs on every pass of the auto-changer loop. While anything
+// is pinned, it re-applies the pins when the set of displays changed or the
+// loop was stalled long enough that the machine must have slept.
+func (a *App) checkMonitors(now time.Time) {
+	a.monitors.mu.Lock()
+	defer a.monitors.mu.Unlock()
+
+	resumed := !a.monitors.lastCheck.IsZero() && now.Sub(a.monitors.lastCheck) > resumeGap
+	a.monitors.lastCheck = now
+	if len(a.GetSettings().PinnedMonitors) == 0 {
+		a.monitors.seen = ""
+		return
+	}
+
+	ids, err := a.listMonitors()
+	if err != nil {
+		return
+	}
+	seen := strings.Join(ids, "\n")
+	changed := a.monitors.seen != "" && seen != a.monitors.seen
+	a.monitors.seen = seen
+	if changed || resumed {
+		fmt.Println("Displays changed; re-applying pinned wallpapers")
+		a.reapplyPins()
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+// listMonitorsWindows is only available on Windows
+func listMonitorsWindows() ([]string, error) {
+	return nil, errPerMonitorUnsupported
+}
+
+// setMonitorWallpaperWindows is only available on Windows
+func setMonitorWallpaperWindows(monitorID, imagePath string) error {
+	return errPerMonitorUnsupported
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	coinitApartmentThreaded = 0x2
+	clsctxAll               = 0x17
+	rpcEChangedMode         = 0x80010106
+)
+
+var (
+	ole32                = syscall.NewLazyDLL("ole32.dll")
+	procCoInitializeEx   = ole32.NewProc("CoInitializeEx")
+	procCoUninitialize   = ole32.NewProc("CoUninitialize")
+	procCoCreateInstance = ole32.NewProc("CoCreateInstance")
+	procCoTaskMemFree    = ole32.NewProc("CoTaskMemFree")
+)
+
+// comGUID mirrors the Win32 GUID structure
+type comGUID struct {
+	data1        uint32
+	data2, data3 uint16
+	data4        [8]byte
+}
+
+var (
+	clsidDesktopWallpaper = comGUID{0xC2CF3110, 0x460E, 0x4FC1, [8]byte{0xB9, 0xD0, 0x8A, 0x1C, 0x0C, 0x9C, 0xC4, 0xBD}}
+	iidDesktopWallpaper   = comGUID{0xB92B56A9, 0x8B55, 0x4E14, [8]byte{0x9A, 0x89, 0x01, 0x99, 0xBB, 0xB6, 0xF9, 0x3B}}
+)
+
+// IDesktopWallpaper vtable slots, after IUnknown's three
+const (
+	dwRelease                   = 2
+	dwSetWallpaper              = 3
+	dwGetMonitorDevicePathAt    = 5
+	dwGetMonitorDevicePathCount = 6
+	dwGetMonitorRECT            = 7
+)
+
+// desktopWallpaper is an IDesktopWallpaper COM object, which can set the
+// wallpaper of each monitor separately (Windows 8 and later)
+type desktopWallpaper struct {
+	vtbl *[19]uintptr
+}
+
+// call invokes the method in vtable slot with the object as its receiver
+func (dw *desktopWallpaper) call(slot int, args ...uintptr) int32 {
+	hr, _, _ := syscall.SyscallN(dw.vtbl[slot], append([]uintptr{uintptr(unsafe.Pointer(dw))}, args...)...)
+	return int32(hr)
+}
+
+// withDesktopWallpaper runs fn with an IDesktopWallpaper on a thread
+// initialised for COM
+func withDesktopWallpaper(fn func(dw *desktopWallpaper) error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	hr, _, _ := procCoInitializeEx.Call(0, coinitApartmentThreaded)
+	if int32(hr) >= 0 {
+		defer procCoUninitialize.Call()
+	} else if uint32(hr) != rpcEChangedMode {
+		return fmt.Errorf("CoInitializeEx failed: 0x%08X", uint32(hr))
+	}
+
+	var dw *desktopWallpaper
+	hr, _, _ = procCoCreateInstance.Call(
+		uintptr(unsafe.Pointer(&clsidDesktopWallpaper)),
+		0,
+		clsctxAll,
+		uintptr(unsafe.Pointer(&iidDesktopWallpaper)),
+		uintptr(unsafe.Pointer(&dw)),
+	)
+	if int32(hr) < 0 || dw == nil {
+		return fmt.Errorf("IDesktopWallpaper is unavailable: 0x%08X", uint32(hr))
+	}
+	defer dw.call(dwRelease)
+	return fn(dw)
+}
+
+// listMonitorsWindows returns the device paths of the attached monitors.
+// Windows also lists monitors that were connected before; those have no
+// rectangle and are left out.
+func listMonitorsWindows() ([]string, error) {
+	var ids []string
+	err := withDesktopWallpaper(func(dw *desktopWallpaper) error {
+		var count uint32
+		if hr := dw.call(dwGetMonitorDevicePathCount, uintptr(unsafe.Pointer(&count))); hr < 0 {
+			return fmt.Errorf("GetMonitorDevicePathCount failed: 0x%08X", uint32(hr))
+		}
+		for i := uint32(0); i < count; i++ {
+			var path *uint16
+			if hr := dw.call(dwGetMonitorDevicePathAt, uintptr(i), uintptr(unsafe.Pointer(&path))); hr < 0 || path == nil {
+				continue
+			}
+			id := utf16PtrToString(path)
+			procCoTaskMemFree.Call(uintptr(unsafe.Pointer(path)))
+
+			var rect winRect
+			monitor := utf16Buffer(id)
+			if hr := dw.call(dwGetMonitorRECT, uintptr(unsafe.Pointer(&monitor[0])), uintptr(unsafe.Pointer(&rect))); hr != 0 {
+				continue
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// setMonitorWallpaperWindows sets the wallpaper of one monitor
+func setMonitorWallpaperWindows(monitorID, imagePath string) error {
+	monitor, err := syscall.UTF16PtrFromString(monitorID)
+	if err != nil {
+		return err
+	}
+	image, err := syscall.UTF16PtrFromString(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to convert path to UTF-16: %v", err)
+	}
+	return withDesktopWallpaper(func(dw *desktopWallpaper) error {
+		if hr := dw.call(dwSetWallpaper, uintptr(unsafe.Pointer(monitor)), uintptr(unsafe.Pointer(image))); hr < 0 {
+			return fmt.Errorf("IDesktopWallpaper.SetWallpaper failed: 0x%08X", uint32(hr))
+		}
+		return nil
+	})
+}
+
+// utf16Buffer converts s to a NUL-terminated UTF-16 buffer
+func utf16Buffer(s string) []uint16 {
+	buf, err := syscall.UTF16FromString(s)
+	if err != nil {
+		return []uint16{0}
+	}
+	return buf
+}
+
+// utf16PtrToString reads a NUL-terminated UTF-16 string
+func utf16PtrToString(p *uint16) string {
+	n := 0
+	for ptr := unsafe.Pointer(p); *(*uint16)(ptr) != 0; n++ {
+		ptr = unsafe.Add(ptr, 2)
+	}
+	return syscall.UTF16ToString(unsafe.Slice(p, n))
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// notificationTimeout is how long a notification waits for its action to
+// be clicked before the helper command is stopped
+const notificationTimeout = 2 * time.Minute
+
+// notificationAction is the notify-send action that opens the window
+const notificationAction = "open"
+
+// powershellAppID is the AppUserModelID toasts are shown under. Unpackaged
+// apps have none of their own, so PowerShell's registered one is borrowed.
+const powershellAppID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+// windowsToastScript shows a toast with two text lines. %[1]s and %[2]s
+// are the quoted XML and app ID.
+const windowsToastScript = `$ErrorActionPreference = 'Stop'
+[Windows.UI.Notifications.ToastNotificationManager,Windows.UI.Notifications,ContentType=WindowsRuntime] | Out-Null
+[Windows.Data.Xml.Dom.XmlDocument,Windows.Data.Xml.Dom.XmlDocument,ContentType=WindowsRuntime] | Out-Null
+$xml = New-Object Windows.Data.Xml.Dom.XmlDocument
+$xml.LoadXml(%[1]s)
+$toast = New-Object Windows.UI.Notifications.ToastNotification $xml
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier(%[2]s).Show($toast)`
+
+// xmlEscape escapes text for an XML element body
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;").Replace(s)
+}
+
+// appleScriptQuote quotes a string as an AppleScript string literal
+func appleScriptQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// notificationPlan returns the ordered candidate commands for showing a
+// desktop notification on goos. Where the platform can report a click, the
+// command prints notificationAction when "Open Wallset" is chosen.
+func notificationPlan(goos, title, message string) []PlannedCommand {
+	switch goos {
+	case "windows":
+		toast := fmt.Sprintf(`<toast><visual><binding template="ToastGeneric"><text>%s</text><text>%s</text></binding></visual></toast>`,
+			xmlEscape(title), xmlEscape(message))
+		return []PlannedCommand{
+			{Name: "powershell", Args: []string{"-NoProfile", "-NonInteractive", "-Command",
+				fmt.Sprintf(windowsToastScript, psQuote(toast), psQuote(powershellAppID))}},
+		}
+	case "darwin":
+		return []PlannedCommand{
+			{Name: "osascript", Args: []string{"-e", fmt.Sprintf("display notification %s with title %s",
+				appleScriptQuote(message), appleScriptQuote(title))}},
+		}
+	case "linux":
+		// --action needs libnotify 0.7.10; older notify-send gets the hint only
+		return []PlannedCommand{
+			{Name: "notify-send", Args: []string{"--app-name=Wallset", "--wait",
+				"--action=" + notificationAction + "=Open Wallset", title, message}},
+			{Name: "notify-send", Args: []string{"--app-name=Wallset", title, message}},
+		}
+	}
+	return nil
+}
+
+// notificationText describes an automatic change: the wallpaper's name,
+// where it came from and how to see it
+func notificationText(info WallpaperInfo) (string, string) {
+	name := info.DisplayName
+	if name == "" {
+		name = info.Filename
+	}
+	source := info.SourceURL
+	if u, err := url.Parse(info.SourceURL); err == nil && u.Host != "" {
+		source = u.Host
+	}
+
+	message := name
+	if source != "" {
+		message += "\nfrom " + source
+	}
+	message += "\nOpen Wallset from the tray to see it."
+	return "New wallpaper", message
+}
+
+// notifyChange shows a desktop notification for an automatic change when
+// NotifyOnChange is enabled. It returns at once; failures are only logged.
+func (a *App) notifyChange(info WallpaperInfo) {
+	if !a.GetSettings().NotifyOnChange {
+		return
+	}
+
+	title, message := notificationText(info)
+	plan := notificationPlan(runtime.GOOS, title, message)
+	go func() {
+		ctx, cancel := context.WithTimeout(a.lifetime(), notificationTimeout)
+		defer cancel()
+
+		var err error
+		for _, cmd := range plan {
+			var out []byte
+			out, err = a.runner.Output(ctx, cmd.Name, cmd.Args...)
+			if ctx.Err() != nil {
+				// Timed out waiting for a click, or shutting down
+				return
+			}
+			if err != nil {
+				continue
+			}
+			if strings.TrimSpace(string(out)) == notificationAction {
+				a.ShowWindow()
+			}
+			return
+		}
+		if err != nil {
+			fmt.Printf("Failed to show notification: %v\n", err)
+		}
+	}()
+}
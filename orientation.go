@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	orientationLandscape = "landscape"
+	orientationPortrait  = "portrait"
+)
+
+// matchesOrientation reports whether an image of the given size suits the
+// orientation filter. An empty filter or unknown size matches everything,
+// and square images match both orientations.
+func matchesOrientation(width, height int, orientation string) bool {
+	if orientation == "" || width == 0 || height == 0 {
+		return true
+	}
+	switch orientation {
+	case orientationLandscape:
+		return width >= height
+	case orientationPortrait:
+		return height >= width
+	}
+	return true
+}
+
+// matchesAspectRatio reports whether an image's width/height ratio is
+// within tolerance of the screen's, relative to the screen's ratio, so 0.2
+// accepts 16:9 ± 20%. Portrait screens work the same way. A tolerance of
+// 0 or an unknown size matches everything.
+func matchesAspectRatio(width, height, screenWidth, screenHeight int, tolerance float64) bool {
+	if tolerance <= 0 || width == 0 || height == 0 || screenWidth == 0 || screenHeight == 0 {
+		return true
+	}
+	ratio := float64(width) / float64(height)
+	screen := float64(screenWidth) / float64(screenHeight)
+	return math.Abs(ratio-screen)/screen <= tolerance
+}
+
+// shapeFilter returns a check of whether a wallpaper of a given size suits
+// the orientation filter and the primary monitor's aspect ratio. The
+// screen is looked up once, so one check can filter a whole library.
+func (a *App) shapeFilter() func(width, height int) bool {
+	screenWidth, screenHeight, _ := a.primaryScreenSize()
+	orientation, tolerance := a.GetSettings().OrientationFilter, a.GetSettings().AspectRatioTolerance
+	return func(width, height int) bool {
+		return matchesOrientation(width, height, orientation) &&
+			matchesAspectRatio(width, height, screenWidth, screenHeight, tolerance)
+	}
+}
+
+// validateOrientation checks an orientation filter value
+func validateOrientation(orientation string) error {
+	switch orientation {
+	case "", orientationLandscape, orientationPortrait:
+		return nil
+	}
+	return fmt.Errorf("invalid orientation %q: use %q or %q", orientation, orientationLandscape, orientationPortrait)
+}
+
+// GetWallpapersByOrientation returns the wallpapers matching an orientation
+func (a *App) GetWallpapersByOrientation(orientation string) ([]WallpaperInfo, error) {
+	if err := validateOrientation(orientation); err != nil {
+		return nil, err
+	}
+
+	var matches []WallpaperInfo
+	for _, wp := range a.GetWallpapers() {
+		if matchesOrientation(wp.Width, wp.Height, orientation) {
+			matches = append(matches, wp)
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// originalsDirName is the subfolder of the wallpaper directory holding the
+// untouched files KeepOriginal saves
+const originalsDirName = ".originals"
+
+// getOriginalsDir returns the directory kept originals are stored in
+func (a *App) getOriginalsDir() string {
+	return filepath.Join(a.getWallpaperDir(), originalsDirName)
+}
+
+// keepOriginal copies src to the originals folder as id plus its
+// extension when KeepOriginal is on, and returns the copy's path. With
+// StripMetadata on the copy is stripped too, and originals whose metadata
+// can't be stripped, i.e. anything but JPEG, aren't kept. It returns ""
+// when nothing was kept; a wallpaper without its original is still worth
+// keeping.
+func (a *App) keepOriginal(src, id string) string {
+	settings := a.GetSettings()
+	if !settings.KeepOriginal {
+		return ""
+	}
+	jpeg := isJPEGFile(src)
+	if settings.StripMetadata && !jpeg {
+		fmt.Printf("Not keeping original of %s: its metadata can't be stripped\n", filepath.Base(src))
+		return ""
+	}
+	dir := a.getOriginalsDir()
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		fmt.Printf("Cannot keep original of %s: %v\n", filepath.Base(src), err)
+		return ""
+	}
+	dest := filepath.Join(dir, id+strings.ToLower(filepath.Ext(src)))
+	var err error
+	if settings.StripMetadata && jpeg {
+		err = stripMetadataTo(src, dest)
+	} else {
+		err = copyFile(src, dest)
+	}
+	if err != nil {
+		os.Remove(dest)
+		fmt.Printf("Cannot keep original of %s: %v\n", filepath.Base(src), err)
+		return ""
+	}
+	return dest
+}
+
+// removeOriginal deletes a kept original, if there is one
+func removeOriginal(path string) {
+	if path != "" {
+		os.Remove(path)
+	}
+}
+
+// attachOriginal records a kept original on a freshly inspected
+// wallpaper. An original identical to the stored file is dropped, since
+// nothing rewrote it.
+func attachOriginal(info *WallpaperInfo, original string) {
+	if original == "" {
+		return
+	}
+	hash, err := fileHash(original)
+	if err != nil || hash == info.Hash {
+		removeOriginal(original)
+		return
+	}
+	info.OriginalPath = original
+	info.OriginalHash = hash
+}
+
+// cleanupOriginals removes kept originals no library entry refers to,
+// e.g. after a crash mid-download. Recent files are left alone, as they
+// may belong to a download still in progress.
+func (a *App) cleanupOriginals(report *CleanupReport) {
+	a.mu.Lock()
+	kept := make(map[string]bool)
+	for _, wp := range a.data.Wallpapers {
+		if wp.OriginalPath != "" {
+			kept[wp.OriginalPath] = true
+		}
+	}
+	a.mu.Unlock()
+
+	cutoff := time.Now().Add(-partialMaxAge)
+	originals, _ := filepath.Glob(filepath.Join(a.getOriginalsDir(), "*"))
+	for _, path := range originals {
+		if kept[path] {
+			continue
+		}
+		if stat, err := os.Stat(path); err != nil || stat.ModTime().After(cutoff) {
+			continue
+		}
+		if size, ok := removeCounted(path); ok {
+			report.OriginalsRemoved++
+			report.BytesReclaimed += size
+		}
+	}
+}
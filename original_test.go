@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestKeepOriginalStripsMetadata adds testdata/gps.jpg with both
+// KeepOriginal and StripMetadata on and checks that no copy of its GPS
+// position is left in the originals folder
+func TestKeepOriginalStripsMetadata(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/gps.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		add  func(t *testing.T, a *App) (*WallpaperInfo, error)
+	}{
+		{"import", func(t *testing.T, a *App) (*WallpaperInfo, error) {
+			return a.ImportWallpaper("testdata/gps.jpg")
+		}},
+		{"download", func(t *testing.T, a *App) (*WallpaperInfo, error) {
+			server := httptest.NewServer(serveBytes("image/jpeg", fixture))
+			defer server.Close()
+			info, err := a.fetchFromURL(server.URL + "/gps.jpg")
+			return &info, err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(t)
+			a.changeSettings(func(s *AppSettings) {
+				s.KeepOriginal = true
+				s.StripMetadata = true
+				s.MinFileSizeBytes = 0
+			})
+
+			info, err := tt.add(t, a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkStripped(t, info.Filepath)
+			// Stripped, the original is the stored file, so it's dropped
+			if info.OriginalPath != "" {
+				t.Errorf("kept original %s, identical to the stored file", info.OriginalPath)
+			}
+			originals, _ := filepath.Glob(filepath.Join(a.getOriginalsDir(), "*"))
+			for _, path := range originals {
+				checkStripped(t, path)
+			}
+		})
+	}
+}
+
+// TestKeepOriginalSkipsUnstrippable checks that with StripMetadata on, an
+// original whose metadata can't be stripped isn't kept
+func TestKeepOriginalSkipsUnstrippable(t *testing.T) {
+	a := newTestApp(t)
+	a.changeSettings(func(s *AppSettings) {
+		s.KeepOriginal = true
+		s.StripMetadata = true
+	})
+	if got := a.keepOriginal("testdata/animated.gif", "gif"); got != "" {
+		t.Errorf("kept %s", got)
+	}
+
+	a.changeSettings(func(s *AppSettings) { s.StripMetadata = false })
+	if got := a.keepOriginal("testdata/animated.gif", "gif"); got == "" {
+		t.Error("original not kept with StripMetadata off")
+	}
+}
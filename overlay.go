@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Corners accepted by OverlayPosition
+const (
+	overlayTopLeft     = "top-left"
+	overlayTopRight    = "top-right"
+	overlayBottomLeft  = "bottom-left"
+	overlayBottomRight = "bottom-right"
+)
+
+const (
+	// Overlay font sizes are pixels on a 1080-pixel-high image and scale
+	// with the wallpaper, so the text looks the same size on any screen
+	minOverlayFontSize   = 8
+	maxOverlayFontSize   = 200
+	overlayReferenceSize = 1080
+	// maxOverlayTextLength keeps the overlay to a single short line
+	maxOverlayTextLength = 200
+)
+
+// overlayFont is the embedded Go Regular typeface, parsed on first use
+var overlayFont = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(goregular.TTF)
+})
+
+// validateOverlay checks the overlay settings. The text may be empty,
+// which disables the overlay.
+func validateOverlay(s AppSettings) error {
+	if len(s.OverlayText) > maxOverlayTextLength {
+		return fmt.Errorf("overlay text must be at most %d characters", maxOverlayTextLength)
+	}
+	switch s.OverlayPosition {
+	case "", overlayTopLeft, overlayTopRight, overlayBottomLeft, overlayBottomRight:
+	default:
+		return fmt.Errorf("overlay position must be %s, %s, %s or %s",
+			overlayTopLeft, overlayTopRight, overlayBottomLeft, overlayBottomRight)
+	}
+	if s.OverlayFontSize < minOverlayFontSize || s.OverlayFontSize > maxOverlayFontSize {
+		return fmt.Errorf("overlay font size must be between %d and %d", minOverlayFontSize, maxOverlayFontSize)
+	}
+	if _, err := parseOverlayColor(s.OverlayColor); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseOverlayColor parses the "#rrggbb" text color, defaulting to white
+func parseOverlayColor(hex string) (color.RGBA, error) {
+	if hex == "" {
+		return color.RGBA{R: 255, G: 255, B: 255, A: 255}, nil
+	}
+	r, g, b, ok := parseHexColor(hex)
+	if !ok {
+		return color.RGBA{}, fmt.Errorf("overlay color must be #rrggbb")
+	}
+	return color.RGBA{R: uint8(r * 255), G: uint8(g * 255), B: uint8(b * 255), A: 255}, nil
+}
+
+// overlayText returns the configured text collapsed onto one line, or ""
+// when the overlay is disabled
+func (a *App) overlayText() string {
+	return strings.Join(strings.Fields(a.GetSettings().OverlayText), " ")
+}
+
+// getOverlayDir returns the cache directory for rendered overlay copies
+func (a *App) getOverlayDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	dir := filepath.Join(cacheDir, "WallpaperEngine", "overlay")
+	os.MkdirAll(dir, os.ModePerm)
+	return dir
+}
+
+// renderOverlay returns the file to hand to the desktop for path: path
+// itself when the overlay is off, otherwise a copy with the text drawn on.
+// Each copy gets a new name, since Windows keeps showing a cached image
+// when the same path is set twice; older copies are removed.
+func (a *App) renderOverlay(path string) (string, error) {
+	text := a.overlayText()
+	if text == "" {
+		return path, nil
+	}
+	textColor, err := parseOverlayColor(a.GetSettings().OverlayColor)
+	if err != nil {
+		return "", err
+	}
+
+	img, err := decodeImage(path)
+	if err != nil {
+		return "", err
+	}
+	canvas := toRGBA(img)
+	if err := drawOverlay(canvas, text, a.GetSettings().OverlayPosition, a.GetSettings().OverlayFontSize, textColor); err != nil {
+		return "", err
+	}
+
+	dir := a.getOverlayDir()
+	out := filepath.Join(dir, fmt.Sprintf("overlay_%d.jpg", time.Now().UnixNano()))
+	if err := saveJPEG(canvas, out); err != nil {
+		return "", err
+	}
+	if old, err := filepath.Glob(filepath.Join(dir, "overlay_*.jpg")); err == nil {
+		for _, f := range old {
+			if f != out {
+				os.Remove(f)
+			}
+		}
+	}
+	return out, nil
+}
+
+// drawOverlay draws text into a corner of img with a one-em margin. A soft
+// outline in the opposite tone of the text, plus a drop shadow, keeps it
+// readable on both light and dark areas.
+func drawOverlay(img *image.RGBA, text, position string, fontSize int, textColor color.RGBA) error {
+	f, err := overlayFont()
+	if err != nil {
+		return fmt.Errorf("failed to load overlay font: %v", err)
+	}
+	bounds := img.Bounds()
+	size := float64(fontSize) * float64(bounds.Dy()) / overlayReferenceSize
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return fmt.Errorf("failed to load overlay font: %v", err)
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	width := font.MeasureString(face, text).Ceil()
+	margin := int(size)
+	if position == "" {
+		position = overlayBottomRight
+	}
+
+	x := bounds.Min.X + margin
+	if position == overlayTopRight || position == overlayBottomRight {
+		x = bounds.Max.X - margin - width
+	}
+	y := bounds.Min.Y + margin + metrics.Ascent.Ceil()
+	if position == overlayBottomLeft || position == overlayBottomRight {
+		y = bounds.Max.Y - margin - metrics.Descent.Ceil()
+	}
+
+	// Light text gets a dark halo and dark text a light one
+	r, g, b := float64(textColor.R)/255, float64(textColor.G)/255, float64(textColor.B)/255
+	halo := color.RGBA{A: 80}
+	if 0.2126*r+0.7152*g+0.0722*b < 0.5 {
+		halo = color.RGBA{R: 80, G: 80, B: 80, A: 80}
+	}
+
+	drawer := font.Drawer{Dst: img, Face: face}
+	drawAt := func(c color.Color, dx, dy int) {
+		drawer.Src = image.NewUniform(c)
+		drawer.Dot = fixed.P(x+dx, y+dy)
+		drawer.DrawString(text)
+	}
+
+	spread := max(1, int(size/24))
+	drawAt(halo, spread*2, spread*2)
+	for _, d := range [][2]int{{-1, -1}, {0, -1}, {1, -1}, {-1, 0}, {1, 0}, {-1, 1}, {0, 1}, {1, 1}} {
+		drawAt(halo, d[0]*spread, d[1]*spread)
+	}
+	drawAt(textColor, 0, 0)
+	return nil
+}
+
+// SetOverlayText changes the overlay text and re-applies the current
+// wallpaper with it at once. An empty string removes the overlay.
+func (a *App) SetOverlayText(text string) error {
+	s := a.GetSettings()
+	s.OverlayText = text
+	if err := validateOverlay(s); err != nil {
+		return err
+	}
+	a.changeSettings(func(s *AppSettings) { s.OverlayText = text })
+	if err := a.saveSettings(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	current := a.data.CurrentPath
+	a.mu.Unlock()
+	if current == "" {
+		return nil
+	}
+	return a.applyWallpaper(current)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// parallelSources is how many sources ParallelSourceFetch downloads from
+// at once
+const parallelSources = 3
+
+var (
+	// errChangeInProgress is returned when a change is requested while
+	// another is downloading
+	errChangeInProgress = errors.New("a wallpaper change is already in progress")
+	// errRaceLost stops the downloads still running once another source
+	// has won a race
+	errRaceLost = errors.New("another source was faster")
+)
+
+// raceResult is the outcome of one download in a race
+type raceResult struct {
+	source string
+	info   *WallpaperInfo
+	err    error
+}
+
+// raceSources downloads from up to parallelSources sources at once,
+// starting the next one whenever a download fails. The first candidate
+// wins and the downloads still running are cancelled, removing their
+// partial files. It returns the winner with its source and the sources
+// not tried; failures before the win are added to failed.
+//
+// Downloads that finish after the winner are kept in the library with
+// KeepExtraDownloads and discarded otherwise. If the user cancels, every
+// download stops and the error is errDownloadCanceled.
+func (a *App) raceSources(sources []string, automatic bool, failed *sourcesFailedError) (string, *WallpaperInfo, []string, error) {
+	ctx, cancel := context.WithCancelCause(a.lifetime())
+	defer cancel(nil)
+
+	results := make(chan raceResult)
+	running, next := 0, 0
+	start := func() {
+		source := sources[next]
+		next++
+		running++
+		go func() {
+			info, err := a.fetchCandidate(ctx, source, automatic)
+			results <- raceResult{source: source, info: info, err: err}
+		}()
+	}
+	for running < parallelSources && next < len(sources) {
+		start()
+	}
+
+	var winner *raceResult
+	canceled := false
+	for running > 0 {
+		r := <-results
+		running--
+		switch {
+		case r.err == nil && winner == nil && !canceled:
+			winner = &r
+			cancel(errRaceLost)
+		case r.err == nil:
+			a.keepExtraDownload(r.source, r.info)
+		case errors.Is(r.err, errDownloadCanceled):
+			canceled = true
+			cancel(errDownloadCanceled)
+		case winner == nil && !canceled:
+			failed.add(r.source, r.err)
+			if next < len(sources) {
+				start()
+			}
+		}
+	}
+
+	if canceled {
+		if winner != nil {
+			removeWallpaperFiles(*winner.info)
+		}
+		return "", nil, nil, errDownloadCanceled
+	}
+	if winner == nil {
+		return "", nil, nil, nil
+	}
+	return winner.source, winner.info, sources[next:], nil
+}
+
+// keepExtraDownload stores a download that finished after a race was
+// won, when KeepExtraDownloads is on, or removes it
+func (a *App) keepExtraDownload(source string, info *WallpaperInfo) {
+	if !a.GetSettings().KeepExtraDownloads {
+		removeWallpaperFiles(*info)
+		return
+	}
+	// The winner may have been the same image
+	if existing, ok := a.findDuplicate(*info); ok && a.sourceConfig(source).Type != sourceTypeEarth {
+		fmt.Printf("Discarding %s: duplicate of %s\n", info.Filename, existing.Filename)
+		removeWallpaperFiles(*info)
+		return
+	}
+	if err := a.storeDownload(source, info); err != nil {
+		fmt.Printf("Discarding %s: %v\n", info.Filename, err)
+		return
+	}
+	a.emit("wallpapersUpdated", a.GetWallpapers())
+}
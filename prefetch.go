@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// maxPrefetch bounds how many wallpapers one prefetch may download
+const maxPrefetch = 100
+
+// Outcomes of one prefetched item, reported in PrefetchProgress.Status
+const (
+	prefetchAdded     = "added"
+	prefetchDuplicate = "duplicate"
+	prefetchFailed    = "failed"
+)
+
+// errPrefetchRunning is returned when a prefetch is started during another
+var errPrefetchRunning = errors.New("a prefetch is already running")
+
+// PrefetchResult summarises a prefetch. PrefetchWallpapers returns it with
+// only Requested set; the prefetchFinished event carries the final counts.
+type PrefetchResult struct {
+	Requested  int  `json:"requested"`
+	Succeeded  int  `json:"succeeded"`
+	Duplicates int  `json:"duplicates"`
+	Failed     int  `json:"failed"`
+	Cancelled  bool `json:"cancelled"`
+}
+
+// PrefetchProgress is emitted as prefetchProgress after each item
+type PrefetchProgress struct {
+	// Index counts items from 1 up to Count
+	Index     int            `json:"index"`
+	Count     int            `json:"count"`
+	Status    string         `json:"status"`
+	Source    string         `json:"source"`
+	Wallpaper *WallpaperInfo `json:"wallpaper,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// prefetchState tracks the running prefetch, if any
+type prefetchState struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	// reserve raises the MaxWallpapers eviction threshold while a prefetch
+	// runs, so its own downloads aren't evicted as they arrive
+	reserve int
+}
+
+// PrefetchWallpapers downloads count wallpapers into the library in the
+// background without setting any of them, e.g. before going offline.
+// Sources are used in turn, subject to their rate limits, and duplicates
+// are skipped. Progress is reported with prefetchProgress events and the
+// summary with prefetchFinished.
+func (a *App) PrefetchWallpapers(count int) (PrefetchResult, error) {
+	if count < 1 || count > maxPrefetch {
+		return PrefetchResult{}, fmt.Errorf("prefetch count must be between 1 and %d", maxPrefetch)
+	}
+	if a.GetSettings().DownloadsDisabled {
+		return PrefetchResult{}, errDownloadsDisabled
+	}
+	if len(a.GetSettings().DownloadSources) == 0 {
+		return PrefetchResult{}, fmt.Errorf("no download sources configured")
+	}
+	if !a.storageAvailable() {
+		return PrefetchResult{}, errStorageUnavailable
+	}
+
+	a.prefetch.mu.Lock()
+	if a.prefetch.cancel != nil {
+		a.prefetch.mu.Unlock()
+		return PrefetchResult{}, errPrefetchRunning
+	}
+	if !a.beginTask() {
+		a.prefetch.mu.Unlock()
+		return PrefetchResult{}, errShuttingDown
+	}
+	ctx, cancel := context.WithCancel(a.lifetime())
+	a.prefetch.cancel = cancel
+	a.prefetch.reserve = count
+	a.prefetch.mu.Unlock()
+
+	go func() {
+		defer a.tasks.Done()
+		result := a.runPrefetch(ctx, count)
+
+		a.prefetch.mu.Lock()
+		a.prefetch.cancel = nil
+		a.prefetch.reserve = 0
+		a.prefetch.mu.Unlock()
+		cancel()
+
+		fmt.Printf("Prefetch finished: %d added, %d duplicates, %d failed\n", result.Succeeded, result.Duplicates, result.Failed)
+		a.emit("wallpapersUpdated", a.GetWallpapers())
+		a.emit("prefetchFinished", result)
+	}()
+	return PrefetchResult{Requested: count}, nil
+}
+
+// CancelPrefetch stops the running prefetch after the item in progress
+func (a *App) CancelPrefetch() {
+	a.prefetch.mu.Lock()
+	defer a.prefetch.mu.Unlock()
+	if a.prefetch.cancel != nil {
+		a.prefetch.cancel()
+	}
+}
+
+// prefetchReserve returns how far a running prefetch raises MaxWallpapers
+func (a *App) prefetchReserve() int {
+	a.prefetch.mu.Lock()
+	defer a.prefetch.mu.Unlock()
+	return a.prefetch.reserve
+}
+
+// runPrefetch downloads count items, cycling through the sources. Once
+// every source is rate limited the remaining items fail.
+func (a *App) runPrefetch(ctx context.Context, count int) PrefetchResult {
+	result := PrefetchResult{Requested: count}
+	sources := a.GetSettings().DownloadSources
+	next := 0
+
+	for i := 1; i <= count; i++ {
+		if ctx.Err() != nil {
+			result.Cancelled = true
+			break
+		}
+
+		progress := PrefetchProgress{Index: i, Count: count, Status: prefetchFailed}
+		source, ok := "", false
+		for tries := 0; tries < len(sources) && !ok; tries++ {
+			source = sources[next%len(sources)]
+			next++
+			ok = a.allowRequest(source)
+		}
+
+		if !ok {
+			progress.Error = errRateLimited.Error()
+		} else {
+			progress.Source = source
+			info, err := a.prefetchOne(source)
+			switch {
+			case errors.Is(err, errDuplicate) || errors.Is(err, errNotModified):
+				progress.Status = prefetchDuplicate
+			case err != nil:
+				progress.Error = err.Error()
+			default:
+				progress.Status = prefetchAdded
+				progress.Wallpaper = info
+			}
+		}
+
+		switch progress.Status {
+		case prefetchAdded:
+			result.Succeeded++
+		case prefetchDuplicate:
+			result.Duplicates++
+		default:
+			result.Failed++
+		}
+		a.emit("prefetchProgress", progress)
+	}
+	return result
+}
+
+// prefetchOne downloads one wallpaper from source and adds it to the
+// library with the same validation and processing as downloadAndSet
+func (a *App) prefetchOne(source string) (*WallpaperInfo, error) {
+	info, err := a.downloadFile(a.lifetime(), source, false)
+	if errors.Is(err, errDownloadCanceled) {
+		return nil, err
+	}
+	a.recordSourceResult(source, err)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing, ok := a.findDuplicate(*info); ok {
+		removeWallpaperFiles(*info)
+		return nil, fmt.Errorf("%w of %s", errDuplicate, existing.Filename)
+	}
+	if err := a.processWallpaper(info); err != nil {
+		fmt.Printf("Failed to process wallpaper %s: %v\n", info.Filename, err)
+	}
+	if err := a.addWallpaper(*info); err != nil {
+		removeWallpaperFiles(*info)
+		return nil, err
+	}
+	a.emitWallpaperDownloaded(*info)
+	return info, nil
+}
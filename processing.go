@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// maxBlurRadius bounds BlurRadius so processing stays reasonably fast
+const maxBlurRadius = 100
+
+// processedPath returns the path of the processed copy for an original file
+func processedPath(original, suffix string) string {
+	ext := filepath.Ext(original)
+	return strings.TrimSuffix(original, ext) + "_" + suffix + ".jpg"
+}
+
+// processWallpaper creates a blurred copy of the wallpaper when BlurRadius is
+// set, leaving the original untouched, and records it as ProcessedPath
+func (a *App) processWallpaper(info *WallpaperInfo) error {
+	radius := a.GetSettings().BlurRadius
+	if radius <= 0 {
+		return nil
+	}
+
+	img, err := decodeImage(info.Filepath)
+	if err != nil {
+		return err
+	}
+
+	out := processedPath(info.Filepath, "blur")
+	if err := saveJPEG(gaussianBlur(img, radius), out); err != nil {
+		return err
+	}
+
+	info.ProcessedPath = out
+	return nil
+}
+
+// wallpaperPath returns the file that should be applied for a wallpaper
+func wallpaperPath(info WallpaperInfo) string {
+	if info.ProcessedPath != "" {
+		return info.ProcessedPath
+	}
+	return info.Filepath
+}
+
+// validateSettings checks settings before they are applied
+func validateSettings(s AppSettings) error {
+	if s.BlurRadius < 0 || s.BlurRadius > maxBlurRadius {
+		return fmt.Errorf("blur radius must be between 0 and %d", maxBlurRadius)
+	}
+	if err := validateOrientation(s.OrientationFilter); err != nil {
+		return err
+	}
+	if err := validateContentFilter(s.ContentFilter); err != nil {
+		return err
+	}
+	if s.AspectRatioTolerance < 0 {
+		return fmt.Errorf("aspect ratio tolerance cannot be negative")
+	}
+	if s.MaxDownloadSpeedKBps < 0 {
+		return fmt.Errorf("download speed limit cannot be negative; use 0 for unlimited")
+	}
+	if s.SimilarityThreshold < -1 || s.SimilarityThreshold > 64 {
+		return fmt.Errorf("similarity threshold must be between -1 and 64")
+	}
+	if s.EnableHotkeys {
+		if err := validateHotkeys(s.Hotkeys); err != nil {
+			return err
+		}
+	}
+	if s.ControlAPIEnabled {
+		if _, _, err := net.SplitHostPort(s.ControlAPIAddress); err != nil {
+			return fmt.Errorf("invalid control API address: %v", err)
+		}
+	}
+	if s.ExtensionOrigin != "" {
+		if u, err := url.Parse(s.ExtensionOrigin); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("extension origin must look like scheme://host")
+		}
+	}
+	if s.Latitude != nil && (*s.Latitude < -90 || *s.Latitude > 90) {
+		return fmt.Errorf("latitude must be between -90 and 90")
+	}
+	if s.Longitude != nil && (*s.Longitude < -180 || *s.Longitude > 180) {
+		return fmt.Errorf("longitude must be between -180 and 180")
+	}
+	if s.WeatherEnabled && (s.Latitude == nil || s.Longitude == nil) {
+		return fmt.Errorf("weather needs a latitude and longitude")
+	}
+	if err := validateSeasonalThemes(s.SeasonalCalendar); err != nil {
+		return err
+	}
+	if s.MinMinutesBetweenUnlockChanges < 0 {
+		return fmt.Errorf("minimum minutes between unlock changes cannot be negative")
+	}
+	if s.OnlyChangeWhenIdle && s.IdleThresholdSeconds <= 0 {
+		return fmt.Errorf("idle threshold must be positive")
+	}
+	if s.CollageGutter < 0 {
+		return fmt.Errorf("collage gutter cannot be negative")
+	}
+	if _, err := parseCollageBackground(s.CollageBackground); err != nil {
+		return err
+	}
+	if err := validateFilenameTemplate(s.FilenameTemplate); err != nil {
+		return err
+	}
+	if err := validateOverlay(s); err != nil {
+		return err
+	}
+	if err := validateSourceRotation(s.SourceRotation); err != nil {
+		return err
+	}
+	if strings.ContainsAny(s.UserAgent, "\r\n") {
+		return fmt.Errorf("user agent cannot contain line breaks")
+	}
+	if _, invalid := normalizeSources(s.DownloadSources, s.SourceConfigs); len(invalid) > 0 {
+		return fmt.Errorf("invalid download sources: %s", strings.Join(invalid, ", "))
+	}
+	switch s.LocalRotation {
+	case "", localRotationShuffle, localRotationSequential:
+	default:
+		return fmt.Errorf("local rotation must be %q or %q", localRotationShuffle, localRotationSequential)
+	}
+	if err := validateSchedules(s); err != nil {
+		return err
+	}
+	if s.ShuffleRecencyWeight < 0 || s.ShuffleRatingWeight < 0 {
+		return fmt.Errorf("shuffle weights cannot be negative")
+	}
+	for source, config := range s.SourceConfigs {
+		if err := validateSourceHeaders(source, config.Headers); err != nil {
+			return err
+		}
+		if err := validateContentFilter(config.ContentFilter); err != nil {
+			return fmt.Errorf("%s: %v", source, err)
+		}
+		switch config.Type {
+		case "", sourceTypeWebDAV, sourceTypeRSS:
+		case sourceTypeSFTP:
+			if u, err := url.Parse(source); err != nil || u.Scheme != "sftp" || u.Host == "" {
+				return fmt.Errorf("SFTP source %s must look like sftp://user@host/dir", source)
+			}
+		case sourceTypeEarth:
+			if earthProvider(source) == "" {
+				return fmt.Errorf("earth source %s must be earth:epic or earth:himawari", source)
+			}
+			if !validHimawariZoom(config.Zoom) {
+				return fmt.Errorf("Himawari zoom for %s must be 2 or 4", source)
+			}
+		case sourceTypeScript:
+			if args, err := splitCommandLine(config.Command); err != nil || len(args) == 0 {
+				return fmt.Errorf("script source %s needs a valid command", source)
+			}
+			if config.TimeoutSeconds < 0 {
+				return fmt.Errorf("script timeout for %s cannot be negative", source)
+			}
+		default:
+			return fmt.Errorf("unknown type %q for %s", config.Type, source)
+		}
+	}
+	if s.MaxWallpaperAgeDays < 0 {
+		return fmt.Errorf("maximum wallpaper age cannot be negative")
+	}
+	if s.TrashRetentionDays < 0 {
+		return fmt.Errorf("trash retention cannot be negative")
+	}
+	if s.MaxFileSizeBytes < 0 {
+		return fmt.Errorf("max file size cannot be negative")
+	}
+	if s.MinFileSizeBytes < 0 {
+		return fmt.Errorf("min file size cannot be negative")
+	}
+	if s.MaxRedirects < 0 || s.MaxRedirects > maxRedirectsLimit {
+		return fmt.Errorf("max redirects must be between 0 and %d", maxRedirectsLimit)
+	}
+	if s.MaxPreviewSizeMB < 0 {
+		return fmt.Errorf("max preview size cannot be negative")
+	}
+	return nil
+}
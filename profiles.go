@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultProfile is the profile in use until another one is created
+const defaultProfile = "default"
+
+// profileNamePattern keeps profile names usable as filenames everywhere
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _-]{0,39}$`)
+
+// ProfileInfo describes one settings profile
+type ProfileInfo struct {
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+// getProfileDir returns the directory holding the saved settings of the
+// profiles that are not active. The active one lives in settings.json, so
+// a single-profile setup works exactly as before.
+func (a *App) getProfileDir() string {
+	dir := filepath.Join(filepath.Dir(a.getConfigPath("settings.json")), "profiles")
+	os.MkdirAll(dir, os.ModePerm)
+	return dir
+}
+
+// profilePath returns where a profile's settings are saved while inactive
+func (a *App) profilePath(name string) string {
+	return filepath.Join(a.getProfileDir(), name+".json")
+}
+
+// activeProfile returns the name of the profile in settings.json
+func (a *App) activeProfile() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.data.ActiveProfile == "" {
+		return defaultProfile
+	}
+	return a.data.ActiveProfile
+}
+
+// findProfile returns the stored spelling of name, matched without regard
+// to case since profile files may live on a case-insensitive filesystem
+func (a *App) findProfile(name string) (string, bool) {
+	for _, p := range a.ListProfiles() {
+		if strings.EqualFold(p.Name, name) {
+			return p.Name, true
+		}
+	}
+	return "", false
+}
+
+// ListProfiles returns every profile, sorted by name, marking the active one
+func (a *App) ListProfiles() []ProfileInfo {
+	active := a.activeProfile()
+	names := map[string]bool{defaultProfile: true, active: true}
+	if files, err := filepath.Glob(filepath.Join(a.getProfileDir(), "*.json")); err == nil {
+		for _, f := range files {
+			names[strings.TrimSuffix(filepath.Base(f), ".json")] = true
+		}
+	}
+
+	profiles := make([]ProfileInfo, 0, len(names))
+	for name := range names {
+		profiles = append(profiles, ProfileInfo{Name: name, Active: name == active})
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles
+}
+
+// CreateProfile saves a new profile starting from the current settings.
+// The active profile does not change.
+func (a *App) CreateProfile(name string) error {
+	name = strings.TrimSpace(name)
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("profile names are 1-40 letters, digits, spaces, dashes or underscores")
+	}
+	if existing, ok := a.findProfile(name); ok {
+		return fmt.Errorf("profile %q already exists", existing)
+	}
+	current, err := a.settingsWithSecrets()
+	if err != nil {
+		return err
+	}
+	return a.saveProfile(name, current)
+}
+
+// SwitchProfile saves the current settings to the active profile, then
+// loads name's settings and applies them as UpdateSettings would, which
+// restarts the services and the auto-changer schedule
+func (a *App) SwitchProfile(name string) (AppSettings, error) {
+	current := a.GetSettings()
+	found, ok := a.findProfile(strings.TrimSpace(name))
+	if !ok {
+		return current, fmt.Errorf("no profile named %q", name)
+	}
+	name = found
+	active := a.activeProfile()
+	if name == active {
+		return current, nil
+	}
+	current, err := a.settingsWithSecrets()
+	if err != nil {
+		return current, err
+	}
+
+	target := current
+	var saved AppSettings
+	if data, err := os.ReadFile(a.profilePath(name)); err == nil {
+		saved = defaultSettings()
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return current, fmt.Errorf("profile %q is corrupt: %v", name, err)
+		}
+		target, _ = a.resolveSecrets(saved)
+	} else if name != defaultProfile {
+		return current, err
+	}
+	if err := validateSettings(target); err != nil {
+		return current, fmt.Errorf("profile %q: %v", name, err)
+	}
+	target.DownloadSources, _ = normalizeSources(target.DownloadSources, target.SourceConfigs)
+
+	if err := a.saveProfile(active, current); err != nil {
+		return current, fmt.Errorf("failed to save profile %q: %v", active, err)
+	}
+	old := a.setSettings(target)
+	if err := a.saveSettings(); err != nil {
+		a.setSettings(old)
+		return old, err
+	}
+	// The active profile's settings are in settings.json only, and its
+	// secrets under the plain names; copies still locked stay referenced
+	os.Remove(a.profilePath(name))
+	a.deleteProfileSecrets(saved, target.unresolvedSecrets)
+
+	a.mu.Lock()
+	a.data.ActiveProfile = name
+	a.mu.Unlock()
+	a.saveWallpapers()
+
+	a.settingsChanged(old)
+	a.emit("profileSwitched", name)
+	return target, nil
+}
+
+// DeleteProfile removes a saved profile. The active and default profiles
+// cannot be deleted.
+func (a *App) DeleteProfile(name string) error {
+	found, ok := a.findProfile(strings.TrimSpace(name))
+	if !ok {
+		return fmt.Errorf("no profile named %q", name)
+	}
+	name = found
+	if name == a.activeProfile() {
+		return fmt.Errorf("switch to another profile before deleting %q", name)
+	}
+	if name == defaultProfile {
+		return fmt.Errorf("the default profile cannot be deleted")
+	}
+	var saved AppSettings
+	if data, err := os.ReadFile(a.profilePath(name)); err == nil && json.Unmarshal(data, &saved) == nil {
+		a.deleteProfileSecrets(saved, nil)
+	}
+	return os.Remove(a.profilePath(name))
+}
+
+// settingsWithSecrets returns the current settings with every secret
+// read, asking the user to unlock the store if needed. A profile's copy
+// of the settings can't refer to the active profile's secrets, which the
+// next profile's replace.
+func (a *App) settingsWithSecrets() (AppSettings, error) {
+	current := a.GetSettings()
+	if len(current.unresolvedSecrets) == 0 {
+		return current, nil
+	}
+	names := make([]string, 0, len(current.unresolvedSecrets))
+	for name := range current.unresolvedSecrets {
+		names = append(names, name)
+	}
+	a.resolvePendingSecrets(names)
+	current = a.GetSettings()
+	if len(current.unresolvedSecrets) > 0 {
+		return current, fmt.Errorf("unlock the keyring to save the profile's secrets")
+	}
+	return current, nil
+}
+
+// saveProfile writes settings as the saved copy of a profile. Its secrets
+// go to the secret store under the profile's own names, and the file
+// holds references to them, as settings.json does.
+func (a *App) saveProfile(name string, settings AppSettings) error {
+	var errs []error
+	settings = mapSecrets(settings, func(secret, value string) string {
+		if value == "" || isSecretRef(value) {
+			return value
+		}
+		stored := profileSecretName(name, secret)
+		if err := a.secretStore().Set(stored, value); err != nil {
+			errs = append(errs, fmt.Errorf("cannot store %s: %v", secret, err))
+		}
+		return secretRefPrefix + stored
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(a.profilePath(name), data, 0644)
+}
+
+// deleteProfileSecrets removes the profile copies of the secrets saved
+// refers to, except those still referenced from keep
+func (a *App) deleteProfileSecrets(saved AppSettings, keep map[string]string) {
+	mapSecrets(saved, func(name, value string) string {
+		if isProfileSecretRef(value) && keep[name] != value {
+			if err := a.secretStore().Delete(strings.TrimPrefix(value, secretRefPrefix)); err != nil {
+				fmt.Printf("Cannot delete secret %s: %v\n", name, err)
+			}
+		}
+		return value
+	})
+}
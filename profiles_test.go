@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestProfileSecretsSurviveSwitching switches between two profiles with
+// different tokens and checks each keeps its own, out of the profile files
+func TestProfileSecretsSurviveSwitching(t *testing.T) {
+	a := newTestApp(t)
+	store := &fakeSecretStore{values: map[string]string{}}
+	useSecretStore(a, store)
+
+	withToken := func(token string) AppSettings {
+		s := a.GetSettings()
+		s.ControlAPIToken = token
+		return s
+	}
+	if err := a.UpdateSettings(withToken("token-default")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CreateProfile("Work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.UpdateSettings(withToken("token-default-2")); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := a.SwitchProfile("Work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ControlAPIToken != "token-default" {
+		t.Errorf("Work token = %q, want the one it was created with", s.ControlAPIToken)
+	}
+	data, err := os.ReadFile(a.profilePath(defaultProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "token-default-2") {
+		t.Errorf("profile file holds a plaintext secret:\n%s", data)
+	}
+
+	s, err = a.SwitchProfile(defaultProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ControlAPIToken != "token-default-2" {
+		t.Errorf("default token = %q after switching back, want token-default-2", s.ControlAPIToken)
+	}
+	if store.values[controlAPITokenSecret] != "token-default-2" {
+		t.Errorf("stored token = %q, want token-default-2", store.values[controlAPITokenSecret])
+	}
+	// Only the inactive Work profile's copy remains
+	for name := range store.values {
+		if strings.HasPrefix(name, "profile-") && name != profileSecretName("Work", controlAPITokenSecret) {
+			t.Errorf("stale profile secret %s", name)
+		}
+	}
+}
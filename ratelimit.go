@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errRateLimited is a request held back by a source's rate limit
+var errRateLimited = errors.New("rate limit reached")
+
+// defaultRetryAfter is the cooldown applied to a 429 without a Retry-After header
+const defaultRetryAfter = 5 * time.Minute
+
+// RateLimit caps how many requests a source may make per period
+type RateLimit struct {
+	Requests      int `json:"requests"`
+	PeriodSeconds int `json:"period_seconds"`
+}
+
+// defaultRateLimits holds known provider quotas, matched by host suffix
+var defaultRateLimits = map[string]RateLimit{
+	"wallhaven.cc": {Requests: 45, PeriodSeconds: 60},
+	"unsplash.com": {Requests: 50, PeriodSeconds: 3600},
+	"reddit.com":   {Requests: 60, PeriodSeconds: 60},
+}
+
+// SourceStatus reports the health and quota of a download source
+type SourceStatus struct {
+	URL             string    `json:"url"`
+	LastAttempt     time.Time `json:"last_attempt"`
+	LastSuccess     time.Time `json:"last_success"`
+	LastError       string    `json:"last_error,omitempty"`
+	CooldownUntil   time.Time `json:"cooldown_until"`
+	TokensRemaining int       `json:"tokens_remaining"` // -1 when not rate limited
+	QuotaRemaining  int       `json:"quota_remaining"`  // -1 when the provider doesn't say
+	QuotaReset      string    `json:"quota_reset,omitempty"`
+}
+
+// tokenBucket is a simple non-blocking token bucket
+type tokenBucket struct {
+	limit         RateLimit
+	tokens        float64
+	lastRefill    time.Time
+	cooldownUntil time.Time
+}
+
+// sourceState tracks per-source results for GetSourceStatus
+type sourceState struct {
+	lastAttempt    time.Time
+	lastSuccess    time.Time
+	lastError      string
+	quotaRemaining int
+	quotaReset     string
+}
+
+// sourceTracker holds rate limiters and status for all sources
+type sourceTracker struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	states  map[string]*sourceState
+	// lastUsed is the source of the last downloaded wallpaper, for
+	// round-robin rotation
+	lastUsed string
+}
+
+// refill tops the bucket up according to the time elapsed
+func (b *tokenBucket) refill(now time.Time) {
+	period := time.Duration(b.limit.PeriodSeconds) * time.Second
+	elapsed := now.Sub(b.lastRefill)
+	b.tokens += float64(b.limit.Requests) * elapsed.Seconds() / period.Seconds()
+	if b.tokens > float64(b.limit.Requests) {
+		b.tokens = float64(b.limit.Requests)
+	}
+	b.lastRefill = now
+}
+
+// rateLimitFor returns the limit for a source and the key its bucket is
+// shared under. Overrides get a bucket per source, defaults one per host.
+func (a *App) rateLimitFor(source string) (RateLimit, string, bool) {
+	if limit := a.sourceConfig(source).RateLimit; limit != nil {
+		return *limit, source, limit.Requests > 0 && limit.PeriodSeconds > 0
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return RateLimit{}, "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	for suffix, limit := range defaultRateLimits {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return limit, suffix, true
+		}
+	}
+	return RateLimit{}, "", false
+}
+
+// bucketFor returns the bucket for a source, creating it on first use.
+// Callers must hold t.mu.
+func (t *sourceTracker) bucketFor(key string, limit RateLimit, now time.Time) *tokenBucket {
+	if t.buckets == nil {
+		t.buckets = make(map[string]*tokenBucket)
+	}
+	b, ok := t.buckets[key]
+	if !ok || b.limit != limit {
+		b = &tokenBucket{limit: limit, tokens: float64(limit.Requests), lastRefill: now}
+		if ok {
+			b.cooldownUntil = t.buckets[key].cooldownUntil
+		}
+		t.buckets[key] = b
+	}
+	return b
+}
+
+// stateFor returns the status record for a source. Callers must hold t.mu.
+func (t *sourceTracker) stateFor(source string) *sourceState {
+	if t.states == nil {
+		t.states = make(map[string]*sourceState)
+	}
+	s, ok := t.states[source]
+	if !ok {
+		s = &sourceState{quotaRemaining: -1}
+		t.states[source] = s
+	}
+	return s
+}
+
+// allowRequest takes a token for the source without blocking. It returns
+// false when the source is out of tokens or cooling down after a 429.
+func (a *App) allowRequest(source string) bool {
+	limit, key, limited := a.rateLimitFor(source)
+	if !limited {
+		key = source
+	}
+
+	now := time.Now()
+	a.sources.mu.Lock()
+	defer a.sources.mu.Unlock()
+
+	if b, ok := a.sources.buckets[key]; ok && now.Before(b.cooldownUntil) {
+		return false
+	}
+	if !limited {
+		return true
+	}
+
+	b := a.sources.bucketFor(key, limit, now)
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// recordResponse captures provider quota headers and applies Retry-After
+// cooldowns on 429 responses
+func (a *App) recordResponse(source string, resp *http.Response) {
+	_, key, limited := a.rateLimitFor(source)
+	if !limited {
+		key = source
+	}
+
+	a.sources.mu.Lock()
+	defer a.sources.mu.Unlock()
+
+	state := a.sources.stateFor(source)
+	if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining != "" {
+		if f, err := strconv.ParseFloat(remaining, 64); err == nil {
+			state.quotaRemaining = int(f)
+		}
+	}
+	if reset := resp.Header.Get("X-RateLimit-Reset"); reset != "" {
+		state.quotaReset = reset
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		cooldown := parseRetryAfter(resp.Header.Get("Retry-After"))
+		if a.sources.buckets == nil {
+			a.sources.buckets = make(map[string]*tokenBucket)
+		}
+		b, ok := a.sources.buckets[key]
+		if !ok {
+			b = &tokenBucket{lastRefill: time.Now()}
+			a.sources.buckets[key] = b
+		}
+		if until := time.Now().Add(cooldown); until.After(b.cooldownUntil) {
+			b.cooldownUntil = until
+		}
+	}
+}
+
+// recordSourceResult stores the outcome of a download attempt
+func (a *App) recordSourceResult(source string, err error) {
+	a.sources.mu.Lock()
+	defer a.sources.mu.Unlock()
+
+	state := a.sources.stateFor(source)
+	state.lastAttempt = time.Now()
+	if err != nil {
+		state.lastError = err.Error()
+		return
+	}
+	state.lastSuccess = state.lastAttempt
+	state.lastError = ""
+}
+
+// parseRetryAfter reads a Retry-After value given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return defaultRetryAfter
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return defaultRetryAfter
+}
+
+// GetSourceStatus returns the health and remaining quota of each source
+func (a *App) GetSourceStatus() []SourceStatus {
+	now := time.Now()
+	var statuses []SourceStatus
+
+	for _, source := range a.GetSettings().DownloadSources {
+		limit, key, limited := a.rateLimitFor(source)
+		if !limited {
+			key = source
+		}
+
+		a.sources.mu.Lock()
+		state := a.sources.stateFor(source)
+		status := SourceStatus{
+			URL:             source,
+			LastAttempt:     state.lastAttempt,
+			LastSuccess:     state.lastSuccess,
+			LastError:       state.lastError,
+			TokensRemaining: -1,
+			QuotaRemaining:  state.quotaRemaining,
+			QuotaReset:      state.quotaReset,
+		}
+		if b, ok := a.sources.buckets[key]; ok {
+			status.CooldownUntil = b.cooldownUntil
+		}
+		if limited {
+			b := a.sources.bucketFor(key, limit, now)
+			b.refill(now)
+			status.TokensRemaining = int(b.tokens)
+		}
+		a.sources.mu.Unlock()
+
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// Values of AppSettings.SourceRotation
+const (
+	sourceRotationSequential = "sequential"
+	sourceRotationRoundRobin = "round-robin"
+	sourceRotationLRU        = "least-recently-used"
+)
+
+// sourceStateFile persists what source rotation needs across restarts
+const sourceStateFile = "source_state.json"
+
+// persistedSourceState is the content of sourceStateFile
+type persistedSourceState struct {
+	// LastUsed is the source of the last downloaded wallpaper; round-robin
+	// starts after it
+	LastUsed string `json:"last_used,omitempty"`
+	// LastSuccess is each source's last successful download, as reported
+	// by GetSourceStatus
+	LastSuccess map[string]time.Time `json:"last_success,omitempty"`
+}
+
+// validateSourceRotation rejects unknown rotation modes; empty means sequential
+func validateSourceRotation(mode string) error {
+	switch mode {
+	case "", sourceRotationSequential, sourceRotationRoundRobin, sourceRotationLRU:
+		return nil
+	}
+	return fmt.Errorf("invalid source rotation %q: use %q, %q or %q", mode,
+		sourceRotationSequential, sourceRotationRoundRobin, sourceRotationLRU)
+}
+
+// rotatedSources returns the download sources in the order downloadAndSet
+// should try them under the SourceRotation setting
+func (a *App) rotatedSources() []string {
+	sources := slices.Clone(a.GetSettings().DownloadSources)
+	if len(sources) < 2 {
+		return sources
+	}
+
+	a.sources.mu.Lock()
+	defer a.sources.mu.Unlock()
+
+	switch a.GetSettings().SourceRotation {
+	case sourceRotationRoundRobin:
+		// A source that has since been removed restarts at the top
+		if i := slices.Index(sources, a.sources.lastUsed); i >= 0 {
+			start := (i + 1) % len(sources)
+			sources = append(sources[start:], sources[:start]...)
+		}
+	case sourceRotationLRU:
+		// Sources that never succeeded come first; ties keep their order
+		slices.SortStableFunc(sources, func(x, y string) int {
+			return a.sources.stateFor(x).lastSuccess.Compare(a.sources.stateFor(y).lastSuccess)
+		})
+	}
+	return sources
+}
+
+// recordSourceUsed remembers the source of the wallpaper just downloaded
+// for round-robin rotation and persists the rotation state
+func (a *App) recordSourceUsed(source string) {
+	a.sources.mu.Lock()
+	a.sources.lastUsed = source
+	a.sources.mu.Unlock()
+	a.saveSourceState()
+}
+
+// loadSourceState restores the last used source and per-source success
+// times
+func (a *App) loadSourceState() {
+	var saved persistedSourceState
+	data, err := os.ReadFile(a.getConfigPath(sourceStateFile))
+	if err == nil {
+		json.Unmarshal(data, &saved)
+	}
+
+	a.sources.mu.Lock()
+	defer a.sources.mu.Unlock()
+	a.sources.lastUsed = saved.LastUsed
+	for source, t := range saved.LastSuccess {
+		if state := a.sources.stateFor(source); t.After(state.lastSuccess) {
+			state.lastSuccess = t
+		}
+	}
+}
+
+func (a *App) saveSourceState() {
+	a.sources.mu.Lock()
+	saved := persistedSourceState{
+		LastUsed:    a.sources.lastUsed,
+		LastSuccess: make(map[string]time.Time),
+	}
+	for source, state := range a.sources.states {
+		if !state.lastSuccess.IsZero() {
+			saved.LastSuccess[source] = state.lastSuccess
+		}
+	}
+	a.sources.mu.Unlock()
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return
+	}
+	os.WriteFile(a.getConfigPath(sourceStateFile), data, 0644)
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// sourceTypeRSS is SourceConfig.Type for an RSS 2.0 or Atom feed whose
+// items link to images
+const sourceTypeRSS = "rss"
+
+const (
+	// maxFeedBytes bounds a feed or an item page read for og:image
+	maxFeedBytes = 5 << 20
+	// maxPageScrapes bounds the item pages fetched for one download
+	maxPageScrapes = 3
+	// mediaNamespace is Media RSS, whose media:title is not the item's
+	mediaNamespace = "http://search.yahoo.com/mrss/"
+)
+
+// feedEntry is an RSS <item> or Atom <entry>; each format leaves the
+// other's fields empty
+type feedEntry struct {
+	Titles []struct {
+		XMLName xml.Name
+		Text    string `xml:",chardata"`
+	} `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+		Text string `xml:",chardata"`
+	} `xml:"link"`
+	Enclosures []feedMedia `xml:"enclosure"`
+	Media      []feedMedia `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaGroup []feedMedia `xml:"http://search.yahoo.com/mrss/ group>content"`
+	Ratings    []string    `xml:"http://search.yahoo.com/mrss/ rating"`
+}
+
+// feedMedia is an <enclosure> or <media:content>
+type feedMedia struct {
+	URL     string   `xml:"url,attr"`
+	Type    string   `xml:"type,attr"`
+	Medium  string   `xml:"medium,attr"`
+	Ratings []string `xml:"http://search.yahoo.com/mrss/ rating"`
+}
+
+// isImage reports whether a media element is an image, judging by its
+// type, its medium or, when neither is given, its extension
+func (m feedMedia) isImage() bool {
+	switch {
+	case m.Type != "":
+		return strings.HasPrefix(m.Type, "image/")
+	case m.Medium != "":
+		return m.Medium == "image"
+	}
+	return imageURL(m.URL)
+}
+
+// feedItem is a feed entry reduced to what a download needs. Images is
+// empty for items that only link to an HTML page. Adult is set for items
+// with a Media RSS rating of adult.
+type feedItem struct {
+	Title  string
+	Link   string
+	Images []string
+	Adult  bool
+}
+
+// feedAutoClose are the HTML void elements that unescaped descriptions
+// leave unclosed. <link> is left out: in RSS it has content.
+var feedAutoClose = slices.DeleteFunc(slices.Clone(xml.HTMLAutoClose), func(name string) bool {
+	return name == "link"
+})
+
+// ogImagePattern finds <meta> tags in an item page
+var ogImagePattern = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+
+// metaAttrPattern reads the attributes of a <meta> tag
+var metaAttrPattern = regexp.MustCompile(`(?is)(property|name|content)\s*=\s*("[^"]*"|'[^']*')`)
+
+// pickFeedImage fetches a feed and picks a random image that isn't in the
+// library yet. Items without an image are tried through the og:image of
+// their page, a few per download.
+func (a *App) pickFeedImage(ctx context.Context, source string) (string, feedItem, error) {
+	items, err := a.fetchFeed(ctx, source)
+	if err != nil {
+		return "", feedItem{}, err
+	}
+
+	filtered := a.contentFilter(source) != contentFilterOff
+	var images []string
+	var owners []feedItem
+	var pages []feedItem
+	for _, item := range items {
+		if item.Adult && filtered {
+			continue
+		}
+		for _, image := range item.Images {
+			if _, ok := a.findBySourceURL(image); !ok {
+				images = append(images, image)
+				owners = append(owners, item)
+			}
+		}
+		if len(item.Images) == 0 && item.Link != "" {
+			if _, ok := a.findByPageURL(item.Link); !ok {
+				pages = append(pages, item)
+			}
+		}
+	}
+	if len(images) > 0 {
+		i := rand.Intn(len(images))
+		return images[i], owners[i], nil
+	}
+
+	rand.Shuffle(len(pages), func(i, j int) { pages[i], pages[j] = pages[j], pages[i] })
+	for i, item := range pages {
+		if i == maxPageScrapes {
+			break
+		}
+		image, err := a.scrapeOGImage(ctx, source, item.Link)
+		if err != nil {
+			fmt.Printf("Skipping feed item %s: %v\n", item.Link, err)
+			continue
+		}
+		if _, ok := a.findBySourceURL(image); !ok {
+			return image, item, nil
+		}
+	}
+
+	if len(items) == 0 {
+		return "", feedItem{}, fmt.Errorf("no items in the feed")
+	}
+	return "", feedItem{}, fmt.Errorf("%w: every image in the feed is already in the library", errNotModified)
+}
+
+// fetchFeed downloads and parses a feed. A feed that breaks off midway
+// still yields the items before the break.
+func (a *App) fetchFeed(ctx context.Context, source string) ([]feedItem, error) {
+	base, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	body, err := a.fetchPage(ctx, source, a.feedURL(source))
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch feed: %v", err)
+	}
+	if a.redditListing(source) {
+		items, err := parseRedditListing(body, base)
+		if err != nil {
+			// Without the listing nothing says which posts are over 18
+			return nil, fmt.Errorf("invalid Reddit listing: %v", err)
+		}
+		return items, nil
+	}
+	items, err := parseFeed(body, base)
+	if err != nil {
+		if len(items) == 0 {
+			return nil, fmt.Errorf("invalid feed: %v", err)
+		}
+		fmt.Printf("Feed %s is damaged, using the first %d items: %v\n", source, len(items), err)
+	}
+	return items, nil
+}
+
+// parseFeed reads RSS 2.0 items and Atom entries, resolving relative URLs
+// against base. Parsing is lenient, as many feeds are not well-formed:
+// unknown entities and unclosed HTML tags are accepted, and an entry that
+// can't be read ends the feed with the entries read so far.
+func parseFeed(data []byte, base *url.URL) ([]feedItem, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	d.AutoClose = feedAutoClose
+	d.Entity = xml.HTMLEntity
+	// Only URLs are needed, which are ASCII, so other charsets are read
+	// as they are rather than rejected
+	d.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var items []feedItem
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return items, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || (start.Name.Local != "item" && start.Name.Local != "entry") {
+			continue
+		}
+		var entry feedEntry
+		if err := d.DecodeElement(&entry, &start); err != nil {
+			return items, err
+		}
+		items = append(items, entry.item(base))
+	}
+}
+
+// item resolves an entry's links and picks out its images
+func (e feedEntry) item(base *url.URL) feedItem {
+	var item feedItem
+	for _, title := range e.Titles {
+		if title.XMLName.Space != mediaNamespace {
+			item.Title = strings.TrimSpace(html.UnescapeString(title.Text))
+			break
+		}
+	}
+	add := func(raw string) {
+		if resolved := resolveURL(base, raw); resolved != "" && !slices.Contains(item.Images, resolved) {
+			item.Images = append(item.Images, resolved)
+		}
+	}
+
+	ratings := e.Ratings
+	for _, media := range append(append(e.Media, e.MediaGroup...), e.Enclosures...) {
+		if media.isImage() {
+			add(media.URL)
+			ratings = append(ratings, media.Ratings...)
+		}
+	}
+	for _, rating := range ratings {
+		if strings.EqualFold(strings.TrimSpace(rating), "adult") {
+			item.Adult = true
+		}
+	}
+	for _, link := range e.Links {
+		switch {
+		case link.Href == "" && strings.TrimSpace(link.Text) != "":
+			// RSS: <link>page</link>
+			if item.Link == "" {
+				item.Link = resolveURL(base, link.Text)
+			}
+		case link.Rel == "enclosure" && (strings.HasPrefix(link.Type, "image/") || imageURL(link.Href)):
+			add(link.Href)
+		case link.Rel == "" || link.Rel == "alternate":
+			if imageURL(link.Href) {
+				add(link.Href)
+			} else if item.Link == "" {
+				item.Link = resolveURL(base, link.Href)
+			}
+		}
+	}
+	return item
+}
+
+// resolveURL resolves raw against base, returning "" for anything that
+// isn't an http(s) URL
+func resolveURL(base *url.URL, raw string) string {
+	ref, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	resolved := base.ResolveReference(ref)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return ""
+	}
+	return resolved.String()
+}
+
+// imageURL reports whether a URL's path ends in an image extension
+func imageURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && importExtensions[strings.ToLower(path.Ext(u.Path))]
+}
+
+// scrapeOGImage returns the og:image of an item page
+func (a *App) scrapeOGImage(ctx context.Context, source, page string) (string, error) {
+	body, err := a.fetchPage(ctx, source, page)
+	if err != nil {
+		return "", err
+	}
+	base, err := url.Parse(page)
+	if err != nil {
+		return "", err
+	}
+	for _, tag := range ogImagePattern.FindAll(body, -1) {
+		var property, content string
+		for _, attr := range metaAttrPattern.FindAllSubmatch(tag, -1) {
+			value := html.UnescapeString(string(attr[2][1 : len(attr[2])-1]))
+			if strings.EqualFold(string(attr[1]), "content") {
+				content = value
+			} else {
+				property = strings.ToLower(value)
+			}
+		}
+		if property == "og:image" || property == "og:image:url" || property == "og:image:secure_url" {
+			if image := resolveURL(base, content); image != "" {
+				return image, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no image and no og:image")
+}
+
+// feedURL returns the URL a feed is fetched from: the source, with the
+// provider's content filter added, or for a filtered Reddit feed its JSON
+// listing
+func (a *App) feedURL(source string) string {
+	feed := filterSourceURL(source, a.contentFilter(source))
+	if a.redditListing(source) {
+		return redditListingURL(feed)
+	}
+	return feed
+}
+
+// redditListing reports whether a Reddit feed is read from its JSON
+// listing. Reddit's RSS doesn't say which posts are marked over 18, so the
+// listing is used whenever the content filter is on.
+func (a *App) redditListing(source string) bool {
+	u, err := url.Parse(source)
+	return err == nil && isRedditHost(u.Hostname()) && a.contentFilter(source) != contentFilterOff
+}
+
+// redditListingURL returns the JSON listing of a Reddit feed or page URL:
+// a path ending in .rss ends in .json instead, and any other path gets
+// /.json added
+func redditListingURL(feed string) string {
+	u, err := url.Parse(feed)
+	if err != nil {
+		return feed
+	}
+	switch {
+	case strings.HasSuffix(u.Path, ".rss"):
+		u.Path = strings.TrimSuffix(u.Path, ".rss") + ".json"
+	case !strings.HasSuffix(u.Path, ".json"):
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/.json"
+	}
+	return u.String()
+}
+
+// redditPosts is the part of a Reddit JSON listing read for feed items
+type redditPosts struct {
+	Data struct {
+		Children []struct {
+			Data struct {
+				Title     string `json:"title"`
+				Permalink string `json:"permalink"`
+				URL       string `json:"url"`
+				Over18    bool   `json:"over_18"`
+			} `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+// parseRedditListing reads the posts of a Reddit JSON listing as feed
+// items. A post linking to an image has it as its image; others link to
+// the post's page for its og:image. Adult is set for posts marked over 18.
+func parseRedditListing(data []byte, base *url.URL) ([]feedItem, error) {
+	var listing redditPosts
+	if err := json.Unmarshal(data, &listing); err != nil {
+		return nil, err
+	}
+	var items []feedItem
+	for _, child := range listing.Data.Children {
+		post := child.Data
+		item := feedItem{
+			Title: strings.TrimSpace(html.UnescapeString(post.Title)),
+			Link:  resolveURL(base, post.Permalink),
+			Adult: post.Over18,
+		}
+		if image := resolveURL(base, html.UnescapeString(post.URL)); image != "" && imageURL(image) {
+			item.Images = []string{image}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// fetchPage GETs a feed or an item page with the source's headers
+func (a *App) fetchPage(ctx context.Context, source, page string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", page, nil)
+	if err != nil {
+		return nil, err
+	}
+	a.setSourceHeaders(req, source)
+
+	resp, err := a.sourceClient(source).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if page == a.feedURL(source) {
+		a.recordResponse(source, resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpStatusError{resp.StatusCode}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxFeedBytes))
+}
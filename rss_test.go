@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRedditListingURL(t *testing.T) {
+	tests := []struct{ feed, want string }{
+		{"https://www.reddit.com/r/wallpapers/.rss", "https://www.reddit.com/r/wallpapers/.json"},
+		{"https://www.reddit.com/r/wallpapers/top.rss?t=week", "https://www.reddit.com/r/wallpapers/top.json?t=week"},
+		{"https://old.reddit.com/r/wallpapers/", "https://old.reddit.com/r/wallpapers/.json"},
+		{"https://www.reddit.com/r/wallpapers/new.json", "https://www.reddit.com/r/wallpapers/new.json"},
+	}
+	for _, tt := range tests {
+		if got := redditListingURL(tt.feed); got != tt.want {
+			t.Errorf("redditListingURL(%q) = %q, want %q", tt.feed, got, tt.want)
+		}
+	}
+}
+
+// redditListingJSON has one post marked over 18 and one that isn't
+const redditListingJSON = `{"kind": "Listing", "data": {"children": [
+	{"kind": "t3", "data": {"title": "Nsfw", "permalink": "/r/wallpapers/comments/a1/nsfw/", "url": "https://i.redd.it/nsfw.jpg", "over_18": true}},
+	{"kind": "t3", "data": {"title": "Lake &amp; hills", "permalink": "/r/wallpapers/comments/b2/lake/", "url": "https://i.redd.it/lake.jpg", "over_18": false}}
+]}}`
+
+// redditFeedAtom is the RSS side of the same subreddit, which doesn't
+// say which posts are over 18
+const redditFeedAtom = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">
+<entry><title>Nsfw</title><link href="https://www.reddit.com/r/wallpapers/comments/a1/nsfw/"/>
+<media:content url="https://i.redd.it/nsfw.jpg" type="image/jpeg"/></entry>
+</feed>`
+
+// TestRedditFeedContentFilter reads a Reddit feed through a transport that
+// sends www.reddit.com to a test server, and checks that posts marked over
+// 18 are skipped unless the filter is off
+func TestRedditFeedContentFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		listing  bool // whether the server has the JSON listing
+		want     string
+		wantPath string
+		wantErr  bool
+	}{
+		{name: "strict", filter: contentFilterStrict, listing: true, want: "https://i.redd.it/lake.jpg", wantPath: "/r/wallpapers/.json"},
+		{name: "moderate", filter: contentFilterModerate, listing: true, want: "https://i.redd.it/lake.jpg", wantPath: "/r/wallpapers/.json"},
+		{name: "off", filter: contentFilterOff, listing: true, want: "https://i.redd.it/nsfw.jpg", wantPath: "/r/wallpapers/.rss"},
+		{name: "strict without a listing", filter: contentFilterStrict, wantPath: "/r/wallpapers/.json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var paths []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				paths = append(paths, r.URL.Path)
+				mu.Unlock()
+				switch {
+				case strings.HasSuffix(r.URL.Path, ".rss"):
+					w.Write([]byte(redditFeedAtom))
+				case tt.listing:
+					w.Write([]byte(redditListingJSON))
+				default:
+					// Reddit answers blocked clients with an HTML page
+					w.Write([]byte("<html><body>Blocked</body></html>"))
+				}
+			}))
+			defer server.Close()
+
+			a := newTestApp(t)
+			a.changeSettings(func(s *AppSettings) { s.ContentFilter = tt.filter })
+			a.client = &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+				},
+			}}
+
+			image, item, err := a.pickFeedImage(context.Background(), "http://www.reddit.com/r/wallpapers/.rss")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("picked %s without knowing which posts are over 18", image)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if image != tt.want {
+				t.Errorf("picked %s (%q), want %s", image, item.Title, tt.want)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(paths) != 1 || paths[0] != tt.wantPath {
+				t.Errorf("fetched %v, want only %s", paths, tt.wantPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// schedulerPollInterval is the longest the auto-changer sleeps between
+// decisions, so settings changes are picked up promptly
+const schedulerPollInterval = 1 * time.Minute
+
+// clock abstracts time for the auto-changer so it can be driven in tests
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// defaultDownloadBatch is DownloadSchedule.BatchSize when unset
+const defaultDownloadBatch = 10
+
+// DownloadSchedule fills the library on its own cadence, separately from
+// wallpaper changes. While it is on, automatic changes pick from the
+// library instead of downloading.
+type DownloadSchedule struct {
+	// IntervalHours between batches (0 = off: every change downloads)
+	IntervalHours int `json:"interval_hours"`
+	// StartHour and EndHour limit batches to a daily window, e.g. 1 and 6
+	// for overnight; equal values allow any hour
+	StartHour int `json:"start_hour"`
+	EndHour   int `json:"end_hour"`
+	// BatchSize is how many wallpapers a batch downloads (0 = 10)
+	BatchSize int `json:"batch_size"`
+	// MinUnshown starts an extra batch, outside the schedule, when fewer
+	// library wallpapers than this have never been shown
+	MinUnshown int `json:"min_unshown"`
+}
+
+// ChangeSchedule limits automatic changes to a daily window, e.g. 8 and
+// 18 for working hours; equal values allow any hour. The interval stays
+// ChangeIntervalHours.
+type ChangeSchedule struct {
+	StartHour int `json:"start_hour"`
+	EndHour   int `json:"end_hour"`
+}
+
+// inWindow reports whether t's hour falls in [start, end), which may wrap
+// around midnight. An empty window (start == end) is the whole day.
+func inWindow(t time.Time, start, end int) bool {
+	hour := t.Hour()
+	switch {
+	case start == end:
+		return true
+	case start < end:
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
+
+// windowStart returns the next time at or after now that the hour start
+// begins
+func windowStart(now time.Time, start int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), start, 0, 0, 0, now.Location())
+	if next.Before(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// SchedulerState is the auto-changer state persisted across restarts
+type SchedulerState struct {
+	LastChange time.Time `json:"last_change"`
+	Paused     bool      `json:"paused,omitempty"`
+	// PausedUntil suspends changes until this time, for timed pauses
+	PausedUntil time.Time `json:"paused_until,omitempty"`
+	// PinnedUntil keeps the current wallpaper until this time
+	PinnedUntil time.Time `json:"pinned_until,omitempty"`
+	// LastDownload is when the last DownloadSchedule batch started
+	LastDownload time.Time `json:"last_download,omitempty"`
+}
+
+// paused reports whether changes are suspended at now
+func (s SchedulerState) paused(now time.Time) bool {
+	return s.Paused || now.Before(s.PausedUntil)
+}
+
+// pinned reports whether the current wallpaper is pinned at now
+func (s SchedulerState) pinned(now time.Time) bool {
+	return now.Before(s.PinnedUntil)
+}
+
+// Action is what the auto-changer should do at a given moment
+type Action int
+
+const (
+	ActionNone Action = iota
+	ActionChange
+)
+
+// nextAction decides whether a change is due at now and, if not, when the
+// next one will be. It has no side effects so every scheduling rule can be
+// checked against fixed times.
+func nextAction(now time.Time, state SchedulerState, settings AppSettings) (Action, time.Time) {
+	if !settings.AutoChangeEnabled || settings.ChangeIntervalHours <= 0 || state.paused(now) {
+		return ActionNone, now.Add(schedulerPollInterval)
+	}
+
+	interval := time.Duration(settings.ChangeIntervalHours) * time.Hour
+	due := state.LastChange.Add(interval)
+	if state.pinned(now) && due.Before(state.PinnedUntil) {
+		due = state.PinnedUntil
+	}
+	if now.Before(due) {
+		return ActionNone, due
+	}
+	if window := settings.ChangeSchedule; !inWindow(now, window.StartHour, window.EndHour) {
+		return ActionNone, windowStart(now, window.StartHour)
+	}
+	return ActionChange, now.Add(interval)
+}
+
+// downloadDue decides whether a DownloadSchedule batch is due at now and,
+// if not, when the next one will be. Like nextAction it has no side
+// effects.
+func downloadDue(now time.Time, state SchedulerState, settings AppSettings) (bool, time.Time) {
+	schedule := settings.DownloadSchedule
+	if schedule.IntervalHours <= 0 || settings.DownloadsDisabled {
+		return false, now.Add(schedulerPollInterval)
+	}
+
+	interval := time.Duration(schedule.IntervalHours) * time.Hour
+	due := state.LastDownload.Add(interval)
+	if now.Before(due) {
+		return false, due
+	}
+	if !inWindow(now, schedule.StartHour, schedule.EndHour) {
+		return false, windowStart(now, schedule.StartHour)
+	}
+	return true, now.Add(interval)
+}
+
+// validateSchedules checks the download and change schedules
+func validateSchedules(s AppSettings) error {
+	d, c := s.DownloadSchedule, s.ChangeSchedule
+	for _, hour := range []int{d.StartHour, d.EndHour, c.StartHour, c.EndHour} {
+		if hour < 0 || hour > 23 {
+			return fmt.Errorf("schedule hours must be between 0 and 23")
+		}
+	}
+	if d.IntervalHours < 0 {
+		return fmt.Errorf("download interval cannot be negative")
+	}
+	if d.BatchSize < 0 || d.BatchSize > maxPrefetch {
+		return fmt.Errorf("download batch size must be between 0 and %d", maxPrefetch)
+	}
+	if d.MinUnshown < 0 {
+		return fmt.Errorf("minimum unshown wallpapers cannot be negative")
+	}
+	return nil
+}
+
+// startDownloadBatch records a batch as started at now and prefetches it
+// in the background. A batch already running counts as this one.
+func (a *App) startDownloadBatch(now time.Time, reason string) {
+	a.mu.Lock()
+	a.data.Scheduler.LastDownload = now
+	a.mu.Unlock()
+	a.saveWallpapers()
+
+	count := a.GetSettings().DownloadSchedule.BatchSize
+	if count <= 0 {
+		count = defaultDownloadBatch
+	}
+	fmt.Printf("Downloading %d wallpapers (%s)\n", count, reason)
+	if _, err := a.PrefetchWallpapers(count); err != nil && !errors.Is(err, errPrefetchRunning) {
+		fmt.Printf("Scheduled download failed: %v\n", err)
+	}
+}
+
+// schedulerState returns a snapshot of the persisted scheduler state
+func (a *App) schedulerState() SchedulerState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.data.Scheduler
+}
+
+// setLastChange records when the wallpaper last changed and persists it
+func (a *App) setLastChange(t time.Time) {
+	a.mu.Lock()
+	a.data.Scheduler.LastChange = t
+	a.mu.Unlock()
+	a.saveWallpapers()
+	a.emitAutoChangeStatus()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextAction(t *testing.T) {
+	// Monday noon
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	at := func(hour int) time.Time { return time.Date(2026, 3, 2, hour, 0, 0, 0, time.UTC) }
+	every3h := AppSettings{AutoChangeEnabled: true, ChangeIntervalHours: 3}
+	withWindow := func(start, end int) AppSettings {
+		s := every3h
+		s.ChangeSchedule = ChangeSchedule{StartHour: start, EndHour: end}
+		return s
+	}
+	poll := now.Add(schedulerPollInterval)
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		state      SchedulerState
+		settings   AppSettings
+		wantAction Action
+		wantNext   time.Time
+	}{
+		{"disabled", now, SchedulerState{}, AppSettings{ChangeIntervalHours: 3}, ActionNone, poll},
+		{"no interval", now, SchedulerState{}, AppSettings{AutoChangeEnabled: true}, ActionNone, poll},
+		{"paused", now, SchedulerState{Paused: true}, every3h, ActionNone, poll},
+		{"paused for a while", now, SchedulerState{PausedUntil: at(13)}, every3h, ActionNone, poll},
+		{"pause over", now, SchedulerState{PausedUntil: at(11)}, every3h, ActionChange, at(15)},
+		{"never changed", now, SchedulerState{}, every3h, ActionChange, at(15)},
+		{"not yet due", now, SchedulerState{LastChange: at(11)}, every3h, ActionNone, at(14)},
+		{"just due", now, SchedulerState{LastChange: at(9)}, every3h, ActionChange, at(15)},
+		{"days overdue", now, SchedulerState{LastChange: now.AddDate(0, 0, -3)}, every3h, ActionChange, at(15)},
+		{"pinned past due", now, SchedulerState{LastChange: at(8), PinnedUntil: at(16)}, every3h, ActionNone, at(16)},
+		{"pinned before due", now, SchedulerState{LastChange: at(11), PinnedUntil: at(13)}, every3h, ActionNone, at(14)},
+		{"pin over", now, SchedulerState{LastChange: at(8), PinnedUntil: at(11)}, every3h, ActionChange, at(15)},
+		{"in window", now, SchedulerState{LastChange: at(9)}, withWindow(8, 18), ActionChange, at(15)},
+		{"after window", at(20), SchedulerState{LastChange: at(9)}, withWindow(8, 18), ActionNone, at(8).AddDate(0, 0, 1)},
+		{"before window", at(6), SchedulerState{LastChange: at(0)}, withWindow(8, 18), ActionNone, at(8)},
+		{"window end is exclusive", at(18), SchedulerState{LastChange: at(9)}, withWindow(8, 18), ActionNone, at(8).AddDate(0, 0, 1)},
+		{"in overnight window", at(23), SchedulerState{LastChange: at(9)}, withWindow(22, 6), ActionChange, at(23).Add(3 * time.Hour)},
+		{"outside overnight window", now, SchedulerState{LastChange: at(0)}, withWindow(22, 6), ActionNone, at(22)},
+		{"empty window", now, SchedulerState{LastChange: at(9)}, withWindow(7, 7), ActionChange, at(15)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, next := nextAction(tt.now, tt.state, tt.settings)
+			if action != tt.wantAction || !next.Equal(tt.wantNext) {
+				t.Errorf("nextAction = %v, %s; want %v, %s", action, next, tt.wantAction, tt.wantNext)
+			}
+		})
+	}
+}
+
+// TestNextActionOverDays drives nextAction with a fake clock the way the
+// auto-changer does, sleeping until each next time, and checks when the
+// changes land
+func TestNextActionOverDays(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC))
+	settings := AppSettings{
+		AutoChangeEnabled:   true,
+		ChangeIntervalHours: 3,
+		ChangeSchedule:      ChangeSchedule{StartHour: 8, EndHour: 18},
+	}
+	state := SchedulerState{LastChange: clock.Now()}
+
+	var changes []string
+	for len(changes) < 5 {
+		now := clock.Now()
+		action, next := nextAction(now, state, settings)
+		if action == ActionChange {
+			changes = append(changes, now.Format("Mon 15:04"))
+			state.LastChange = now
+			continue
+		}
+		if !next.After(now) {
+			t.Fatalf("nextAction at %s waits until %s", now, next)
+		}
+		<-clock.After(next.Sub(now))
+	}
+
+	want := []string{"Mon 15:00", "Tue 08:00", "Tue 11:00", "Tue 14:00", "Tue 17:00"}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("changes at %v, want %v", changes, want)
+		}
+	}
+}
+
+// TestNextActionAfterRestart records a change, restarts the app from the
+// same config two hours later, and checks that the first change after the
+// restart is due an interval after the persisted one, not after the
+// restart
+func TestNextActionAfterRestart(t *testing.T) {
+	settings := AppSettings{AutoChangeEnabled: true, ChangeIntervalHours: 3}
+	lastChange := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	before := newTestApp(t)
+	before.setLastChange(lastChange)
+
+	after := newTestApp(t)
+	after.configDir = before.configDir
+	after.clock = newFakeClock(lastChange.Add(2 * time.Hour))
+	after.loadWallpapers()
+
+	state := after.schedulerState()
+	if !state.LastChange.Equal(lastChange) {
+		t.Fatalf("restored last change %s, want %s", state.LastChange, lastChange)
+	}
+	now := after.clock.Now()
+	action, next := nextAction(now, state, settings)
+	if want := lastChange.Add(3 * time.Hour); action != ActionNone || !next.Equal(want) {
+		t.Errorf("nextAction = %v, %s; want %v, %s rather than %s", action, next, ActionNone, want, now.Add(3*time.Hour))
+	}
+
+	// Once that time comes, the change happens
+	if action, _ := nextAction(next, state, settings); action != ActionChange {
+		t.Errorf("nextAction at %s = %v, want %v", next, action, ActionChange)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Fallback resolution for source templates when the screen can't be detected
+const (
+	defaultTemplateWidth  = 3840
+	defaultTemplateHeight = 2160
+)
+
+// primaryScreenSize returns the primary display's resolution in physical
+// pixels, or false when it can't be determined
+func (a *App) primaryScreenSize() (int, int, bool) {
+	if a.ctx == nil {
+		return 0, 0, false
+	}
+
+	screens, err := wailsruntime.ScreenGetAll(a.ctx)
+	if err != nil {
+		fmt.Printf("Failed to detect screen size: %v\n", err)
+		return 0, 0, false
+	}
+
+	for _, screen := range screens {
+		if !screen.IsPrimary {
+			continue
+		}
+		if screen.PhysicalSize.Width > 0 && screen.PhysicalSize.Height > 0 {
+			return screen.PhysicalSize.Width, screen.PhysicalSize.Height, true
+		}
+		if screen.Width > 0 && screen.Height > 0 {
+			return screen.Width, screen.Height, true
+		}
+	}
+	return 0, 0, false
+}
+
+// expandSourceURL substitutes {width} and {height} in a source URL with the
+// detected screen resolution when UseScreenResolution is on, or the 4K
+// default otherwise
+func (a *App) expandSourceURL(source string) string {
+	if !strings.Contains(source, "{width}") && !strings.Contains(source, "{height}") {
+		return source
+	}
+
+	width, height := defaultTemplateWidth, defaultTemplateHeight
+	if a.GetSettings().UseScreenResolution {
+		if w, h, ok := a.primaryScreenSize(); ok {
+			width, height = w, h
+		}
+	}
+
+	return strings.NewReplacer(
+		"{width}", strconv.Itoa(width),
+		"{height}", strconv.Itoa(height),
+	).Replace(source)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sourceTypeScript is SourceConfig.Type for a user command that prints an
+// image path or URL. The source itself is just a name, e.g. script:photos.
+const sourceTypeScript = "script"
+
+const (
+	// defaultScriptTimeout applies when TimeoutSeconds is unset
+	defaultScriptTimeout = 30 * time.Second
+	// scriptWaitDelay bounds the wait for a script's children to release
+	// its output after it exits or is killed
+	scriptWaitDelay = 2 * time.Second
+)
+
+// scriptEnvVars are passed on to source scripts; everything else in the
+// app's environment is withheld
+var scriptEnvVars = []string{
+	"PATH", "HOME", "USER", "LANG", "TMPDIR",
+	// Windows needs these to start most programs
+	"SYSTEMROOT", "PATHEXT", "USERPROFILE", "APPDATA", "LOCALAPPDATA", "TEMP", "TMP",
+}
+
+// runSourceScript runs a script source's command and returns the first
+// line it printed: an absolute image path or an http(s) URL. A failed
+// exit, a timeout or empty output is an error, with the script's stderr
+// logged.
+func (a *App) runSourceScript(ctx context.Context, source string) (string, error) {
+	config := a.sourceConfig(source)
+	args, err := splitCommandLine(config.Command)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 {
+		return "", fmt.Errorf("no command for %s", source)
+	}
+	timeout := defaultScriptTimeout
+	if config.TimeoutSeconds > 0 {
+		timeout = time.Duration(config.TimeoutSeconds) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = a.scriptEnv()
+	cmd.WaitDelay = scriptWaitDelay
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if stderr.Len() > 0 {
+		fmt.Printf("%s stderr:\n%s\n", source, strings.TrimRight(stderr.String(), "\n"))
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("script timed out after %s", timeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("script failed: %v", err)
+	}
+
+	line, _, _ := bufio.NewReader(&stdout).ReadLine()
+	target := strings.TrimSpace(string(line))
+	if target == "" {
+		return "", fmt.Errorf("script printed nothing")
+	}
+	if validateImageURL(target) == nil {
+		return target, nil
+	}
+	if !filepath.IsAbs(target) {
+		return "", fmt.Errorf("script output is neither an http(s) URL nor an absolute path: %q", target)
+	}
+	return target, nil
+}
+
+// scriptEnv returns the minimal environment for a source script, plus
+// WALLSET_RESOLUTION as WIDTHxHEIGHT of the primary monitor
+func (a *App) scriptEnv() []string {
+	var env []string
+	for _, name := range scriptEnvVars {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	width, height := defaultTemplateWidth, defaultTemplateHeight
+	if w, h, ok := a.primaryScreenSize(); ok {
+		width, height = w, h
+	}
+	return append(env, fmt.Sprintf("WALLSET_RESOLUTION=%dx%d", width, height))
+}
+
+// copyScriptFile copies a file printed by a script source to dest, within
+// the same size limits as a download
+func copyScriptFile(src, dest string, maxSize int64) error {
+	if !importExtensions[strings.ToLower(filepath.Ext(src))] {
+		return fmt.Errorf("unsupported image type: %s", filepath.Ext(src))
+	}
+	stat, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !stat.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a file", src)
+	}
+	if maxSize > 0 && stat.Size() > maxSize {
+		return tooLargeError(maxSize)
+	}
+
+	part := dest + ".part"
+	if err := copyFile(src, part); err != nil {
+		os.Remove(part)
+		return err
+	}
+	return os.Rename(part, dest)
+}
+
+// splitCommandLine splits a command line into arguments without a shell.
+// Single and double quotes group words. A backslash escapes a quote or
+// whitespace and is kept as-is before anything else, so Windows and UNC
+// paths need no doubling.
+func splitCommandLine(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\\' && quote != '\'' && i+1 < len(runes) && strings.ContainsRune("\"' \t", runes[i+1]):
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// monthDayLayout is the format of SeasonalTheme dates
+const monthDayLayout = "01-02"
+
+// SeasonalTheme is a stretch of the year and the keywords that suit it.
+// Both ends are included, and a range may wrap past the new year, as
+// winter's 12-01 to 02-29 does.
+type SeasonalTheme struct {
+	Name string `json:"name"`
+	// StartMonthDay and EndMonthDay are written MM-DD
+	StartMonthDay string   `json:"start_month_day"`
+	EndMonthDay   string   `json:"end_month_day"`
+	Keywords      []string `json:"keywords"`
+}
+
+// defaultSeasonalThemes is the built-in calendar, used unless
+// SeasonalCalendar replaces it. Holidays fall inside the seasons; the
+// shorter range wins.
+var defaultSeasonalThemes = []SeasonalTheme{
+	{Name: "Winter", StartMonthDay: "12-01", EndMonthDay: "02-29", Keywords: []string{"winter", "snow"}},
+	{Name: "Autumn", StartMonthDay: "10-01", EndMonthDay: "11-30", Keywords: []string{"autumn", "foliage"}},
+	{Name: "Valentine's Day", StartMonthDay: "02-10", EndMonthDay: "02-14", Keywords: []string{"valentine", "hearts"}},
+	{Name: "Halloween", StartMonthDay: "10-24", EndMonthDay: "10-31", Keywords: []string{"halloween", "pumpkin"}},
+	{Name: "Christmas", StartMonthDay: "12-18", EndMonthDay: "12-26", Keywords: []string{"christmas", "lights"}},
+	{Name: "New Year", StartMonthDay: "12-30", EndMonthDay: "01-01", Keywords: []string{"fireworks", "celebration"}},
+}
+
+// dayOfYear returns the position of an MM-DD date within a leap year, so
+// 02-29 has a place of its own
+func dayOfYear(monthDay string) (int, error) {
+	t, err := time.Parse(monthDayLayout, monthDay)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q, expected MM-DD", monthDay)
+	}
+	return time.Date(2000, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).YearDay(), nil
+}
+
+// bounds returns the first and last day of the theme's range as
+// dayOfYear positions
+func (t SeasonalTheme) bounds() (int, int, error) {
+	start, err := dayOfYear(t.StartMonthDay)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := dayOfYear(t.EndMonthDay)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// activeOn reports whether date falls in the theme's range. The second
+// result is the length of the range in days, for picking between
+// overlapping themes.
+func (t SeasonalTheme) activeOn(date time.Time) (bool, int) {
+	start, end, err := t.bounds()
+	if err != nil {
+		return false, 0
+	}
+	day := time.Date(2000, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC).YearDay()
+	if start <= end {
+		return day >= start && day <= end, end - start + 1
+	}
+	// The range wraps past the new year
+	return day >= start || day <= end, 366 - start + end + 1
+}
+
+// validateSeasonalThemes checks a user calendar for bad dates and themes
+// without keywords
+func validateSeasonalThemes(themes []SeasonalTheme) error {
+	for _, theme := range themes {
+		if strings.TrimSpace(theme.Name) == "" {
+			return fmt.Errorf("seasonal themes need a name")
+		}
+		if _, _, err := theme.bounds(); err != nil {
+			return fmt.Errorf("seasonal theme %s: %v", theme.Name, err)
+		}
+		if len(normalizeTags(theme.Keywords)) == 0 {
+			return fmt.Errorf("seasonal theme %s has no keywords", theme.Name)
+		}
+	}
+	return nil
+}
+
+// activeSeasonalTheme returns the theme for today when SeasonalThemes is
+// on. When several match, the one with the shortest range wins, so a
+// holiday takes over from its season.
+func (a *App) activeSeasonalTheme() (SeasonalTheme, bool) {
+	if !a.GetSettings().SeasonalThemes {
+		return SeasonalTheme{}, false
+	}
+	themes := a.GetSettings().SeasonalCalendar
+	if len(themes) == 0 {
+		themes = defaultSeasonalThemes
+	}
+
+	today := a.clock.Now()
+	var active SeasonalTheme
+	shortest := 0
+	for _, theme := range themes {
+		if ok, length := theme.activeOn(today); ok && (shortest == 0 || length < shortest) {
+			active, shortest = theme, length
+		}
+	}
+	return active, shortest > 0
+}
+
+// seasonalKeywords returns the active theme's keywords, lowercased and
+// without repeats, or nil when no theme applies
+func (a *App) seasonalKeywords() []string {
+	theme, ok := a.activeSeasonalTheme()
+	if !ok {
+		return nil
+	}
+	return normalizeTags(theme.Keywords)
+}
+
+// seasonalSourceURL adds keywords to the search terms of a source URL, in
+// the first of keywordParams it has. Sources without search terms are
+// returned unchanged. Terms separated by commas get commas, others
+// spaces.
+func seasonalSourceURL(raw string, keywords []string) string {
+	if len(keywords) == 0 {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	query := u.Query()
+	for _, param := range keywordParams {
+		value := strings.TrimSpace(query.Get(param))
+		if value == "" {
+			continue
+		}
+		sep := " "
+		if strings.Contains(value, ",") {
+			sep = ","
+		}
+		terms := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+			return r == ',' || r == ' ' || r == '+'
+		})
+		for _, keyword := range keywords {
+			if !slices.Contains(terms, keyword) {
+				value += sep + keyword
+			}
+		}
+		query.Set(param, value)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+	return raw
+}
@@ -0,0 +1,566 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// secretService names the app's entries in the OS secret stores
+	secretService = "io.github.wallset"
+	// secretRefPrefix marks a settings value that is a reference into the
+	// secret store rather than the secret itself
+	secretRefPrefix = "secret:"
+	// secretsFile and secretsKeyFile hold the encrypted-file fallback
+	secretsFile    = "secrets.enc"
+	secretsKeyFile = "secrets.key"
+	// controlAPITokenSecret is the store name of ControlAPIToken
+	controlAPITokenSecret = "control-api-token"
+)
+
+// errSecretNotFound is returned by a store that has no entry for a name
+var errSecretNotFound = errors.New("secret not found")
+
+// errSecretLocked is returned when reading a secret would need the user
+// to unlock the store
+var errSecretLocked = errors.New("secret store is locked")
+
+// secretStore keeps named secrets outside settings.json
+type secretStore interface {
+	Get(name string) (string, error)
+	Set(name, value string) error
+	Delete(name string) error
+	// Backend names the store for diagnostics
+	Backend() string
+}
+
+// lockableSecretStore is a store that asks the user to unlock it when a
+// locked secret is read. GetUnlocked reads without asking, returning
+// errSecretLocked instead.
+type lockableSecretStore interface {
+	GetUnlocked(name string) (string, error)
+}
+
+// secretState holds the store, opened on first use
+type secretState struct {
+	once  sync.Once
+	store secretStore
+	// mu guards stored, the values known to be in the store, so saving
+	// settings only writes secrets that changed and deletes removed ones
+	mu     sync.Mutex
+	stored map[string]string
+}
+
+// GetSecretBackend names where secrets from the settings are kept:
+// "credential-manager", "keychain", "secret-service" or "encrypted-file"
+func (a *App) GetSecretBackend() string {
+	return a.secretStore().Backend()
+}
+
+// secretStore returns the OS secret store, or the encrypted file when the
+// platform has none or it can't be reached. Falling back is reported with
+// a "secretStoreFallback" event.
+func (a *App) secretStore() secretStore {
+	a.secrets.once.Do(func() {
+		store, err := a.openSystemSecretStore()
+		if err == nil {
+			a.secrets.store = store
+			return
+		}
+		fmt.Printf("No OS secret store (%v); keeping secrets in an encrypted file\n", err)
+		a.emit("secretStoreFallback", err.Error())
+		a.secrets.store = &fileSecretStore{
+			path:    a.getConfigPath(secretsFile),
+			keyPath: a.getConfigPath(secretsKeyFile),
+		}
+	})
+	return a.secrets.store
+}
+
+// openSystemSecretStore connects to the platform's secret store
+func (a *App) openSystemSecretStore() (secretStore, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return newCredentialStore()
+	case "darwin":
+		store := keychainStore{runner: a.runner}
+		if err := store.probe(); err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "linux":
+		return newSecretServiceStore()
+	}
+	return nil, fmt.Errorf("not supported on %s", runtime.GOOS)
+}
+
+// isSecretRef reports whether a settings value is a secret store reference
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretRefPrefix)
+}
+
+// headerSecretName is the store name of a source header's value
+func headerSecretName(source, header string) string {
+	return fmt.Sprintf("header-%s-%s", sourceHash(source), strings.ToLower(header))
+}
+
+// passwordSecretName is the store name of a source's password
+func passwordSecretName(source string) string {
+	return "password-" + sourceHash(source)
+}
+
+// passphraseSecretName is the store name of a source's key passphrase
+func passphraseSecretName(source string) string {
+	return "passphrase-" + sourceHash(source)
+}
+
+// profileSecretName is the store name of a secret in a saved profile.
+// Saved profiles keep their own copies, so the active profile's secrets,
+// stored under the plain names, never overwrite them.
+func profileSecretName(profile, name string) string {
+	return fmt.Sprintf("profile-%s-%s", sourceHash(strings.ToLower(profile)), name)
+}
+
+// isProfileSecretRef reports whether a settings value refers to a saved
+// profile's copy of a secret
+func isProfileSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretRefPrefix+"profile-")
+}
+
+// sourceHash shortens a source URL for secret names
+func sourceHash(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:6])
+}
+
+// mapSecrets returns a copy of s with every secret value replaced by
+// fn(name, value): the control API token, source passwords and key
+// passphrases, and source headers whose names mark them sensitive. fn is
+// called for empty values too, which may stand for a secret that couldn't
+// be read. The source configs are copied, so s is unchanged.
+func mapSecrets(s AppSettings, fn func(name, value string) string) AppSettings {
+	s.ControlAPIToken = fn(controlAPITokenSecret, s.ControlAPIToken)
+	if s.SourceConfigs != nil {
+		configs := make(map[string]SourceConfig, len(s.SourceConfigs))
+		for source, config := range s.SourceConfigs {
+			config.Password = fn(passwordSecretName(source), config.Password)
+			config.KeyPassphrase = fn(passphraseSecretName(source), config.KeyPassphrase)
+			if config.Headers != nil {
+				headers := make(map[string]string, len(config.Headers))
+				for name, value := range config.Headers {
+					if sensitiveHeader(name) {
+						value = fn(headerSecretName(source, name), value)
+					}
+					headers[name] = value
+				}
+				config.Headers = headers
+			}
+			configs[source] = config
+		}
+		s.SourceConfigs = configs
+	}
+	return s
+}
+
+// withSecretRefs returns s as written to settings.json: each secret is
+// replaced by its store reference, and a secret that couldn't be read
+// keeps the reference it was loaded with
+func withSecretRefs(s AppSettings) AppSettings {
+	return mapSecrets(s, func(name, value string) string {
+		if value == "" {
+			return s.unresolvedSecrets[name]
+		}
+		if isSecretRef(value) {
+			return value
+		}
+		return secretRefPrefix + name
+	})
+}
+
+// withUnresolvedSecrets returns s carrying the references in refs whose
+// secrets are still empty in s. Settings edited in the UI come back
+// without them, and a secret typed in since replaces its reference.
+func withUnresolvedSecrets(s AppSettings, refs map[string]string) AppSettings {
+	s.unresolvedSecrets = nil
+	mapSecrets(s, func(name, value string) string {
+		if ref, ok := refs[name]; ok && value == "" {
+			if s.unresolvedSecrets == nil {
+				s.unresolvedSecrets = make(map[string]string)
+			}
+			s.unresolvedSecrets[name] = ref
+		}
+		return value
+	})
+	return s
+}
+
+// storeSecrets writes the secret values in s that changed to the secret
+// store, and deletes the secrets s no longer has. Secrets s couldn't read
+// are kept.
+func (a *App) storeSecrets(s AppSettings) error {
+	a.secrets.mu.Lock()
+	defer a.secrets.mu.Unlock()
+	if a.secrets.stored == nil {
+		a.secrets.stored = make(map[string]string)
+	}
+
+	var errs []error
+	current := make(map[string]bool)
+	mapSecrets(s, func(name, value string) string {
+		if value == "" {
+			if _, ok := s.unresolvedSecrets[name]; ok {
+				current[name] = true
+			}
+			return value
+		}
+		current[name] = true
+		if isSecretRef(value) || a.secrets.stored[name] == value {
+			return value
+		}
+		if err := a.secretStore().Set(name, value); err != nil {
+			errs = append(errs, fmt.Errorf("cannot store %s: %v", name, err))
+		} else {
+			a.secrets.stored[name] = value
+		}
+		return value
+	})
+	for name := range a.secrets.stored {
+		if !current[name] {
+			if err := a.secretStore().Delete(name); err != nil {
+				fmt.Printf("Cannot delete secret %s: %v\n", name, err)
+			}
+			delete(a.secrets.stored, name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolveSecrets replaces the references in s with the stored secrets. It
+// never asks the user to unlock the store: a secret that exists but can't
+// be read is left empty and its reference kept in unresolvedSecrets, for
+// resolvePendingSecrets to ask for when a feature needs it. It also
+// reports whether s held plaintext secrets, left by a version that didn't
+// use the store, so the caller can save to migrate them.
+func (a *App) resolveSecrets(s AppSettings) (AppSettings, bool) {
+	plaintext := false
+	unresolved := make(map[string]string)
+	s = mapSecrets(s, func(name, value string) string {
+		if value == "" {
+			return value
+		}
+		if !isSecretRef(value) {
+			plaintext = true
+			return value
+		}
+		store := a.secretStore()
+		get := store.Get
+		if lockable, ok := store.(lockableSecretStore); ok {
+			get = lockable.GetUnlocked
+		}
+		secret, err := get(strings.TrimPrefix(value, secretRefPrefix))
+		if errors.Is(err, errSecretNotFound) {
+			fmt.Printf("Secret %s is missing from the secret store\n", name)
+			return ""
+		}
+		if err != nil {
+			if !errors.Is(err, errSecretLocked) {
+				fmt.Printf("Cannot read secret %s: %v\n", name, err)
+			}
+			unresolved[name] = value
+			return ""
+		}
+		// A secret read from another name, such as a profile's copy, is
+		// written under its own name at the next save
+		if value == secretRefPrefix+name {
+			a.secrets.mu.Lock()
+			if a.secrets.stored == nil {
+				a.secrets.stored = make(map[string]string)
+			}
+			a.secrets.stored[name] = secret
+			a.secrets.mu.Unlock()
+		}
+		return secret
+	})
+	return withUnresolvedSecrets(s, unresolved), plaintext
+}
+
+// pendingSecrets returns the unresolved secrets of s that a feature
+// enabled in s but not in old needs: the control API token while the API
+// is on, and the secrets of the download sources. Comparing with old
+// keeps a dismissed unlock prompt from coming back on every settings
+// change.
+func pendingSecrets(s, old AppSettings) []string {
+	needed := func(s AppSettings) map[string]bool {
+		names := make(map[string]bool)
+		if s.ControlAPIEnabled {
+			names[controlAPITokenSecret] = true
+		}
+		for _, source := range s.DownloadSources {
+			names[passwordSecretName(source)] = true
+			names[passphraseSecretName(source)] = true
+			for header := range s.SourceConfigs[source].Headers {
+				names[headerSecretName(source, header)] = true
+			}
+		}
+		return names
+	}
+
+	before := needed(old)
+	var pending []string
+	for name := range needed(s) {
+		if _, ok := s.unresolvedSecrets[name]; ok && !before[name] {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// resolvePendingSecrets reads the named unresolved secrets, letting the
+// store ask the user to unlock it, and applies the ones it gets as a
+// settings change. It may wait on the user, so callers run it in the
+// background.
+func (a *App) resolvePendingSecrets(names []string) {
+	refs := a.GetSettings().unresolvedSecrets
+	resolved := make(map[string]string)
+	for _, name := range names {
+		ref, ok := refs[name]
+		if !ok {
+			continue
+		}
+		secret, err := a.secretStore().Get(strings.TrimPrefix(ref, secretRefPrefix))
+		if err != nil {
+			fmt.Printf("Cannot read secret %s: %v\n", name, err)
+			continue
+		}
+		resolved[name] = secret
+	}
+	if len(resolved) == 0 {
+		return
+	}
+
+	a.secrets.mu.Lock()
+	if a.secrets.stored == nil {
+		a.secrets.stored = make(map[string]string)
+	}
+	for name, secret := range resolved {
+		if refs[name] == secretRefPrefix+name {
+			a.secrets.stored[name] = secret
+		}
+	}
+	a.secrets.mu.Unlock()
+
+	// Only secrets still waiting on the same reference are filled in, in
+	// case the settings changed while the user was asked
+	old := a.changeSettings(func(s *AppSettings) {
+		filled := mapSecrets(*s, func(name, value string) string {
+			if secret, ok := resolved[name]; ok && value == "" && s.unresolvedSecrets[name] == refs[name] {
+				return secret
+			}
+			return value
+		})
+		*s = withUnresolvedSecrets(filled, s.unresolvedSecrets)
+	})
+	// A profile's copy is no longer needed once saved under the plain name
+	if err := a.saveSettings(); err == nil {
+		for name := range resolved {
+			if isProfileSecretRef(refs[name]) {
+				a.secretStore().Delete(strings.TrimPrefix(refs[name], secretRefPrefix))
+			}
+		}
+	}
+	a.settingsChanged(old)
+	a.emit("settingsReloaded", a.GetSettings())
+}
+
+// keychainStore keeps secrets in the macOS login keychain with the
+// security tool. The value is passed as an argument, as security can't
+// read it from a pipe; it is visible in the process list for the moment
+// the command runs.
+type keychainStore struct {
+	runner commandRunner
+}
+
+func (keychainStore) Backend() string { return "keychain" }
+
+// probe checks that the security tool can reach a keychain
+func (k keychainStore) probe() error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	_, err := k.runner.Output(ctx, "security", "default-keychain")
+	return err
+}
+
+// Exit statuses of the security tool, from the Security framework's
+// result codes
+const (
+	// keychainItemNotFound is errSecItemNotFound
+	keychainItemNotFound = 44
+	// keychainInteractionNotAllowed is errSecInteractionNotAllowed, which
+	// a locked keychain gives when no prompt can be shown
+	keychainInteractionNotAllowed = 36
+)
+
+// Get reads a secret. Only a missing item is errSecretNotFound; a locked
+// keychain, a cancelled prompt or a timeout are errors of their own, so
+// the reference to the secret is kept for later.
+func (k keychainStore) Get(name string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	out, err := k.runner.Output(ctx, "security", "find-generic-password", "-s", secretService, "-a", name, "-w")
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.ExitCode() {
+		case keychainItemNotFound:
+			return "", errSecretNotFound
+		case keychainInteractionNotAllowed:
+			return "", errSecretLocked
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot read keychain: %w", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (k keychainStore) Set(name, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return k.runner.Run(ctx, "security", "add-generic-password", "-U", "-s", secretService, "-a", name, "-w", value)
+}
+
+func (k keychainStore) Delete(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return k.runner.Run(ctx, "security", "delete-generic-password", "-s", secretService, "-a", name)
+}
+
+// fileSecretStore keeps secrets AES-GCM encrypted in the config directory,
+// with the key in a separate owner-only file. It keeps secrets out of
+// settings.json and its exports, but anyone who can read both files as
+// the user can decrypt them.
+type fileSecretStore struct {
+	mu      sync.Mutex
+	path    string
+	keyPath string
+}
+
+func (*fileSecretStore) Backend() string { return "encrypted-file" }
+
+func (f *fileSecretStore) Get(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secrets, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", errSecretNotFound
+	}
+	return value, nil
+}
+
+func (f *fileSecretStore) Set(name, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secrets, err := f.load()
+	if err != nil {
+		return err
+	}
+	if secrets[name] == value {
+		return nil
+	}
+	secrets[name] = value
+	return f.save(secrets)
+}
+
+func (f *fileSecretStore) Delete(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secrets, err := f.load()
+	if err != nil {
+		return err
+	}
+	delete(secrets, name)
+	return f.save(secrets)
+}
+
+// cipher returns the AEAD for the store, creating the key on first use
+func (f *fileSecretStore) cipher() (cipher.AEAD, error) {
+	key, err := os.ReadFile(f.keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(f.keyPath, key, 0600); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", secretsKeyFile, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// load decrypts the secrets file; a missing file is an empty store.
+// f.mu must be held.
+func (f *fileSecretStore) load() (map[string]string, error) {
+	secrets := make(map[string]string)
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	aead, err := f.cipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("%s is damaged", secretsFile)
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt %s: %v", secretsFile, err)
+	}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// save encrypts secrets into the secrets file. f.mu must be held.
+func (f *fileSecretStore) save(secrets map[string]string) error {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	aead, err := f.cipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, aead.Seal(nonce, nonce, plain, nil), 0600)
+}
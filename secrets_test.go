@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeSecretStore is an in-memory secretStore whose reads can be made to
+// fail as a locked keyring's do
+type fakeSecretStore struct {
+	values  map[string]string
+	getErr  error
+	deleted []string
+}
+
+func (f *fakeSecretStore) Backend() string { return "fake" }
+
+func (f *fakeSecretStore) Get(name string) (string, error) {
+	if f.getErr != nil {
+		return "", f.getErr
+	}
+	value, ok := f.values[name]
+	if !ok {
+		return "", errSecretNotFound
+	}
+	return value, nil
+}
+
+func (f *fakeSecretStore) Set(name, value string) error {
+	f.values[name] = value
+	return nil
+}
+
+func (f *fakeSecretStore) Delete(name string) error {
+	f.deleted = append(f.deleted, name)
+	delete(f.values, name)
+	return nil
+}
+
+// useSecretStore has a keep its secrets in store instead of the OS one
+func useSecretStore(a *App, store secretStore) {
+	a.secrets.once.Do(func() { a.secrets.store = store })
+}
+
+func TestUnreadableSecretKeepsReference(t *testing.T) {
+	a := newTestApp(t)
+	store := &fakeSecretStore{
+		values: map[string]string{controlAPITokenSecret: "token"},
+		getErr: errors.New("keyring is locked"),
+	}
+	useSecretStore(a, store)
+
+	s := a.GetSettings()
+	s.ControlAPIToken = secretRefPrefix + controlAPITokenSecret
+	data, _ := json.Marshal(s)
+	if err := os.WriteFile(a.getConfigPath("settings.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a.loadSettings()
+	if got := a.GetSettings().ControlAPIToken; got != "" {
+		t.Fatalf("ControlAPIToken = %q, want it left empty", got)
+	}
+	if err := a.saveSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := os.ReadFile(a.getConfigPath("settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(saved), `"control_api_token": "secret:control-api-token"`) {
+		t.Errorf("saved settings lost the token reference:\n%s", saved)
+	}
+	if len(store.deleted) > 0 || store.values[controlAPITokenSecret] != "token" {
+		t.Errorf("store entries deleted: %v", store.deleted)
+	}
+}
+
+// exitRunner stands in for the security tool: Output runs a shell that
+// exits with status, printing "value" when that is 0
+type exitRunner struct {
+	status int
+}
+
+func (r exitRunner) Run(ctx context.Context, name string, args ...string) error {
+	_, err := r.Output(ctx, name, args...)
+	return err
+}
+
+func (r exitRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, "sh", "-c", "echo value; exit "+strconv.Itoa(r.status)).Output()
+}
+
+// TestKeychainGetErrors checks that only a missing item makes the
+// keychain report a secret as not found, and that any other failure keeps
+// the reference to it
+func TestKeychainGetErrors(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("needs sh")
+	}
+	tests := []struct {
+		name    string
+		status  int
+		want    string
+		wantErr error // nil for any error other than the sentinels
+	}{
+		{name: "found", status: 0, want: "value"},
+		{name: "item not found", status: keychainItemNotFound, wantErr: errSecretNotFound},
+		{name: "locked", status: keychainInteractionNotAllowed, wantErr: errSecretLocked},
+		// errSecUserCanceled
+		{name: "prompt cancelled", status: 128},
+		{name: "other failure", status: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := keychainStore{runner: exitRunner{tt.status}}
+			got, err := store.Get(controlAPITokenSecret)
+			switch {
+			case tt.status == 0:
+				if err != nil || got != tt.want {
+					t.Errorf("Get = %q, %v; want %q", got, err, tt.want)
+				}
+				return
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("Get error %v, want %v", err, tt.wantErr)
+			case tt.wantErr == nil && (err == nil || errors.Is(err, errSecretNotFound)):
+				t.Errorf("Get error %v, want a failure other than not found", err)
+			}
+
+			a := newTestApp(t)
+			useSecretStore(a, store)
+			s := a.GetSettings()
+			s.ControlAPIToken = secretRefPrefix + controlAPITokenSecret
+			s, _ = a.resolveSecrets(s)
+			ref, kept := s.unresolvedSecrets[controlAPITokenSecret]
+			if missing := tt.status == keychainItemNotFound; kept == missing {
+				t.Errorf("reference kept: %v, want %v", kept, !missing)
+			}
+			if kept && ref != secretRefPrefix+controlAPITokenSecret {
+				t.Errorf("kept reference %q", ref)
+			}
+		})
+	}
+}
+
+// lockedSecretStore is a fakeSecretStore whose secrets can only be read
+// after prompting
+type lockedSecretStore struct {
+	fakeSecretStore
+	prompts int
+}
+
+func (l *lockedSecretStore) Get(name string) (string, error) {
+	l.prompts++
+	return l.fakeSecretStore.Get(name)
+}
+
+func (l *lockedSecretStore) GetUnlocked(name string) (string, error) {
+	return "", errSecretLocked
+}
+
+func TestLockedSecretsResolveWithoutPrompting(t *testing.T) {
+	a := newTestApp(t)
+	store := &lockedSecretStore{fakeSecretStore: fakeSecretStore{
+		values: map[string]string{controlAPITokenSecret: "token"},
+	}}
+	useSecretStore(a, store)
+
+	s := a.GetSettings()
+	s.ControlAPIToken = secretRefPrefix + controlAPITokenSecret
+	s, _ = a.resolveSecrets(s)
+	if store.prompts != 0 {
+		t.Fatalf("resolveSecrets prompted %d times", store.prompts)
+	}
+	a.setSettings(s)
+
+	// Nothing needs the token while the control API is off
+	if pending := pendingSecrets(s, AppSettings{}); len(pending) != 0 {
+		t.Fatalf("pendingSecrets = %v with the control API off", pending)
+	}
+	s.ControlAPIEnabled = true
+	pending := pendingSecrets(s, AppSettings{})
+	if len(pending) != 1 || pending[0] != controlAPITokenSecret {
+		t.Fatalf("pendingSecrets = %v, want the control API token", pending)
+	}
+	if again := pendingSecrets(s, s); len(again) != 0 {
+		t.Errorf("pendingSecrets = %v for settings that already needed them", again)
+	}
+
+	a.resolvePendingSecrets(pending)
+	if store.prompts != 1 {
+		t.Errorf("prompted %d times, want 1", store.prompts)
+	}
+	got := a.GetSettings()
+	if got.ControlAPIToken != "token" || len(got.unresolvedSecrets) != 0 {
+		t.Errorf("after resolving, token = %q and unresolved = %v", got.ControlAPIToken, got.unresolvedSecrets)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretsBusName       = "org.freedesktop.secrets"
+	secretsServicePath   = "/org/freedesktop/secrets"
+	secretsServiceIface  = "org.freedesktop.Secret.Service"
+	secretsDefaultPath   = "/org/freedesktop/secrets/aliases/default"
+	secretsPromptTimeout = 2 * time.Minute
+)
+
+// secretValue mirrors the Secret Service Secret structure (oayays)
+type secretValue struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// secretServiceStore keeps secrets in the desktop keyring (GNOME Keyring,
+// KWallet) through the freedesktop Secret Service API. The session uses
+// the "plain" algorithm; the values only travel over the user's session
+// bus.
+type secretServiceStore struct {
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+}
+
+// newSecretServiceStore opens a session with the Secret Service, failing
+// when no keyring daemon provides it
+func newSecretServiceStore() (secretStore, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	err = conn.Object(secretsBusName, secretsServicePath).
+		Call(secretsServiceIface+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Secret Service unavailable: %v", err)
+	}
+	return &secretServiceStore{conn: conn, session: session}, nil
+}
+
+func (*secretServiceStore) Backend() string { return "secret-service" }
+
+// attributes identify the app's item for a secret
+func (s *secretServiceStore) attributes(name string) map[string]string {
+	return map[string]string{"service": secretService, "name": name}
+}
+
+// service returns the Secret Service object
+func (s *secretServiceStore) service() dbus.BusObject {
+	return s.conn.Object(secretsBusName, secretsServicePath)
+}
+
+// search returns the app's items for name. Locked ones are unlocked when
+// prompt is set, which may ask the user; otherwise finding only locked
+// ones returns errSecretLocked.
+func (s *secretServiceStore) search(name string, prompt bool) ([]dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	err := s.service().Call(secretsServiceIface+".SearchItems", 0, s.attributes(name)).Store(&unlocked, &locked)
+	if err != nil {
+		return nil, err
+	}
+	if !prompt {
+		if len(unlocked) == 0 && len(locked) > 0 {
+			return nil, errSecretLocked
+		}
+		return unlocked, nil
+	}
+	if len(locked) > 0 {
+		if err := s.unlock(locked); err != nil {
+			return nil, err
+		}
+		unlocked = append(unlocked, locked...)
+	}
+	return unlocked, nil
+}
+
+// unlock unlocks items or collections, prompting the user if needed
+func (s *secretServiceStore) unlock(paths []dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	if err := s.service().Call(secretsServiceIface+".Unlock", 0, paths).Store(&unlocked, &prompt); err != nil {
+		return err
+	}
+	return s.runPrompt(prompt)
+}
+
+// runPrompt shows a Secret Service prompt and waits for it to complete;
+// "/" means no prompt is needed
+func (s *secretServiceStore) runPrompt(prompt dbus.ObjectPath) error {
+	if prompt == "/" || prompt == "" {
+		return nil
+	}
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(prompt),
+		dbus.WithMatchInterface("org.freedesktop.Secret.Prompt"),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := s.conn.AddMatchSignal(match...); err != nil {
+		return err
+	}
+	defer s.conn.RemoveMatchSignal(match...)
+	signals := make(chan *dbus.Signal, 1)
+	s.conn.Signal(signals)
+	defer s.conn.RemoveSignal(signals)
+
+	if err := s.conn.Object(secretsBusName, prompt).Call("org.freedesktop.Secret.Prompt.Prompt", 0, "").Err; err != nil {
+		return err
+	}
+	timeout := time.After(secretsPromptTimeout)
+	for {
+		select {
+		case sig := <-signals:
+			if sig.Path != prompt || len(sig.Body) == 0 {
+				continue
+			}
+			if dismissed, _ := sig.Body[0].(bool); dismissed {
+				return fmt.Errorf("keyring prompt dismissed")
+			}
+			return nil
+		case <-timeout:
+			return fmt.Errorf("keyring prompt timed out")
+		}
+	}
+}
+
+func (s *secretServiceStore) Get(name string) (string, error) {
+	return s.get(name, true)
+}
+
+func (s *secretServiceStore) GetUnlocked(name string) (string, error) {
+	return s.get(name, false)
+}
+
+// get reads the secret for name, unlocking it first if prompt is set
+func (s *secretServiceStore) get(name string, prompt bool) (string, error) {
+	items, err := s.search(name, prompt)
+	if err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		return "", errSecretNotFound
+	}
+	var secret secretValue
+	err = s.conn.Object(secretsBusName, items[0]).
+		Call("org.freedesktop.Secret.Item.GetSecret", 0, s.session).
+		Store(&secret)
+	if err != nil {
+		return "", err
+	}
+	return string(secret.Value), nil
+}
+
+func (s *secretServiceStore) Set(name, value string) error {
+	if err := s.unlock([]dbus.ObjectPath{secretsDefaultPath}); err != nil {
+		return err
+	}
+	props := map[string]dbus.Variant{
+		"org.freedesktop.Secret.Item.Label":      dbus.MakeVariant("Wallset " + name),
+		"org.freedesktop.Secret.Item.Attributes": dbus.MakeVariant(s.attributes(name)),
+	}
+	secret := secretValue{Session: s.session, Value: []byte(value), ContentType: "text/plain"}
+
+	var item, prompt dbus.ObjectPath
+	err := s.conn.Object(secretsBusName, secretsDefaultPath).
+		Call("org.freedesktop.Secret.Collection.CreateItem", 0, props, secret, true).
+		Store(&item, &prompt)
+	if err != nil {
+		return err
+	}
+	return s.runPrompt(prompt)
+}
+
+func (s *secretServiceStore) Delete(name string) error {
+	items, err := s.search(name, true)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		var prompt dbus.ObjectPath
+		if err := s.conn.Object(secretsBusName, item).Call("org.freedesktop.Secret.Item.Delete", 0).Store(&prompt); err != nil {
+			return err
+		}
+		if err := s.runPrompt(prompt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
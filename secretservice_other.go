@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// newSecretServiceStore is only available on Linux
+func newSecretServiceStore() (secretStore, error) {
+	return nil, fmt.Errorf("the Secret Service is only available on Linux")
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// settingsReloadDelay debounces bursts of writes to settings.json, such as
+// an editor saving in several steps or a sync tool replacing the file
+const settingsReloadDelay = 500 * time.Millisecond
+
+// settingsWatcher follows settings.json for changes made outside the app
+type settingsWatcher struct {
+	mu      sync.Mutex
+	watcher *fsnotify.Watcher
+	timer   *time.Timer
+}
+
+// startSettingsWatcher reloads settings.json whenever it changes on disk.
+// The directory is watched rather than the file, because editors and sync
+// tools often replace the file, which would end a watch on it.
+func (a *App) startSettingsWatcher() {
+	path := a.getConfigPath("settings.json")
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Printf("Failed to watch settings: %v\n", err)
+		return
+	}
+	if err := w.Add(filepath.Dir(path)); err != nil {
+		w.Close()
+		fmt.Printf("Failed to watch settings: %v\n", err)
+		return
+	}
+
+	a.settingsWatch.mu.Lock()
+	a.settingsWatch.watcher = w
+	a.settingsWatch.mu.Unlock()
+	go a.watchSettings(w, path)
+}
+
+// stopSettingsWatcher stops watching and drops any pending reload
+func (a *App) stopSettingsWatcher() {
+	a.settingsWatch.mu.Lock()
+	defer a.settingsWatch.mu.Unlock()
+
+	if a.settingsWatch.timer != nil {
+		a.settingsWatch.timer.Stop()
+		a.settingsWatch.timer = nil
+	}
+	if a.settingsWatch.watcher != nil {
+		a.settingsWatch.watcher.Close()
+		a.settingsWatch.watcher = nil
+	}
+}
+
+// watchSettings schedules a reload for each write to path until the
+// watcher is closed
+func (a *App) watchSettings(w *fsnotify.Watcher, path string) {
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == path && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				a.scheduleSettingsReload()
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			fmt.Printf("Settings watcher error: %v\n", err)
+		}
+	}
+}
+
+// scheduleSettingsReload (re)starts the debounce timer
+func (a *App) scheduleSettingsReload() {
+	a.settingsWatch.mu.Lock()
+	defer a.settingsWatch.mu.Unlock()
+
+	if a.settingsWatch.watcher == nil {
+		return
+	}
+	if a.settingsWatch.timer != nil {
+		a.settingsWatch.timer.Stop()
+	}
+	a.settingsWatch.timer = time.AfterFunc(settingsReloadDelay, a.reloadSettings)
+}
+
+// reloadSettings applies settings.json after an external edit and emits
+// "settingsReloaded". A file that doesn't parse or validate is reported
+// with "settingsReloadFailed" and the current settings stay in effect.
+func (a *App) reloadSettings() {
+	data, err := os.ReadFile(a.getConfigPath("settings.json"))
+	if err != nil {
+		// Removed or being replaced; the next write triggers another reload
+		return
+	}
+	// Skip the app's own saves
+	if current, err := json.MarshalIndent(withSecretRefs(a.GetSettings()), "", "  "); err == nil && bytes.Equal(current, data) {
+		return
+	}
+
+	newSettings := defaultSettings()
+	if err := json.Unmarshal(data, &newSettings); err != nil {
+		a.settingsReloadFailed(fmt.Errorf("settings.json is not valid JSON: %v", err))
+		return
+	}
+	newSettings, plaintext := a.resolveSecrets(newSettings)
+	if err := validateSettings(newSettings); err != nil {
+		a.settingsReloadFailed(err)
+		return
+	}
+	newSettings.DownloadSources, _ = normalizeSources(newSettings.DownloadSources, newSettings.SourceConfigs)
+
+	fmt.Println("Reloaded settings.json after an external change")
+	old := a.setSettings(newSettings)
+	// A secret typed into the file moves to the secret store
+	if plaintext {
+		a.saveSettings()
+	}
+	a.settingsChanged(old)
+	a.emit("settingsReloaded", newSettings)
+}
+
+// settingsReloadFailed reports a settings.json that could not be applied
+func (a *App) settingsReloadFailed(err error) {
+	fmt.Printf("Ignoring settings.json change: %v\n", err)
+	a.emit("settingsReloadFailed", err.Error())
+}
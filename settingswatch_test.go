@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"testing"
+)
+
+// TestReloadSettingsConcurrentReads reloads settings.json while other
+// goroutines read and change the settings; run with -race
+func TestReloadSettingsConcurrentReads(t *testing.T) {
+	a := newTestApp(t)
+	if err := a.saveSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			s := a.GetSettings()
+			s.ChangeIntervalHours = i%5 + 1
+			data, err := json.MarshalIndent(withSecretRefs(s), "", "  ")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			os.WriteFile(a.getConfigPath("settings.json"), data, 0644)
+			a.reloadSettings()
+		}()
+		go func() {
+			defer wg.Done()
+			_ = a.GetSettings().ChangeIntervalHours
+			a.GetAutoChangeStatus()
+		}()
+		go func() {
+			defer wg.Done()
+			a.changeSettings(func(s *AppSettings) { s.OverlayText = "test" })
+		}()
+	}
+	wg.Wait()
+
+	if got := a.GetSettings().ChangeIntervalHours; got < 1 || got > 5 {
+		t.Errorf("ChangeIntervalHours = %d after reloads, want 1-5", got)
+	}
+}
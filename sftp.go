@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sourceTypeSFTP is SourceConfig.Type for a folder on an SSH server, with
+// the source written as sftp://user@host:port/remote/dir
+const sourceTypeSFTP = "sftp"
+
+// sftpKnownHostsFile holds the host keys the user has trusted
+const sftpKnownHostsFile = "sftp_known_hosts"
+
+// errHostKeyUnknown is returned while a server's key awaits confirmation
+var errHostKeyUnknown = errors.New("SFTP host key not trusted yet")
+
+// SFTPHostKey is the payload of the "sftpHostKeyPrompt" event, sent the
+// first time a server is seen so the user can compare the fingerprint
+type SFTPHostKey struct {
+	Host        string `json:"host"`
+	KeyType     string `json:"key_type"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// sftpHostState holds host keys seen on first connect, until the user
+// trusts them with TrustSFTPHostKey
+type sftpHostState struct {
+	mu      sync.Mutex
+	pending map[string]ssh.PublicKey
+}
+
+// sftpFile is an image listed in a remote folder. ETag combines size and
+// modification time, so a replaced file is fetched again.
+type sftpFile struct {
+	URL  string
+	Path string
+	Size int64
+	ETag string
+}
+
+// sftpSession is an open SFTP connection
+type sftpSession struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+func (s *sftpSession) Close() error {
+	s.sftp.Close()
+	return s.ssh.Close()
+}
+
+// fetchSFTP picks a random image from an SFTP source that isn't in the
+// library yet, or has changed since, and downloads it to dest. As with
+// HTTP, downloadTimeout covers the whole fetch, or only each stall once a
+// throttled transfer starts, and shutting down cancels it at any point.
+func (a *App) fetchSFTP(ctx context.Context, source, dest string, speedLimit int, maxSize int64) (sftpFile, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := time.AfterFunc(downloadTimeout, cancel)
+	defer timer.Stop()
+
+	session, err := a.dialSFTP(ctx, source)
+	if err != nil {
+		return sftpFile{}, err
+	}
+	defer session.Close()
+	// Closing the session interrupts whatever call is blocked on it
+	stop := context.AfterFunc(ctx, func() { session.Close() })
+	defer stop()
+
+	file, err := a.pickSFTPFile(session, source)
+	if err != nil {
+		return sftpFile{}, ctxErr(ctx, err)
+	}
+	if maxSize > 0 && file.Size > maxSize {
+		return sftpFile{}, tooLargeError(maxSize)
+	}
+
+	remote, err := session.sftp.Open(file.Path)
+	if err != nil {
+		return sftpFile{}, ctxErr(ctx, err)
+	}
+	defer remote.Close()
+
+	part := dest + ".part"
+	out, err := os.Create(part)
+	if err != nil {
+		return sftpFile{}, err
+	}
+	var body io.Reader = remote
+	if speedLimit > 0 {
+		timer.Reset(downloadTimeout)
+		body = newThrottledReader(idleReader{remote, func() { timer.Reset(downloadTimeout) }}, speedLimit)
+	}
+	written, err := io.Copy(out, body)
+	out.Close()
+	if err == nil && written != file.Size {
+		err = fmt.Errorf("truncated download: got %d of %d bytes", written, file.Size)
+	}
+	if err != nil {
+		os.Remove(part)
+		return sftpFile{}, ctxErr(ctx, err)
+	}
+	if err := os.Rename(part, dest); err != nil {
+		os.Remove(part)
+		return sftpFile{}, err
+	}
+	return file, nil
+}
+
+// ctxErr reports a cancelled or timed-out fetch as such, rather than as
+// the connection error closing the session caused
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("SFTP download stopped: %w", ctx.Err())
+	}
+	return err
+}
+
+// dialSFTP connects and authenticates to the server of an SFTP source
+func (a *App) dialSFTP(ctx context.Context, source string) (*sftpSession, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	config := a.sourceConfig(source)
+	user := config.Username
+	if user == "" {
+		user = u.User.Username()
+	}
+	if user == "" {
+		return nil, fmt.Errorf("no SFTP user for %s", u.Host)
+	}
+	auth, err := sftpAuth(config)
+	if err != nil {
+		return nil, err
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "22"
+	}
+	addr := net.JoinHostPort(u.Hostname(), port)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, ctxErr(ctx, fmt.Errorf("cannot reach SFTP server: %v", err))
+	}
+	// The SSH handshake has no context of its own; closing the connection
+	// ends it when the fetch is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: a.checkSFTPHostKey,
+	})
+	if err != nil {
+		conn.Close()
+		if errors.Is(err, errHostKeyUnknown) {
+			return nil, fmt.Errorf("%w: confirm the key for %s first", errHostKeyUnknown, addr)
+		}
+		return nil, ctxErr(ctx, fmt.Errorf("SFTP login to %s failed: %v", addr, err))
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		client.Close()
+		return nil, ctxErr(ctx, fmt.Errorf("%s has no SFTP subsystem: %v", addr, err))
+	}
+	return &sftpSession{ssh: client, sftp: sc}, nil
+}
+
+// sftpAuth returns the auth methods of a source: its private key,
+// decrypted with KeyPassphrase if needed, then its password
+func sftpAuth(config SourceConfig) ([]ssh.AuthMethod, error) {
+	var auth []ssh.AuthMethod
+	if config.PrivateKeyPath != "" {
+		data, err := os.ReadFile(config.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read private key: %v", err)
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			if config.KeyPassphrase == "" {
+				return nil, fmt.Errorf("private key %s needs a passphrase", config.PrivateKeyPath)
+			}
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(config.KeyPassphrase))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key %s: %v", config.PrivateKeyPath, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if config.Password != "" {
+		auth = append(auth, ssh.Password(config.Password))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("SFTP sources need a password or a private key")
+	}
+	return auth, nil
+}
+
+// checkSFTPHostKey accepts keys in the known hosts file. An unknown host
+// is held for TrustSFTPHostKey and announced with "sftpHostKeyPrompt";
+// a key that differs from the trusted one is refused outright.
+func (a *App) checkSFTPHostKey(host string, remote net.Addr, key ssh.PublicKey) error {
+	path := a.getConfigPath(sftpKnownHostsFile)
+	if f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600); err == nil {
+		f.Close()
+	}
+	check, err := knownhosts.New(path)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %v", sftpKnownHostsFile, err)
+	}
+	err = check(host, remote, key)
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return err
+	}
+	if len(keyErr.Want) > 0 {
+		return fmt.Errorf("host key for %s has changed; remove it from %s if this is expected", host, sftpKnownHostsFile)
+	}
+
+	a.sftpHosts.mu.Lock()
+	if a.sftpHosts.pending == nil {
+		a.sftpHosts.pending = make(map[string]ssh.PublicKey)
+	}
+	a.sftpHosts.pending[host] = key
+	a.sftpHosts.mu.Unlock()
+
+	fingerprint := ssh.FingerprintSHA256(key)
+	fmt.Printf("Unknown SFTP host key for %s: %s\n", host, fingerprint)
+	a.emit("sftpHostKeyPrompt", SFTPHostKey{Host: host, KeyType: key.Type(), Fingerprint: fingerprint})
+	return errHostKeyUnknown
+}
+
+// TrustSFTPHostKey adds the key offered by a "sftpHostKeyPrompt" event to
+// the known hosts. The fingerprint must match the one shown, so a key
+// swapped since the prompt isn't trusted by mistake.
+func (a *App) TrustSFTPHostKey(host, fingerprint string) error {
+	a.sftpHosts.mu.Lock()
+	key, ok := a.sftpHosts.pending[host]
+	if ok && ssh.FingerprintSHA256(key) == fingerprint {
+		delete(a.sftpHosts.pending, host)
+	}
+	a.sftpHosts.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no host key waiting for %s", host)
+	}
+	if ssh.FingerprintSHA256(key) != fingerprint {
+		return fmt.Errorf("fingerprint does not match the key offered by %s", host)
+	}
+
+	f, err := os.OpenFile(a.getConfigPath(sftpKnownHostsFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(host)}, key))
+	return err
+}
+
+// pickSFTPFile lists the source folder and picks a random image that isn't
+// in the library yet, or whose size or modification time changed
+func (a *App) pickSFTPFile(session *sftpSession, source string) (sftpFile, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return sftpFile{}, err
+	}
+	dir := u.Path
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := session.sftp.ReadDir(dir)
+	if err != nil {
+		return sftpFile{}, fmt.Errorf("cannot list %s: %v", dir, err)
+	}
+
+	// Library entries are keyed by the URL without the user
+	u.User = nil
+	var files, fresh []sftpFile
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() || !importExtensions[strings.ToLower(path.Ext(entry.Name()))] {
+			continue
+		}
+		remote := path.Join(dir, entry.Name())
+		u.Path = remote
+		file := sftpFile{
+			URL:  u.String(),
+			Path: remote,
+			Size: entry.Size(),
+			ETag: fmt.Sprintf("%d-%d", entry.Size(), entry.ModTime().Unix()),
+		}
+		files = append(files, file)
+		if wp, ok := a.findBySourceURL(file.URL); ok && wp.RemoteETag == file.ETag {
+			continue
+		}
+		fresh = append(fresh, file)
+	}
+	if len(fresh) == 0 {
+		if len(files) == 0 {
+			return sftpFile{}, fmt.Errorf("no images in the SFTP folder")
+		}
+		return sftpFile{}, fmt.Errorf("%w: every image in the SFTP folder is already in the library", errNotModified)
+	}
+	return fresh[rand.Intn(len(fresh))], nil
+}
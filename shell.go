@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+)
+
+const (
+	// setFlag is the command-line flag Explorer passes with an image path
+	setFlag = "--set"
+	// shellVerb is the registry key name of the context-menu entry
+	shellVerb = "Wallset"
+	// shellVerbLabel is the context-menu text
+	shellVerbLabel = "Set as wallpaper with Wallset"
+)
+
+// errShellUnsupported is returned outside Windows
+var errShellUnsupported = fmt.Errorf("shell integration is only supported on Windows")
+
+// shellVerbKeys returns the per-user registry key of the context-menu verb
+// for every importable image type, in a stable order
+func shellVerbKeys() []string {
+	var keys []string
+	for ext := range importExtensions {
+		keys = append(keys, `HKCU\Software\Classes\SystemFileAssociations\`+ext+`\shell\`+shellVerb)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// InstallShellIntegration adds "Set as wallpaper with Wallset" to Explorer's
+// context menu for image files. Only HKCU is written, so no admin rights
+// are needed. The verb runs this executable with --set, which a running
+// instance receives through the single-instance lock; otherwise the image
+// is set without opening a window.
+func (a *App) InstallShellIntegration() error {
+	if runtime.GOOS != "windows" {
+		return errShellUnsupported
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	command := fmt.Sprintf(`"%s" %s "%%1"`, exe, setFlag)
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	for _, key := range shellVerbKeys() {
+		for _, args := range [][]string{
+			{"add", key, "/ve", "/d", shellVerbLabel, "/f"},
+			{"add", key, "/v", "Icon", "/d", exe + ",0", "/f"},
+			{"add", key + `\command`, "/ve", "/d", command, "/f"},
+		} {
+			if err := a.runner.Run(ctx, "reg", args...); err != nil {
+				a.removeShellVerbs(ctx)
+				return fmt.Errorf("failed to register context menu: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveShellIntegration deletes every registry key InstallShellIntegration
+// wrote. Keys that are already gone are not an error.
+func (a *App) RemoveShellIntegration() error {
+	if runtime.GOOS != "windows" {
+		return errShellUnsupported
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return a.removeShellVerbs(ctx)
+}
+
+// removeShellVerbs deletes the verb keys, skipping ones that don't exist
+func (a *App) removeShellVerbs(ctx context.Context) error {
+	var lastErr error
+	for _, key := range shellVerbKeys() {
+		if err := a.runner.Run(ctx, "reg", "query", key); err != nil {
+			continue
+		}
+		if err := a.runner.Run(ctx, "reg", "delete", key, "/f"); err != nil {
+			lastErr = fmt.Errorf("failed to remove %s: %v", key, err)
+		}
+	}
+	return lastErr
+}
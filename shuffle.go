@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// recencyHorizon is how long after being shown a wallpaper counts as fully
+// neglected again
+const recencyHorizon = 30 * 24 * time.Hour
+
+// shuffleWeight is a wallpaper's relative chance of being picked from the
+// library. Recency runs from 0 just after it was shown to 1 after
+// recencyHorizon or if it was never shown; rating from 0 for one star to 1
+// for five stars or a favorite, with unrated wallpapers in the middle.
+// Each factor is scaled by its weight, so both weights at 0 give every
+// wallpaper the same chance.
+func shuffleWeight(wp WallpaperInfo, now time.Time, recencyWeight, ratingWeight float64) float64 {
+	recency := 1.0
+	if !wp.LastSetAt.IsZero() {
+		recency = min(max(now.Sub(wp.LastSetAt).Hours()/recencyHorizon.Hours(), 0), 1)
+	}
+	rating := 0.5
+	switch {
+	case wp.Favorite:
+		rating = 1
+	case wp.Rating > 0:
+		rating = float64(wp.Rating-1) / 4
+	}
+	return (1 + recencyWeight*recency) * (1 + ratingWeight*rating)
+}
+
+// weightedPick picks from a non-empty pool with probability proportional
+// to shuffleWeight. random returns values in [0, 1), e.g. rand.Float64 or
+// a seeded rand.Rand's Float64 for reproducible picks.
+func weightedPick(pool []WallpaperInfo, now time.Time, recencyWeight, ratingWeight float64, random func() float64) WallpaperInfo {
+	weights := make([]float64, len(pool))
+	total := 0.0
+	for i, wp := range pool {
+		weights[i] = shuffleWeight(wp, now, recencyWeight, ratingWeight)
+		total += weights[i]
+	}
+	r := random() * total
+	for i, w := range weights {
+		if r < w {
+			return pool[i]
+		}
+		r -= w
+	}
+	return pool[len(pool)-1]
+}
+
+// pickFromLibrary picks one of candidates for rotation, favoring
+// wallpapers not shown for a while and rated highly
+func (a *App) pickFromLibrary(candidates []WallpaperInfo) WallpaperInfo {
+	return weightedPick(candidates, a.clock.Now(), a.GetSettings().ShuffleRecencyWeight, a.GetSettings().ShuffleRatingWeight, rand.Float64)
+}
+
+// recordShown counts a wallpaper as applied now. path may be the original
+// or its processed copy.
+func (a *App) recordShown(path string) {
+	now := a.clock.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i := range a.data.Wallpapers {
+		wp := &a.data.Wallpapers[i]
+		if path != "" && (wp.Filepath == path || wp.ProcessedPath == path) {
+			wp.TimesSet++
+			wp.LastSetAt = now
+		}
+	}
+}
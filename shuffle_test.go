@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+// shufflePool is a library with one wallpaper of each kind the weighting
+// tells apart
+func shufflePool(now time.Time) []WallpaperInfo {
+	return []WallpaperInfo{
+		{ID: "never-shown"},
+		{ID: "just-shown", LastSetAt: now.Add(-time.Minute)},
+		{ID: "favorite", Favorite: true, LastSetAt: now.Add(-60 * 24 * time.Hour)},
+		{ID: "one-star", Rating: 1, LastSetAt: now.Add(-60 * 24 * time.Hour)},
+		{ID: "five-stars", Rating: 5, LastSetAt: now.Add(-7 * 24 * time.Hour)},
+	}
+}
+
+// rotate picks n wallpapers in turn with an RNG seeded with seed, marking
+// each as shown an hour apart as rotation would, and returns their IDs
+func rotate(seed int64, n int) []string {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	pool := shufflePool(now)
+	random := rand.New(rand.NewSource(seed)).Float64
+	var order []string
+	for range n {
+		picked := weightedPick(pool, now, 2, 2, random)
+		order = append(order, picked.ID)
+		for i := range pool {
+			if pool[i].ID == picked.ID {
+				pool[i].LastSetAt = now
+			}
+		}
+		now = now.Add(time.Hour)
+	}
+	return order
+}
+
+func TestWeightedPickSeeded(t *testing.T) {
+	first, second := rotate(42, 20), rotate(42, 20)
+	if !slices.Equal(first, second) {
+		t.Errorf("same seed gave different orders:\n%v\n%v", first, second)
+	}
+	if other := rotate(7, 20); slices.Equal(first, other) {
+		t.Errorf("different seeds gave the same order %v", first)
+	}
+}
+
+func TestWeightedPickFavorsNeglectedFavorites(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	pool := shufflePool(now)
+	random := rand.New(rand.NewSource(1)).Float64
+	counts := map[string]int{}
+	for range 10000 {
+		counts[weightedPick(pool, now, 2, 2, random).ID]++
+	}
+	// Weights are (1 + 2*recency) * (1 + 2*rating): 6, about 2, 9, 3 and
+	// about 4.4
+	if !(counts["favorite"] > counts["never-shown"] && counts["never-shown"] > counts["one-star"] && counts["one-star"] > counts["just-shown"]) {
+		t.Errorf("picks %v, want favorite > never-shown > one-star > just-shown", counts)
+	}
+
+	// Without weights every wallpaper has the same chance
+	clear(counts)
+	for range 10000 {
+		counts[weightedPick(pool, now, 0, 0, random).ID]++
+	}
+	for id, n := range counts {
+		if n < 1800 || n > 2200 {
+			t.Errorf("unweighted %s picked %d times of 10000, want about 2000", id, n)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+)
+
+// defaultSimilarityThreshold is the Hamming distance used when the setting
+// is disabled but FindSimilar is called
+const defaultSimilarityThreshold = 5
+
+// errDuplicate is returned when a new wallpaper matches an existing one
+var errDuplicate = errors.New("duplicate wallpaper")
+
+// differenceHash computes a 64-bit dHash: the image is reduced to a 9x8
+// grayscale grid and each bit records whether a cell is brighter than its
+// right-hand neighbour. Re-encoded or resized copies produce nearby hashes.
+func differenceHash(img image.Image) uint64 {
+	grid := grayscaleGrid(img, 9, 8)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if grid[y*9+x] > grid[y*9+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// grayscaleGrid averages the image luminance over a cols x rows grid
+func grayscaleGrid(img image.Image, cols, rows int) []float64 {
+	bounds := img.Bounds()
+	grid := make([]float64, cols*rows)
+	counts := make([]int, cols*rows)
+
+	stepX := max(bounds.Dx()/(cols*16), 1)
+	stepY := max(bounds.Dy()/(rows*16), 1)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		cy := (y - bounds.Min.Y) * rows / bounds.Dy()
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			cx := (x - bounds.Min.X) * cols / bounds.Dx()
+			r, g, b, _ := img.At(x, y).RGBA()
+			grid[cy*cols+cx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			counts[cy*cols+cx]++
+		}
+	}
+
+	for i := range grid {
+		if counts[i] > 0 {
+			grid[i] /= float64(counts[i])
+		}
+	}
+	return grid
+}
+
+// formatHash encodes a perceptual hash for storage
+func formatHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// hashDistance returns the Hamming distance between two stored hashes, or
+// -1 when either is missing or malformed
+func hashDistance(a, b string) int {
+	if a == "" || b == "" {
+		return -1
+	}
+	ha, errA := strconv.ParseUint(a, 16, 64)
+	hb, errB := strconv.ParseUint(b, 16, 64)
+	if errA != nil || errB != nil {
+		return -1
+	}
+	return bits.OnesCount64(ha ^ hb)
+}
+
+// isSimilar reports whether two wallpapers have identical content, as
+// stored or as originally downloaded, or perceptual hashes within
+// threshold bits of each other
+func isSimilar(a, b WallpaperInfo, threshold int) bool {
+	for _, x := range []string{a.Hash, a.OriginalHash} {
+		if x != "" && (x == b.Hash || x == b.OriginalHash) {
+			return true
+		}
+	}
+	if threshold < 0 {
+		return false
+	}
+	d := hashDistance(a.PerceptualHash, b.PerceptualHash)
+	return d >= 0 && d <= threshold
+}
+
+// findDuplicateLocked returns an existing wallpaper matching info.
+// Callers must hold a.mu.
+func (a *App) findDuplicateLocked(info WallpaperInfo) (WallpaperInfo, bool) {
+	for _, wp := range a.data.Wallpapers {
+		if wp.ID != info.ID && isSimilar(wp, info, a.GetSettings().SimilarityThreshold) {
+			return wp, true
+		}
+	}
+	return WallpaperInfo{}, false
+}
+
+// FindSimilar returns wallpapers that look like the given one
+func (a *App) FindSimilar(id string) ([]WallpaperInfo, error) {
+	threshold := a.GetSettings().SimilarityThreshold
+	if threshold < 0 {
+		threshold = defaultSimilarityThreshold
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var target *WallpaperInfo
+	for i := range a.data.Wallpapers {
+		if a.data.Wallpapers[i].ID == id {
+			target = &a.data.Wallpapers[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("wallpaper not found: %s", id)
+	}
+
+	var similar []WallpaperInfo
+	for _, wp := range a.data.Wallpapers {
+		if wp.ID != id && isSimilar(*target, wp, threshold) {
+			similar = append(similar, wp)
+		}
+	}
+	return similar, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// normalizeSources cleans up a DownloadSources list: entries are trimmed,
+// and empty ones and repeats are dropped, keeping the first occurrence.
+// Entries that aren't http(s) URLs, or sftp URLs and script: or earth:
+// names for those source types, are left out of sources and returned as
+// invalid.
+func normalizeSources(list []string, configs map[string]SourceConfig) (sources, invalid []string) {
+	seen := make(map[string]bool)
+	for _, source := range list {
+		source = strings.TrimSpace(source)
+		if source == "" || seen[source] {
+			continue
+		}
+		seen[source] = true
+		if !validSourceURL(source, configs[source]) {
+			invalid = append(invalid, source)
+			continue
+		}
+		sources = append(sources, source)
+	}
+	return sources, invalid
+}
+
+// validSourceURL reports whether source is a URL its type can fetch
+func validSourceURL(source string, config SourceConfig) bool {
+	u, err := url.Parse(source)
+	if err != nil {
+		return false
+	}
+	switch config.Type {
+	case sourceTypeEarth:
+		return earthProvider(source) != ""
+	case sourceTypeScript:
+		return u.Scheme == "script" && (u.Opaque != "" || u.Host != "")
+	case sourceTypeSFTP:
+		return u.Scheme == "sftp" && u.Host != ""
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
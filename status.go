@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// statusFile is the status document in the config directory
+	statusFile = "status.json"
+	// statusVersion is StatusDocument.Version. Fields are only ever added;
+	// it changes only if an incompatible change can't be avoided.
+	statusVersion = 1
+)
+
+// StatusDocument is written to status.json for status bars (polybar,
+// waybar) and scripts, and printed by --status. The JSON names are stable.
+type StatusDocument struct {
+	Version int `json:"version"`
+	// Running is false once the app has exited, and when --status found no
+	// running instance
+	Running     bool   `json:"running"`
+	WallpaperID string `json:"wallpaper_id"`
+	Title       string `json:"title"`
+	Path        string `json:"path"`
+	// State is "off", "paused", "pinned", "scheduled" or "waiting_for_idle"
+	State  string `json:"state"`
+	Paused bool   `json:"paused"`
+	// PinnedUntil is when a pin on the current wallpaper ends, or null
+	PinnedUntil *time.Time `json:"pinned_until"`
+	// NextChange and LastChange are RFC 3339 times, or null
+	NextChange *time.Time `json:"next_change"`
+	LastChange *time.Time `json:"last_change"`
+	// LastError is the most recent failed change since the last successful
+	// one, or empty
+	LastError   string     `json:"last_error"`
+	LastErrorAt *time.Time `json:"last_error_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// statusState holds what the status document reports beyond the
+// auto-changer status
+type statusState struct {
+	mu          sync.Mutex
+	lastError   string
+	lastErrorAt time.Time
+	lastSuccess time.Time
+	// lastFailure is the auto-changer's most recent failure since the
+	// last success, for GetLastError
+	lastFailure *ChangeFailure
+	// failureSignature and failureEmittedAt rate-limit repeats of the
+	// "wallpaperChangeFailed" event
+	failureSignature string
+	failureEmittedAt time.Time
+	// writeMu keeps concurrent writers off the shared temporary file
+	writeMu sync.Mutex
+}
+
+// statusDocument describes the app's current state. running is whether
+// the GUI instance is up and its auto-changer active.
+func (a *App) statusDocument(running bool) StatusDocument {
+	status := a.GetAutoChangeStatus()
+	doc := StatusDocument{
+		Version:     statusVersion,
+		Running:     running,
+		Title:       status.CurrentTitle,
+		Path:        status.CurrentPath,
+		State:       status.State,
+		Paused:      status.Paused,
+		PinnedUntil: optionalTime(status.PinnedUntil),
+		NextChange:  optionalTime(status.NextChange),
+		LastChange:  optionalTime(status.LastChange),
+		UpdatedAt:   a.clock.Now(),
+	}
+	// Nothing changes while the app isn't running
+	if !running {
+		doc.NextChange = nil
+		if !doc.Paused {
+			doc.State = autoChangeOff
+		}
+	}
+
+	a.mu.Lock()
+	if wp, ok := a.findByPathLocked(status.CurrentPath); ok {
+		doc.WallpaperID = wp.ID
+	}
+	a.mu.Unlock()
+
+	a.status.mu.Lock()
+	doc.LastError = a.status.lastError
+	doc.LastErrorAt = optionalTime(a.status.lastErrorAt)
+	a.status.mu.Unlock()
+	return doc
+}
+
+// optionalTime returns nil for the zero time, so it is written as null
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// writeStatus replaces status.json. The file is renamed into place, so a
+// status bar never reads it half-written. Headless runs and the final
+// write at shutdown report the app as not running.
+func (a *App) writeStatus() {
+	a.lifecycleMu.Lock()
+	running := a.ctx != nil && !a.shuttingDown
+	a.lifecycleMu.Unlock()
+
+	data, err := json.MarshalIndent(a.statusDocument(running), "", "  ")
+	if err != nil {
+		return
+	}
+	a.status.writeMu.Lock()
+	defer a.status.writeMu.Unlock()
+	path := a.getConfigPath(statusFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		fmt.Printf("Failed to write status: %v\n", err)
+	}
+}
+
+// recordChangeError notes a failed change for the status document
+func (a *App) recordChangeError(err error) {
+	a.status.mu.Lock()
+	a.status.lastError = err.Error()
+	a.status.lastErrorAt = a.clock.Now()
+	a.status.mu.Unlock()
+	a.writeStatus()
+}
+
+// recordChangeSuccess notes a successful change and forgets the last
+// failure
+func (a *App) recordChangeSuccess() {
+	a.status.mu.Lock()
+	a.status.lastError = ""
+	a.status.lastErrorAt = time.Time{}
+	a.status.lastFailure = nil
+	a.status.failureSignature = ""
+	a.status.lastSuccess = a.clock.Now()
+	a.status.mu.Unlock()
+}
+
+// printStatus writes the status document for --status. With an instance
+// running its status.json is current; otherwise the state is read from
+// disk and reported as not running.
+func printStatus(configDir string) int {
+	a := NewApp()
+	a.configDir = configDir
+	if instanceRunning(configDir) {
+		if data, err := os.ReadFile(a.getConfigPath(statusFile)); err == nil {
+			fmt.Println(string(data))
+			return 0
+		}
+	}
+
+	a.loadSettings()
+	a.loadWallpapers()
+	data, err := json.MarshalIndent(a.statusDocument(false), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wallset: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(data))
+	return 0
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"net/http"
+	"os"
+)
+
+// Content types that are converted on the way in, since desktop setters
+// either reject them or show them inconsistently
+const (
+	contentTypeGIF  = "image/gif"
+	contentTypeTIFF = "image/tiff"
+	contentTypeAVIF = "image/avif"
+	contentTypeHEIC = "image/heic"
+)
+
+// sniffImageType returns the content type of a file from its first bytes.
+// TIFF, AVIF and HEIC are recognised here because http.DetectContentType
+// does not know them.
+func sniffImageType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+
+	if bytes.HasPrefix(head, []byte("II*\x00")) || bytes.HasPrefix(head, []byte("MM\x00*")) {
+		return contentTypeTIFF, nil
+	}
+	if t := sniffHEIF(head); t != "" {
+		return t, nil
+	}
+	return http.DetectContentType(head), nil
+}
+
+// convertForDesktop replaces a file the desktop setters can't use: GIFs
+// and TIFFs become a PNG of their first frame, AVIF and HEIC are
+// transcoded to JPEG. It returns the new path and the original content
+// type; any other file is returned unchanged with an empty type.
+func (a *App) convertForDesktop(path string) (string, string, error) {
+	contentType, err := sniffImageType(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	var out string
+	switch contentType {
+	case contentTypeGIF, contentTypeTIFF:
+		out, err = convertToStill(path, contentType)
+	case contentTypeAVIF, contentTypeHEIC:
+		out, err = a.transcodeToJPEG(path)
+	default:
+		return path, "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return out, contentType, nil
+}
+
+// convertToStill replaces a GIF (animated or not) or multi-page TIFF at
+// path with a PNG of its first frame and returns the PNG's path
+func convertToStill(path, contentType string) (string, error) {
+	frame, err := firstFrame(path, contentType)
+	if err != nil {
+		return "", err
+	}
+	out, err := convertedPath(path, ".png")
+	if err != nil {
+		return "", err
+	}
+	if err := savePNG(frame, out); err != nil {
+		if out != path {
+			os.Remove(out)
+		}
+		return "", err
+	}
+	if out != path {
+		os.Remove(path)
+	}
+	return out, nil
+}
+
+// firstFrame decodes the first frame of a GIF, drawn onto the full logical
+// screen since later frames may only cover part of it, or the first page
+// of a TIFF
+func firstFrame(path, contentType string) (image.Image, error) {
+	if contentType != contentTypeGIF {
+		return decodeImage(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	if len(anim.Image) == 0 {
+		return nil, fmt.Errorf("failed to decode image: GIF has no frames")
+	}
+	frame := anim.Image[0]
+	width, height := anim.Config.Width, anim.Config.Height
+	if width == 0 || height == 0 {
+		width, height = frame.Bounds().Max.X, frame.Bounds().Max.Y
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+	return canvas, nil
+}
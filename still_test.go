@@ -0,0 +1,94 @@
+package main
+
+import (
+	"image/color"
+	"image/png"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// checkStill asserts that info is a PNG of the fixture's first frame,
+// converted from a GIF, and that it can be set as the wallpaper
+func checkStill(t *testing.T, a *App, info *WallpaperInfo) {
+	t.Helper()
+	if info.OriginalType != contentTypeGIF {
+		t.Errorf("original type %q, want %q", info.OriginalType, contentTypeGIF)
+	}
+	if ext := filepath.Ext(info.Filepath); ext != ".png" {
+		t.Errorf("stored as %s, want a .png", info.Filename)
+	}
+	if info.Width != 64 || info.Height != 36 {
+		t.Errorf("size %dx%d, want 64x36", info.Width, info.Height)
+	}
+
+	f, err := os.Open(info.Filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("stored file is not a PNG: %v", err)
+	}
+	// The later frames paint blue and green over parts of the first
+	red := color.RGBAModel.Convert(color.RGBA{0xff, 0, 0, 0xff})
+	for _, p := range [][2]int{{0, 0}, {16, 16}, {40, 16}, {63, 35}} {
+		if got := color.RGBAModel.Convert(img.At(p[0], p[1])); got != red {
+			t.Errorf("pixel %v is %v, want the first frame's red", p, got)
+		}
+	}
+
+	if got := a.GetWallpapers(); len(got) != 1 || got[0].ID != info.ID {
+		t.Fatalf("library holds %v, want only %s", got, info.ID)
+	}
+	if err := a.setWallpaper(wallpaperPath(*info), triggerManual); err != nil {
+		t.Errorf("still could not be set: %v", err)
+	}
+}
+
+// TestAnimatedGIFBecomesStill imports and downloads a three-frame GIF,
+// as some Reddit links serve, and checks that the library gets a PNG of the first frame
+func TestAnimatedGIFBecomesStill(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/animated.gif")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("import", func(t *testing.T) {
+		a := newTestApp(t)
+		a.changeSettings(func(s *AppSettings) { s.MinFileSizeBytes = 0 })
+		path := filepath.Join(t.TempDir(), "animated.gif")
+		if err := os.WriteFile(path, fixture, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := a.ImportWallpaper(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.SourceURL != path {
+			t.Errorf("source %q, want %q", info.SourceURL, path)
+		}
+		checkStill(t, a, info)
+	})
+
+	t.Run("download", func(t *testing.T) {
+		a := newTestApp(t)
+		a.changeSettings(func(s *AppSettings) { s.MinFileSizeBytes = 0 })
+		server := httptest.NewServer(serveBytes(contentTypeGIF, fixture))
+		defer server.Close()
+
+		info, err := a.fetchFromURL(server.URL + "/reaction.gif")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range wallpaperFiles(t, a) {
+			if filepath.Ext(name) == ".gif" {
+				t.Errorf("GIF %s left in the wallpaper directory", name)
+			}
+		}
+		checkStill(t, a, &info)
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// errStorageUnavailable is returned when the wallpaper directory can't be
+// reached, e.g. because a removable or network drive is not mounted
+var errStorageUnavailable = fmt.Errorf("wallpaper storage is unavailable")
+
+// storageAvailable reports whether the wallpaper directory exists. The
+// first call after the state changes emits "storageUnavailable" or
+// "storageAvailable" so the frontend can explain what is going on.
+func (a *App) storageAvailable() bool {
+	dir := a.getWallpaperDir()
+	stat, err := os.Stat(dir)
+	available := err == nil && stat.IsDir()
+
+	a.mu.Lock()
+	changed := available == a.storageDown
+	a.storageDown = !available
+	a.mu.Unlock()
+
+	if changed {
+		if available {
+			fmt.Printf("Wallpaper storage is back: %s\n", dir)
+			a.emit("storageAvailable", dir)
+		} else {
+			fmt.Printf("Wallpaper storage is unavailable: %s\n", dir)
+			a.emit("storageUnavailable", dir)
+		}
+	}
+	return available
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// sunAltitude is the solar altitude at sunrise and sunset in degrees,
+// allowing for refraction and the sun's radius
+const sunAltitude = -0.833
+
+// Polar states reported by sunTimes when the sun doesn't rise or set
+const (
+	sunNormal = iota
+	sunPolarDay
+	sunPolarNight
+)
+
+// sunSchedule caches the sunrise and sunset for one local date, so they are
+// recomputed once a day
+type sunSchedule struct {
+	mu      sync.Mutex
+	date    string
+	lat     float64
+	lon     float64
+	sunrise time.Time
+	sunset  time.Time
+	polar   int
+}
+
+// julianDay converts a time to a Julian day number
+func julianDay(t time.Time) float64 {
+	return float64(t.Unix())/86400 + 2440587.5
+}
+
+// fromJulianDay converts a Julian day number to a time in loc
+func fromJulianDay(j float64, loc *time.Location) time.Time {
+	secs := (j - 2440587.5) * 86400
+	return time.Unix(int64(math.Round(secs)), 0).In(loc)
+}
+
+// sunTimes returns sunrise and sunset on the local date of day at the given
+// latitude and longitude (degrees, north and east positive), using the
+// sunrise equation. Within the polar circles it may report sunPolarDay or
+// sunPolarNight instead, with zero times.
+func sunTimes(day time.Time, lat, lon float64) (sunrise, sunset time.Time, polar int) {
+	rad := math.Pi / 180
+	noon := time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, day.Location())
+
+	// Mean solar time and anomaly
+	n := math.Round(julianDay(noon) - 2451545.0 + 0.0008)
+	meanNoon := n - lon/360
+	m := math.Mod(357.5291+0.98560028*meanNoon, 360)
+
+	// Ecliptic longitude, solar transit and declination
+	c := 1.9148*math.Sin(m*rad) + 0.02*math.Sin(2*m*rad) + 0.0003*math.Sin(3*m*rad)
+	lambda := math.Mod(m+c+180+102.9372, 360)
+	transit := 2451545.0 + meanNoon + 0.0053*math.Sin(m*rad) - 0.0069*math.Sin(2*lambda*rad)
+	sinDecl := math.Sin(lambda*rad) * math.Sin(23.4397*rad)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+
+	// Hour angle at which the sun crosses sunAltitude
+	cosHour := (math.Sin(sunAltitude*rad) - math.Sin(lat*rad)*sinDecl) / (math.Cos(lat*rad) * cosDecl)
+	switch {
+	case cosHour < -1:
+		return time.Time{}, time.Time{}, sunPolarDay
+	case cosHour > 1:
+		return time.Time{}, time.Time{}, sunPolarNight
+	}
+	hour := math.Acos(cosHour) / rad
+
+	sunrise = fromJulianDay(transit-hour/360, day.Location())
+	sunset = fromJulianDay(transit+hour/360, day.Location())
+	return sunrise, sunset, sunNormal
+}
+
+// timesAt returns the sunrise and sunset on now's local date at the given
+// location, recomputing them when the date changes
+func (s *sunSchedule) timesAt(now time.Time, lat, lon float64) (sunrise, sunset time.Time, polar int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	date := now.Format("2006-01-02")
+	if date != s.date || lat != s.lat || lon != s.lon {
+		s.sunrise, s.sunset, s.polar = sunTimes(now, lat, lon)
+		s.date, s.lat, s.lon = date, lat, lon
+	}
+	return s.sunrise, s.sunset, s.polar
+}
+
+// isNightAt reports whether now is between sunset and sunrise at the given
+// location. ok is false on polar days and nights, when the fixed hours
+// apply instead.
+func (s *sunSchedule) isNightAt(now time.Time, lat, lon float64) (night, ok bool) {
+	sunrise, sunset, polar := s.timesAt(now, lat, lon)
+	if polar != sunNormal {
+		return false, false
+	}
+	return now.Before(sunrise) || !now.Before(sunset), true
+}
+
+// SunTimes is today's sunrise and sunset, as returned by GetSunTimes
+type SunTimes struct {
+	Sunrise time.Time `json:"sunrise"`
+	Sunset  time.Time `json:"sunset"`
+	// Polar is "polar_day" or "polar_night" when the sun doesn't rise or
+	// set today, with zero times; the fixed day hours apply then
+	Polar string `json:"polar,omitempty"`
+	// Following is whether FollowSun is in effect
+	Following bool `json:"following"`
+}
+
+// GetSunTimes returns today's sunrise and sunset at the configured location
+func (a *App) GetSunTimes() (SunTimes, error) {
+	settings := a.GetSettings()
+	lat, lon := settings.Latitude, settings.Longitude
+	if lat == nil || lon == nil {
+		return SunTimes{}, fmt.Errorf("set a latitude and longitude first")
+	}
+	sunrise, sunset, polar := a.sun.timesAt(a.clock.Now(), *lat, *lon)
+	times := SunTimes{Sunrise: sunrise, Sunset: sunset, Following: settings.FollowSun}
+	switch polar {
+	case sunPolarDay:
+		times.Polar = "polar_day"
+	case sunPolarNight:
+		times.Polar = "polar_night"
+	}
+	return times, nil
+}
+
+// sunLocation returns the location to follow the sun at, if FollowSun is on
+// and a location is set
+func (a *App) sunLocation() (lat, lon float64, ok bool) {
+	settings := a.GetSettings()
+	if !settings.FollowSun || settings.Latitude == nil || settings.Longitude == nil {
+		return 0, 0, false
+	}
+	return *settings.Latitude, *settings.Longitude, true
+}
+
+// nextThemeBoundary returns the next time after now that day turns to
+// night or back: the next sunrise or sunset when following the sun, or
+// else the next of the fixed day and night hours
+func (a *App) nextThemeBoundary(now time.Time) time.Time {
+	if lat, lon, ok := a.sunLocation(); ok {
+		sunrise, sunset, polar := a.sun.timesAt(now, lat, lon)
+		if polar == sunNormal {
+			for _, t := range []time.Time{sunrise, sunset} {
+				if t.After(now) {
+					return t
+				}
+			}
+			tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+			if sunrise, _, polar := sunTimes(tomorrow, lat, lon); polar == sunNormal {
+				return sunrise
+			}
+		}
+	}
+
+	next := time.Time{}
+	for _, hour := range []int{a.GetSettings().DayStartHour, a.GetSettings().NightStartHour} {
+		t := windowStart(now, hour)
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	return next
+}
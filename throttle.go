@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// throttledReader limits how fast bytes can be read from the wrapped reader
+type throttledReader struct {
+	r           io.Reader
+	bytesPerSec int
+	start       time.Time
+	read        int64
+}
+
+// newThrottledReader wraps r so reads average at most kbps kilobytes per second
+func newThrottledReader(r io.Reader, kbps int) *throttledReader {
+	return &throttledReader{
+		r:           r,
+		bytesPerSec: kbps * 1024,
+		start:       time.Now(),
+	}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// Never read more than a second's worth at once so the rate stays smooth
+	if len(p) > t.bytesPerSec {
+		p = p[:t.bytesPerSec]
+	}
+
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+
+	expected := time.Duration(float64(t.read) / float64(t.bytesPerSec) * float64(time.Second))
+	if elapsed := time.Since(t.start); expected > elapsed {
+		time.Sleep(expected - elapsed)
+	}
+	return n, err
+}
+
+// idleReader calls reset after every read that returns data. A timeout
+// reset from it only fires once the transfer stalls, so a slow throttled
+// body isn't cut off.
+type idleReader struct {
+	r     io.Reader
+	reset func()
+}
+
+func (r idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.reset()
+	}
+	return n, err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"image"
+	"image/jpeg"
+	"io"
+)
+
+// resizeToFit downscales img so its longest side is at most maxDim pixels,
+// averaging the source pixels covered by each destination pixel. Images
+// that already fit are returned unchanged.
+func resizeToFit(img image.Image, maxDim int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if maxDim <= 0 || (w <= maxDim && h <= maxDim) {
+		return img
+	}
+
+	dw, dh := maxDim, maxDim
+	if w >= h {
+		dh = max(h*maxDim/w, 1)
+	} else {
+		dw = max(w*maxDim/h, 1)
+	}
+
+	src := toRGBA(img)
+	return resample(src, src.Bounds(), dw, dh)
+}
+
+// resample scales the region r of src to dw x dh pixels. Each destination
+// pixel averages the source pixels it covers, so downscaling is smooth;
+// upscaling repeats pixels.
+func resample(src *image.RGBA, r image.Rectangle, dw, dh int) *image.RGBA {
+	w, h := r.Dx(), r.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[src.PixOffset(r.Min.X, r.Min.Y+sy):]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			out := dst.Pix[y*dst.Stride+x*4:]
+			for c := 0; c < 4; c++ {
+				out[c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+// scaleToCover scales img to exactly w x h, cropping the centre of the
+// image to that aspect ratio first
+func scaleToCover(img image.Image, w, h int) *image.RGBA {
+	src := toRGBA(img)
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+
+	crop := b
+	if sw*h > sh*w {
+		// Too wide: trim the sides
+		cw := max(sh*w/h, 1)
+		crop.Min.X += (sw - cw) / 2
+		crop.Max.X = crop.Min.X + cw
+	} else {
+		// Too tall: trim top and bottom
+		ch := max(sw*h/w, 1)
+		crop.Min.Y += (sh - ch) / 2
+		crop.Max.Y = crop.Min.Y + ch
+	}
+	return resample(src, crop, w, h)
+}
+
+// encodeThumbnail writes a downscaled JPEG of img to w
+func encodeThumbnail(w io.Writer, img image.Image, maxDim int) error {
+	return jpeg.Encode(w, resizeToFit(img, maxDim), &jpeg.Options{Quality: 85})
+}
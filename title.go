@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// maxTitleLength caps a generated title, in characters
+const maxTitleLength = 80
+
+// resolutionPattern matches size segments such as 3840x2160 in source URLs
+var resolutionPattern = regexp.MustCompile(`^\d+(x\d+)?$`)
+
+// keywordParams are query parameters that carry a source's search terms
+var keywordParams = []string{"q", "query", "search", "keywords", "tags"}
+
+// wallpaperTitle derives a title for a new library entry: the feed item's
+// title when there is one, the file's name for imports and file-based
+// sources, else the source's keyword or site and the download date, e.g.
+// "Mountain — Apr 16"
+func (a *App) wallpaperTitle(wp WallpaperInfo) string {
+	if wp.Title != "" {
+		return sanitizeTitle(wp.Title)
+	}
+	date := wp.DownloadDate.Local().Format("Jan 2")
+	if slices.Contains(wp.Tags, collageTag) {
+		return "Collage — " + date
+	}
+
+	source := wp.Source
+	if source == "" {
+		source = wp.SourceURL
+	}
+	switch earthProvider(source) {
+	case earthEPIC:
+		return "Earth from EPIC — " + date
+	case earthHimawari:
+		return "Earth from Himawari — " + date
+	}
+	switch a.sourceConfig(source).Type {
+	case sourceTypeScript, sourceTypeWebDAV, sourceTypeSFTP:
+		return fileTitle(wp.SourceURL)
+	}
+	if u, err := url.Parse(source); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		// Imports keep their original name
+		return fileTitle(wp.SourceURL)
+	}
+	if keyword := sourceKeyword(source); keyword != "" {
+		return sanitizeTitle(keyword + " — " + date)
+	}
+	return sanitizeTitle(siteName(source) + " — " + date)
+}
+
+// fileTitle is the name of the file a path or URL points to, without its
+// extension
+func fileTitle(location string) string {
+	name := filepath.Base(location)
+	// A one-letter scheme is a Windows drive, not a URL
+	if u, err := url.Parse(location); err == nil && len(u.Scheme) > 1 {
+		name = path.Base(u.Path)
+	}
+	return sanitizeTitle(strings.TrimSuffix(name, path.Ext(name)))
+}
+
+// sourceKeyword returns the search term a source URL names, from its
+// query or the last path segment that is neither a size nor a file,
+// capitalised: "https://source.unsplash.com/3840x2160/mountain" gives
+// "Mountain"
+func sourceKeyword(source string) string {
+	u, err := url.Parse(source)
+	if err != nil {
+		return ""
+	}
+	keyword := ""
+	for _, param := range keywordParams {
+		if value := u.Query().Get(param); value != "" {
+			keyword = value
+			break
+		}
+	}
+	if keyword == "" {
+		segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+		for i := len(segments) - 1; i >= 0; i-- {
+			segment := segments[i]
+			if segment != "" && !resolutionPattern.MatchString(segment) && path.Ext(segment) == "" {
+				keyword = segment
+				break
+			}
+		}
+	}
+	keyword = strings.Join(strings.FieldsFunc(keyword, func(r rune) bool {
+		return r == '-' || r == '_' || r == '+' || r == ',' || unicode.IsSpace(r)
+	}), " ")
+	if keyword == "" {
+		return ""
+	}
+	runes := []rune(keyword)
+	return string(unicode.ToUpper(runes[0])) + string(runes[1:])
+}
+
+// siteName names a source after its domain: "https://picsum.photos/2560"
+// gives "Picsum"
+func siteName(source string) string {
+	u, err := url.Parse(source)
+	if err != nil || u.Hostname() == "" {
+		return "Download"
+	}
+	labels := strings.Split(u.Hostname(), ".")
+	name := labels[0]
+	if len(labels) >= 2 {
+		name = labels[len(labels)-2]
+	}
+	if name == "" {
+		return "Download"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// sanitizeTitle drops control characters, collapses whitespace and cuts a
+// title to maxTitleLength, at a word boundary when there is one in its
+// second half
+func sanitizeTitle(title string) string {
+	title = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, title)
+	title = strings.Join(strings.Fields(title), " ")
+
+	runes := []rune(title)
+	if len(runes) <= maxTitleLength {
+		return title
+	}
+	cut := string(runes[:maxTitleLength-1])
+	if space := strings.LastIndex(cut, " "); space > len(cut)/2 {
+		cut = cut[:space]
+	}
+	return strings.TrimRight(cut, " ,.;:-—") + "…"
+}
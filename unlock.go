@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// onUnlock runs when the session is unlocked. With ChangeOnUnlock on, it
+// performs the standard automatic change unless auto-change is paused, the
+// current wallpaper is pinned or the last change was less than
+// MinMinutesBetweenUnlockChanges ago.
+func (a *App) onUnlock() {
+	if !a.GetSettings().ChangeOnUnlock {
+		return
+	}
+	// Some platforms report one unlock twice; only one change may run
+	if !a.unlockBusy.TryLock() {
+		return
+	}
+	defer a.unlockBusy.Unlock()
+
+	now := a.clock.Now()
+	state := a.schedulerState()
+	if state.paused(now) || state.pinned(now) {
+		return
+	}
+	gap := time.Duration(a.GetSettings().MinMinutesBetweenUnlockChanges) * time.Minute
+	if now.Sub(state.LastChange) < gap {
+		return
+	}
+
+	fmt.Printf("Changing wallpaper on unlock at %s\n", now.Format("15:04:05"))
+	a.autoChange(triggerUnlock)
+}
+
+// startSessionWatcher starts watching for unlocks when ChangeOnUnlock is on
+func (a *App) startSessionWatcher() {
+	if !a.GetSettings().ChangeOnUnlock {
+		return
+	}
+	if err := a.watchSession(); err != nil {
+		fmt.Printf("Failed to watch for session unlocks: %v\n", err)
+	}
+}
+
+// restartSessionWatcher applies a changed ChangeOnUnlock setting
+func (a *App) restartSessionWatcher() {
+	a.stopSessionWatcher()
+	a.startSessionWatcher()
+}
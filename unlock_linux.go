@@ -0,0 +1,132 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	login1Name    = "org.freedesktop.login1"
+	login1Path    = dbus.ObjectPath("/org/freedesktop/login1")
+	login1Manager = "org.freedesktop.login1.Manager"
+	login1Session = "org.freedesktop.login1.Session"
+)
+
+// unlockState holds the system bus connection watching the login session
+type unlockState struct {
+	mu   sync.Mutex
+	conn *dbus.Conn
+	done chan struct{}
+}
+
+// sessionPath finds this process's login1 session, falling back to
+// XDG_SESSION_ID when the process isn't inside a session scope
+func sessionPath(conn *dbus.Conn) (dbus.ObjectPath, error) {
+	manager := conn.Object(login1Name, login1Path)
+
+	var path dbus.ObjectPath
+	err := manager.Call(login1Manager+".GetSessionByPID", 0, uint32(os.Getpid())).Store(&path)
+	if err == nil {
+		return path, nil
+	}
+	if id := os.Getenv("XDG_SESSION_ID"); id != "" {
+		if err = manager.Call(login1Manager+".GetSession", 0, id).Store(&path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no login session found: %v", err)
+}
+
+// watchSession listens on the system bus for the session's Unlock signal
+// and for LockedHint turning false, which desktops that lock themselves
+// report instead
+func (a *App) watchSession() error {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return err
+	}
+	path, err := sessionPath(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	for _, match := range [][]dbus.MatchOption{
+		{dbus.WithMatchObjectPath(path), dbus.WithMatchInterface(login1Session), dbus.WithMatchMember("Unlock")},
+		{dbus.WithMatchObjectPath(path), dbus.WithMatchInterface("org.freedesktop.DBus.Properties"), dbus.WithMatchMember("PropertiesChanged")},
+	} {
+		if err := conn.AddMatchSignal(match...); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+	done := make(chan struct{})
+
+	a.unlock.mu.Lock()
+	a.unlock.conn = conn
+	a.unlock.done = done
+	a.unlock.mu.Unlock()
+
+	go func() {
+		locked := false
+		for {
+			select {
+			case <-done:
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					return
+				}
+				if sig.Name == login1Session+".Unlock" {
+					go a.onUnlock()
+					continue
+				}
+				if hint, ok := lockedHint(sig); ok {
+					if locked && !hint {
+						go a.onUnlock()
+					}
+					locked = hint
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// lockedHint extracts LockedHint from a session PropertiesChanged signal
+func lockedHint(sig *dbus.Signal) (bool, bool) {
+	if sig.Name != "org.freedesktop.DBus.Properties.PropertiesChanged" || len(sig.Body) < 2 {
+		return false, false
+	}
+	if iface, _ := sig.Body[0].(string); iface != login1Session {
+		return false, false
+	}
+	changed, _ := sig.Body[1].(map[string]dbus.Variant)
+	v, ok := changed["LockedHint"]
+	if !ok {
+		return false, false
+	}
+	hint, ok := v.Value().(bool)
+	return hint, ok
+}
+
+// stopSessionWatcher stops the signal loop and closes the connection
+func (a *App) stopSessionWatcher() {
+	a.unlock.mu.Lock()
+	defer a.unlock.mu.Unlock()
+
+	if a.unlock.conn == nil {
+		return
+	}
+	close(a.unlock.done)
+	a.unlock.conn.Close()
+	a.unlock.conn, a.unlock.done = nil, nil
+}
@@ -0,0 +1,17 @@
+//go:build !windows && !linux
+
+package main
+
+import "fmt"
+
+// unlockState is empty where unlocks can't be watched
+type unlockState struct{}
+
+// watchSession is unsupported here: macOS posts com.apple.screenIsUnlocked
+// as a distributed notification, which needs cgo to observe
+func (a *App) watchSession() error {
+	return fmt.Errorf("unlock detection is not supported on this platform")
+}
+
+// stopSessionWatcher does nothing where unlocks aren't watched
+func (a *App) stopSessionWatcher() {}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	wmWTSSessionChange   = 0x02B1
+	wtsSessionUnlock     = 0x8
+	notifyForThisSession = 0
+	sessionWatcherClass  = "WallsetSessionWatcher"
+)
+
+// hwndMessage is HWND_MESSAGE, the parent of message-only windows
+var hwndMessage = ^uintptr(2)
+
+var (
+	wtsapi32                 = syscall.NewLazyDLL("wtsapi32.dll")
+	procWTSRegisterSession   = wtsapi32.NewProc("WTSRegisterSessionNotification")
+	procWTSUnRegisterSession = wtsapi32.NewProc("WTSUnRegisterSessionNotification")
+	procRegisterClassExW     = user32.NewProc("RegisterClassExW")
+	procCreateWindowExW      = user32.NewProc("CreateWindowExW")
+	procDestroyWindow        = user32.NewProc("DestroyWindow")
+	procDefWindowProcW       = user32.NewProc("DefWindowProcW")
+	procDispatchMessageW     = user32.NewProc("DispatchMessageW")
+	procGetModuleHandleW     = kernel32.NewProc("GetModuleHandleW")
+)
+
+// wndClassEx mirrors the Win32 WNDCLASSEXW structure
+type wndClassEx struct {
+	size       uint32
+	style      uint32
+	wndProc    uintptr
+	clsExtra   int32
+	wndExtra   int32
+	instance   uintptr
+	icon       uintptr
+	cursor     uintptr
+	background uintptr
+	menuName   *uint16
+	className  *uint16
+	iconSm     uintptr
+}
+
+// unlockState tracks the thread owning the message-only window that
+// receives session notifications
+type unlockState struct {
+	mu       sync.Mutex
+	threadID uintptr
+	done     chan struct{}
+}
+
+// The window class and its callback are registered once per process, as
+// callbacks can't be freed; the handler they call is swapped per watcher.
+var (
+	sessionClassOnce sync.Once
+	sessionClassErr  error
+	sessionHandlerMu sync.Mutex
+	sessionHandler   func()
+)
+
+// sessionWndProc forwards unlock notifications to the current handler
+func sessionWndProc(hwnd, msg, wParam, lParam uintptr) uintptr {
+	if msg == wmWTSSessionChange && wParam == wtsSessionUnlock {
+		sessionHandlerMu.Lock()
+		handler := sessionHandler
+		sessionHandlerMu.Unlock()
+		if handler != nil {
+			go handler()
+		}
+	}
+	ret, _, _ := procDefWindowProcW.Call(hwnd, msg, wParam, lParam)
+	return ret
+}
+
+// registerSessionClass registers the watcher's window class
+func registerSessionClass() error {
+	sessionClassOnce.Do(func() {
+		instance, _, _ := procGetModuleHandleW.Call(0)
+		name, _ := syscall.UTF16PtrFromString(sessionWatcherClass)
+		class := wndClassEx{
+			wndProc:   syscall.NewCallback(sessionWndProc),
+			instance:  instance,
+			className: name,
+		}
+		class.size = uint32(unsafe.Sizeof(class))
+		if ret, _, err := procRegisterClassExW.Call(uintptr(unsafe.Pointer(&class))); ret == 0 {
+			sessionClassErr = fmt.Errorf("RegisterClassExW: %v", err)
+		}
+	})
+	return sessionClassErr
+}
+
+// watchSession creates a message-only window registered for session
+// notifications and runs its message loop on a locked thread until
+// stopSessionWatcher
+func (a *App) watchSession() error {
+	if err := registerSessionClass(); err != nil {
+		return err
+	}
+	sessionHandlerMu.Lock()
+	sessionHandler = a.onUnlock
+	sessionHandlerMu.Unlock()
+
+	started := make(chan error, 1)
+	done := make(chan struct{})
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer close(done)
+
+		name, _ := syscall.UTF16PtrFromString(sessionWatcherClass)
+		instance, _, _ := procGetModuleHandleW.Call(0)
+		hwnd, _, err := procCreateWindowExW.Call(0, uintptr(unsafe.Pointer(name)), 0, 0, 0, 0, 0, 0,
+			hwndMessage, 0, instance, 0)
+		if hwnd == 0 {
+			started <- fmt.Errorf("CreateWindowExW: %v", err)
+			return
+		}
+		defer procDestroyWindow.Call(hwnd)
+
+		if ret, _, err := procWTSRegisterSession.Call(hwnd, notifyForThisSession); ret == 0 {
+			started <- fmt.Errorf("WTSRegisterSessionNotification: %v", err)
+			return
+		}
+		defer procWTSUnRegisterSession.Call(hwnd)
+
+		tid, _, _ := procGetThreadID.Call()
+		a.unlock.mu.Lock()
+		a.unlock.threadID = tid
+		a.unlock.done = done
+		a.unlock.mu.Unlock()
+		started <- nil
+
+		var msg winMsg
+		for {
+			ret, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+			if int32(ret) <= 0 {
+				break
+			}
+			procDispatchMessageW.Call(uintptr(unsafe.Pointer(&msg)))
+		}
+	}()
+
+	return <-started
+}
+
+// stopSessionWatcher ends the message loop, which unregisters the
+// notification and destroys the window
+func (a *App) stopSessionWatcher() {
+	a.unlock.mu.Lock()
+	tid, done := a.unlock.threadID, a.unlock.done
+	a.unlock.threadID, a.unlock.done = 0, nil
+	a.unlock.mu.Unlock()
+
+	if done == nil {
+		return
+	}
+	procPostThreadMsg.Call(tid, wmQuit, 0, 0)
+	<-done
+
+	sessionHandlerMu.Lock()
+	sessionHandler = nil
+	sessionHandlerMu.Unlock()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// latestReleaseURL is GitHub's API endpoint for the newest release
+	latestReleaseURL = "https://api.github.com/repos/HItzz07/WallsetGoSv/releases/latest"
+	// updateCheckTTL is how long a check's answer is reused. Unauthenticated
+	// API calls are limited to 60 an hour per address.
+	updateCheckTTL = time.Hour
+	// updateCheckTimeout bounds a single request
+	updateCheckTimeout = 10 * time.Second
+)
+
+// errUpdateCheckDisabled is returned while UpdateCheckEnabled is off
+var errUpdateCheckDisabled = errors.New("update checks are disabled in settings")
+
+// UpdateInfo is the result of CheckForUpdate
+type UpdateInfo struct {
+	CurrentVersion string `json:"current_version"`
+	LatestVersion  string `json:"latest_version"`
+	// UpdateAvailable is false for development builds, whose version
+	// can't be compared
+	UpdateAvailable bool      `json:"update_available"`
+	ReleaseURL      string    `json:"release_url"`
+	CheckedAt       time.Time `json:"checked_at"`
+}
+
+// updateState caches the last answer and any rate-limit backoff
+type updateState struct {
+	mu           sync.Mutex
+	last         *UpdateInfo
+	blockedUntil time.Time
+}
+
+// CheckForUpdate asks GitHub for the latest release and compares it with
+// this build's version. Nothing is downloaded. Answers are cached for an
+// hour; when GitHub can't be reached or is rate limiting, the last answer
+// is returned if there is one.
+func (a *App) CheckForUpdate() (UpdateInfo, error) {
+	if !a.GetSettings().UpdateCheckEnabled {
+		return UpdateInfo{}, errUpdateCheckDisabled
+	}
+
+	a.updates.mu.Lock()
+	defer a.updates.mu.Unlock()
+	now := a.clock.Now()
+	if last := a.updates.last; last != nil && now.Sub(last.CheckedAt) < updateCheckTTL {
+		return *last, nil
+	}
+	if now.Before(a.updates.blockedUntil) {
+		return a.cachedUpdate(fmt.Errorf("GitHub rate limit reached; try again after %s", a.updates.blockedUntil.Format("15:04")))
+	}
+
+	tag, releaseURL, err := a.fetchLatestRelease()
+	if err != nil {
+		return a.cachedUpdate(err)
+	}
+	info := UpdateInfo{
+		CurrentVersion:  version,
+		LatestVersion:   tag,
+		UpdateAvailable: newerVersion(tag, version),
+		ReleaseURL:      releaseURL,
+		CheckedAt:       now,
+	}
+	a.updates.last = &info
+	return info, nil
+}
+
+// cachedUpdate returns the last answer in place of err, if there is one.
+// a.updates.mu must be held.
+func (a *App) cachedUpdate(err error) (UpdateInfo, error) {
+	if a.updates.last != nil {
+		fmt.Printf("Update check failed, using the last answer: %v\n", err)
+		return *a.updates.last, nil
+	}
+	return UpdateInfo{CurrentVersion: version}, err
+}
+
+// fetchLatestRelease returns the tag and page URL of the latest release.
+// a.updates.mu must be held.
+func (a *App) fetchLatestRelease() (string, string, error) {
+	ctx, cancel := context.WithTimeout(a.lifetime(), updateCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", latestReleaseURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("User-Agent", a.userAgent())
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("can't reach GitHub (offline?): %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		a.updates.blockedUntil = rateLimitReset(resp.Header, a.clock.Now())
+		return "", "", fmt.Errorf("GitHub rate limit reached")
+	case resp.StatusCode == http.StatusNotFound:
+		return "", "", fmt.Errorf("no releases have been published yet")
+	case resp.StatusCode != http.StatusOK:
+		return "", "", fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", "", fmt.Errorf("invalid response: %v", err)
+	}
+	if release.TagName == "" {
+		return "", "", fmt.Errorf("response has no release tag")
+	}
+	return release.TagName, release.HTMLURL, nil
+}
+
+// rateLimitReset returns when GitHub allows requests again, from
+// Retry-After or X-RateLimit-Reset, defaulting to an hour from now
+func rateLimitReset(header http.Header, now time.Time) time.Time {
+	if secs, err := strconv.Atoi(header.Get("Retry-After")); err == nil && secs > 0 {
+		return now.Add(time.Duration(secs) * time.Second)
+	}
+	if unix, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		if reset := time.Unix(unix, 0); reset.After(now) {
+			return reset
+		}
+	}
+	return now.Add(updateCheckTTL)
+}
+
+// newerVersion reports whether release is a later version than current.
+// Both are dotted numbers with an optional "v" prefix; anything after a
+// "-" or "+" is ignored. Versions that don't parse, such as "dev", are
+// never older.
+func newerVersion(release, current string) bool {
+	r, ok := parseVersion(release)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := 0; i < max(len(r), len(c)); i++ {
+		var rv, cv int
+		if i < len(r) {
+			rv = r[i]
+		}
+		if i < len(c) {
+			cv = c[i]
+		}
+		if rv != cv {
+			return rv > cv
+		}
+	}
+	return false
+}
+
+// parseVersion splits "v1.2.3-beta" into [1 2 3]
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, field := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
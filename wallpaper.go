@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// commandTimeout bounds how long a single wallpaper command may run
+const commandTimeout = 30 * time.Second
+
+// errWallpaperNotFound is returned for an ID that isn't in the library
+var errWallpaperNotFound = fmt.Errorf("wallpaper not found")
+
+// errFileNotFound and errNotAnImage are returned by checkImageFile, before
+// any wallpaper command runs
+var (
+	errFileNotFound = errors.New("file not found")
+	errNotAnImage   = errors.New("not a supported image")
+)
+
+// MissingWallpaper is the payload of the "wallpaperMissing" event, sent
+// when a library entry's file has disappeared. Prune offers to remove the
+// entry with DeleteWallpaper.
+type MissingWallpaper struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Path     string `json:"path"`
+	Prune    bool   `json:"prune"`
+}
+
+// maxWindowsPath is MAX_PATH, the longest path the Windows wallpaper API
+// takes without the \\?\ prefix, counting the terminating NUL
+const maxWindowsPath = 260
+
+// PlannedCommand is one candidate way of applying a wallpaper
+type PlannedCommand struct {
+	Name string   `json:"name"`
+	Args []string `json:"args"`
+	// Native marks an in-process API call rather than an external command
+	Native bool `json:"native,omitempty"`
+}
+
+// String renders the command for logs
+func (c PlannedCommand) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// commandRunner executes external commands; tests can record them instead
+type commandRunner interface {
+	Run(ctx context.Context, name string, args ...string) error
+	Output(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// execRunner runs commands with os/exec
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, name string, args ...string) error {
+	return exec.CommandContext(ctx, name, args...).Run()
+}
+
+func (execRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// wallpaperPlan returns the ordered candidate commands for setting path as
+// the desktop background on goos. The first one that succeeds wins. On
+// Linux the choice depends on the desktop detected from the environment.
+func wallpaperPlan(goos, path string) []PlannedCommand {
+	switch goos {
+	case "windows":
+		return []PlannedCommand{
+			{Name: "SystemParametersInfoW", Args: []string{path}, Native: true},
+		}
+	case "darwin":
+		return []PlannedCommand{
+			{Name: "osascript", Args: []string{"-e", fmt.Sprintf(`tell application "Finder" to set desktop picture to POSIX file "%s"`, path)}},
+		}
+	case "linux":
+		// Only the detected desktop's setter, or every candidate if unsure
+		return linuxWallpaperPlan(detectDesktop(os.Getenv), path)
+	}
+	return nil
+}
+
+// runPlannedCommand executes one step of a wallpaper plan. In dry-run mode
+// it only logs the step and reports success.
+func (a *App) runPlannedCommand(cmd PlannedCommand) error {
+	if a.GetSettings().DryRun {
+		fmt.Printf("Dry run, not running: %s\n", cmd)
+		return nil
+	}
+	if cmd.Native {
+		return setWallpaperWindows(cmd.Args[0])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return a.runner.Run(ctx, cmd.Name, cmd.Args...)
+}
+
+// setWallpaper applies filepath and records the attempt in the change
+// history under trigger. The file is checked first, and a relative path
+// made absolute.
+func (a *App) setWallpaper(filepath, trigger string) error {
+	filepath, err := checkImageFile(filepath, false)
+	if err == nil {
+		err = a.applyWallpaper(filepath)
+	}
+	a.recordChange(trigger, filepath, err)
+	if err != nil {
+		return err
+	}
+	a.recordRecent(filepath)
+	a.recordShown(filepath)
+	a.setCurrentPath(filepath)
+	return nil
+}
+
+// SetWallpaperByID sets the library wallpaper with the given ID. This is
+// how the frontend applies a wallpaper, so it never hands the backend a
+// raw path. An unknown ID fails with errWallpaperNotFound; an entry whose
+// file is gone fails with errFileNotFound and announces the entry with
+// "wallpaperMissing", so the gallery can offer to remove it.
+func (a *App) SetWallpaperByID(id string) (*WallpaperInfo, error) {
+	wp, ok := a.findWallpaper(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errWallpaperNotFound, id)
+	}
+	if err := a.setWallpaper(wallpaperPath(wp), triggerManual); err != nil {
+		if errors.Is(err, errFileNotFound) {
+			a.emit("wallpaperMissing", MissingWallpaper{ID: wp.ID, Filename: wp.Filename, Path: wallpaperPath(wp), Prune: true})
+		}
+		return nil, err
+	}
+	a.emitWallpaperChanged(wp)
+	return &wp, nil
+}
+
+// applyWallpaper runs the wallpaper plan for path, the lock screen plan
+// when enabled and GNOME's dark-style background, without any bookkeeping. With an overlay configured, a copy
+// carrying the text is applied instead; pinned monitors keep their image.
+func (a *App) applyWallpaper(filepath string) error {
+	if rendered, err := a.renderOverlay(filepath); err != nil {
+		fmt.Printf("Failed to render overlay: %v\n", err)
+	} else {
+		filepath = rendered
+	}
+
+	// With monitors pinned, only the others take the new image
+	if handled, err := a.applyAcrossMonitors(filepath); handled {
+		if err == nil {
+			a.setLockScreen(filepath)
+			a.setDarkVariant(filepath)
+		}
+		return err
+	}
+
+	plan := wallpaperPlan(runtime.GOOS, filepath)
+	if len(plan) == 0 && a.GetSettings().DryRun {
+		fmt.Printf("Dry run, no wallpaper command for %s\n", runtime.GOOS)
+		return nil
+	}
+	if len(plan) == 0 {
+		return fmt.Errorf("unsupported operating system")
+	}
+
+	var lastErr error
+	for _, cmd := range plan {
+		if lastErr = a.runPlannedCommand(cmd); lastErr == nil {
+			a.setLockScreen(filepath)
+			a.setDarkVariant(filepath)
+			return nil
+		}
+	}
+
+	if len(plan) > 1 {
+		return fmt.Errorf("no suitable wallpaper command found")
+	}
+	return lastErr
+}
+
+// checkImageFile makes path absolute and checks that it is a readable
+// regular file that decodes as a supported image, so a bad path fails
+// with errFileNotFound or errNotAnImage instead of in a platform command.
+// allowHEIF also accepts AVIF and HEIC, which imports convert to JPEG.
+func checkImageFile(path string, allowHEIF bool) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path, err
+	}
+	if runtime.GOOS == "windows" && len(abs) >= maxWindowsPath && !strings.HasPrefix(abs, `\\?\`) {
+		return abs, fmt.Errorf("path is longer than %d characters: %s", maxWindowsPath-1, abs)
+	}
+
+	f, err := os.Open(abs)
+	if errors.Is(err, fs.ErrNotExist) {
+		return abs, fmt.Errorf("%w: %s", errFileNotFound, abs)
+	}
+	if err != nil {
+		return abs, err
+	}
+	defer f.Close()
+	if stat, err := f.Stat(); err != nil {
+		return abs, err
+	} else if !stat.Mode().IsRegular() {
+		return abs, fmt.Errorf("%w: %s is not a file", errNotAnImage, abs)
+	}
+
+	if allowHEIF {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(f, head)
+		if sniffHEIF(head[:n]) != "" {
+			return abs, nil
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return abs, err
+		}
+	}
+	if _, _, err := image.DecodeConfig(f); err != nil {
+		return abs, fmt.Errorf("%w: %s: %v", errNotAnImage, filepath.Base(abs), err)
+	}
+	return abs, nil
+}
+
+// ExplainSetWallpaper returns the commands setting a wallpaper would try for a path,
+// in order, without running any of them
+func (a *App) ExplainSetWallpaper(path string) []PlannedCommand {
+	return wallpaperPlan(runtime.GOOS, path)
+}
+
+// setCurrentPath records the file most recently applied and persists it
+func (a *App) setCurrentPath(path string) {
+	a.mu.Lock()
+	a.data.CurrentPath = path
+	a.mu.Unlock()
+	a.saveWallpapers()
+}
+
+// restoreWallpaper re-applies the last wallpaper that was set. It does
+// nothing if none was recorded or the file no longer exists.
+func (a *App) restoreWallpaper() {
+	a.mu.Lock()
+	path := a.data.CurrentPath
+	a.mu.Unlock()
+
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	if err := a.setWallpaper(path, triggerRestore); err != nil {
+		fmt.Printf("Failed to restore wallpaper: %v\n", err)
+	}
+}
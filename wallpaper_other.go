@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// setWallpaperWindows is only available on Windows
+func setWallpaperWindows(imagePath string) error {
+	return fmt.Errorf("SystemParametersInfoW is only available on Windows")
+}
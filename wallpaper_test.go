@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// recordingRunner is a commandRunner that records the commands it is
+// given instead of running them. Commands named in fail return an error.
+type recordingRunner struct {
+	mu    sync.Mutex
+	calls []PlannedCommand
+	fail  map[string]bool
+}
+
+func (r *recordingRunner) Run(ctx context.Context, name string, args ...string) error {
+	_, err := r.Output(ctx, name, args...)
+	return err
+}
+
+func (r *recordingRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, PlannedCommand{Name: name, Args: args})
+	if r.fail[name] {
+		return nil, errors.New(name + " failed")
+	}
+	return nil, nil
+}
+
+// setDesktopEnv replaces the variables detectDesktop reads for the test
+func setDesktopEnv(t *testing.T, xdg, session, swaysock string) {
+	t.Setenv("XDG_CURRENT_DESKTOP", xdg)
+	t.Setenv("DESKTOP_SESSION", session)
+	t.Setenv("SWAYSOCK", swaysock)
+}
+
+func TestWallpaperPlan(t *testing.T) {
+	const path = "/home/me/Pictures/wall.jpg"
+	const uri = "file://" + path
+	gnome := []PlannedCommand{{Name: "gsettings", Args: []string{"set", "org.gnome.desktop.background", "picture-uri", uri}}}
+	tests := []struct {
+		name                   string
+		goos                   string
+		xdg, session, swaysock string
+		want                   []PlannedCommand
+	}{
+		{name: "windows", goos: "windows", want: []PlannedCommand{
+			{Name: "SystemParametersInfoW", Args: []string{path}, Native: true},
+		}},
+		{name: "darwin", goos: "darwin", want: []PlannedCommand{
+			{Name: "osascript", Args: []string{"-e", `tell application "Finder" to set desktop picture to POSIX file "` + path + `"`}},
+		}},
+		{name: "unsupported", goos: "plan9", want: nil},
+		{name: "gnome", goos: "linux", xdg: "ubuntu:GNOME", want: gnome},
+		{name: "budgie uses gnome", goos: "linux", xdg: "Budgie:GNOME", want: gnome},
+		{name: "kde from session path", goos: "linux", session: "/usr/share/xsessions/plasma", want: []PlannedCommand{
+			{Name: "plasma-apply-wallpaperimage", Args: []string{path}},
+		}},
+		{name: "xfce", goos: "linux", xdg: "XFCE", want: []PlannedCommand{
+			{Name: "sh", Args: []string{"-c", `props=$(xfconf-query -c xfce4-desktop -l | grep '/last-image$') || exit 1
+for p in $props; do xfconf-query -c xfce4-desktop -p "$p" -s "$1" || exit 1; done`, "sh", path}},
+		}},
+		{name: "cinnamon", goos: "linux", xdg: "X-Cinnamon", want: []PlannedCommand{
+			{Name: "gsettings", Args: []string{"set", "org.cinnamon.desktop.background", "picture-uri", uri}},
+		}},
+		{name: "mate", goos: "linux", xdg: "MATE", want: []PlannedCommand{
+			{Name: "gsettings", Args: []string{"set", "org.mate.background", "picture-filename", path}},
+		}},
+		{name: "sway from socket", goos: "linux", swaysock: "/run/user/1000/sway-ipc.sock", want: []PlannedCommand{
+			{Name: "swaymsg", Args: []string{"output", "*", "bg", path, "fill"}},
+		}},
+		{name: "unknown", goos: "linux", xdg: "i3", want: []PlannedCommand{
+			gnome[0],
+			{Name: "feh", Args: []string{"--bg-scale", path}},
+			{Name: "nitrogen", Args: []string{"--set-scaled", path}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setDesktopEnv(t, tt.xdg, tt.session, tt.swaysock)
+			plan := wallpaperPlan(tt.goos, path)
+			if !reflect.DeepEqual(plan, tt.want) {
+				t.Fatalf("wallpaperPlan(%q) = %v, want %v", tt.goos, plan, tt.want)
+			}
+
+			// Each external step reaches the runner exactly as planned
+			a := newTestApp(t)
+			a.changeSettings(func(s *AppSettings) { s.DryRun = false })
+			runner := &recordingRunner{}
+			a.runner = runner
+			var external []PlannedCommand
+			for _, cmd := range plan {
+				if cmd.Native {
+					continue
+				}
+				external = append(external, cmd)
+				if err := a.runPlannedCommand(cmd); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(runner.calls, external) {
+				t.Errorf("ran %v, want %v", runner.calls, external)
+			}
+		})
+	}
+}
+
+func TestApplyWallpaperFallsBack(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the fallback chain is for unknown Linux desktops")
+	}
+	const path = "/home/me/Pictures/wall.jpg"
+	setDesktopEnv(t, "", "", "")
+	a := newTestApp(t)
+	a.changeSettings(func(s *AppSettings) { s.DryRun = false })
+	runner := &recordingRunner{fail: map[string]bool{"gsettings": true, "feh": true}}
+	a.runner = runner
+
+	if err := a.applyWallpaper(path); err != nil {
+		t.Fatal(err)
+	}
+	if want := wallpaperPlan("linux", path); !reflect.DeepEqual(runner.calls, want) {
+		t.Errorf("ran %v, want each candidate in turn: %v", runner.calls, want)
+	}
+
+	runner.calls = nil
+	runner.fail["nitrogen"] = true
+	if err := a.applyWallpaper(path); err == nil {
+		t.Error("applyWallpaper succeeded with every setter failing")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	spiSetDeskWallpaper = 20
+	spifUpdateIniFile   = 0x1
+	spifSendChange      = 0x2
+
+	hkeyCurrentUser = 0x80000001
+	regSZ           = 1
+	// desktopKey holds the per-user wallpaper values under HKEY_CURRENT_USER
+	desktopKey = `Control Panel\Desktop`
+	// wallpaperStyleFill and tileWallpaperOff select the "Fill" fit
+	wallpaperStyleFill = "10"
+	tileWallpaperOff   = "0"
+)
+
+var (
+	procSystemParametersInfo = user32.NewProc("SystemParametersInfoW")
+
+	advapi32            = syscall.NewLazyDLL("advapi32.dll")
+	procRegSetKeyValueW = advapi32.NewProc("RegSetKeyValueW")
+)
+
+// setWallpaperWindows uses direct Windows API call - no external processes.
+// The fit the user chose in Windows settings is kept. Where the call can't
+// update the user profile, as on some locked-down machines, the wallpaper
+// and a "Fill" fit are written to the registry directly and the change
+// broadcast without updating the profile.
+func setWallpaperWindows(imagePath string) error {
+	err := systemParametersSetWallpaper(imagePath, spifUpdateIniFile|spifSendChange)
+	if err == nil {
+		return nil
+	}
+
+	// SystemParametersInfoW reads the fit when applying, so it goes first
+	if err := setDesktopValue("WallpaperStyle", wallpaperStyleFill); err != nil {
+		fmt.Printf("Failed to set the wallpaper style: %v\n", err)
+	}
+	if err := setDesktopValue("TileWallpaper", tileWallpaperOff); err != nil {
+		fmt.Printf("Failed to set the wallpaper tiling: %v\n", err)
+	}
+	if regErr := setDesktopValue("Wallpaper", imagePath); regErr != nil {
+		return fmt.Errorf("%v; registry fallback failed: %v", err, regErr)
+	}
+	if fallbackErr := systemParametersSetWallpaper(imagePath, spifSendChange); fallbackErr != nil {
+		return fmt.Errorf("%v; registry fallback failed: %v", err, fallbackErr)
+	}
+	return nil
+}
+
+// systemParametersSetWallpaper calls SystemParametersInfoW with
+// SPI_SETDESKWALLPAPER and the given fWinIni flags
+func systemParametersSetWallpaper(imagePath string, flags uintptr) error {
+	// Convert Go string to Windows UTF-16 string pointer
+	imagePathPtr, err := syscall.UTF16PtrFromString(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to convert path to UTF-16: %v", err)
+	}
+
+	ret, _, lastErr := procSystemParametersInfo.Call(
+		spiSetDeskWallpaper,
+		0,                                     // uiParam (not used)
+		uintptr(unsafe.Pointer(imagePathPtr)), // pvParam (image path)
+		flags,
+	)
+	if ret == 0 {
+		return fmt.Errorf("SystemParametersInfoW failed: %v", lastErr)
+	}
+	return nil
+}
+
+// setDesktopValue writes a string value under HKCU\Control Panel\Desktop
+func setDesktopValue(name, value string) error {
+	key, err := syscall.UTF16PtrFromString(desktopKey)
+	if err != nil {
+		return err
+	}
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	data, err := syscall.UTF16FromString(value)
+	if err != nil {
+		return err
+	}
+
+	ret, _, _ := procRegSetKeyValueW.Call(
+		hkeyCurrentUser,
+		uintptr(unsafe.Pointer(key)),
+		uintptr(unsafe.Pointer(namePtr)),
+		regSZ,
+		uintptr(unsafe.Pointer(&data[0])),
+		uintptr(len(data)*2), // size in bytes, including the terminator
+	)
+	if ret != 0 {
+		return fmt.Errorf("RegSetKeyValueW %s: %v", name, syscall.Errno(ret))
+	}
+	return nil
+}
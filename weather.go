@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// weatherURL is Open-Meteo's forecast endpoint, which needs no API key
+	weatherURL = "https://api.open-meteo.com/v1/forecast?latitude=%.4f&longitude=%.4f&current=weather_code"
+	// weatherTTL is how long fetched conditions are reused
+	weatherTTL = 30 * time.Minute
+	// weatherRetry is how long to wait after a failed fetch before trying
+	// again; selection falls back to normal rotation meanwhile
+	weatherRetry = 10 * time.Minute
+	// weatherTimeout bounds a single weather request
+	weatherTimeout = 10 * time.Second
+)
+
+// Weather conditions derived from WMO weather codes
+const (
+	weatherClear  = "clear"
+	weatherCloudy = "cloudy"
+	weatherFog    = "fog"
+	weatherRain   = "rain"
+	weatherSnow   = "snow"
+	weatherStorm  = "storm"
+)
+
+// weatherTags lists the wallpaper tags that suit each condition
+var weatherTags = map[string][]string{
+	weatherClear:  {"sunny", "clear", "bright"},
+	weatherCloudy: {"cloudy", "overcast", "clouds"},
+	weatherFog:    {"fog", "foggy", "mist", "moody"},
+	weatherRain:   {"rain", "rainy", "moody"},
+	weatherSnow:   {"snow", "snowy", "winter"},
+	weatherStorm:  {"storm", "thunder", "moody"},
+}
+
+// weatherCache remembers the last condition fetched for a location
+type weatherCache struct {
+	mu        sync.Mutex
+	lat       float64
+	lon       float64
+	condition string
+	expires   time.Time
+}
+
+// weatherCondition maps a WMO weather interpretation code to a condition
+func weatherCondition(code int) string {
+	switch {
+	case code <= 1:
+		return weatherClear
+	case code <= 3:
+		return weatherCloudy
+	case code == 45 || code == 48:
+		return weatherFog
+	case code >= 95:
+		return weatherStorm
+	case (code >= 71 && code <= 77) || code == 85 || code == 86:
+		return weatherSnow
+	case code >= 51 && code <= 82:
+		return weatherRain
+	}
+	return ""
+}
+
+// currentWeather returns the condition at the configured location, using
+// the cached value while it is fresh. It returns "" when weather is off,
+// no location is set or the API can't be reached.
+func (a *App) currentWeather() string {
+	lat, lon := a.GetSettings().Latitude, a.GetSettings().Longitude
+	if !a.GetSettings().WeatherEnabled || lat == nil || lon == nil {
+		return ""
+	}
+
+	a.weather.mu.Lock()
+	defer a.weather.mu.Unlock()
+	now := a.clock.Now()
+	if a.weather.lat == *lat && a.weather.lon == *lon && now.Before(a.weather.expires) {
+		return a.weather.condition
+	}
+
+	condition, err := a.fetchWeather(*lat, *lon)
+	a.weather.lat, a.weather.lon = *lat, *lon
+	a.weather.condition = condition
+	if err != nil {
+		fmt.Printf("Weather unavailable: %v\n", err)
+		a.weather.expires = now.Add(weatherRetry)
+		return ""
+	}
+	a.weather.expires = now.Add(weatherTTL)
+	return condition
+}
+
+// fetchWeather asks Open-Meteo for the current weather code
+func (a *App) fetchWeather(lat, lon float64) (string, error) {
+	ctx, cancel := context.WithTimeout(a.lifetime(), weatherTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(weatherURL, lat, lon), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", a.userAgent())
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	var body struct {
+		Current struct {
+			WeatherCode *int `json:"weather_code"`
+		} `json:"current"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid response: %v", err)
+	}
+	if body.Current.WeatherCode == nil {
+		return "", fmt.Errorf("response has no weather code")
+	}
+	return weatherCondition(*body.Current.WeatherCode), nil
+}
+
+// hasAnyTag reports whether a wallpaper carries one of tags
+func hasAnyTag(wp WallpaperInfo, tags []string) bool {
+	for _, tag := range tags {
+		if hasTag(wp, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredTags returns the tags automatic changes favor: those for the
+// current weather plus the active seasonal theme's keywords. The second
+// result describes them for the log.
+func (a *App) preferredTags() ([]string, string) {
+	var tags, reasons []string
+	if condition := a.currentWeather(); condition != "" {
+		tags = append(tags, weatherTags[condition]...)
+		reasons = append(reasons, "the weather ("+condition+")")
+	}
+	if theme, ok := a.activeSeasonalTheme(); ok {
+		tags = append(tags, normalizeTags(theme.Keywords)...)
+		reasons = append(reasons, "the season ("+theme.Name+")")
+	}
+	return tags, strings.Join(reasons, " and ")
+}
+
+// matchTagPreference returns the wallpaper to apply for an automatic
+// change when weather or seasonal themes are enabled: the download if it
+// carries a preferred tag, otherwise a random library wallpaper that
+// does. The second result is false when no tag-based choice was made.
+func (a *App) matchTagPreference(downloaded WallpaperInfo) (WallpaperInfo, bool) {
+	tags, reason := a.preferredTags()
+	if len(tags) == 0 {
+		return downloaded, false
+	}
+	if hasAnyTag(downloaded, tags) {
+		return downloaded, true
+	}
+
+	fits := a.shapeFilter()
+	a.mu.Lock()
+	var candidates []WallpaperInfo
+	for _, wp := range a.data.Wallpapers {
+		if !wp.Skipped && fits(wp.Width, wp.Height) && hasAnyTag(wp, tags) {
+			candidates = append(candidates, wp)
+		}
+	}
+	a.mu.Unlock()
+	if len(candidates) == 0 {
+		return downloaded, false
+	}
+
+	choice := a.pickFromLibrary(candidates)
+	fmt.Printf("Using %s instead of %s to match %s\n", choice.Filename, downloaded.Filename, reason)
+	return choice, true
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/xml"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// sourceTypeWebDAV is SourceConfig.Type for a WebDAV or Nextcloud folder
+const sourceTypeWebDAV = "webdav"
+
+// propfindBody asks for just the properties needed to pick an image
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getetag/><d:getcontenttype/></d:prop></d:propfind>`
+
+// davMultistatus is the body of a PROPFIND response
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Prop struct {
+				ETag        string    `xml:"DAV: getetag"`
+				ContentType string    `xml:"DAV: getcontenttype"`
+				Collection  *struct{} `xml:"DAV: resourcetype>collection"`
+			} `xml:"DAV: prop"`
+			Status string `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// davFile is an image listed in a WebDAV folder
+type davFile struct {
+	URL  string
+	ETag string
+}
+
+// insecureClient is shared by sources with InsecureSkipVerify
+var insecureClient = sync.OnceValue(func() *http.Client {
+	client := newHTTPClient()
+	transport := client.Transport.(*http.Transport)
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return client
+})
+
+// sourceClient returns the client for a source: the shared one, or one
+// accepting self-signed certificates when the source opts in
+func (a *App) sourceClient(source string) fetcher {
+	if a.sourceConfig(source).InsecureSkipVerify {
+		client := *insecureClient()
+		client.CheckRedirect = a.checkRedirect
+		return &client
+	}
+	return a.client
+}
+
+// pickWebDAVFile lists a WebDAV folder and picks a random image that isn't
+// in the library yet, or has changed on the server since it was downloaded
+func (a *App) pickWebDAVFile(ctx context.Context, source string) (davFile, error) {
+	files, err := a.listWebDAV(ctx, source)
+	if err != nil {
+		return davFile{}, err
+	}
+
+	var fresh []davFile
+	for _, f := range files {
+		if wp, ok := a.findBySourceURL(f.URL); ok && wp.RemoteETag == f.ETag {
+			continue
+		}
+		fresh = append(fresh, f)
+	}
+	if len(fresh) == 0 {
+		if len(files) == 0 {
+			return davFile{}, fmt.Errorf("no images in the WebDAV folder")
+		}
+		return davFile{}, fmt.Errorf("%w: every image in the WebDAV folder is already in the library", errNotModified)
+	}
+	return fresh[rand.Intn(len(fresh))], nil
+}
+
+// listWebDAV returns the images directly inside the source folder. A 401
+// is reported as an authentication failure, separately from the server
+// being unreachable, so GetSourceStatus says which it is.
+func (a *App) listWebDAV(ctx context.Context, source string) ([]davFile, error) {
+	base, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", base.String(), strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	a.setSourceHeaders(req, source)
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := a.sourceClient(source).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach WebDAV server: %v", err)
+	}
+	defer resp.Body.Close()
+	a.recordResponse(source, resp)
+
+	switch resp.StatusCode {
+	case http.StatusMultiStatus:
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("WebDAV authentication failed (%w): check the username and password", httpStatusError{resp.StatusCode})
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("WebDAV access denied (%w)", httpStatusError{resp.StatusCode})
+	default:
+		return nil, fmt.Errorf("WebDAV listing failed: %w", httpStatusError{resp.StatusCode})
+	}
+
+	var listing davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("invalid WebDAV listing: %v", err)
+	}
+
+	var files []davFile
+	for _, r := range listing.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") || ps.Prop.Collection != nil {
+				continue
+			}
+			ext := strings.ToLower(path.Ext(href.Path))
+			if !importExtensions[ext] && !strings.HasPrefix(ps.Prop.ContentType, "image/") {
+				continue
+			}
+			files = append(files, davFile{URL: base.ResolveReference(href).String(), ETag: ps.Prop.ETag})
+		}
+	}
+	return files, nil
+}